@@ -0,0 +1,153 @@
+package broker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cacheEntry is a broker response stored in the cache directory
+type cacheEntry struct {
+	URL         string `json:"url"`
+	ETag        string `json:"etag,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body"`
+}
+
+// response recreates the cached response for the given request
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	header := http.Header{}
+	if e.ContentType != "" {
+		header.Set("Content-Type", e.ContentType)
+	}
+	if e.ETag != "" {
+		header.Set("ETag", e.ETag)
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// cacheable returns true for read only requests. Besides GETs, this includes
+// the pacts for verification query, which is a POST.
+func cacheable(req *http.Request) bool {
+	return req.Method == "GET" || (req.Method == "POST" && strings.HasSuffix(req.URL.Path, "/for-verification"))
+}
+
+// cachedRoundTrip issues a cacheable request, revalidating any cached copy
+// with its ETag and falling back to it when offline
+func (c *Client) cachedRoundTrip(req *http.Request) (*http.Response, error) {
+	key, err := cacheKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := c.readCache(key)
+	if entry != nil && entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	res, err := c.roundTrip(req)
+
+	if (err != nil || res.StatusCode >= 500) && c.Offline && entry != nil {
+		log.Printf("[WARN] broker: %s unavailable, using cached response", req.URL)
+		if res != nil {
+			res.Body.Close()
+		}
+		return entry.response(req), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotModified && entry != nil {
+		log.Printf("[DEBUG] broker: %s not modified, using cached response", req.URL)
+		res.Body.Close()
+		return entry.response(req), nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return res, nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	c.writeCache(key, &cacheEntry{
+		URL:         req.URL.String(),
+		ETag:        res.Header.Get("ETag"),
+		ContentType: res.Header.Get("Content-Type"),
+		Body:        body,
+	})
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+// cacheKey identifies a request by its method, URL and body
+func cacheKey(req *http.Request) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.String()))
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return "", err
+		}
+		h.Write(b)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *Client) readCache(key string) *cacheEntry {
+	b, err := ioutil.ReadFile(filepath.Join(c.CacheDir, key+".json"))
+	if err != nil {
+		return nil
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		log.Println("[WARN] broker: ignoring corrupt cache entry", key)
+		return nil
+	}
+
+	return &entry
+}
+
+func (c *Client) writeCache(key string, entry *cacheEntry) {
+	b, err := json.Marshal(entry)
+	if err == nil {
+		err = os.MkdirAll(c.CacheDir, 0755)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(c.CacheDir, key+".json"), b, 0644)
+	}
+	if err != nil {
+		log.Println("[WARN] broker: unable to write cache entry:", err)
+	}
+}
@@ -0,0 +1,76 @@
+package broker
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Cache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pact-broker-cache")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var conditional []string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacts/provider/bobby/consumer/billy/latest": func(w http.ResponseWriter, r *http.Request) {
+			conditional = append(conditional, r.Header.Get("If-None-Match"))
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			jsonResponse(pactDocument)(w, r)
+		},
+	})
+	client.CacheDir = dir
+
+	pact, err := client.LatestPact("bobby", "billy")
+	assert.NoError(t, err)
+	assert.Equal(t, "billy", pact.Consumer.Name)
+
+	pact, err = client.LatestPact("bobby", "billy")
+	assert.NoError(t, err)
+	assert.Equal(t, "billy", pact.Consumer.Name)
+	assert.Equal(t, []string{"", `"v1"`}, conditional)
+
+	server.Close()
+
+	_, err = client.LatestPact("bobby", "billy")
+	assert.Error(t, err)
+
+	client.Offline = true
+	pact, err = client.LatestPact("bobby", "billy")
+	assert.NoError(t, err)
+	assert.Equal(t, "bobby", pact.Provider.Name)
+}
+
+func TestClient_CacheIgnoresWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pact-broker-cache")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacticipants/billy/versions/1.0.0/tags/prod": jsonResponse(`{"name":"prod"}`),
+	})
+	defer server.Close()
+	client.CacheDir = dir
+
+	_, err = client.AddTag("billy", "1.0.0", "prod")
+	assert.NoError(t, err)
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Empty(t, files)
+}
+
+func TestClient_OfflineRequiresCacheDir(t *testing.T) {
+	client := &Client{BrokerURL: "http://localhost", Offline: true}
+	assert.Error(t, client.call("GET", "/", nil, nil))
+}
@@ -0,0 +1,58 @@
+package broker
+
+import (
+	"errors"
+	"net/url"
+)
+
+// CanIDeploy asks the broker whether the pacticipant version is compatible
+// with every pacticipant version currently deployed to the environment.
+func (c *Client) CanIDeploy(pacticipant string, version string, environment string) (*MatrixResult, error) {
+	if pacticipant == "" || version == "" || environment == "" {
+		return nil, errors.New("'pacticipant', 'version' and 'environment' are mandatory")
+	}
+
+	q := url.Values{}
+	q.Set("pacticipant", pacticipant)
+	q.Set("version", version)
+	q.Set("environment", environment)
+
+	var res MatrixResult
+	if err := c.call("GET", "/can-i-deploy?"+q.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// CanIDeployToTag asks the broker whether the pacticipant version is
+// compatible with the latest version of each integrated pacticipant carrying
+// the tag. This is for brokers or workflows that model environments as tags.
+func (c *Client) CanIDeployToTag(pacticipant string, version string, tag string) (*MatrixResult, error) {
+	if pacticipant == "" || version == "" || tag == "" {
+		return nil, errors.New("'pacticipant', 'version' and 'tag' are mandatory")
+	}
+
+	return c.QueryMatrix(MatrixQuery{
+		Selectors: []MatrixSelector{{Pacticipant: pacticipant, Version: version}},
+		LatestBy:  "cvp",
+		Latest:    true,
+		Tag:       tag,
+	})
+}
+
+// CanIMerge asks the broker whether the pacticipant version, typically built
+// from a feature branch, is compatible with the latest version on the main
+// branch of every integrated pacticipant. It is intended for pull request
+// merge gates.
+func (c *Client) CanIMerge(pacticipant string, version string) (*MatrixResult, error) {
+	if pacticipant == "" || version == "" {
+		return nil, errors.New("'pacticipant' and 'version' are mandatory")
+	}
+
+	return c.QueryMatrix(MatrixQuery{
+		Selectors:  []MatrixSelector{{Pacticipant: pacticipant, Version: version}},
+		LatestBy:   "cvp",
+		MainBranch: true,
+	})
+}
@@ -0,0 +1,83 @@
+package broker
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const matrixResponse = `{
+	"summary": {"deployable": true, "reason": "All required verification results are published and successful", "success": 1, "failed": 0, "unknown": 0},
+	"notices": [{"type": "success", "text": "billy 1.0.0 can be deployed"}],
+	"matrix": [{
+		"consumer": {"name": "billy", "version": {"number": "1.0.0"}},
+		"provider": {"name": "bobby", "version": {"number": "2.0.0"}},
+		"pact": {"createdAt": "2021-01-01T00:00:00+00:00"},
+		"verificationResult": {"success": true, "verifiedAt": "2021-01-01T00:00:00+00:00"}
+	}]
+}`
+
+func TestClient_CanIDeploy(t *testing.T) {
+	var query map[string][]string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/can-i-deploy": func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.Query()
+			jsonResponse(matrixResponse)(w, r)
+		},
+	})
+	defer server.Close()
+
+	res, err := client.CanIDeploy("billy", "1.0.0", "production")
+	assert.NoError(t, err)
+	assert.True(t, res.Deployable())
+	assert.Equal(t, []string{"production"}, query["environment"])
+	if assert.Len(t, res.Matrix, 1) {
+		assert.Equal(t, "2.0.0", res.Matrix[0].Provider.VersionNumber())
+		assert.True(t, res.Matrix[0].Verified())
+	}
+	assert.Len(t, res.Notices, 1)
+
+	_, err = client.CanIDeploy("billy", "", "production")
+	assert.Error(t, err)
+}
+
+func TestClient_CanIDeployToTag(t *testing.T) {
+	var query map[string][]string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/matrix": func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.Query()
+			jsonResponse(`{"summary": {"deployable": null, "reason": "no verification results"}, "matrix": []}`)(w, r)
+		},
+	})
+	defer server.Close()
+
+	res, err := client.CanIDeployToTag("billy", "1.0.0", "prod")
+	assert.NoError(t, err)
+	assert.False(t, res.Deployable(), "an unknown result must not be deployable")
+	assert.Equal(t, []string{"billy"}, query["q[][pacticipant]"])
+	assert.Equal(t, []string{"prod"}, query["tag"])
+	assert.Equal(t, []string{"cvp"}, query["latestby"])
+}
+
+func TestClient_CanIMerge(t *testing.T) {
+	var query map[string][]string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/matrix": func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.Query()
+			jsonResponse(`{"summary": {"deployable": true, "reason": "all verified"}, "matrix": []}`)(w, r)
+		},
+	})
+	defer server.Close()
+
+	res, err := client.CanIMerge("billy", "abc123")
+	assert.NoError(t, err)
+	assert.True(t, res.Deployable())
+	assert.Equal(t, []string{"billy"}, query["q[][pacticipant]"])
+	assert.Equal(t, []string{"abc123"}, query["q[][version]"])
+	assert.Equal(t, []string{"true"}, query["mainBranch"])
+	assert.Equal(t, []string{"cvp"}, query["latestby"])
+
+	_, err = client.CanIMerge("billy", "")
+	assert.Error(t, err)
+}
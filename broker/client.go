@@ -0,0 +1,356 @@
+/*
+Package broker contains a typed client for the Pact Broker HAL API.
+
+It can be used independently of provider verification, for example to fetch
+pacts, look up pacticipant versions or query verification results from
+release tooling written in Go.
+
+See https://docs.pact.io/pact_broker for more on the Pact Broker.
+*/
+package broker
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pact-foundation/pact-go/types"
+)
+
+// Client communicates with a Pact Broker.
+type Client struct {
+	// BrokerURL is the base URL of the Pact Broker
+	BrokerURL string
+
+	// Username when authenticating to a Pact Broker.
+	BrokerUsername string
+
+	// Password when authenticating to a Pact Broker.
+	BrokerPassword string
+
+	// BrokerToken is required when authenticating using the Bearer token mechanism
+	BrokerToken string
+
+	// TokenSource supplies bearer tokens, and takes precedence over BrokerToken.
+	// It is called for every request so that expiring tokens can be refreshed.
+	TokenSource types.TokenSource
+
+	// TLSConfig is used for connections to the broker, e.g. to present
+	// a client certificate for mutual TLS
+	TLSConfig *tls.Config
+
+	// HTTPClient is used for all requests to the broker. When set,
+	// TLSConfig and ProxyURL are ignored. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// ProxyURL is an HTTP(S) proxy to use for requests to the broker.
+	// Defaults to the proxy configured by the HTTPS_PROXY, HTTP_PROXY
+	// and NO_PROXY environment variables.
+	ProxyURL string
+
+	// Retry configures retries of failed requests. Disabled by default.
+	Retry types.RetryPolicy
+
+	// CacheDir is a directory used to cache broker responses, allowing
+	// unchanged pacts to be revalidated using their ETag
+	CacheDir string
+
+	// Offline serves responses from CacheDir when the broker can't be reached
+	Offline bool
+}
+
+// NewClientFromVerifyRequest creates a Client using the broker URL and
+// credentials of a VerifyRequest
+func NewClientFromVerifyRequest(request types.VerifyRequest) *Client {
+	return &Client{
+		BrokerURL:      request.BrokerURL,
+		BrokerUsername: request.BrokerUsername,
+		BrokerPassword: request.BrokerPassword,
+		BrokerToken:    request.BrokerToken,
+		TokenSource:    request.BrokerTokenSource,
+		TLSConfig:      request.BrokerTLSConfig,
+		HTTPClient:     request.BrokerHTTPClient,
+		ProxyURL:       request.BrokerProxyURL,
+		Retry:          request.BrokerRetry,
+		CacheDir:       request.BrokerCacheDir,
+		Offline:        request.BrokerOffline,
+	}
+}
+
+// NewClientFromPublishRequest creates a Client using the broker URL and
+// credentials of a PublishRequest
+func NewClientFromPublishRequest(request types.PublishRequest) *Client {
+	return &Client{
+		BrokerURL:      request.PactBroker,
+		BrokerUsername: request.BrokerUsername,
+		BrokerPassword: request.BrokerPassword,
+		BrokerToken:    request.BrokerToken,
+		TokenSource:    request.BrokerTokenSource,
+		TLSConfig:      request.BrokerTLSConfig,
+		HTTPClient:     request.BrokerHTTPClient,
+		ProxyURL:       request.BrokerProxyURL,
+		Retry:          request.BrokerRetry,
+	}
+}
+
+// Error is returned when the broker responds with a non-2xx status code
+type Error struct {
+	// StatusCode is the HTTP status returned by the broker
+	StatusCode int
+
+	// Method and URL of the failed request
+	Method string
+	URL    string
+
+	// Body of the broker response
+	Body string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("broker returned %d for %s %s: %s", e.StatusCode, e.Method, e.URL, strings.TrimSpace(e.Body))
+}
+
+// IsNotFound returns true if the error is a 404 from the broker
+func IsNotFound(err error) bool {
+	brokerErr, ok := err.(*Error)
+	return ok && brokerErr.StatusCode == http.StatusNotFound
+}
+
+// validate checks that the minimum configuration has been provided
+func (c *Client) validate() error {
+	if c.BrokerURL == "" {
+		return errors.New("'BrokerURL' is mandatory")
+	}
+
+	if (c.BrokerUsername == "" && c.BrokerPassword != "") || (c.BrokerUsername != "" && c.BrokerPassword == "") {
+		return errors.New("both 'BrokerUsername' and 'BrokerPassword' must be supplied if one given")
+	}
+
+	if c.ProxyURL != "" {
+		if _, err := url.Parse(c.ProxyURL); err != nil {
+			return fmt.Errorf("invalid 'ProxyURL': %v", err)
+		}
+	}
+
+	if c.Offline && c.CacheDir == "" {
+		return errors.New("'CacheDir' must be supplied if 'Offline' is set")
+	}
+
+	return nil
+}
+
+// resolve turns a path relative to the broker, or an absolute HAL href,
+// into a full URL
+func (c *Client) resolve(path string) (string, error) {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path, nil
+	}
+
+	base, err := url.Parse(strings.TrimSuffix(c.BrokerURL, "/") + "/")
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := url.Parse(strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return "", err
+	}
+
+	return base.ResolveReference(ref).String(), nil
+}
+
+// newRequest creates an authenticated request to the broker
+func (c *Client) newRequest(method string, path string, body interface{}) (*http.Request, error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	u, err := c.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/hal+json, application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if err := c.authorize(req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// authorize adds the configured credentials to the request
+func (c *Client) authorize(req *http.Request) error {
+	switch {
+	case c.TokenSource != nil:
+		token, err := c.TokenSource.Token()
+		if err != nil {
+			return fmt.Errorf("unable to obtain broker token: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case c.BrokerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.BrokerToken)
+	case c.BrokerUsername != "":
+		req.SetBasicAuth(c.BrokerUsername, c.BrokerPassword)
+	}
+
+	return nil
+}
+
+// do issues the request to the broker, via the cache if configured
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.CacheDir == "" || !cacheable(req) {
+		return c.roundTrip(req)
+	}
+
+	return c.cachedRoundTrip(req)
+}
+
+// roundTrip issues the request to the broker, retrying according to the
+// Retry policy, and returns the final response as is
+func (c *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		log.Printf("[DEBUG] broker: %s %s", req.Method, req.URL)
+
+		res, err := c.httpClient().Do(req)
+		if attempt > c.Retry.MaxRetries || (err == nil && !c.Retry.Retryable(res.StatusCode)) {
+			return res, err
+		}
+
+		delay := c.Retry.Delay(attempt)
+		if err != nil {
+			log.Printf("[WARN] broker: %s %s failed, retrying in %s: %v", req.Method, req.URL, delay, err)
+		} else {
+			if after := retryAfter(res); after > 0 {
+				delay = after
+			}
+			log.Printf("[WARN] broker: %s %s returned %d, retrying in %s", req.Method, req.URL, res.StatusCode, delay)
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		time.Sleep(delay)
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// retryAfter returns the delay requested by the Retry-After header, if any
+func retryAfter(res *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// httpClient returns the HTTP client used to communicate with the broker
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+
+	if c.TLSConfig == nil && c.ProxyURL == "" {
+		return http.DefaultClient
+	}
+
+	defaults := http.DefaultTransport.(*http.Transport)
+	transport := &http.Transport{
+		Proxy:                 defaults.Proxy,
+		DialContext:           defaults.DialContext,
+		MaxIdleConns:          defaults.MaxIdleConns,
+		IdleConnTimeout:       defaults.IdleConnTimeout,
+		TLSHandshakeTimeout:   defaults.TLSHandshakeTimeout,
+		ExpectContinueTimeout: defaults.ExpectContinueTimeout,
+		TLSClientConfig:       c.TLSConfig,
+		DisableKeepAlives:     true,
+	}
+
+	if proxy, err := url.Parse(c.ProxyURL); c.ProxyURL != "" && err == nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+// send issues the request and returns the raw response body
+func (c *Client) send(req *http.Request) ([]byte, error) {
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return body, &Error{
+			StatusCode: res.StatusCode,
+			Method:     req.Method,
+			URL:        req.URL.String(),
+			Body:       string(body),
+		}
+	}
+
+	return body, nil
+}
+
+// call sends a request to the broker, decoding any JSON response into out
+func (c *Client) call(method string, path string, body interface{}, out interface{}) error {
+	req, err := c.newRequest(method, path, body)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.send(req)
+	if err != nil {
+		return err
+	}
+
+	if out == nil || len(bytes.TrimSpace(res)) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(res, out); err != nil {
+		return fmt.Errorf("unable to decode broker response from %s: %v", req.URL, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,238 @@
+package broker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupMockBroker creates a fake broker serving the given routes
+func setupMockBroker(routes map[string]http.HandlerFunc) (*httptest.Server, *Client) {
+	mux := http.NewServeMux()
+	for path, handler := range routes {
+		mux.HandleFunc(path, handler)
+	}
+	server := httptest.NewServer(mux)
+
+	return server, &Client{BrokerURL: server.URL}
+}
+
+// jsonResponse writes the body as a HAL response, replacing any
+// %[1]s placeholders with the broker base URL
+func jsonResponse(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/hal+json")
+		fmt.Fprint(w, strings.Replace(body, "%[1]s", "http://"+r.Host, -1))
+	}
+}
+
+func TestClient_Authentication(t *testing.T) {
+	tests := []struct {
+		name   string
+		client Client
+		want   string
+	}{
+		{name: "basic", client: Client{BrokerUsername: "foo", BrokerPassword: "bar"}, want: "Basic Zm9vOmJhcg=="},
+		{name: "token", client: Client{BrokerToken: "1234"}, want: "Bearer 1234"},
+		{name: "token source", client: Client{BrokerToken: "1234", TokenSource: types.TokenSourceFunc(func() (string, error) { return "5678", nil })}, want: "Bearer 5678"},
+		{name: "none", client: Client{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			server, _ := setupMockBroker(map[string]http.HandlerFunc{
+				"/": func(w http.ResponseWriter, r *http.Request) {
+					got = r.Header.Get("Authorization")
+				},
+			})
+			defer server.Close()
+
+			tt.client.BrokerURL = server.URL
+			err := tt.client.call("GET", "/", nil, nil)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClient_TokenSourceError(t *testing.T) {
+	client := &Client{
+		BrokerURL: "http://localhost",
+		TokenSource: types.TokenSourceFunc(func() (string, error) {
+			return "", errors.New("token expired")
+		}),
+	}
+
+	err := client.call("GET", "/", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestClient_TLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(jsonResponse(`{}`))
+	defer server.Close()
+
+	err := (&Client{BrokerURL: server.URL}).call("GET", "/", nil, nil)
+	assert.Error(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	client := &Client{
+		BrokerURL: server.URL,
+		TLSConfig: &tls.Config{RootCAs: pool},
+	}
+
+	err = client.call("GET", "/", nil, nil)
+	assert.NoError(t, err)
+}
+
+type recordingTransport struct {
+	requests []string
+}
+
+func (r *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClient_HTTPClient(t *testing.T) {
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/": jsonResponse(`{}`),
+	})
+	defer server.Close()
+
+	transport := &recordingTransport{}
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	err := client.call("GET", "/pacticipants", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"GET /pacticipants"}, transport.requests)
+}
+
+func TestClient_Retry(t *testing.T) {
+	var bodies []string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/": func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			if len(bodies) < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			jsonResponse(`{}`)(w, r)
+		},
+	})
+	defer server.Close()
+
+	client.Retry = types.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+	err := client.call("POST", "/", map[string]string{"foo": "bar"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"foo":"bar"}`, `{"foo":"bar"}`, `{"foo":"bar"}`}, bodies)
+
+	bodies = nil
+	client.Retry.MaxRetries = 1
+	err = client.call("POST", "/", map[string]string{"foo": "bar"}, nil)
+	assert.Error(t, err)
+	assert.Len(t, bodies, 2)
+}
+
+func TestClient_RetryNotRetryable(t *testing.T) {
+	calls := 0
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/": func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusNotFound)
+		},
+	})
+	defer server.Close()
+
+	client.Retry = types.RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond}
+	err := client.call("GET", "/", nil, nil)
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestClient_ProxyURL(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		jsonResponse(`{}`)(w, r)
+	}))
+	defer proxy.Close()
+
+	client := &Client{BrokerURL: "http://broker.example.com", ProxyURL: proxy.URL}
+	err := client.call("GET", "/pacticipants", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://broker.example.com/pacticipants", proxied)
+
+	client.ProxyURL = "://bad"
+	assert.Error(t, client.call("GET", "/pacticipants", nil, nil))
+}
+
+func TestClient_Validation(t *testing.T) {
+	err := (&Client{}).call("GET", "/", nil, nil)
+	assert.Error(t, err)
+
+	err = (&Client{BrokerURL: "http://localhost", BrokerUsername: "foo"}).call("GET", "/", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestClient_Error(t *testing.T) {
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		},
+	})
+	defer server.Close()
+
+	err := client.call("GET", "/missing", nil, nil)
+	assert.Error(t, err)
+	assert.True(t, IsNotFound(err))
+	assert.Contains(t, err.Error(), "404")
+}
+
+func TestClient_resolve(t *testing.T) {
+	tests := []struct {
+		base string
+		path string
+		want string
+	}{
+		{base: "http://broker", path: "/pacts", want: "http://broker/pacts"},
+		{base: "http://broker/", path: "pacts", want: "http://broker/pacts"},
+		{base: "http://broker/base", path: "/pacts", want: "http://broker/base/pacts"},
+		{base: "http://broker", path: "https://other/pacts", want: "https://other/pacts"},
+	}
+
+	for _, tt := range tests {
+		got, err := (&Client{BrokerURL: tt.base}).resolve(tt.path)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestNewClientFromRequests(t *testing.T) {
+	c := NewClientFromVerifyRequest(types.VerifyRequest{
+		BrokerURL:      "http://broker",
+		BrokerUsername: "foo",
+		BrokerPassword: "bar",
+	})
+	assert.Equal(t, &Client{BrokerURL: "http://broker", BrokerUsername: "foo", BrokerPassword: "bar"}, c)
+
+	c = NewClientFromPublishRequest(types.PublishRequest{
+		PactBroker:  "http://broker",
+		BrokerToken: "1234",
+	})
+	assert.Equal(t, &Client{BrokerURL: "http://broker", BrokerToken: "1234"}, c)
+}
@@ -0,0 +1,111 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Environment is a deployment target known to the broker, e.g. "production"
+type Environment struct {
+	UUID        string `json:"uuid"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName,omitempty"`
+	Production  bool   `json:"production"`
+	Links       Links  `json:"_links,omitempty"`
+}
+
+// DeployedVersion records a pacticipant version deployed to an environment
+type DeployedVersion struct {
+	UUID                string `json:"uuid"`
+	Currently           bool   `json:"currentlyDeployed"`
+	ApplicationInstance string `json:"applicationInstance,omitempty"`
+	CreatedAt           string `json:"createdAt,omitempty"`
+	Links               Links  `json:"_links,omitempty"`
+}
+
+// ReleasedVersion records a pacticipant version released to an environment
+type ReleasedVersion struct {
+	UUID      string `json:"uuid"`
+	Currently bool   `json:"currentlySupported"`
+	CreatedAt string `json:"createdAt,omitempty"`
+	Links     Links  `json:"_links,omitempty"`
+}
+
+type environmentList struct {
+	Embedded struct {
+		Environments []Environment `json:"environments"`
+	} `json:"_embedded"`
+}
+
+// Environments lists the environments configured in the broker
+func (c *Client) Environments() ([]Environment, error) {
+	var res environmentList
+	err := c.call("GET", "/environments", nil, &res)
+
+	return res.Embedded.Environments, err
+}
+
+// Environment finds an environment by name
+func (c *Client) Environment(name string) (*Environment, error) {
+	environments, err := c.Environments()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range environments {
+		if e.Name == name {
+			return &e, nil
+		}
+	}
+
+	return nil, fmt.Errorf("environment '%s' does not exist in the broker", name)
+}
+
+// RecordDeployment records that the pacticipant version has been deployed to
+// the environment. applicationInstance is optional, and distinguishes between
+// multiple instances of the pacticipant deployed to the same environment.
+func (c *Client) RecordDeployment(pacticipant string, version string, environment string, applicationInstance string) (*DeployedVersion, error) {
+	if pacticipant == "" || version == "" || environment == "" {
+		return nil, errors.New("'pacticipant', 'version' and 'environment' are mandatory")
+	}
+
+	env, err := c.Environment(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]string{}
+	if applicationInstance != "" {
+		body["applicationInstance"] = applicationInstance
+	}
+
+	var res DeployedVersion
+	path := pathSegments("pacticipants", pacticipant, "versions", version, "deployed-versions", "environment", env.UUID)
+	if err := c.call("POST", path, body, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// RecordRelease records that the pacticipant version has been released to the
+// environment. Unlike deployments, multiple released versions can be
+// supported in an environment at once (e.g. mobile apps).
+func (c *Client) RecordRelease(pacticipant string, version string, environment string) (*ReleasedVersion, error) {
+	if pacticipant == "" || version == "" || environment == "" {
+		return nil, errors.New("'pacticipant', 'version' and 'environment' are mandatory")
+	}
+
+	env, err := c.Environment(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	var res ReleasedVersion
+	path := pathSegments("pacticipants", pacticipant, "versions", version, "released-versions", "environment", env.UUID)
+	if err := c.call("POST", path, map[string]string{}, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
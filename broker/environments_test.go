@@ -0,0 +1,66 @@
+package broker
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const environmentsResponse = `{"_embedded":{"environments":[{"uuid":"1234","name":"production","production":true},{"uuid":"5678","name":"test"}]}}`
+
+func TestClient_Environment(t *testing.T) {
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/environments": jsonResponse(environmentsResponse),
+	})
+	defer server.Close()
+
+	env, err := client.Environment("production")
+	assert.NoError(t, err)
+	assert.Equal(t, "1234", env.UUID)
+	assert.True(t, env.Production)
+
+	_, err = client.Environment("staging")
+	assert.Error(t, err)
+}
+
+func TestClient_RecordDeployment(t *testing.T) {
+	var method string
+	var body map[string]string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/environments": jsonResponse(environmentsResponse),
+		"/pacticipants/billy/versions/1.0.0/deployed-versions/environment/1234": func(w http.ResponseWriter, r *http.Request) {
+			method = r.Method
+			json.NewDecoder(r.Body).Decode(&body)
+			jsonResponse(`{"uuid":"abcd","currentlyDeployed":true,"applicationInstance":"blue"}`)(w, r)
+		},
+	})
+	defer server.Close()
+
+	res, err := client.RecordDeployment("billy", "1.0.0", "production", "blue")
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", method)
+	assert.Equal(t, "blue", body["applicationInstance"])
+	assert.True(t, res.Currently)
+
+	_, err = client.RecordDeployment("billy", "1.0.0", "", "")
+	assert.Error(t, err)
+}
+
+func TestClient_RecordRelease(t *testing.T) {
+	var method string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/environments": jsonResponse(environmentsResponse),
+		"/pacticipants/billy/versions/1.0.0/released-versions/environment/5678": func(w http.ResponseWriter, r *http.Request) {
+			method = r.Method
+			jsonResponse(`{"uuid":"abcd","currentlySupported":true}`)(w, r)
+		},
+	})
+	defer server.Close()
+
+	res, err := client.RecordRelease("billy", "1.0.0", "test")
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", method)
+	assert.True(t, res.Currently)
+}
@@ -0,0 +1,74 @@
+package broker
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// Link is a HAL link to a related broker resource
+type Link struct {
+	Href      string `json:"href"`
+	Title     string `json:"title,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Templated bool   `json:"templated,omitempty"`
+}
+
+// Expand fills in the parameters of a templated link, e.g.
+// `/pacticipants/billy/versions/1.0.0/tags/{tag}`
+func (l Link) Expand(params map[string]string) string {
+	href := l.Href
+	for k, v := range params {
+		href = strings.Replace(href, "{"+k+"}", url.PathEscape(v), -1)
+	}
+
+	return href
+}
+
+// Links are the HAL links of a resource, keyed by relation. The broker
+// returns either a single link or a list of links for a relation, both
+// are represented as a list here.
+type Links map[string][]Link
+
+// UnmarshalJSON accepts both single and multiple links for a relation
+func (l *Links) UnmarshalJSON(data []byte) error {
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*l = make(Links, len(raw))
+	for rel, value := range raw {
+		var links []Link
+		if err := json.Unmarshal(value, &links); err != nil {
+			var link Link
+			if err := json.Unmarshal(value, &link); err != nil {
+				return err
+			}
+			links = []Link{link}
+		}
+		(*l)[rel] = links
+	}
+
+	return nil
+}
+
+// Get returns the first link for the relation
+func (l Links) Get(rel string) (Link, bool) {
+	links := l[rel]
+	if len(links) == 0 {
+		return Link{}, false
+	}
+
+	return links[0], true
+}
+
+// pathSegments escapes each segment of a broker resource path
+func pathSegments(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, s := range segments {
+		escaped[i] = url.PathEscape(s)
+	}
+
+	return "/" + strings.Join(escaped, "/")
+}
@@ -0,0 +1,36 @@
+package broker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinks_UnmarshalJSON(t *testing.T) {
+	var links Links
+	err := json.Unmarshal([]byte(`{
+		"self": {"href": "http://broker/pacts"},
+		"pb:pacts": [{"href": "http://broker/pacts/1", "name": "billy"}, {"href": "http://broker/pacts/2"}]
+	}`), &links)
+
+	assert.NoError(t, err)
+	assert.Len(t, links["self"], 1)
+	assert.Len(t, links["pb:pacts"], 2)
+
+	link, ok := links.Get("pb:pacts")
+	assert.True(t, ok)
+	assert.Equal(t, "billy", link.Name)
+
+	_, ok = links.Get("missing")
+	assert.False(t, ok)
+}
+
+func TestLink_Expand(t *testing.T) {
+	link := Link{Href: "http://broker/pacticipants/billy/versions/1.0.0/tags/{tag}", Templated: true}
+	assert.Equal(t, "http://broker/pacticipants/billy/versions/1.0.0/tags/feat%2Ffoo", link.Expand(map[string]string{"tag": "feat/foo"}))
+}
+
+func TestPathSegments(t *testing.T) {
+	assert.Equal(t, "/pacticipants/my%20app/versions/1.0.0", pathSegments("pacticipants", "my app", "versions", "1.0.0"))
+}
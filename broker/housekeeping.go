@@ -0,0 +1,116 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// RetentionPolicy decides which pacticipant versions are deleted by PruneVersions
+type RetentionPolicy struct {
+	// MaxAge is the age after which a version may be deleted. Required.
+	MaxAge time.Duration
+
+	// KeepLatest is the number of most recent versions that are never deleted
+	KeepLatest int
+
+	// KeepTags protects any version with one of these tags, e.g. "prod"
+	KeepTags []string
+
+	// KeepBranches protects any version built from one of these branches, e.g. "main"
+	KeepBranches []string
+
+	// DryRun reports the versions that would be deleted without deleting them
+	DryRun bool
+
+	// Now is the time against which MaxAge is measured. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// DeleteVersion removes the pacticipant version, along with its pacts,
+// tags and verification results
+func (c *Client) DeleteVersion(pacticipant string, version string) error {
+	if pacticipant == "" || version == "" {
+		return errors.New("'pacticipant' and 'version' are mandatory")
+	}
+
+	return c.call("DELETE", pathSegments("pacticipants", pacticipant, "versions", version), nil, nil)
+}
+
+// PruneVersions deletes the versions of the pacticipant that fall outside the
+// retention policy, such as stale versions from ephemeral feature branches.
+// It returns the versions that were (or in a dry run, would be) deleted.
+func (c *Client) PruneVersions(pacticipant string, policy RetentionPolicy) ([]Version, error) {
+	if policy.MaxAge <= 0 {
+		return nil, errors.New("'MaxAge' must be supplied to prune versions")
+	}
+
+	now := time.Now
+	if policy.Now != nil {
+		now = policy.Now
+	}
+	cutoff := now().Add(-policy.MaxAge)
+
+	versions, err := c.Versions(pacticipant)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []Version
+	for i, v := range versions {
+		keep, err := policy.keep(i, v, cutoff)
+		if err != nil {
+			return pruned, err
+		}
+		if keep {
+			continue
+		}
+
+		pruned = append(pruned, v)
+		if policy.DryRun {
+			log.Printf("[INFO] broker: would delete %s version %s", pacticipant, v.Number)
+			continue
+		}
+
+		log.Printf("[INFO] broker: deleting %s version %s", pacticipant, v.Number)
+		if err := c.DeleteVersion(pacticipant, v.Number); err != nil {
+			return pruned, err
+		}
+	}
+
+	return pruned, nil
+}
+
+// keep returns true if the version at the given position, newest first,
+// is retained by the policy
+func (p RetentionPolicy) keep(position int, v Version, cutoff time.Time) (bool, error) {
+	if position < p.KeepLatest {
+		return true, nil
+	}
+
+	for _, b := range p.KeepBranches {
+		if v.Branch == b {
+			return true, nil
+		}
+	}
+
+	for _, t := range v.Tags() {
+		for _, keep := range p.KeepTags {
+			if t.Name == keep {
+				return true, nil
+			}
+		}
+	}
+
+	if v.CreatedAt == "" {
+		return true, nil
+	}
+
+	created, err := time.Parse(time.RFC3339, v.CreatedAt)
+	if err != nil {
+		return true, fmt.Errorf("unable to parse creation date of version %s: %v", v.Number, err)
+	}
+
+	return !created.Before(cutoff), nil
+}
@@ -0,0 +1,60 @@
+package broker
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const versionsResponse = `{"_embedded":{"versions":[
+	{"number":"5","branch":"feat/e","createdAt":"2020-06-30T00:00:00+00:00"},
+	{"number":"4","branch":"feat/d","createdAt":"2020-01-05T00:00:00+00:00"},
+	{"number":"3","branch":"main","createdAt":"2020-01-04T00:00:00+00:00"},
+	{"number":"2","branch":"feat/b","createdAt":"2020-01-03T00:00:00+00:00","_embedded":{"tags":[{"name":"prod"}]}},
+	{"number":"1","branch":"feat/a","createdAt":"2020-01-02T00:00:00+00:00"}
+]}}`
+
+func TestClient_PruneVersions(t *testing.T) {
+	var deleted []string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacticipants/billy/versions": jsonResponse(versionsResponse),
+		"/pacticipants/billy/versions/": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "DELETE", r.Method)
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+	defer server.Close()
+
+	policy := RetentionPolicy{
+		MaxAge:       30 * 24 * time.Hour,
+		KeepLatest:   1,
+		KeepTags:     []string{"prod"},
+		KeepBranches: []string{"main"},
+		Now: func() time.Time {
+			return time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)
+		},
+	}
+
+	policy.DryRun = true
+	pruned, err := client.PruneVersions("billy", policy)
+	assert.NoError(t, err)
+	assert.Len(t, pruned, 2)
+	assert.Empty(t, deleted)
+
+	policy.DryRun = false
+	pruned, err = client.PruneVersions("billy", policy)
+	assert.NoError(t, err)
+	assert.Equal(t, "4", pruned[0].Number)
+	assert.Equal(t, "1", pruned[1].Number)
+	assert.Equal(t, []string{"/pacticipants/billy/versions/4", "/pacticipants/billy/versions/1"}, deleted)
+}
+
+func TestClient_PruneVersionsValidation(t *testing.T) {
+	_, err := (&Client{BrokerURL: "http://localhost"}).PruneVersions("billy", RetentionPolicy{})
+	assert.Error(t, err)
+
+	assert.Error(t, (&Client{BrokerURL: "http://localhost"}).DeleteVersion("billy", ""))
+}
@@ -0,0 +1,199 @@
+package broker
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// MatrixSelector selects the pacticipant versions to include in a matrix query
+type MatrixSelector struct {
+	// Pacticipant name. Required.
+	Pacticipant string
+
+	// Version number of the pacticipant
+	Version string
+
+	// Latest selects the latest version of the pacticipant, optionally
+	// the latest with Tag or on Branch
+	Latest bool
+
+	// Tag selects versions of the pacticipant with the tag
+	Tag string
+
+	// Branch selects versions of the pacticipant on the branch
+	Branch string
+}
+
+// MatrixQuery configures a matrix request
+type MatrixQuery struct {
+	// Selectors for the pacticipant versions to include
+	Selectors []MatrixSelector
+
+	// LatestBy is one of 'cvp' (the latest row for each consumer version and
+	// provider) or 'cvpv' (the latest row for each consumer and provider
+	// version). Optional, defaults to returning every row.
+	LatestBy string
+
+	// Latest, Tag and Environment restrict the other integrations to those
+	// deployed or tagged for a particular target, as used by can-i-deploy
+	Latest      bool
+	Tag         string
+	Environment string
+
+	// MainBranch restricts the other integrations to the latest version on
+	// their main branch, as used by can-i-merge
+	MainBranch bool
+
+	// Limit is the maximum number of rows to return. Optional.
+	Limit int
+}
+
+// Matrix returns the verification status of every combination of the
+// selected pacticipant versions
+func (c *Client) Matrix(selectors ...MatrixSelector) (*MatrixResult, error) {
+	return c.QueryMatrix(MatrixQuery{Selectors: selectors})
+}
+
+// QueryMatrix runs a matrix query with full control over the options
+func (c *Client) QueryMatrix(query MatrixQuery) (*MatrixResult, error) {
+	if len(query.Selectors) == 0 {
+		return nil, errors.New("at least one matrix selector must be provided")
+	}
+
+	q := url.Values{}
+	for _, s := range query.Selectors {
+		if s.Pacticipant == "" {
+			return nil, errors.New("matrix selector 'Pacticipant' is mandatory")
+		}
+
+		q.Add("q[][pacticipant]", s.Pacticipant)
+		if s.Version != "" {
+			q.Add("q[][version]", s.Version)
+		}
+		if s.Latest {
+			q.Add("q[][latest]", "true")
+		}
+		if s.Tag != "" {
+			q.Add("q[][tag]", s.Tag)
+		}
+		if s.Branch != "" {
+			q.Add("q[][branch]", s.Branch)
+		}
+	}
+
+	if query.LatestBy != "" {
+		q.Set("latestby", query.LatestBy)
+	}
+	if query.Latest {
+		q.Set("latest", "true")
+	}
+	if query.Tag != "" {
+		q.Set("tag", query.Tag)
+	}
+	if query.Environment != "" {
+		q.Set("environment", query.Environment)
+	}
+	if query.MainBranch {
+		q.Set("mainBranch", "true")
+	}
+	if query.Limit > 0 {
+		q.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	var res MatrixResult
+	if err := c.call("GET", "/matrix?"+q.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// MatrixResult is the broker's view of which versions of the given
+// pacticipants have been verified against each other
+type MatrixResult struct {
+	// Summary of the result, including the deployment verdict
+	Summary MatrixSummary `json:"summary"`
+
+	// Matrix rows matching the query
+	Matrix []MatrixRow `json:"matrix"`
+
+	// Notices returned by the broker explaining the result
+	Notices []Notice `json:"notices,omitempty"`
+}
+
+// Deployable returns true only if the broker has determined the
+// queried versions are safe to deploy. An unknown result is not deployable.
+func (m *MatrixResult) Deployable() bool {
+	return m.Summary.Deployable != nil && *m.Summary.Deployable
+}
+
+// MatrixSummary summarises the rows of a matrix result
+type MatrixSummary struct {
+	// Deployable is nil if the broker could not determine the result
+	Deployable *bool `json:"deployable"`
+
+	// Reason for the verdict
+	Reason string `json:"reason"`
+
+	// Success is the number of successful verifications
+	Success int `json:"success"`
+
+	// Failed is the number of failed verifications
+	Failed int `json:"failed"`
+
+	// Unknown is the number of integrations with no verification
+	Unknown int `json:"unknown"`
+}
+
+// MatrixRow is the verification status of a consumer version
+// against a provider version
+type MatrixRow struct {
+	Consumer           MatrixPacticipant         `json:"consumer"`
+	Provider           MatrixPacticipant         `json:"provider"`
+	Pact               *MatrixPact               `json:"pact,omitempty"`
+	VerificationResult *MatrixVerificationResult `json:"verificationResult,omitempty"`
+}
+
+// Verified returns true if the row has a successful verification result
+func (r MatrixRow) Verified() bool {
+	return r.VerificationResult != nil && r.VerificationResult.Success
+}
+
+// MatrixPacticipant is a pacticipant and version within a matrix row
+type MatrixPacticipant struct {
+	Name    string `json:"name"`
+	Version *struct {
+		Number string `json:"number"`
+		Branch string `json:"branch,omitempty"`
+	} `json:"version,omitempty"`
+}
+
+// VersionNumber returns the version number, or an empty string
+// where the pacticipant has no version in the row
+func (p MatrixPacticipant) VersionNumber() string {
+	if p.Version == nil {
+		return ""
+	}
+
+	return p.Version.Number
+}
+
+// MatrixPact is the pact referenced by a matrix row
+type MatrixPact struct {
+	CreatedAt string `json:"createdAt"`
+	Links     Links  `json:"_links,omitempty"`
+}
+
+// MatrixVerificationResult is the verification referenced by a matrix row
+type MatrixVerificationResult struct {
+	Success    bool   `json:"success"`
+	VerifiedAt string `json:"verifiedAt"`
+	Links      Links  `json:"_links,omitempty"`
+}
+
+// Notice is an informational message returned by the broker
+type Notice struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
@@ -0,0 +1,58 @@
+package broker
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Matrix(t *testing.T) {
+	var query map[string][]string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/matrix": func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.Query()
+			jsonResponse(matrixResponse)(w, r)
+		},
+	})
+	defer server.Close()
+
+	res, err := client.Matrix(
+		MatrixSelector{Pacticipant: "billy", Version: "1.0.0"},
+		MatrixSelector{Pacticipant: "bobby", Latest: true, Branch: "main"},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"billy", "bobby"}, query["q[][pacticipant]"])
+	assert.Equal(t, []string{"1.0.0"}, query["q[][version]"])
+	assert.Equal(t, []string{"true"}, query["q[][latest]"])
+	assert.Equal(t, []string{"main"}, query["q[][branch]"])
+	assert.Nil(t, query["latestby"])
+
+	if assert.Len(t, res.Matrix, 1) {
+		row := res.Matrix[0]
+		assert.Equal(t, "billy", row.Consumer.Name)
+		assert.Equal(t, "1.0.0", row.Consumer.VersionNumber())
+		assert.True(t, row.Verified())
+	}
+
+	_, err = client.QueryMatrix(MatrixQuery{Selectors: []MatrixSelector{{Pacticipant: "billy"}}, LatestBy: "cvpv", Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cvpv"}, query["latestby"])
+	assert.Equal(t, []string{"10"}, query["limit"])
+}
+
+func TestClient_MatrixValidation(t *testing.T) {
+	client := &Client{BrokerURL: "http://localhost"}
+
+	_, err := client.Matrix()
+	assert.Error(t, err)
+
+	_, err = client.Matrix(MatrixSelector{Version: "1.0.0"})
+	assert.Error(t, err)
+}
+
+func TestMatrixRow_Unverified(t *testing.T) {
+	row := MatrixRow{Consumer: MatrixPacticipant{Name: "billy"}}
+	assert.False(t, row.Verified())
+	assert.Equal(t, "", row.Provider.VersionNumber())
+}
@@ -0,0 +1,123 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Pacticipant is an application that participates in a pact, either as
+// a consumer or provider
+type Pacticipant struct {
+	// Name of the pacticipant. Required.
+	Name string `json:"name"`
+
+	// DisplayName is an optional, human friendly name
+	DisplayName string `json:"displayName,omitempty"`
+
+	// RepositoryURL of the pacticipant source code
+	RepositoryURL string `json:"repositoryUrl,omitempty"`
+
+	// MainBranch is the branch from which the pacticipant is released,
+	// e.g. "main". Used when selecting pacts and in can-i-merge.
+	MainBranch string `json:"mainBranch,omitempty"`
+
+	// CreatedAt is the time the pacticipant was created
+	CreatedAt string `json:"createdAt,omitempty"`
+
+	// UpdatedAt is the time the pacticipant was last updated
+	UpdatedAt string `json:"updatedAt,omitempty"`
+
+	// Links to related resources
+	Links Links `json:"_links,omitempty"`
+}
+
+type pacticipantList struct {
+	Embedded struct {
+		Pacticipants []Pacticipant `json:"pacticipants"`
+	} `json:"_embedded"`
+}
+
+// Pacticipants lists all pacticipants known to the broker
+func (c *Client) Pacticipants() ([]Pacticipant, error) {
+	var pacticipants []Pacticipant
+	err := c.eachPage("/pacticipants", func(page []byte) (Links, error) {
+		var res pacticipantList
+		links, err := decodePage(page, &res)
+		pacticipants = append(pacticipants, res.Embedded.Pacticipants...)
+		return links, err
+	})
+
+	return pacticipants, err
+}
+
+// DescribePacticipant retrieves the pacticipant by name
+func (c *Client) DescribePacticipant(name string) (*Pacticipant, error) {
+	if name == "" {
+		return nil, errors.New("pacticipant name is mandatory")
+	}
+
+	var res Pacticipant
+	if err := c.call("GET", pathSegments("pacticipants", name), nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// CreatePacticipant registers a new pacticipant with the broker
+func (c *Client) CreatePacticipant(pacticipant Pacticipant) (*Pacticipant, error) {
+	if pacticipant.Name == "" {
+		return nil, errors.New("pacticipant 'Name' is mandatory")
+	}
+
+	var res Pacticipant
+	if err := c.call("POST", "/pacticipants", pacticipant, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// UpdatePacticipant updates the attributes of an existing pacticipant.
+// Empty fields are left unchanged.
+func (c *Client) UpdatePacticipant(pacticipant Pacticipant) (*Pacticipant, error) {
+	if pacticipant.Name == "" {
+		return nil, errors.New("pacticipant 'Name' is mandatory")
+	}
+
+	req, err := c.newRequest("PATCH", pathSegments("pacticipants", pacticipant.Name), pacticipant)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+
+	body, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var res Pacticipant
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("unable to decode broker response from %s: %v", req.URL, err)
+	}
+
+	return &res, nil
+}
+
+// EnsurePacticipant creates the pacticipant if it does not yet exist,
+// otherwise updates it. This allows a service to register itself with
+// the broker, e.g. on first publish.
+func (c *Client) EnsurePacticipant(pacticipant Pacticipant) (*Pacticipant, error) {
+	_, err := c.DescribePacticipant(pacticipant.Name)
+
+	if IsNotFound(err) {
+		return c.CreatePacticipant(pacticipant)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c.UpdatePacticipant(pacticipant)
+}
@@ -0,0 +1,65 @@
+package broker
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Pacticipants(t *testing.T) {
+	var updated Pacticipant
+	var contentType string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacticipants": func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" {
+				var p Pacticipant
+				json.NewDecoder(r.Body).Decode(&p)
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(p)
+				return
+			}
+			jsonResponse(`{"_embedded":{"pacticipants":[{"name":"billy"},{"name":"bobby","mainBranch":"main"}]}}`)(w, r)
+		},
+		"/pacticipants/bobby": func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PATCH" {
+				contentType = r.Header.Get("Content-Type")
+				json.NewDecoder(r.Body).Decode(&updated)
+				json.NewEncoder(w).Encode(updated)
+				return
+			}
+			jsonResponse(`{"name":"bobby","mainBranch":"main","repositoryUrl":"https://github.com/foo/bobby"}`)(w, r)
+		},
+		"/pacticipants/billy": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+	})
+	defer server.Close()
+
+	all, err := client.Pacticipants()
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	bobby, err := client.DescribePacticipant("bobby")
+	assert.NoError(t, err)
+	assert.Equal(t, "main", bobby.MainBranch)
+	assert.Equal(t, "https://github.com/foo/bobby", bobby.RepositoryURL)
+
+	t.Run("ensure creates a missing pacticipant", func(t *testing.T) {
+		res, err := client.EnsurePacticipant(Pacticipant{Name: "billy", MainBranch: "master"})
+		assert.NoError(t, err)
+		assert.Equal(t, "master", res.MainBranch)
+	})
+
+	t.Run("ensure updates an existing pacticipant", func(t *testing.T) {
+		res, err := client.EnsurePacticipant(Pacticipant{Name: "bobby", MainBranch: "trunk"})
+		assert.NoError(t, err)
+		assert.Equal(t, "trunk", res.MainBranch)
+		assert.Equal(t, "application/merge-patch+json", contentType)
+		assert.Equal(t, "trunk", updated.MainBranch)
+	})
+
+	_, err = client.CreatePacticipant(Pacticipant{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PacticipantName is the consumer/provider name as it appears in a pact
+type PacticipantName struct {
+	Name string `json:"name"`
+}
+
+// Pact is a pact document retrieved from the broker
+type Pact struct {
+	// Consumer of the pact
+	Consumer PacticipantName `json:"consumer"`
+
+	// Provider of the pact
+	Provider PacticipantName `json:"provider"`
+
+	// Interactions are the HTTP interactions in the pact
+	Interactions []json.RawMessage `json:"interactions,omitempty"`
+
+	// Messages are the asynchronous interactions in the pact
+	Messages []json.RawMessage `json:"messages,omitempty"`
+
+	// Metadata of the pact, including the specification version
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+
+	// Links to related resources
+	Links Links `json:"_links,omitempty"`
+
+	// Raw is the pact document exactly as returned by the broker
+	Raw json.RawMessage `json:"-"`
+}
+
+type pactList struct {
+	Links Links `json:"_links"`
+}
+
+// pactLinks returns the "pb:pacts" links of every page of a pact list
+func (c *Client) pactLinks(path string) ([]Link, error) {
+	var pacts []Link
+	err := c.eachPage(path, func(page []byte) (Links, error) {
+		var res pactList
+		links, err := decodePage(page, &res)
+		pacts = append(pacts, res.Links["pb:pacts"]...)
+		return links, err
+	})
+
+	return pacts, err
+}
+
+// LatestPacts returns the links to the latest pact for each consumer of the provider
+func (c *Client) LatestPacts(provider string) ([]Link, error) {
+	return c.pactLinks(pathSegments("pacts", "provider", provider, "latest"))
+}
+
+// LatestPactsWithTag returns the links to the latest pact for each consumer
+// of the provider, where the consumer version has the given tag
+func (c *Client) LatestPactsWithTag(provider string, tag string) ([]Link, error) {
+	return c.pactLinks(pathSegments("pacts", "provider", provider, "latest", tag))
+}
+
+// LatestPact returns the latest pact between the provider and consumer
+func (c *Client) LatestPact(provider string, consumer string) (*Pact, error) {
+	return c.Pact(pathSegments("pacts", "provider", provider, "consumer", consumer, "latest"))
+}
+
+// PactForVersion returns the pact published by the given consumer version
+func (c *Client) PactForVersion(provider string, consumer string, consumerVersion string) (*Pact, error) {
+	return c.Pact(pathSegments("pacts", "provider", provider, "consumer", consumer, "version", consumerVersion))
+}
+
+// Pact fetches the pact document at href, which may be an absolute
+// link returned by the broker or a path relative to BrokerURL
+func (c *Client) Pact(href string) (*Pact, error) {
+	var raw json.RawMessage
+	if err := c.call("GET", href, nil, &raw); err != nil {
+		return nil, err
+	}
+
+	pact := &Pact{Raw: raw}
+	if err := json.Unmarshal(raw, pact); err != nil {
+		return nil, fmt.Errorf("unable to decode pact from %s: %v", href, err)
+	}
+
+	return pact, nil
+}
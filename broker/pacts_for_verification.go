@@ -0,0 +1,83 @@
+package broker
+
+import (
+	"errors"
+
+	"github.com/pact-foundation/pact-go/types"
+)
+
+// PactsForVerificationRequest selects the pacts a provider version should verify
+type PactsForVerificationRequest struct {
+	// ProviderVersionTags are the tags of the provider version being verified,
+	// used to calculate the pending status of each pact
+	ProviderVersionTags []string `json:"providerVersionTags,omitempty"`
+
+	// ProviderVersionBranch is the branch of the provider version being verified
+	ProviderVersionBranch string `json:"providerVersionBranch,omitempty"`
+
+	// ConsumerVersionSelectors choose the consumer versions to verify
+	ConsumerVersionSelectors []types.ConsumerVersionSelector `json:"consumerVersionSelectors,omitempty"`
+
+	// IncludePendingStatus requests the pending status of each pact (see pact.io/pending)
+	IncludePendingStatus bool `json:"includePendingStatus"`
+
+	// IncludeWipPactsSince includes work in progress pacts created after
+	// this date, in RFC3339 format (see pact.io/wip)
+	IncludeWipPactsSince string `json:"includeWipPactsSince,omitempty"`
+}
+
+// PactForVerification is a pact selected for verification, along with
+// the reasons it was selected
+type PactForVerification struct {
+	// ShortDescription of the pact
+	ShortDescription string `json:"shortDescription"`
+
+	// VerificationProperties describe how the pact should be verified
+	VerificationProperties struct {
+		// Pending is true if failures of this pact should not fail the build
+		Pending bool `json:"pending"`
+
+		// WIP is true if the pact was included as a work in progress pact
+		WIP bool `json:"wip"`
+
+		// Notices explain why the pact was selected, and its pending status
+		Notices []types.VerificationNotice `json:"notices"`
+	} `json:"verificationProperties"`
+
+	// Links to the pact itself
+	Links Links `json:"_links"`
+}
+
+// URL of the pact document
+func (p PactForVerification) URL() string {
+	link, _ := p.Links.Get("self")
+	return link.Href
+}
+
+// PactsForVerification returns the pacts the provider should verify, using
+// the broker's "pacts for verification" API
+func (c *Client) PactsForVerification(provider string, request PactsForVerificationRequest) ([]PactForVerification, error) {
+	var index struct {
+		Links Links `json:"_links"`
+	}
+	if err := c.call("GET", "/", nil, &index); err != nil {
+		return nil, err
+	}
+
+	link, ok := index.Links.Get("pb:provider-pacts-for-verification")
+	if !ok {
+		return nil, errors.New("broker does not support the pacts for verification API")
+	}
+
+	var res struct {
+		Embedded struct {
+			Pacts []PactForVerification `json:"pacts"`
+		} `json:"_embedded"`
+	}
+	path := link.Expand(map[string]string{"provider": provider})
+	if err := c.call("POST", path, request, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Embedded.Pacts, nil
+}
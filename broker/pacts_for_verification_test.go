@@ -0,0 +1,51 @@
+package broker
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_PactsForVerification(t *testing.T) {
+	var received PactsForVerificationRequest
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/": jsonResponse(`{"_links":{"pb:provider-pacts-for-verification":{"href":"%[1]s/pacts/provider/{provider}/for-verification","templated":true}}}`),
+		"/pacts/provider/bobby/for-verification": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "POST", r.Method)
+			if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+				t.Fatalf("unable to decode request: %v", err)
+			}
+			jsonResponse(`{"_embedded":{"pacts":[{"shortDescription":"latest main","verificationProperties":{"pending":true,"notices":[{"when":"before_verification","text":"selected as latest main"}]},"_links":{"self":{"href":"%[1]s/pacts/provider/bobby/consumer/billy/pact-version/abc"}}}]}}`)(w, r)
+		},
+	})
+	defer server.Close()
+
+	pacts, err := client.PactsForVerification("bobby", PactsForVerificationRequest{
+		ProviderVersionBranch:    "main",
+		ConsumerVersionSelectors: []types.ConsumerVersionSelector{{Tag: "main", Latest: true}},
+		IncludePendingStatus:     true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "main", received.ProviderVersionBranch)
+	assert.True(t, received.IncludePendingStatus)
+
+	if len(pacts) != 1 {
+		t.Fatalf("expected 1 pact, got %d", len(pacts))
+	}
+	assert.True(t, pacts[0].VerificationProperties.Pending)
+	assert.Equal(t, "selected as latest main", pacts[0].VerificationProperties.Notices[0].Text)
+	assert.Equal(t, server.URL+"/pacts/provider/bobby/consumer/billy/pact-version/abc", pacts[0].URL())
+}
+
+func TestClient_PactsForVerificationUnsupported(t *testing.T) {
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/": jsonResponse(`{"_links":{}}`),
+	})
+	defer server.Close()
+
+	_, err := client.PactsForVerification("bobby", PactsForVerificationRequest{})
+	assert.Error(t, err)
+}
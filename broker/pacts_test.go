@@ -0,0 +1,51 @@
+package broker
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const pactDocument = `{"consumer":{"name":"billy"},"provider":{"name":"bobby"},"interactions":[{"description":"a request"}],"metadata":{"pactSpecificationVersion":"2.0.0"},"_links":{"pb:latest-verification-results":{"href":"%[1]s/pacts/provider/bobby/consumer/billy/version/1.0.0/verification-results/latest"}}}`
+
+func TestClient_LatestPacts(t *testing.T) {
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacts/provider/bobby/latest":      jsonResponse(`{"_links":{"self":{"href":"%[1]s/pacts/provider/bobby/latest"},"pb:pacts":[{"href":"%[1]s/pacts/provider/bobby/consumer/billy/version/1.0.0","name":"billy"}]}}`),
+		"/pacts/provider/bobby/latest/prod": jsonResponse(`{"_links":{"pb:pacts":[]}}`),
+	})
+	defer server.Close()
+
+	links, err := client.LatestPacts("bobby")
+	assert.NoError(t, err)
+	if assert.Len(t, links, 1) {
+		assert.Equal(t, "billy", links[0].Name)
+		assert.Equal(t, server.URL+"/pacts/provider/bobby/consumer/billy/version/1.0.0", links[0].Href)
+	}
+
+	links, err = client.LatestPactsWithTag("bobby", "prod")
+	assert.NoError(t, err)
+	assert.Len(t, links, 0)
+}
+
+func TestClient_Pact(t *testing.T) {
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacts/provider/bobby/consumer/billy/latest":        jsonResponse(pactDocument),
+		"/pacts/provider/bobby/consumer/billy/version/1.0.0": jsonResponse(pactDocument),
+	})
+	defer server.Close()
+
+	pact, err := client.LatestPact("bobby", "billy")
+	assert.NoError(t, err)
+	assert.Equal(t, "billy", pact.Consumer.Name)
+	assert.Equal(t, "bobby", pact.Provider.Name)
+	assert.Len(t, pact.Interactions, 1)
+	assert.NotEmpty(t, pact.Raw)
+
+	pact, err = client.PactForVersion("bobby", "billy", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "billy", pact.Consumer.Name)
+
+	_, err = client.PactForVersion("bobby", "billy", "2.0.0")
+	assert.True(t, IsNotFound(err))
+}
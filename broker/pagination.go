@@ -0,0 +1,58 @@
+package broker
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// eachPage fetches a list resource, following its "next" links until the
+// last page. Each page is passed to fn for decoding, which returns the page's
+// links so the next page can be found.
+func (c *Client) eachPage(path string, fn func(page []byte) (Links, error)) error {
+	seen := make(map[string]bool)
+
+	for path != "" {
+		u, err := c.resolve(path)
+		if err != nil {
+			return err
+		}
+		if seen[u] {
+			return nil
+		}
+		seen[u] = true
+
+		req, err := c.newRequest("GET", u, nil)
+		if err != nil {
+			return err
+		}
+
+		body, err := c.send(req)
+		if err != nil {
+			return err
+		}
+
+		links, err := fn(body)
+		if err != nil {
+			return fmt.Errorf("unable to decode broker response from %s: %v", req.URL, err)
+		}
+
+		next, _ := links.Get("next")
+		path = next.Href
+	}
+
+	return nil
+}
+
+// decodePage decodes a single page of a list resource into out, returning its links
+func decodePage(page []byte, out interface{}) (Links, error) {
+	if err := json.Unmarshal(page, out); err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Links Links `json:"_links"`
+	}
+	err := json.Unmarshal(page, &res)
+
+	return res.Links, err
+}
@@ -0,0 +1,46 @@
+package broker
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_VersionsPagination(t *testing.T) {
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacticipants/billy/versions": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("pageNumber") == "2" {
+				jsonResponse(`{"_embedded":{"versions":[{"number":"1.0.0"}]},"_links":{"previous":{"href":"%[1]s/pacticipants/billy/versions?pageNumber=1"}}}`)(w, r)
+				return
+			}
+			jsonResponse(`{"_embedded":{"versions":[{"number":"3.0.0"},{"number":"2.0.0"}]},"_links":{"next":{"href":"%[1]s/pacticipants/billy/versions?pageNumber=2"}}}`)(w, r)
+		},
+	})
+	defer server.Close()
+
+	versions, err := client.Versions("billy")
+	assert.NoError(t, err)
+
+	var numbers []string
+	for _, v := range versions {
+		numbers = append(numbers, v.Number)
+	}
+	assert.Equal(t, []string{"3.0.0", "2.0.0", "1.0.0"}, numbers)
+}
+
+func TestClient_PacticipantsPaginationLoop(t *testing.T) {
+	calls := 0
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacticipants": func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			jsonResponse(`{"_embedded":{"pacticipants":[{"name":"billy"}]},"_links":{"next":{"href":"%[1]s/pacticipants"}}}`)(w, r)
+		},
+	})
+	defer server.Close()
+
+	pacticipants, err := client.Pacticipants()
+	assert.NoError(t, err)
+	assert.Len(t, pacticipants, 1)
+	assert.Equal(t, 1, calls)
+}
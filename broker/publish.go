@@ -0,0 +1,194 @@
+package broker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/pact-foundation/pact-go/utils"
+)
+
+// Publisher publishes pact files to a Pact Broker natively, without
+// requiring the pact-broker CLI to be installed.
+type Publisher struct {
+	// Client used to communicate with the broker. Defaults to a client
+	// configured from the PublishRequest.
+	Client *Client
+}
+
+type publishContractsRequest struct {
+	PacticipantName          string            `json:"pacticipantName"`
+	PacticipantVersionNumber string            `json:"pacticipantVersionNumber"`
+	Branch                   string            `json:"branch,omitempty"`
+	Tags                     []string          `json:"tags,omitempty"`
+	BuildURL                 string            `json:"buildUrl,omitempty"`
+	Contracts                []publishContract `json:"contracts"`
+}
+
+type publishContract struct {
+	ConsumerName  string `json:"consumerName"`
+	ProviderName  string `json:"providerName"`
+	Specification string `json:"specification"`
+	ContentType   string `json:"contentType"`
+	Content       string `json:"content"`
+}
+
+// pactFile is a pact read from disk prior to publishing
+type pactFile struct {
+	path     string
+	consumer string
+	provider string
+	content  []byte
+}
+
+// Publish sends the pact files (or directories of pact files) in the
+// request to the broker, applying the consumer version, branch, tags and build URL.
+func (p *Publisher) Publish(request types.PublishRequest) error {
+	log.Println("[DEBUG] broker publisher: publish pacts")
+
+	if request.Branch == "" && !request.DisableBranchDetection {
+		if branch, err := utils.DetectBranch(""); err == nil {
+			log.Println("[DEBUG] broker publisher: using detected branch", branch)
+			request.Branch = branch
+		}
+	}
+
+	if err := request.Validate(); err != nil {
+		return err
+	}
+
+	client := p.Client
+	if client == nil {
+		client = NewClientFromPublishRequest(request)
+	}
+
+	files, err := readPactFiles(request.PactURLs)
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return errors.New("no pact files found to publish")
+	}
+
+	consumer := files[0].consumer
+	body := publishContractsRequest{
+		PacticipantName:          consumer,
+		PacticipantVersionNumber: request.ConsumerVersion,
+		Branch:                   request.Branch,
+		Tags:                     request.Tags,
+		BuildURL:                 request.BuildURL,
+	}
+
+	for _, f := range files {
+		if f.consumer != consumer {
+			return fmt.Errorf("pact files for multiple consumers can't be published together: found %s and %s", consumer, f.consumer)
+		}
+
+		body.Contracts = append(body.Contracts, publishContract{
+			ConsumerName:  f.consumer,
+			ProviderName:  f.provider,
+			Specification: "pact",
+			ContentType:   "application/json",
+			Content:       base64.StdEncoding.EncodeToString(f.content),
+		})
+	}
+
+	err = client.call("POST", "/contracts/publish", body, nil)
+	if IsNotFound(err) {
+		log.Println("[DEBUG] broker publisher: contracts endpoint not supported, falling back to publishing individual pacts")
+		return p.publishLegacy(client, request, files)
+	}
+
+	return err
+}
+
+// publishLegacy publishes to brokers that predate the contracts publish
+// endpoint, by uploading each pact and then tagging the consumer version
+func (p *Publisher) publishLegacy(client *Client, request types.PublishRequest, files []pactFile) error {
+	consumer := files[0].consumer
+
+	if request.Branch != "" {
+		path := pathSegments("pacticipants", consumer, "branches", request.Branch, "versions", request.ConsumerVersion)
+		if err := client.call("PUT", path, map[string]string{}, nil); err != nil {
+			return err
+		}
+	}
+
+	for _, tag := range request.Tags {
+		if _, err := client.AddTag(consumer, request.ConsumerVersion, tag); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		log.Println("[DEBUG] broker publisher: publishing", f.path)
+
+		path := pathSegments("pacts", "provider", f.provider, "consumer", f.consumer, "version", request.ConsumerVersion)
+		if err := client.call("PUT", path, json.RawMessage(f.content), nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readPactFiles loads the pact files, expanding any directories
+// into the JSON files they contain
+func readPactFiles(paths []string) ([]pactFile, error) {
+	var files []pactFile
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		matches := []string{path}
+		if info.IsDir() {
+			matches, err = filepath.Glob(filepath.Join(path, "*.json"))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, m := range matches {
+			f, err := readPactFile(m)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, f)
+		}
+	}
+
+	return files, nil
+}
+
+func readPactFile(path string) (pactFile, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return pactFile{}, err
+	}
+
+	var pact Pact
+	if err := json.Unmarshal(content, &pact); err != nil {
+		return pactFile{}, fmt.Errorf("unable to parse pact file %s: %v", path, err)
+	}
+
+	if pact.Consumer.Name == "" || pact.Provider.Name == "" {
+		return pactFile{}, fmt.Errorf("pact file %s must contain a consumer and provider name", path)
+	}
+
+	return pactFile{
+		path:     path,
+		consumer: pact.Consumer.Name,
+		provider: pact.Provider.Name,
+		content:  content,
+	}, nil
+}
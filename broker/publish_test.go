@@ -0,0 +1,145 @@
+package broker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const localPact = `{"consumer":{"name":"billy"},"provider":{"name":"bobby"},"interactions":[],"metadata":{"pactSpecification":{"version":"2.0.0"}}}`
+
+func writeLocalPact(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "pact-publish")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "billy-bobby.json"), []byte(localPact), 0644); err != nil {
+		t.Fatalf("unable to write pact: %v", err)
+	}
+
+	return dir
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	dir := writeLocalPact(t)
+	defer os.RemoveAll(dir)
+
+	var received publishContractsRequest
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/contracts/publish": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "POST", r.Method)
+			if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+				t.Fatalf("unable to decode publish request: %v", err)
+			}
+			jsonResponse(`{}`)(w, r)
+		},
+	})
+	defer server.Close()
+
+	publisher := &Publisher{Client: client}
+	err := publisher.Publish(types.PublishRequest{
+		PactURLs:        []string{dir},
+		PactBroker:      client.BrokerURL,
+		ConsumerVersion: "1.0.0",
+		Branch:          "main",
+		Tags:            []string{"prod"},
+		BuildURL:        "http://ci/builds/1",
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "billy", received.PacticipantName)
+	assert.Equal(t, "1.0.0", received.PacticipantVersionNumber)
+	assert.Equal(t, "main", received.Branch)
+	assert.Equal(t, []string{"prod"}, received.Tags)
+	assert.Equal(t, "http://ci/builds/1", received.BuildURL)
+	if len(received.Contracts) != 1 {
+		t.Fatalf("expected 1 contract, got %d", len(received.Contracts))
+	}
+	assert.Equal(t, "bobby", received.Contracts[0].ProviderName)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(localPact)), received.Contracts[0].Content)
+}
+
+func TestPublisher_PublishDisableBranchDetection(t *testing.T) {
+	dir := writeLocalPact(t)
+	defer os.RemoveAll(dir)
+
+	var received publishContractsRequest
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/contracts/publish": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&received)
+			jsonResponse(`{}`)(w, r)
+		},
+	})
+	defer server.Close()
+
+	publisher := &Publisher{Client: client}
+	err := publisher.Publish(types.PublishRequest{
+		PactURLs:               []string{dir},
+		PactBroker:             client.BrokerURL,
+		ConsumerVersion:        "1.0.0",
+		DisableBranchDetection: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "", received.Branch)
+	assert.Equal(t, "1.0.0", received.PacticipantVersionNumber)
+}
+
+func TestPublisher_PublishLegacy(t *testing.T) {
+	dir := writeLocalPact(t)
+	defer os.RemoveAll(dir)
+
+	var calls []string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		jsonResponse(`{}`)(w, r)
+	}
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/contracts/publish": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+		"/pacticipants/billy/branches/main/versions/1.0.0":   record,
+		"/pacticipants/billy/versions/1.0.0/tags/prod":       record,
+		"/pacts/provider/bobby/consumer/billy/version/1.0.0": record,
+	})
+	defer server.Close()
+
+	publisher := &Publisher{Client: client}
+	err := publisher.Publish(types.PublishRequest{
+		PactURLs:        []string{filepath.Join(dir, "billy-bobby.json")},
+		PactBroker:      client.BrokerURL,
+		ConsumerVersion: "1.0.0",
+		Branch:          "main",
+		Tags:            []string{"prod"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"PUT /pacticipants/billy/branches/main/versions/1.0.0",
+		"PUT /pacticipants/billy/versions/1.0.0/tags/prod",
+		"PUT /pacts/provider/bobby/consumer/billy/version/1.0.0",
+	}, calls)
+}
+
+func TestPublisher_PublishValidation(t *testing.T) {
+	publisher := &Publisher{}
+
+	err := publisher.Publish(types.PublishRequest{
+		PactURLs:   []string{"/does/not/exist.json"},
+		PactBroker: "http://localhost:1234",
+	})
+	assert.Error(t, err)
+
+	err = publisher.Publish(types.PublishRequest{
+		PactURLs:        []string{"/does/not/exist.json"},
+		PactBroker:      "http://localhost:1234",
+		ConsumerVersion: "1.0.0",
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,147 @@
+package broker
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Relay is a local HTTP server that forwards requests to a Pact Broker
+// using a Client. It allows tools that only understand a broker URL and
+// static credentials, such as the pact-provider-verifier CLI, to benefit
+// from the authentication and transport options configured on the Client.
+//
+// Absolute broker URLs in response bodies are rewritten to point back to
+// the relay, so that HAL links are also followed through it.
+type Relay struct {
+	// URL is the base URL of the relay, to be used in place of the broker URL
+	URL string
+
+	client    *Client
+	transform func([]byte) ([]byte, error)
+	listener  net.Listener
+	server    *http.Server
+}
+
+// NewRelay starts a relay to the broker of the given Client on a free local port
+func NewRelay(client *Client) (*Relay, error) {
+	return NewTransformingRelay(client, nil)
+}
+
+// NewTransformingRelay starts a relay which rewrites the body of each
+// successful response with transform, e.g. to convert pacts to a format the
+// tool understands. Responses that can't be transformed fail with the error.
+func NewTransformingRelay(client *Client, transform func(body []byte) ([]byte, error)) (*Relay, error) {
+	if err := client.validate(); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("unable to start broker relay: %v", err)
+	}
+
+	r := &Relay{
+		URL:       "http://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)),
+		client:    client,
+		transform: transform,
+		listener:  ln,
+	}
+	r.server = &http.Server{Handler: r}
+
+	log.Println("[DEBUG] broker relay: starting on", r.URL)
+	go r.server.Serve(ln)
+
+	return r, nil
+}
+
+// RewriteURL maps a URL on the broker to the equivalent URL on the relay.
+// URLs that do not belong to the broker are returned unchanged.
+func (r *Relay) RewriteURL(u string) string {
+	base := strings.TrimSuffix(r.client.BrokerURL, "/")
+	if u == base || strings.HasPrefix(u, base+"/") {
+		return r.URL + strings.TrimPrefix(u, base)
+	}
+
+	return u
+}
+
+// Close stops the relay
+func (r *Relay) Close() error {
+	return r.server.Close()
+}
+
+// ServeHTTP forwards the request to the broker
+func (r *Relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := r.client.resolve(req.URL.RequestURI())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out, err := http.NewRequest(req.Method, u, bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, h := range []string{"Accept", "Content-Type"} {
+		if v := req.Header.Get(h); v != "" {
+			out.Header.Set(h, v)
+		}
+	}
+
+	if err := r.client.authorize(out); err != nil {
+		log.Println("[ERROR] broker relay:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	res, err := r.client.do(out)
+	if err != nil {
+		log.Println("[ERROR] broker relay:", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if r.transform != nil && res.StatusCode >= 200 && res.StatusCode < 300 {
+		if resBody, err = r.transform(resBody); err != nil {
+			log.Println("[ERROR] broker relay:", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	base := strings.TrimSuffix(r.client.BrokerURL, "/")
+	resBody = bytes.Replace(resBody, []byte(base), []byte(r.URL), -1)
+
+	for k, v := range res.Header {
+		if k == "Content-Length" || k == "Content-Encoding" {
+			continue
+		}
+		w.Header()[k] = v
+	}
+	if location := res.Header.Get("Location"); location != "" {
+		w.Header().Set("Location", r.RewriteURL(location))
+	}
+	w.WriteHeader(res.StatusCode)
+	w.Write(resBody)
+}
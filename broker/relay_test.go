@@ -0,0 +1,108 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelay(t *testing.T) {
+	var auth []string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacts/provider/bobby/latest": func(w http.ResponseWriter, r *http.Request) {
+			auth = append(auth, r.Header.Get("Authorization"))
+			jsonResponse(`{"_links":{"self":{"href":"%[1]s/pacts/provider/bobby/latest"}}}`)(w, r)
+		},
+	})
+	defer server.Close()
+
+	tokens := 0
+	client.TokenSource = types.TokenSourceFunc(func() (string, error) {
+		tokens++
+		return fmt.Sprintf("token-%d", tokens), nil
+	})
+
+	relay, err := NewRelay(client)
+	if err != nil {
+		t.Fatalf("unable to start relay: %v", err)
+	}
+	defer relay.Close()
+
+	for i := 0; i < 2; i++ {
+		res, err := http.Get(relay.URL + "/pacts/provider/bobby/latest")
+		if err != nil {
+			t.Fatalf("relay request failed: %v", err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, `{"_links":{"self":{"href":"`+relay.URL+`/pacts/provider/bobby/latest"}}}`, string(body))
+	}
+
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, auth)
+}
+
+func TestNewTransformingRelay(t *testing.T) {
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacts/provider/bobby/latest": jsonResponse(`{"version":"4.0"}`),
+		"/missing":                     http.NotFound,
+		"/unsupported":                 jsonResponse(`{"version":"5.0"}`),
+	})
+	defer server.Close()
+
+	relay, err := NewTransformingRelay(client, func(body []byte) ([]byte, error) {
+		if strings.Contains(string(body), "5.0") {
+			return nil, errors.New("unsupported version")
+		}
+		return []byte(strings.Replace(string(body), "4.0", "3.0.0", -1)), nil
+	})
+	if err != nil {
+		t.Fatalf("unable to start relay: %v", err)
+	}
+	defer relay.Close()
+
+	res, err := http.Get(relay.URL + "/pacts/provider/bobby/latest")
+	if err != nil {
+		t.Fatalf("relay request failed: %v", err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, `{"version":"3.0.0"}`, string(body))
+
+	res, err = http.Get(relay.URL + "/missing")
+	if err != nil {
+		t.Fatalf("relay request failed: %v", err)
+	}
+	body, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Equal(t, "404 page not found\n", string(body))
+
+	res, err = http.Get(relay.URL + "/unsupported")
+	if err != nil {
+		t.Fatalf("relay request failed: %v", err)
+	}
+	body, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+	assert.Equal(t, "unsupported version\n", string(body))
+}
+
+func TestRelay_RewriteURL(t *testing.T) {
+	relay, err := NewRelay(&Client{BrokerURL: "http://broker.local/"})
+	if err != nil {
+		t.Fatalf("unable to start relay: %v", err)
+	}
+	defer relay.Close()
+
+	assert.Equal(t, relay.URL+"/pacts/foo", relay.RewriteURL("http://broker.local/pacts/foo"))
+	assert.Equal(t, "http://other.local/pacts/foo", relay.RewriteURL("http://other.local/pacts/foo"))
+	assert.Equal(t, "/tmp/pact.json", relay.RewriteURL("/tmp/pact.json"))
+}
@@ -0,0 +1,57 @@
+package broker
+
+import "errors"
+
+// Label is a label applied to a pacticipant, e.g. "team-a"
+type Label struct {
+	Name  string `json:"name"`
+	Links Links  `json:"_links,omitempty"`
+}
+
+// AddTag tags the pacticipant version, e.g. with "prod" once it has been
+// deployed. The version is created if it does not already exist.
+func (c *Client) AddTag(pacticipant string, version string, tag string) (*Tag, error) {
+	if pacticipant == "" || version == "" || tag == "" {
+		return nil, errors.New("'pacticipant', 'version' and 'tag' are mandatory")
+	}
+
+	var res Tag
+	if err := c.call("PUT", pathSegments("pacticipants", pacticipant, "versions", version, "tags", tag), map[string]string{}, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// RemoveTag removes the tag from the pacticipant version
+func (c *Client) RemoveTag(pacticipant string, version string, tag string) error {
+	if pacticipant == "" || version == "" || tag == "" {
+		return errors.New("'pacticipant', 'version' and 'tag' are mandatory")
+	}
+
+	return c.call("DELETE", pathSegments("pacticipants", pacticipant, "versions", version, "tags", tag), nil, nil)
+}
+
+// AddLabel labels the pacticipant. Unlike tags, labels apply to
+// the pacticipant as a whole rather than to a particular version.
+func (c *Client) AddLabel(pacticipant string, label string) (*Label, error) {
+	if pacticipant == "" || label == "" {
+		return nil, errors.New("'pacticipant' and 'label' are mandatory")
+	}
+
+	var res Label
+	if err := c.call("PUT", pathSegments("pacticipants", pacticipant, "labels", label), map[string]string{}, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// RemoveLabel removes the label from the pacticipant
+func (c *Client) RemoveLabel(pacticipant string, label string) error {
+	if pacticipant == "" || label == "" {
+		return errors.New("'pacticipant' and 'label' are mandatory")
+	}
+
+	return c.call("DELETE", pathSegments("pacticipants", pacticipant, "labels", label), nil, nil)
+}
@@ -0,0 +1,57 @@
+package broker
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Tags(t *testing.T) {
+	var methods []string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacticipants/billy/versions/1.0.0/tags/prod": func(w http.ResponseWriter, r *http.Request) {
+			methods = append(methods, r.Method)
+			if r.Method == "PUT" {
+				jsonResponse(`{"name":"prod"}`)(w, r)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+	defer server.Close()
+
+	tag, err := client.AddTag("billy", "1.0.0", "prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", tag.Name)
+
+	assert.NoError(t, client.RemoveTag("billy", "1.0.0", "prod"))
+	assert.Equal(t, []string{"PUT", "DELETE"}, methods)
+
+	_, err = client.AddTag("billy", "", "prod")
+	assert.Error(t, err)
+}
+
+func TestClient_Labels(t *testing.T) {
+	var methods []string
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacticipants/billy/labels/team-a": func(w http.ResponseWriter, r *http.Request) {
+			methods = append(methods, r.Method)
+			if r.Method == "PUT" {
+				jsonResponse(`{"name":"team-a"}`)(w, r)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+	defer server.Close()
+
+	label, err := client.AddLabel("billy", "team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", label.Name)
+
+	assert.NoError(t, client.RemoveLabel("billy", "team-a"))
+	assert.Equal(t, []string{"PUT", "DELETE"}, methods)
+
+	assert.Error(t, client.RemoveLabel("", "team-a"))
+}
@@ -0,0 +1,47 @@
+package broker
+
+// VerificationResult is the outcome of a provider verifying a pact
+type VerificationResult struct {
+	// Success is true if the provider verified the pact successfully
+	Success bool `json:"success"`
+
+	// ProviderApplicationVersion is the version of the provider that ran the verification
+	ProviderApplicationVersion string `json:"providerApplicationVersion"`
+
+	// VerificationDate as reported by the broker
+	VerificationDate string `json:"verificationDate,omitempty"`
+
+	// BuildURL of the CI job that produced the verification, if known
+	BuildURL string `json:"buildUrl,omitempty"`
+
+	// Links to related resources
+	Links Links `json:"_links,omitempty"`
+}
+
+// LatestVerificationResult returns the latest verification result of the pact
+// published by the given consumer version
+func (c *Client) LatestVerificationResult(provider string, consumer string, consumerVersion string) (*VerificationResult, error) {
+	var res VerificationResult
+	path := pathSegments("pacts", "provider", provider, "consumer", consumer, "version", consumerVersion, "verification-results", "latest")
+	if err := c.call("GET", path, nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// LatestVerificationResultForPact returns the latest verification result
+// linked from a pact previously fetched from the broker
+func (c *Client) LatestVerificationResultForPact(pact *Pact) (*VerificationResult, error) {
+	link, ok := pact.Links.Get("pb:latest-verification-results")
+	if !ok {
+		return nil, &Error{StatusCode: 404, Method: "GET", Body: "pact has no verification results link"}
+	}
+
+	var res VerificationResult
+	if err := c.call("GET", link.Href, nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,31 @@
+package broker
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_LatestVerificationResult(t *testing.T) {
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacts/provider/bobby/consumer/billy/version/1.0.0":                             jsonResponse(pactDocument),
+		"/pacts/provider/bobby/consumer/billy/version/1.0.0/verification-results/latest": jsonResponse(`{"success":true,"providerApplicationVersion":"2.0.0"}`),
+	})
+	defer server.Close()
+
+	res, err := client.LatestVerificationResult("bobby", "billy", "1.0.0")
+	assert.NoError(t, err)
+	assert.True(t, res.Success)
+	assert.Equal(t, "2.0.0", res.ProviderApplicationVersion)
+
+	pact, err := client.PactForVersion("bobby", "billy", "1.0.0")
+	assert.NoError(t, err)
+
+	res, err = client.LatestVerificationResultForPact(pact)
+	assert.NoError(t, err)
+	assert.True(t, res.Success)
+
+	_, err = client.LatestVerificationResultForPact(&Pact{})
+	assert.True(t, IsNotFound(err))
+}
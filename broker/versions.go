@@ -0,0 +1,84 @@
+package broker
+
+// Version is an application version of a pacticipant
+type Version struct {
+	// Number is the version number, e.g. a semantic version or git SHA
+	Number string `json:"number"`
+
+	// Branch the version was built from, if known
+	Branch string `json:"branch,omitempty"`
+
+	// BuildURL of the CI job that produced the version, if known
+	BuildURL string `json:"buildUrl,omitempty"`
+
+	// CreatedAt is the time the version was first seen by the broker
+	CreatedAt string `json:"createdAt,omitempty"`
+
+	Embedded struct {
+		Tags []Tag `json:"tags,omitempty"`
+	} `json:"_embedded,omitempty"`
+
+	// Links to related resources
+	Links Links `json:"_links,omitempty"`
+}
+
+// Tags returns the tags applied to the version
+func (v Version) Tags() []Tag {
+	return v.Embedded.Tags
+}
+
+// Tag is a label applied to a pacticipant version, e.g. "prod"
+type Tag struct {
+	Name string `json:"name"`
+}
+
+type versionList struct {
+	Embedded struct {
+		Versions []Version `json:"versions"`
+	} `json:"_embedded"`
+	Links Links `json:"_links"`
+}
+
+// LatestVersion returns the most recent version of the pacticipant
+func (c *Client) LatestVersion(pacticipant string) (*Version, error) {
+	var res Version
+	if err := c.call("GET", pathSegments("pacticipants", pacticipant, "latest-version"), nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// LatestVersionWithTag returns the most recent version of the pacticipant
+// with the given tag
+func (c *Client) LatestVersionWithTag(pacticipant string, tag string) (*Version, error) {
+	var res Version
+	if err := c.call("GET", pathSegments("pacticipants", pacticipant, "latest-version", tag), nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Version returns a specific version of the pacticipant
+func (c *Client) Version(pacticipant string, version string) (*Version, error) {
+	var res Version
+	if err := c.call("GET", pathSegments("pacticipants", pacticipant, "versions", version), nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Versions lists the versions of the pacticipant, newest first
+func (c *Client) Versions(pacticipant string) ([]Version, error) {
+	var versions []Version
+	err := c.eachPage(pathSegments("pacticipants", pacticipant, "versions"), func(page []byte) (Links, error) {
+		var res versionList
+		links, err := decodePage(page, &res)
+		versions = append(versions, res.Embedded.Versions...)
+		return links, err
+	})
+
+	return versions, err
+}
@@ -0,0 +1,36 @@
+package broker
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Versions(t *testing.T) {
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/pacticipants/billy/latest-version":      jsonResponse(`{"number":"1.0.1","branch":"main","_embedded":{"tags":[{"name":"prod"}]}}`),
+		"/pacticipants/billy/latest-version/prod": jsonResponse(`{"number":"1.0.0"}`),
+		"/pacticipants/billy/versions/1.0.0":      jsonResponse(`{"number":"1.0.0"}`),
+		"/pacticipants/billy/versions":            jsonResponse(`{"_embedded":{"versions":[{"number":"1.0.1"},{"number":"1.0.0"}]}}`),
+	})
+	defer server.Close()
+
+	latest, err := client.LatestVersion("billy")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.1", latest.Number)
+	assert.Equal(t, "main", latest.Branch)
+	assert.Equal(t, []Tag{{Name: "prod"}}, latest.Tags())
+
+	tagged, err := client.LatestVersionWithTag("billy", "prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", tagged.Number)
+
+	version, err := client.Version("billy", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", version.Number)
+
+	versions, err := client.Versions("billy")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+}
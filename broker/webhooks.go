@@ -0,0 +1,165 @@
+package broker
+
+import (
+	"errors"
+	"strings"
+)
+
+// Webhook events supported by the broker
+const (
+	// EventContractContentChanged fires when a pact is published with changed content
+	EventContractContentChanged = "contract_content_changed"
+
+	// EventContractPublished fires whenever a pact is published
+	EventContractPublished = "contract_published"
+
+	// EventProviderVerificationPublished fires whenever verification results are published
+	EventProviderVerificationPublished = "provider_verification_published"
+
+	// EventProviderVerificationSucceeded fires when successful verification results are published
+	EventProviderVerificationSucceeded = "provider_verification_succeeded"
+
+	// EventProviderVerificationFailed fires when failed verification results are published
+	EventProviderVerificationFailed = "provider_verification_failed"
+)
+
+// Webhook is a HTTP request the broker makes when the given events occur
+type Webhook struct {
+	// UUID of the webhook, assigned by the broker when created
+	UUID string `json:"uuid,omitempty"`
+
+	// Description of the webhook
+	Description string `json:"description,omitempty"`
+
+	// Consumer restricts the webhook to pacts with this consumer. Optional.
+	Consumer *PacticipantName `json:"consumer,omitempty"`
+
+	// Provider restricts the webhook to pacts with this provider. Optional.
+	Provider *PacticipantName `json:"provider,omitempty"`
+
+	// Events that trigger the webhook
+	Events []WebhookEvent `json:"events"`
+
+	// Request the broker makes when triggered
+	Request WebhookRequest `json:"request"`
+
+	// Enabled webhooks are triggered by events
+	Enabled bool `json:"enabled"`
+
+	// Links to related resources
+	Links Links `json:"_links,omitempty"`
+}
+
+// WebhookEvent is an event that triggers a webhook
+type WebhookEvent struct {
+	Name string `json:"name"`
+}
+
+// WebhookRequest is the request made by the broker when a webhook is triggered
+type WebhookRequest struct {
+	Method   string            `json:"method"`
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     interface{}       `json:"body,omitempty"`
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+}
+
+// WebhookExecution is the result of test-triggering a webhook
+type WebhookExecution struct {
+	Request  map[string]interface{} `json:"request"`
+	Response map[string]interface{} `json:"response"`
+	Logs     string                 `json:"logs"`
+	Success  bool                   `json:"success"`
+}
+
+type webhookList struct {
+	Links Links `json:"_links"`
+}
+
+// CreateWebhook creates the webhook, returning it with the UUID assigned by the broker
+func (c *Client) CreateWebhook(webhook Webhook) (*Webhook, error) {
+	if len(webhook.Events) == 0 {
+		return nil, errors.New("at least one webhook event must be specified")
+	}
+
+	if webhook.Request.Method == "" || webhook.Request.URL == "" {
+		return nil, errors.New("webhook request 'Method' and 'URL' are mandatory")
+	}
+
+	if webhook.UUID != "" {
+		return nil, errors.New("webhook already has a UUID, use UpdateWebhook instead")
+	}
+
+	var res Webhook
+	if err := c.call("POST", "/webhooks", webhook, &res); err != nil {
+		return nil, err
+	}
+	res.UUID = webhookUUID(res)
+
+	return &res, nil
+}
+
+// UpdateWebhook replaces the webhook with the given UUID
+func (c *Client) UpdateWebhook(webhook Webhook) (*Webhook, error) {
+	if webhook.UUID == "" {
+		return nil, errors.New("webhook 'UUID' is mandatory")
+	}
+
+	var res Webhook
+	if err := c.call("PUT", pathSegments("webhooks", webhook.UUID), webhook, &res); err != nil {
+		return nil, err
+	}
+	res.UUID = webhookUUID(res)
+
+	return &res, nil
+}
+
+// Webhooks lists the links to all webhooks configured in the broker
+func (c *Client) Webhooks() ([]Link, error) {
+	var res webhookList
+	err := c.call("GET", "/webhooks", nil, &res)
+
+	return res.Links["pb:webhooks"], err
+}
+
+// Webhook retrieves the webhook with the given UUID
+func (c *Client) Webhook(uuid string) (*Webhook, error) {
+	var res Webhook
+	if err := c.call("GET", pathSegments("webhooks", uuid), nil, &res); err != nil {
+		return nil, err
+	}
+	res.UUID = uuid
+
+	return &res, nil
+}
+
+// ExecuteWebhook test-triggers the webhook, returning the request made
+// and the response received by the broker
+func (c *Client) ExecuteWebhook(uuid string) (*WebhookExecution, error) {
+	var res WebhookExecution
+	if err := c.call("POST", pathSegments("webhooks", uuid, "execute"), map[string]string{}, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// DeleteWebhook removes the webhook with the given UUID
+func (c *Client) DeleteWebhook(uuid string) error {
+	return c.call("DELETE", pathSegments("webhooks", uuid), nil, nil)
+}
+
+// webhookUUID extracts the UUID from the self link of a webhook response
+func webhookUUID(webhook Webhook) string {
+	if webhook.UUID != "" {
+		return webhook.UUID
+	}
+
+	self, ok := webhook.Links.Get("self")
+	if !ok {
+		return ""
+	}
+
+	return self.Href[strings.LastIndex(self.Href, "/")+1:]
+}
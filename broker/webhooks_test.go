@@ -0,0 +1,76 @@
+package broker
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Webhooks(t *testing.T) {
+	var created Webhook
+	var deleted bool
+	server, client := setupMockBroker(map[string]http.HandlerFunc{
+		"/webhooks": func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" {
+				json.NewDecoder(r.Body).Decode(&created)
+				w.WriteHeader(http.StatusCreated)
+				jsonResponse(`{"description":"notify CI","events":[{"name":"contract_content_changed"}],"request":{"method":"POST","url":"https://ci/build"},"enabled":true,"_links":{"self":{"href":"%[1]s/webhooks/abcd"}}}`)(w, r)
+				return
+			}
+			jsonResponse(`{"_links":{"pb:webhooks":[{"href":"%[1]s/webhooks/abcd","title":"notify CI"}]}}`)(w, r)
+		},
+		"/webhooks/abcd": func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case "DELETE":
+				deleted = true
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				jsonResponse(`{"description":"notify CI","events":[{"name":"contract_content_changed"}],"request":{"method":"POST","url":"https://ci/build"},"enabled":true}`)(w, r)
+			}
+		},
+		"/webhooks/abcd/execute": jsonResponse(`{"success":true,"logs":"webhook executed","request":{},"response":{"status":200}}`),
+	})
+	defer server.Close()
+
+	webhook, err := client.CreateWebhook(Webhook{
+		Description: "notify CI",
+		Provider:    &PacticipantName{Name: "bobby"},
+		Events:      []WebhookEvent{{Name: EventContractContentChanged}},
+		Request:     WebhookRequest{Method: "POST", URL: "https://ci/build"},
+		Enabled:     true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd", webhook.UUID)
+	assert.Equal(t, "bobby", created.Provider.Name)
+	assert.Nil(t, created.Consumer)
+
+	links, err := client.Webhooks()
+	assert.NoError(t, err)
+	assert.Len(t, links, 1)
+
+	webhook, err = client.Webhook("abcd")
+	assert.NoError(t, err)
+	assert.Equal(t, "notify CI", webhook.Description)
+
+	execution, err := client.ExecuteWebhook("abcd")
+	assert.NoError(t, err)
+	assert.True(t, execution.Success)
+
+	assert.NoError(t, client.DeleteWebhook("abcd"))
+	assert.True(t, deleted)
+}
+
+func TestClient_CreateWebhookValidation(t *testing.T) {
+	client := &Client{BrokerURL: "http://localhost"}
+
+	_, err := client.CreateWebhook(Webhook{Request: WebhookRequest{Method: "POST", URL: "https://ci"}})
+	assert.Error(t, err)
+
+	_, err = client.CreateWebhook(Webhook{Events: []WebhookEvent{{Name: EventContractPublished}}})
+	assert.Error(t, err)
+
+	_, err = client.UpdateWebhook(Webhook{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,15 @@
+package command
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate code from pacts",
+	Long:  "Generates Go code to help implement the pacts of a provider",
+}
+
+func init() {
+	RootCmd.AddCommand(generateCmd)
+}
@@ -0,0 +1,67 @@
+package command
+
+import (
+	"log"
+	"os"
+
+	"github.com/pact-foundation/pact-go/dsl"
+	"github.com/pact-foundation/pact-go/types"
+
+	"github.com/spf13/cobra"
+)
+
+var stateHandlersOutput string
+var stateHandlersPackage string
+var stateHandlersName string
+var stateHandlersProvider string
+var stateHandlersBrokerURL string
+var stateHandlersBrokerToken string
+var stateHandlersCmd = &cobra.Command{
+	Use:   "state-handlers [pact files or URLs]",
+	Short: "Generate provider state handler stubs",
+	Long: `Generates a Go file with a stub StateHandler for every provider state
+found in the given pacts, or in the pacts for the provider in a Pact Broker`,
+	Run: func(cmd *cobra.Command, args []string) {
+		setLogLevel(verbose, logLevel)
+
+		if err := generateStateHandlers(args); err != nil {
+			log.Println("[ERROR] unable to generate state handlers:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func generateStateHandlers(pactURLs []string) error {
+	pact := &dsl.Pact{Provider: stateHandlersProvider}
+	states, err := pact.RequiredStates(types.VerifyRequest{
+		PactURLs:    pactURLs,
+		BrokerURL:   stateHandlersBrokerURL,
+		BrokerToken: stateHandlersBrokerToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if stateHandlersOutput != "" {
+		if out, err = os.Create(stateHandlersOutput); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	return dsl.GenerateStateHandlers(out, states, dsl.StateHandlerStubOptions{
+		Package: stateHandlersPackage,
+		Name:    stateHandlersName,
+	})
+}
+
+func init() {
+	stateHandlersCmd.Flags().StringVarP(&stateHandlersOutput, "output", "o", "", "File to write the state handlers to, defaults to stdout")
+	stateHandlersCmd.Flags().StringVar(&stateHandlersPackage, "package", "provider", "Package of the generated file")
+	stateHandlersCmd.Flags().StringVar(&stateHandlersName, "name", "stateHandlers", "Name of the generated StateHandlers variable")
+	stateHandlersCmd.Flags().StringVar(&stateHandlersProvider, "provider", "", "Name of the provider, when fetching pacts from a Pact Broker")
+	stateHandlersCmd.Flags().StringVar(&stateHandlersBrokerURL, "broker-url", "", "URL of a Pact Broker to fetch the pacts for the provider from")
+	stateHandlersCmd.Flags().StringVar(&stateHandlersBrokerToken, "broker-token", "", "Bearer token to authenticate to the Pact Broker")
+	generateCmd.AddCommand(stateHandlersCmd)
+}
@@ -0,0 +1,42 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateStateHandlers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pact-go")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pactFile := filepath.Join(dir, "billy-bobby.json")
+	ioutil.WriteFile(pactFile, []byte(`{"consumer": {"name": "billy"}, "provider": {"name": "bobby"}, "interactions": [{"providerState": "user 1 exists"}]}`), 0644)
+
+	stateHandlersOutput = filepath.Join(dir, "states.go")
+	stateHandlersPackage = "users"
+	defer func() {
+		stateHandlersOutput = ""
+		stateHandlersPackage = "provider"
+	}()
+
+	if err := generateStateHandlers([]string{pactFile}); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	out, err := ioutil.ReadFile(stateHandlersOutput)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	for _, want := range []string{"package users", `"user 1 exists": func() error {`} {
+		if !strings.Contains(string(out), want) {
+			t.Fatalf("Expected %q in the generated file, got %s", want, out)
+		}
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"log"
 	"net"
 	"net/url"
+	"os"
+	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
@@ -67,6 +69,10 @@ type PactClient struct {
 
 	// TimeoutDuration specifies how long to wait for Pact CLI to start
 	TimeoutDuration time.Duration
+
+	// Serialises the creation of commands, as each service manager is shared
+	// by every command it creates
+	mu sync.Mutex
 }
 
 // newClient creates a new Pact client manager with the provided services
@@ -94,8 +100,7 @@ func NewClient() *PactClient {
 func (p *PactClient) StartServer(args []string, port int) *types.MockServer {
 	log.Println("[DEBUG] client: starting a server with args:", args, "port:", port)
 	args = append(args, []string{"--port", strconv.Itoa(port)}...)
-	svc := p.pactMockSvcManager.NewService(args)
-	cmd := svc.Start()
+	cmd := p.start(p.pactMockSvcManager, args)
 
 	waitForPort(port, p.getNetworkInterface(), p.Address, p.TimeoutDuration,
 		fmt.Sprintf(`Timed out waiting for Mock Server to start on port %d - are you sure it's running?`, port))
@@ -106,6 +111,24 @@ func (p *PactClient) StartServer(args []string, port int) *types.MockServer {
 	}
 }
 
+// command creates the command for a service with the given arguments. The
+// arguments are held by the service manager until the command is created, so
+// concurrent callers must not interleave.
+func (p *PactClient) command(manager client.Service, args []string) *exec.Cmd {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return manager.NewService(args).Command()
+}
+
+// start starts a service with the given arguments, see command
+func (p *PactClient) start(manager client.Service, args []string) *exec.Cmd {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return manager.NewService(args).Start()
+}
+
 // ListServers lists all known Mock Servers
 func (p *PactClient) ListServers() []*types.MockServer {
 	log.Println("[DEBUG] client: starting a server")
@@ -174,8 +197,9 @@ func (p *PactClient) VerifyProvider(request types.VerifyRequest) ([]types.Provid
 	// First, attempt to decode the response of the stdout.
 	// If that is successful, we are at case 3. Return stdout as message, no error.
 	// Else, return an error, include stderr and stdout in both the error and message.
-	svc := p.verificationSvcManager.NewService(request.Args)
-	cmd := svc.Command()
+	cmd := p.command(p.verificationSvcManager, request.Args)
+	cmd.Env = append(cmd.Env, proxyEnvironment(request.BrokerProxyURL)...)
+	cmd.Env = append(cmd.Env, request.Env...)
 
 	stdOutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -195,8 +219,8 @@ func (p *PactClient) VerifyProvider(request types.VerifyRequest) ([]types.Provid
 	// Each pact is verified by line, and the results (as JSON) sent to stdout.
 	// See https://github.com/pact-foundation/pact-go/issues/88#issuecomment-404686337
 	stdOutScanner := bufio.NewScanner(stdOutPipe)
+	wg.Add(2)
 	go func() {
-		wg.Add(1)
 		defer wg.Done()
 		stdOutBuf := make([]byte, bufio.MaxScanTokenSize)
 		stdOutScanner.Buffer(stdOutBuf, 64*1024*1024)
@@ -209,7 +233,6 @@ func (p *PactClient) VerifyProvider(request types.VerifyRequest) ([]types.Provid
 	// Scrape errors
 	stdErrScanner := bufio.NewScanner(stdErrPipe)
 	go func() {
-		wg.Add(1)
 		defer wg.Done()
 		for stdErrScanner.Scan() {
 			stdErr.WriteString(fmt.Sprintf("%s\n", stdErrScanner.Text()))
@@ -262,8 +285,7 @@ func (p *PactClient) UpdateMessagePact(request types.PactMessageRequest) error {
 		return err
 	}
 
-	svc := p.messageSvcManager.NewService(request.Args)
-	cmd := svc.Command()
+	cmd := p.command(p.messageSvcManager, request.Args)
 
 	stdOutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -297,9 +319,8 @@ func (p *PactClient) UpdateMessagePact(request types.PactMessageRequest) error {
 
 // PublishPacts publishes a set of pacts to a pact broker
 func (p *PactClient) PublishPacts(request types.PublishRequest) error {
-	svc := p.publishSvcManager.NewService(request.Args)
 	log.Println("[DEBUG] about to publish pacts")
-	cmd := svc.Start()
+	cmd := p.start(p.publishSvcManager, request.Args)
 
 	log.Println("[DEBUG] waiting for response")
 	err := cmd.Wait()
@@ -326,8 +347,7 @@ func (p *PactClient) ReifyMessage(request *types.PactReificationRequest) (res *t
 		return
 	}
 
-	svc := p.messageSvcManager.NewService(request.Args)
-	cmd := svc.Command()
+	cmd := p.command(p.messageSvcManager, request.Args)
 
 	stdOutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -377,12 +397,9 @@ func (p *PactClient) ReifyMessage(request *types.PactReificationRequest) (res *t
 func getPort(rawURL string) int {
 	parsedURL, err := url.Parse(rawURL)
 	if err == nil {
-		splitHost := strings.Split(parsedURL.Host, ":")
-		if len(splitHost) == 2 {
-			port, err := strconv.Atoi(splitHost[1])
-			if err == nil {
-				return port
-			}
+		port, err := strconv.Atoi(parsedURL.Port())
+		if err == nil {
+			return port
 		}
 		if parsedURL.Scheme == "https" {
 			return 443
@@ -400,8 +417,41 @@ func getAddress(rawURL string) string {
 		return ""
 	}
 
-	splitHost := strings.Split(parsedURL.Host, ":")
-	return splitHost[0]
+	return parsedURL.Hostname()
+}
+
+// localAddresses must always bypass any HTTP proxy, as they are used to reach
+// the provider and the verification and broker relays
+var localAddresses = []string{"localhost", "127.0.0.1", "::1"}
+
+// proxyEnvironment returns the environment variables configuring the verifier
+// process to use the given proxy, if any. Whenever a proxy is in use, local
+// addresses are excluded from proxying.
+func proxyEnvironment(proxyURL string) []string {
+	var env []string
+
+	if proxyURL != "" {
+		for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			env = append(env, name+"="+proxyURL)
+		}
+	} else if os.Getenv("HTTP_PROXY") == "" && os.Getenv("HTTPS_PROXY") == "" &&
+		os.Getenv("http_proxy") == "" && os.Getenv("https_proxy") == "" {
+		return nil
+	}
+
+	noProxy := os.Getenv("NO_PROXY")
+	if noProxy == "" {
+		noProxy = os.Getenv("no_proxy")
+	}
+
+	hosts := []string{}
+	if noProxy != "" {
+		hosts = append(hosts, noProxy)
+	}
+	hosts = append(hosts, localAddresses...)
+	noProxy = strings.Join(hosts, ",")
+
+	return append(env, "NO_PROXY="+noProxy, "no_proxy="+noProxy)
 }
 
 // Use this to wait for a port to be running prior
@@ -416,8 +466,9 @@ var waitForPort = func(port int, network string, address string, timeoutDuration
 			log.Printf("[ERROR] Expected server to start < %s. %s", timeoutDuration, message)
 			return fmt.Errorf("Expected server to start < %s. %s", timeoutDuration, message)
 		case <-time.After(50 * time.Millisecond):
-			_, err := net.Dial(network, fmt.Sprintf("%s:%d", address, port))
+			conn, err := net.Dial(network, net.JoinHostPort(address, strconv.Itoa(port)))
 			if err == nil {
+				conn.Close()
 				return nil
 			}
 		}
@@ -3,6 +3,7 @@ package dsl
 import (
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"reflect"
@@ -124,6 +125,7 @@ func TestClient_getPort(t *testing.T) {
 		"http://localhost:8000": 8000,
 		"http://localhost":      80,
 		"https://localhost":     443,
+		"http://[::1]:8000":     8000,
 		":::::":                 -1,
 	}
 
@@ -139,6 +141,7 @@ func TestClient_getAddress(t *testing.T) {
 		"http://localhost:8000": "localhost",
 		"http://localhost":      "localhost",
 		"http://127.0.0.1":      "127.0.0.1",
+		"http://[::1]:8000":     "::1",
 		":::::":                 "",
 	}
 
@@ -149,6 +152,56 @@ func TestClient_getAddress(t *testing.T) {
 	}
 }
 
+func TestClient_waitForPortIPv6(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 loopback is unavailable:", err)
+	}
+	defer ln.Close()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	if err := waitForPort(port, "tcp", "::1", 1*time.Second, "timed out"); err != nil {
+		t.Fatalf("Expected port %d to be reachable on ::1 but got %v", port, err)
+	}
+}
+
+func TestClient_proxyEnvironment(t *testing.T) {
+	names := []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy", "NO_PROXY", "no_proxy"}
+	saved := make(map[string]string)
+	for _, name := range names {
+		if v, ok := os.LookupEnv(name); ok {
+			saved[name] = v
+		}
+		os.Unsetenv(name)
+	}
+	defer func() {
+		for _, name := range names {
+			os.Unsetenv(name)
+		}
+		for name, v := range saved {
+			os.Setenv(name, v)
+		}
+	}()
+
+	if env := proxyEnvironment(""); len(env) != 0 {
+		t.Fatalf("expected no proxy environment, got %v", env)
+	}
+
+	env := strings.Join(proxyEnvironment("http://proxy.local:3128"), " ")
+	for _, want := range []string{"HTTPS_PROXY=http://proxy.local:3128", "http_proxy=http://proxy.local:3128", "NO_PROXY=localhost,127.0.0.1,::1"} {
+		if !strings.Contains(env, want) {
+			t.Fatalf("expected %q in proxy environment, got %v", want, env)
+		}
+	}
+
+	os.Setenv("HTTPS_PROXY", "http://proxy.local:3128")
+	os.Setenv("NO_PROXY", "internal.local")
+	env = strings.Join(proxyEnvironment(""), " ")
+	if env != "NO_PROXY=internal.local,localhost,127.0.0.1,::1 no_proxy=internal.local,localhost,127.0.0.1,::1" {
+		t.Fatalf("expected local addresses to be added to NO_PROXY, got %v", env)
+	}
+}
+
 func TestClient_sanitiseRubyResponse(t *testing.T) {
 	var tests = map[string]string{
 		"this is a sentence with a hash # so it should be in tact":                                           "this is a sentence with a hash # so it should be in tact",
@@ -0,0 +1,268 @@
+package dsl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pact-foundation/pact-go/proxy"
+	"github.com/pact-foundation/pact-go/utils"
+)
+
+var unsafeFileCharacters = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// exchangeLog is the on-disk representation of a single interaction
+// replayed against the provider
+type exchangeLog struct {
+	Consumer    string              `json:"consumer,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Request     exchangeLogRequest  `json:"request"`
+	Response    exchangeLogResponse `json:"response"`
+
+	// ProviderResponse is the response as sent by the provider, before a
+	// ResponseFilter changed it into Response
+	ProviderResponse *exchangeLogResponse `json:"providerResponse,omitempty"`
+}
+
+type exchangeLogRequest struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Query   string              `json:"query,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body,omitempty"`
+}
+
+type exchangeLogResponse struct {
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body,omitempty"`
+}
+
+type providerResponseKey struct{}
+
+// providerResponse keeps a copy of a response from the provider, as it was
+// before any ResponseFilter ran
+type providerResponse struct {
+	captured bool
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	original io.ReadCloser
+}
+
+// bodyString returns the body sent by the provider. What the filter left
+// unread, e.g. when replacing the body, is read once the exchange is over.
+func (p *providerResponse) bodyString() string {
+	if p.original != nil {
+		io.Copy(ioutil.Discard, p.original)
+		p.original.Close()
+		p.original = nil
+	}
+	return p.body.String()
+}
+
+// exchangeLogResponseFilter records each response from the provider for the
+// exchange log, before handing it to filter. The body is copied as it is read,
+// so that it is still streamed to the verifier.
+func exchangeLogResponseFilter(filter proxy.ResponseFilter) proxy.ResponseFilter {
+	return func(res *http.Response) error {
+		if res.Request != nil {
+			if raw, ok := res.Request.Context().Value(providerResponseKey{}).(*providerResponse); ok {
+				raw.captured = true
+				raw.status = res.StatusCode
+				raw.header = make(http.Header, len(res.Header))
+				for k, v := range res.Header {
+					raw.header[k] = append([]string{}, v...)
+				}
+				if res.Body != nil {
+					raw.original = teeReadCloser{Reader: io.TeeReader(res.Body, &raw.body), Closer: res.Body}
+					res.Body = raw.original
+				}
+			}
+		}
+
+		return filter(res)
+	}
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// exchangeLogMiddleware writes the request sent to, and the response received
+// from, the provider for every proxied interaction into a new subdirectory of
+// dir, so that runs sharing the directory don't overwrite each other. Values
+// of well known credential headers (utils.SensitiveHeaders), along with any
+// header named in redact, are masked.
+func exchangeLogMiddleware(dir string, redact []string) proxy.Middleware {
+	var mu sync.Mutex
+	var seq int
+	var runDir string
+
+	redacted := append([]string{}, utils.SensitiveHeaders...)
+	redacted = append(redacted, redact...)
+
+	capture := proxy.CaptureMiddleware(func(e *proxy.Exchange) {
+		mu.Lock()
+		seq++
+		n := seq
+		var err error
+		if runDir == "" {
+			runDir, err = exchangeLogRunDir(dir)
+		}
+		run := runDir
+		mu.Unlock()
+
+		if err != nil {
+			log.Println("[ERROR] unable to create exchange log directory:", err)
+			return
+		}
+
+		interaction, _ := InteractionFromContext(e.Request.Context())
+		entry := exchangeLog{
+			Consumer:    interaction.Consumer,
+			Description: interaction.Description,
+			Request: exchangeLogRequest{
+				Method:  e.Request.Method,
+				Path:    e.Request.URL.Path,
+				Query:   e.Request.URL.RawQuery,
+				Headers: redactHeaders(e.Request.Header, redacted),
+				Body:    string(e.RequestBody),
+			},
+			Response: exchangeLogResponse{
+				Status:  e.StatusCode,
+				Headers: redactHeaders(e.ResponseHeader, redacted),
+				Body:    string(e.ResponseBody),
+			},
+		}
+
+		if raw, ok := e.Request.Context().Value(providerResponseKey{}).(*providerResponse); ok && raw.captured {
+			entry.ProviderResponse = &exchangeLogResponse{
+				Status:  raw.status,
+				Headers: redactHeaders(raw.header, redacted),
+				Body:    raw.bodyString(),
+			}
+		}
+
+		if err := writeExchangeLog(run, n, entry); err != nil {
+			log.Println("[ERROR] unable to write exchange log:", err)
+		}
+	})
+
+	return func(next http.Handler) http.Handler {
+		captured := capture(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isInternalPath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			captured.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), providerResponseKey{}, &providerResponse{})))
+		})
+	}
+}
+
+// exchangeLogRunDir creates a uniquely named directory in dir for the
+// exchanges of a single verification run
+func exchangeLogRunDir(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	run, err := ioutil.TempDir(dir, time.Now().Format("20060102-150405")+"-")
+	if err != nil {
+		return "", err
+	}
+
+	log.Println("[INFO] writing exchange logs to", run)
+	return run, os.Chmod(run, 0755)
+}
+
+func writeExchangeLog(dir string, seq int, entry exchangeLog) error {
+	body, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%04d-%s.json", seq, exchangeLogName(entry))
+
+	log.Println("[DEBUG] writing exchange log", name)
+	return ioutil.WriteFile(filepath.Join(dir, name), body, 0644)
+}
+
+// maxExchangeLogName keeps file names well within the limits of common
+// filesystems, for interactions with long descriptions
+const maxExchangeLogName = 200
+
+// exchangeLogName names the file of an exchange after the consumer and
+// description of its interaction, or the request where the interaction
+// isn't known
+func exchangeLogName(entry exchangeLog) string {
+	parts := []string{entry.Consumer, entry.Description}
+	if entry.Description == "" {
+		parts = []string{entry.Consumer, entry.Request.Method, entry.Request.Path}
+	}
+
+	var names []string
+	for _, part := range parts {
+		if part = strings.Trim(unsafeFileCharacters.ReplaceAllString(part, "_"), "_"); part != "" {
+			names = append(names, part)
+		}
+	}
+
+	name := strings.Join(names, "-")
+	if len(name) > maxExchangeLogName {
+		name = name[:maxExchangeLogName]
+	}
+
+	return name
+}
+
+// redactHeaders returns a copy of the headers with the values of
+// any of the given header names masked
+func redactHeaders(headers http.Header, redact []string) map[string][]string {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	res := make(map[string][]string, len(headers))
+	for k, v := range headers {
+		res[k] = append([]string{}, v...)
+	}
+
+	for _, name := range redact {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if values, ok := res[name]; ok {
+			for i := range values {
+				values[i] = utils.RedactedValue
+			}
+		}
+	}
+
+	return res
+}
+
+// customHeaderNames extracts the header names from a list of
+// 'Name: value' formatted custom provider headers
+func customHeaderNames(headers []string) []string {
+	names := make([]string, 0, len(headers))
+	for _, h := range headers {
+		if i := strings.Index(h, ":"); i > 0 {
+			names = append(names, h[:i])
+		}
+	}
+
+	return names
+}
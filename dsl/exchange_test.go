@@ -0,0 +1,173 @@
+package dsl
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/pact-foundation/pact-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchangeLogMiddleware(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pactgo-exchange")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":1}`))
+	})
+
+	mw := exchangeLogMiddleware(dir, []string{"X-Custom-Token"})
+
+	req, _ := http.NewRequest("GET", "/users/1?expand=true", nil)
+	req.Header.Set("Authorization", "Bearer 1234")
+	req.Header.Set("X-Custom-Token", "abcd")
+	req.Header.Set("Accept", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), interactionContextKey{}, types.InteractionMetadata{
+		Consumer:    "billy",
+		Description: "a request for user 1",
+	}))
+	mw(target).ServeHTTP(httptest.NewRecorder(), req)
+
+	setup, _ := http.NewRequest("POST", providerStatesSetupPath, nil)
+	mw(target).ServeHTTP(httptest.NewRecorder(), setup)
+
+	runs, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	if !assert.Len(t, runs, 1) || !assert.True(t, runs[0].IsDir(), "expected the run to be logged in a subdirectory") {
+		return
+	}
+
+	run := filepath.Join(dir, runs[0].Name())
+	files, err := ioutil.ReadDir(run)
+	assert.NoError(t, err)
+	if !assert.Len(t, files, 1, "expected only the proxied interaction to be logged") {
+		return
+	}
+	assert.Equal(t, "0001-billy-a_request_for_user_1.json", files[0].Name())
+
+	data, err := ioutil.ReadFile(filepath.Join(run, files[0].Name()))
+	assert.NoError(t, err)
+
+	var entry exchangeLog
+	assert.NoError(t, json.Unmarshal(data, &entry))
+
+	assert.Equal(t, "billy", entry.Consumer)
+	assert.Equal(t, "a request for user 1", entry.Description)
+	assert.Equal(t, "/users/1", entry.Request.Path)
+	assert.Equal(t, "expand=true", entry.Request.Query)
+	assert.Equal(t, []string{utils.RedactedValue}, entry.Request.Headers["Authorization"])
+	assert.Equal(t, []string{utils.RedactedValue}, entry.Request.Headers["X-Custom-Token"])
+	assert.Equal(t, []string{"application/json"}, entry.Request.Headers["Accept"])
+	assert.Equal(t, 200, entry.Response.Status)
+	assert.Equal(t, []string{utils.RedactedValue}, entry.Response.Headers["Set-Cookie"])
+	assert.Equal(t, `{"id":1}`, entry.Response.Body)
+}
+
+func TestExchangeLogMiddleware_SeparateRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pactgo-exchange")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "/users/1", nil)
+		exchangeLogMiddleware(dir, nil)(target).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	runs, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	if !assert.Len(t, runs, 2, "expected each run to be logged in its own directory") {
+		return
+	}
+
+	for _, r := range runs {
+		files, err := ioutil.ReadDir(filepath.Join(dir, r.Name()))
+		assert.NoError(t, err)
+		if assert.Len(t, files, 1) {
+			assert.Equal(t, "0001-GET-users_1.json", files[0].Name())
+		}
+	}
+}
+
+func TestExchangeLogMiddleware_ResponseFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pactgo-exchange")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Internal", "true")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":1,"secret":"s3cr3t"}`))
+	}))
+	defer provider.Close()
+
+	u, _ := url.Parse(provider.URL)
+	reverse := httputil.NewSingleHostReverseProxy(u)
+	reverse.ModifyResponse = exchangeLogResponseFilter(func(res *http.Response) error {
+		res.Header.Del("X-Internal")
+		res.Body = ioutil.NopCloser(strings.NewReader(`{"id":1}`))
+		return nil
+	})
+
+	req, _ := http.NewRequest("GET", "/users/1", nil)
+	rr := httptest.NewRecorder()
+	exchangeLogMiddleware(dir, nil)(reverse).ServeHTTP(rr, req)
+	assert.Equal(t, `{"id":1}`, rr.Body.String())
+
+	runs, err := ioutil.ReadDir(dir)
+	if !assert.NoError(t, err) || !assert.Len(t, runs, 1) {
+		return
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, runs[0].Name(), "0001-GET-users_1.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var entry exchangeLog
+	assert.NoError(t, json.Unmarshal(data, &entry))
+
+	assert.Equal(t, `{"id":1}`, entry.Response.Body)
+	assert.Empty(t, entry.Response.Headers["X-Internal"])
+	if assert.NotNil(t, entry.ProviderResponse, "expected the unfiltered response to be logged") {
+		assert.Equal(t, 200, entry.ProviderResponse.Status)
+		assert.Equal(t, `{"id":1,"secret":"s3cr3t"}`, entry.ProviderResponse.Body)
+		assert.Equal(t, []string{"true"}, entry.ProviderResponse.Headers["X-Internal"])
+		assert.Equal(t, []string{utils.RedactedValue}, entry.ProviderResponse.Headers["Set-Cookie"])
+	}
+}
+
+func TestExchangeLogName(t *testing.T) {
+	long := exchangeLog{Consumer: "billy", Description: strings.Repeat("a very long description ", 20)}
+	assert.Len(t, exchangeLogName(long), maxExchangeLogName)
+
+	unknown := exchangeLog{Request: exchangeLogRequest{Method: "POST", Path: "/users"}}
+	assert.Equal(t, "POST-users", exchangeLogName(unknown))
+}
+
+func TestCustomHeaderNames(t *testing.T) {
+	assert.Equal(t, []string{"Authorization", "X-Foo"}, customHeaderNames([]string{"Authorization: Basic cGFjdDpwYWN0", "X-Foo:bar", "broken"}))
+}
@@ -0,0 +1,32 @@
+package dsl
+
+// ForbiddenRequest is a request which must not be received during a test,
+// e.g. one that the client's cache or feature flags should suppress. Only the
+// parts of the request given are matched: a request without a method or path
+// forbids any method or path, and one without a query or body forbids any
+// query or body. Forbidden requests aren't written to the pact.
+type ForbiddenRequest struct {
+	// Description of the request, reported if it's received
+	Description string `json:"description,omitempty"`
+
+	// Request which must not be received, which may contain matchers, e.g.
+	// Term("/users/1", `^/users/\d+$`) for any user
+	Request Request `json:"request"`
+}
+
+// ForbidRequest fails the verification of the test in progress if a matching
+// request is received, e.g.
+//
+//	pact.ForbidRequest("deleting a user", Request{
+//		Method: "DELETE",
+//		Path:   Term("/users/1", `^/users/\d+$`),
+//	})
+//
+// Forbidden requests are cleared after each test, with the interactions. Only
+// supported by the native mock server.
+func (p *Pact) ForbidRequest(description string, request Request) {
+	p.ForbiddenRequests = append(p.ForbiddenRequests, &ForbiddenRequest{
+		Description: description,
+		Request:     request,
+	})
+}
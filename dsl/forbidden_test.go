@@ -0,0 +1,60 @@
+package dsl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/mockserver"
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPact_ForbidRequest(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	pact := &Pact{
+		Consumer:         "My Consumer",
+		Provider:         "My Provider",
+		PactDir:          dir,
+		LogLevel:         "ERROR",
+		NativeMockServer: true,
+	}
+	defer pact.Teardown()
+
+	get := func(path string) error {
+		res, err := http.Get(fmt.Sprintf("http://localhost:%d%s", pact.Server.Port, path))
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	}
+
+	// A cached client only requests the user once
+	pact.
+		AddInteraction().
+		UponReceiving("a request for the user").
+		WithRequest(Request{Method: "GET", Path: String("/users/1")}).
+		WillRespondWith(Response{Status: 200})
+	pact.ForbidRequest("requesting the user again", Request{Method: "GET", Path: Term("/users/1", `^/users/\d+$`), Headers: MapMatcher{"If-None-Match": Like("abc")}})
+	err := pact.Verify(func() error { return get("/users/1") })
+	assert.NoError(t, err)
+	assert.Empty(t, pact.ForbiddenRequests)
+
+	// Only forbidden requests are expected when the feature is disabled
+	pact.ForbidRequest("requesting the new feature", Request{Path: String("/feature")})
+	err = pact.Verify(func() error { return get("/feature") })
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "forbidden request: GET /feature (requesting the new feature)")
+	assert.Len(t, err.(*mockserver.VerificationError).Forbidden, 1)
+}
+
+func TestPact_ForbidRequestRequiresNativeMockServer(t *testing.T) {
+	pact := &Pact{Consumer: "My Consumer", Provider: "My Provider", Server: &types.MockServer{}, toolValidityCheck: true}
+	pact.ForbidRequest("", Request{Method: "DELETE"})
+
+	assert.EqualError(t, pact.Verify(func() error { return nil }), "forbidden requests require the NativeMockServer")
+}
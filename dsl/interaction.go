@@ -3,6 +3,7 @@ package dsl
 import (
 	"encoding/json"
 	"log"
+	"time"
 )
 
 // Interaction is the main implementation of the Pact interface.
@@ -18,6 +19,38 @@ type Interaction struct {
 
 	// Provider state to be written into the Pact file
 	State string `json:"providerState,omitempty"`
+
+	// MinCalls and MaxCalls bound the number of times the request must be
+	// made during the test, set with Times, AtLeast and AtMost. Defaults to at
+	// least once. Only enforced by the native mock server.
+	MinCalls *int `json:"minCalls,omitempty"`
+	MaxCalls *int `json:"maxCalls,omitempty"`
+
+	// Sequence names the scenario the interaction is a step of, set with
+	// InSequence. Only supported by the native mock server.
+	Sequence string `json:"sequence,omitempty"`
+
+	// PrecededBy are the descriptions of interactions which must be requested
+	// before this one, set with After. Only enforced by the native mock server.
+	PrecededBy []string `json:"precededBy,omitempty"`
+
+	// Delay before the mock server responds, set with WillRespondWithDelay.
+	// Only supported by the native mock server.
+	Delay time.Duration `json:"responseDelay,omitempty"`
+
+	// Comments in markdown and the name of the test, set with WithComment and
+	// WithTestName. Only written to version 4 pacts.
+	Comments []string `json:"comments,omitempty"`
+	TestName string   `json:"testName,omitempty"`
+
+	// Pending interactions don't fail the verification of the provider until
+	// they're implemented, set with AsPending. Only written to version 4
+	// pacts.
+	Pending bool `json:"pending,omitempty"`
+
+	// template the interaction was created from, whose request and response
+	// are merged with those given to it
+	template *InteractionTemplate
 }
 
 // Given specifies a provider state. Optional.
@@ -37,9 +70,12 @@ func (i *Interaction) UponReceiving(description string) *Interaction {
 
 // WithRequest specifies the details of the HTTP request that will be used to
 // confirm that the Provider provides an API listening on the given interface.
-// Mandatory.
+// Mandatory, unless the interaction was created from a template.
 func (i *Interaction) WithRequest(request Request) *Interaction {
 	i.Request = request
+	if i.template != nil {
+		i.Request = mergeRequest(i.template.Request, request)
+	}
 
 	// Check if someone tried to add an object as a string representation
 	// as per original allowed implementation, e.g.
@@ -54,9 +90,92 @@ func (i *Interaction) WithRequest(request Request) *Interaction {
 }
 
 // WillRespondWith specifies the details of the HTTP response that will be used to
-// confirm that the Provider must satisfy. Mandatory.
+// confirm that the Provider must satisfy. Mandatory, unless the interaction
+// was created from a template.
 func (i *Interaction) WillRespondWith(response Response) *Interaction {
 	i.Response = response
+	if i.template != nil {
+		i.Response = mergeResponse(i.template.Response, response)
+	}
+
+	return i
+}
+
+// InSequence makes the interaction a step of the named sequence. The steps of
+// a sequence are expected in the order they are added, so that the same
+// request can have a different response each time, e.g. 202 then 200 while
+// polling. Each step is served until it has been requested the most times
+// allowed by Times or AtMost, which defaults to once. Optional.
+func (i *Interaction) InSequence(name string) *Interaction {
+	i.Sequence = name
+
+	return i
+}
+
+// After requires the interactions with the given descriptions to be
+// requested before this one is first requested, e.g. to authenticate before
+// calling an API. Optional.
+func (i *Interaction) After(descriptions ...string) *Interaction {
+	i.PrecededBy = append(i.PrecededBy, descriptions...)
+
+	return i
+}
+
+// WillRespondWithDelay delays the response by d, so that tests can exercise
+// the timeouts and retries of the client. Optional.
+func (i *Interaction) WillRespondWithDelay(d time.Duration) *Interaction {
+	i.Delay = d
+
+	return i
+}
+
+// WithComment adds a comment, in markdown, to the interaction in the pact, e.g.
+// to explain it to the provider team. Optional.
+func (i *Interaction) WithComment(comment string) *Interaction {
+	i.Comments = append(i.Comments, comment)
+
+	return i
+}
+
+// WithTestName records the name of the test that defines the interaction,
+// e.g. t.Name(). Optional.
+func (i *Interaction) WithTestName(name string) *Interaction {
+	i.TestName = name
+
+	return i
+}
+
+// AsPending marks the interaction as pending, so that it doesn't fail the
+// verification of the provider until it has been implemented. Optional.
+func (i *Interaction) AsPending() *Interaction {
+	i.Pending = true
+
+	return i
+}
+
+// Times requires the request to be made exactly n times. Optional.
+func (i *Interaction) Times(n int) *Interaction {
+	i.MinCalls = &n
+	i.MaxCalls = &n
+
+	return i
+}
+
+// AtLeast requires the request to be made at least n times. Optional.
+func (i *Interaction) AtLeast(n int) *Interaction {
+	i.MinCalls = &n
+
+	return i
+}
+
+// AtMost requires the request to be made no more than n times, and allows it
+// not to be made at all unless combined with AtLeast. Optional.
+func (i *Interaction) AtMost(n int) *Interaction {
+	if i.MinCalls == nil {
+		none := 0
+		i.MinCalls = &none
+	}
+	i.MaxCalls = &n
 
 	return i
 }
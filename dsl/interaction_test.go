@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestInteraction_NewInteraction(t *testing.T) {
@@ -111,6 +112,67 @@ func TestInteraction_WillRespondWith(t *testing.T) {
 	}
 }
 
+func TestInteraction_CallCounts(t *testing.T) {
+	i := (&Interaction{}).Times(2)
+	if *i.MinCalls != 2 || *i.MaxCalls != 2 {
+		t.Fatalf("Expected exactly 2 calls but got %d to %d", *i.MinCalls, *i.MaxCalls)
+	}
+
+	i = (&Interaction{}).AtMost(3)
+	if *i.MinCalls != 0 || *i.MaxCalls != 3 {
+		t.Fatalf("Expected 0 to 3 calls but got %d to %d", *i.MinCalls, *i.MaxCalls)
+	}
+
+	i = (&Interaction{}).AtLeast(1).AtMost(3)
+	if *i.MinCalls != 1 || *i.MaxCalls != 3 {
+		t.Fatalf("Expected 1 to 3 calls but got %d to %d", *i.MinCalls, *i.MaxCalls)
+	}
+
+	body, _ := json.Marshal(&Interaction{})
+	if string(body) != `{"request":{"method":"","path":null},"response":{"status":0},"description":""}` {
+		t.Fatalf("Expected no call counts by default but got %s", body)
+	}
+}
+
+func TestInteraction_InSequence(t *testing.T) {
+	i := (&Interaction{}).InSequence("polling")
+	if i.Sequence != "polling" {
+		t.Fatalf("Expected sequence 'polling' but got '%s'", i.Sequence)
+	}
+}
+
+func TestInteraction_After(t *testing.T) {
+	i := (&Interaction{}).After("log in").After("get token")
+	if !reflect.DeepEqual(i.PrecededBy, []string{"log in", "get token"}) {
+		t.Fatalf("Expected to be preceded by 'log in' and 'get token' but got %v", i.PrecededBy)
+	}
+}
+
+func TestInteraction_WillRespondWithDelay(t *testing.T) {
+	i := (&Interaction{}).WillRespondWithDelay(2 * time.Second)
+	if i.Delay != 2*time.Second {
+		t.Fatalf("Expected a delay of 2s but got %v", i.Delay)
+	}
+}
+
+func TestInteraction_V4(t *testing.T) {
+	i := (&Interaction{}).
+		WithComment("See the *users* API").
+		WithComment("Added for the profile page").
+		WithTestName("TestUsers").
+		AsPending()
+
+	if !reflect.DeepEqual(i.Comments, []string{"See the *users* API", "Added for the profile page"}) {
+		t.Fatalf("Expected 2 comments but got %v", i.Comments)
+	}
+	if i.TestName != "TestUsers" {
+		t.Fatalf("Expected the test name 'TestUsers' but got %q", i.TestName)
+	}
+	if !i.Pending {
+		t.Fatalf("Expected the interaction to be pending")
+	}
+}
+
 func TestInteraction_isStringLikeObject(t *testing.T) {
 	testCases := map[string]bool{
 		"somestring":    false,
@@ -1,11 +1,14 @@
 package dsl
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
 	"reflect"
 	"regexp"
+	"regexp/syntax"
+	"sort"
 	"strings"
 	"time"
 )
@@ -19,12 +22,16 @@ const (
 	timestamp   = `^([\+-]?\d{4}(?!\d{2}\b))((-?)((0[1-9]|1[0-2])(\3([12]\d|0[1-9]|3[01]))?|W([0-4]\d|5[0-2])(-?[1-7])?|(00[1-9]|0[1-9]\d|[12]\d{2}|3([0-5]\d|6[1-6])))([T\s]((([01]\d|2[0-3])((:?)[0-5]\d)?|24\:?00)([\.,]\d+(?!:))?)?(\17[0-5]\d([\.,]\d+)?)?([zZ]|([\+-])([01]\d|2[0-3]):?([0-5]\d)?)?)?)?$`
 	date        = `^([\+-]?\d{4}(?!\d{2}\b))((-?)((0[1-9]|1[0-2])(\3([12]\d|0[1-9]|3[01]))?|W([0-4]\d|5[0-2])(-?[1-7])?|(00[1-9]|0[1-9]\d|[12]\d{2}|3([0-5]\d|6[1-6])))?)`
 	timeRegex   = `^(T\d\d:\d\d(:\d\d)?(\.\d+)?(([+-]\d\d:\d\d)|Z)?)?$`
+	semver      = `^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`
 )
 
 var timeExample = time.Date(2000, 2, 1, 12, 30, 0, 0, time.UTC)
 
 var fullRegex = regexp.MustCompile(`regex=(.*)$`)
 var exampleRegex = regexp.MustCompile(`^example=(.*)`)
+var uuidRegex = regexp.MustCompile("^" + uuid + "$")
+var timeType = reflect.TypeOf(time.Time{})
+var jsonIdentifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 
 type eachLike struct {
 	Contents interface{} `json:"contents"`
@@ -98,6 +105,23 @@ type termMatcher struct {
 	Regex interface{} `json:"s"`
 }
 
+// checkRegex panics if the regular expression of a matcher is invalid, or
+// its example doesn't match it, rather than the pact failing to verify
+func checkRegex(example string, regex string) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		// Go doesn't support every Ruby regular expression, e.g. lookaheads
+		if e, ok := err.(*syntax.Error); ok && (e.Code == syntax.ErrInvalidPerlOp || e.Code == syntax.ErrInvalidEscape) {
+			log.Printf("[WARN] unable to check the example %q against /%s/: %v", example, regex, err)
+			return
+		}
+		panic(fmt.Sprintf("match: invalid regular expression /%s/: %v", regex, err))
+	}
+	if !re.MatchString(example) {
+		panic(fmt.Sprintf("match: the example %q doesn't match /%s/", example, regex))
+	}
+}
+
 // EachLike specifies that a given element in a JSON body can be repeated
 // "minRequired" times. Number needs to be 1 or greater
 func EachLike(content interface{}, minRequired int) Matcher {
@@ -116,8 +140,11 @@ func Like(content interface{}) Matcher {
 }
 
 // Term specifies that the matching should generate a value
-// and also match using a regular expression.
+// and also match using a regular expression. Panics if the regular
+// expression is invalid, or the generated value doesn't match it.
 func Term(generate string, matcher string) Matcher {
+	checkRegex(generate, matcher)
+
 	return term{
 		Data: termData{
 			Generate: generate,
@@ -140,8 +167,15 @@ func Identifier() Matcher {
 	return Like(42)
 }
 
-// Integer defines a matcher that accepts ints. Identical to Identifier.
-var Integer = Identifier
+// Integer defines a matcher that accepts integers, rejecting decimals such as
+// 10.5. It's written as a version 3 integer matcher when supported, and
+// otherwise accepts any number, as Identifier does.
+func Integer() Matcher {
+	return v3Matcher{
+		Type:  "integer",
+		Value: 42,
+	}
+}
 
 // IPAddress defines a matcher that accepts valid IPv4 addresses.
 func IPAddress() Matcher {
@@ -156,9 +190,14 @@ func IPv6Address() Matcher {
 	return Regex("::ffff:192.0.2.128", ipAddress)
 }
 
-// Decimal defines a matcher that accepts any decimal value.
+// Decimal defines a matcher that accepts decimal values, rejecting integers
+// such as 10. It's written as a version 3 decimal matcher when supported, and
+// otherwise accepts any number.
 func Decimal() Matcher {
-	return Like(42.0)
+	return v3Matcher{
+		Type:  "decimal",
+		Value: 42.5,
+	}
 }
 
 // Timestamp matches a pattern corresponding to the ISO_DATETIME_FORMAT, which
@@ -180,13 +219,66 @@ func Time() Matcher {
 }
 
 // UUID defines a matcher that accepts UUIDs. Produces a v4 UUID as the example.
+// Providers generate a random UUID in their requests, with version 3 of the
+// specification or later.
 func UUID() Matcher {
-	return Regex("fc763eba-0905-41c5-a27f-3934ab26786c", uuid)
+	return UUIDWithExample("fc763eba-0905-41c5-a27f-3934ab26786c")
+}
+
+// UUIDWithExample defines a matcher that accepts UUIDs, as UUID does, with
+// the given example. Panics if the example isn't a lower case UUID.
+func UUIDWithExample(example string) Matcher {
+	if !uuidRegex.MatchString(example) {
+		panic(fmt.Sprintf("match: %q is not a UUID", example))
+	}
+
+	return v3Matcher{
+		Type:       "regex",
+		Value:      example,
+		Attributes: map[string]interface{}{"regex": uuid},
+		Generator:  "Uuid",
+	}
 }
 
 // Regex is a more appropriately named alias for the "Term" matcher
 var Regex = Term
 
+// Link is a link of a Link header, e.g. the next page of a collection
+type Link struct {
+	// URL of the link, which is the example of any URL, e.g. "/orders?page=2"
+	URL string
+
+	// Rel is the relation type of the link, e.g. "next"
+	Rel string
+}
+
+// LinkHeader defines a matcher that accepts Link headers with links of the
+// relation types, in order, to any URLs, e.g. for pagination:
+//
+//	"Link": LinkHeader(Link{URL: "/orders?page=2", Rel: "next"}, Link{URL: "/orders?page=9", Rel: "last"})
+//
+// matches `</orders?page=3>; rel="next", </orders?page=9>; rel="last"`.
+func LinkHeader(links ...Link) Matcher {
+	if len(links) == 0 {
+		panic("match: a Link header must have a link")
+	}
+
+	examples := make([]string, len(links))
+	patterns := make([]string, len(links))
+	for i, link := range links {
+		examples[i] = fmt.Sprintf(`<%s>; rel="%s"`, link.URL, link.Rel)
+		patterns[i] = fmt.Sprintf(`<[^>]*>; ?rel="?%s"?`, regexp.QuoteMeta(link.Rel))
+	}
+	return Term(strings.Join(examples, ", "), "^"+strings.Join(patterns, ", ?")+"$")
+}
+
+// ContentDisposition defines a matcher that accepts Content-Disposition
+// headers of the disposition type, e.g. "attachment" or "inline", with any
+// filename, using the filename given in the example.
+func ContentDisposition(dispositionType string, filename string) Matcher {
+	return Term(fmt.Sprintf(`%s; filename="%s"`, dispositionType, filename), fmt.Sprintf(`^%s; ?filename=("[^"]*"|[^;\s]+)$`, regexp.QuoteMeta(dispositionType)))
+}
+
 // Matcher allows various implementations such String or StructMatcher
 // to be provided in when matching with the DSL
 // We use the strategy outlined at http://www.jerf.org/iri/post/2917
@@ -339,6 +431,176 @@ func match(srcType reflect.Type, params params) Matcher {
 	}
 }
 
+// MatchV3 recursively traverses the provided type and outputs a matcher for
+// it, as Match does, using the matchers of version 3 of the specification
+// where the type calls for them:
+//
+//	integers   Integer, e.g. `pact:"example=42"`
+//	floats     Decimal, e.g. `pact:"example=19.99"`
+//	bools      Boolean, e.g. `pact:"example=true"`
+//	time.Time  DateTime in time.RFC3339, or `pact:"format=2006-01-02"`
+//	pointers   Nullable, matching the type they point to or null
+//	maps       EachKeyLike, with "key" as the example key
+//
+// Strings, slices and nested structs are matched as Match matches them, with
+// the same tags. Requires version 3 of the specification, unless the type has
+// no time.Time, pointer or map fields, as Integer, Decimal and Boolean fall
+// back to matching the type in version 2.
+func MatchV3(src interface{}) Matcher {
+	srcType := reflect.TypeOf(src)
+	if srcType.Kind() == reflect.Ptr {
+		srcType = srcType.Elem()
+	}
+	return matchV3(srcType, getDefaults())
+}
+
+// matchV3 recursively traverses the provided type and outputs a matcher for
+// it using the matchers of version 3 of the specification
+func matchV3(srcType reflect.Type, params params) Matcher {
+	if srcType == timeType {
+		if params.time.format == "" {
+			return DateTime(timeExample, time.RFC3339)
+		}
+		return DateTime(timeExample, params.time.format)
+	}
+
+	switch kind := srcType.Kind(); kind {
+	case reflect.Ptr:
+		return Nullable(matchV3(srcType.Elem(), params))
+	case reflect.Slice, reflect.Array:
+		return EachLike(matchV3(srcType.Elem(), getDefaults()), params.slice.min)
+	case reflect.Map:
+		if srcType.Key().Kind() != reflect.String {
+			panic(fmt.Sprintf("match: unhandled map key type: %v", srcType.Key()))
+		}
+		return EachKeyLike("key", matchV3(srcType.Elem(), getDefaults()))
+	case reflect.Struct:
+		result := StructMatcher{}
+
+		for i := 0; i < srcType.NumField(); i++ {
+			field := srcType.Field(i)
+			fieldName := getJsonFieldName(field)
+			if fieldName == "" {
+				continue
+			}
+			fieldType := field.Type
+			for fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			result[fieldName] = matchV3(field.Type, pluckParams(fieldType, field.Tag.Get("pact")))
+		}
+		return result
+	case reflect.Bool:
+		return Boolean(!params.boolean.defined || params.boolean.value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if params.number.integer != 0 {
+			return v3Matcher{Type: "integer", Value: params.number.integer}
+		}
+		return Integer()
+	case reflect.Float32, reflect.Float64:
+		if params.number.float != 0 {
+			return v3Matcher{Type: "decimal", Value: params.number.float}
+		}
+		return Decimal()
+	default:
+		return match(srcType, params)
+	}
+}
+
+// LikeJSON returns a matcher of a JSON example document, e.g. a captured
+// response, matching every value by type. Arrays must have at least one
+// element like their first one, as EachLike does, and empty arrays and nulls
+// must be empty or null. The example is JSON text, as a string or []byte, or
+// a value written as JSON, e.g. a map.
+//
+// Overrides replace the matchers of values at their paths, written as in
+// the matching rules of pacts, e.g. "$.id" or "$.items[*].sku" for each
+// element of an array. They may be matchers, or values to match exactly:
+//
+//	LikeJSON(captured, map[string]interface{}{
+//		"$.id":           UUID(),
+//		"$.items[*].sku": Regex("ABC-123", `^[A-Z]+-\d+$`),
+//		"$.currency":     "EUR",
+//	})
+//
+// Panics if the example isn't JSON, or an override has no value at its path.
+func LikeJSON(example interface{}, overrides map[string]interface{}) Matcher {
+	var content []byte
+	switch e := example.(type) {
+	case string:
+		content = []byte(e)
+	case []byte:
+		content = e
+	default:
+		var err error
+		if content, err = json.Marshal(e); err != nil {
+			panic(fmt.Sprintf("match: unable to write the example as JSON: %v", err))
+		}
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		panic(fmt.Sprintf("match: invalid JSON example: %v", err))
+	}
+
+	unused := map[string]bool{}
+	for path := range overrides {
+		unused[path] = true
+	}
+	matcher := likeJSON("$", value, overrides, unused)
+	if len(unused) > 0 {
+		paths := make([]string, 0, len(unused))
+		for path := range unused {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		panic(fmt.Sprintf("match: no values of the example at the override paths %s", strings.Join(paths, ", ")))
+	}
+
+	if m, ok := matcher.(Matcher); ok {
+		return m
+	}
+	return Like(matcher)
+}
+
+// likeJSON returns the matcher of a decoded JSON value at path, recording
+// the overrides used
+func likeJSON(path string, value interface{}, overrides map[string]interface{}, unused map[string]bool) interface{} {
+	if override, ok := overrides[path]; ok {
+		delete(unused, path)
+		return override
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := StructMatcher{}
+		for k, e := range v {
+			result[k] = likeJSON(jsonFieldPath(path, k), e, overrides, unused)
+		}
+		return result
+	case []interface{}:
+		if len(v) == 0 {
+			return v
+		}
+		return EachLike(likeJSON(path+"[*]", v[0], overrides, unused), 1)
+	case nil:
+		return nil
+	}
+	return Like(value)
+}
+
+// jsonFieldPath returns the path of a field of the object at path, as written
+// in the matching rules of pacts
+func jsonFieldPath(path string, field string) string {
+	if jsonIdentifier.MatchString(field) {
+		return path + "." + field
+	}
+	return path + "['" + strings.Replace(field, "'", `\'`, -1) + "']"
+}
+
 // getJsonFieldName retrieves the name for a JSON field as
 // https://golang.org/pkg/encoding/json/#Marshal would do.
 func getJsonFieldName(field reflect.StructField) string {
@@ -365,6 +627,7 @@ type params struct {
 	str     stringParams
 	number  numberParams
 	boolean boolParams
+	time    timeParams
 }
 
 type numberParams struct {
@@ -376,6 +639,10 @@ type boolParams struct {
 	defined bool
 }
 
+type timeParams struct {
+	format string
+}
+
 type sliceParams struct {
 	min int
 }
@@ -398,12 +665,21 @@ func getDefaults() params {
 // Supported Tag Formats
 // Minimum Slice Size: `pact:"min=2"`
 // String RegEx:       `pact:"example=2000-01-01,regex=^\\d{4}-\\d{2}-\\d{2}$"`
+// Time Format:        `pact:"format=2006-01-02"`
 func pluckParams(srcType reflect.Type, pactTag string) params {
 	params := getDefaults()
 	if pactTag == "" {
 		return params
 	}
 
+	if srcType == timeType {
+		if !strings.HasPrefix(pactTag, "format=") || pactTag == "format=" {
+			triggerInvalidPactTagPanic(pactTag, fmt.Errorf("invalid format: expected format=<layout>"))
+		}
+		params.time.format = strings.TrimPrefix(pactTag, "format=")
+		return params
+	}
+
 	switch kind := srcType.Kind(); kind {
 	case reflect.Bool:
 		if _, err := fmt.Sscanf(pactTag, "example=%t", &params.boolean.value); err != nil {
@@ -7,6 +7,7 @@ import (
 	"log"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -39,6 +40,74 @@ func TestMatcher_TermGetValue(t *testing.T) {
 	}
 }
 
+func TestMatcher_TermValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		generate  string
+		matcher   string
+		wantPanic string
+	}{
+		{name: "matching example", generate: "admin", matcher: "admin|user|guest"},
+		{name: "unsupported by Go", generate: "2000-01-01", matcher: `^\d{4}(?!\d)`},
+		{name: "invalid regex", generate: "admin", matcher: "(admin|user", wantPanic: "match: invalid regular expression /(admin|user/"},
+		{name: "example not matching", generate: "root", matcher: "^(admin|user)$", wantPanic: `match: the example "root" doesn't match /^(admin|user)$/`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				rec, _ := recover().(string)
+				if !strings.HasPrefix(rec, tt.wantPanic) || (tt.wantPanic == "") != (rec == "") {
+					t.Errorf("Term() - '%s': panic = %q, want %q", tt.name, rec, tt.wantPanic)
+				}
+			}()
+			Term(tt.generate, tt.matcher)
+		})
+	}
+}
+
+func TestMatcher_HeaderMatchers(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		example string
+		matches []string
+		rejects []string
+	}{
+		{
+			name:    "Link",
+			matcher: LinkHeader(Link{URL: "/orders?page=2", Rel: "next"}, Link{URL: "/orders?page=9", Rel: "last"}),
+			example: `</orders?page=2>; rel="next", </orders?page=9>; rel="last"`,
+			matches: []string{`<https://example.com/orders?page=3>; rel="next", <https://example.com/orders?page=3>; rel="last"`, `</a>;rel=next,</b>;rel=last`},
+			rejects: []string{`</orders?page=2>; rel="next"`, `</orders?page=9>; rel="last", </orders?page=2>; rel="next"`},
+		},
+		{
+			name:    "Content-Disposition",
+			matcher: ContentDisposition("attachment", "report.csv"),
+			example: `attachment; filename="report.csv"`,
+			matches: []string{`attachment; filename="orders 2020.csv"`, `attachment;filename=orders.csv`},
+			rejects: []string{`inline; filename="report.csv"`, `attachment`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matcher.GetValue(); got != tt.example {
+				t.Errorf("%s - example = %q, want %q", tt.name, got, tt.example)
+			}
+			re := regexp.MustCompile(tt.matcher.(term).Data.Matcher.Regex.(string))
+			for _, header := range tt.matches {
+				if !re.MatchString(header) {
+					t.Errorf("%s - expected %q to match", tt.name, header)
+				}
+			}
+			for _, header := range tt.rejects {
+				if re.MatchString(header) {
+					t.Errorf("%s - expected %q not to match", tt.name, header)
+				}
+			}
+		})
+	}
+}
+
 func TestMatcher_LikeBasicString(t *testing.T) {
 	expected := formatJSON(`
 		{
@@ -356,6 +425,13 @@ func getMatcherValue(m interface{}) interface{} {
 		return likeValue.Contents
 	}
 
+	// try version 3 matchers
+	v3Value := map[string]interface{}{}
+	err = json.Unmarshal([]byte(mString), &v3Value)
+	if _, ok := v3Value["pact:matcher:type"]; err == nil && ok {
+		return v3Value["value"]
+	}
+
 	// try term
 	termValue := &term{}
 	err = json.Unmarshal([]byte(mString), termValue)
@@ -827,6 +903,73 @@ func TestMatch(t *testing.T) {
 	}
 }
 
+func TestLikeJSON(t *testing.T) {
+	captured := `{
+		"id": "fc763eba-0905-41c5-a27f-3934ab26786c",
+		"total": 19.98,
+		"paid": true,
+		"cancelledAt": null,
+		"items": [{"sku": "ABC-123", "quantity": 2}],
+		"tags": [],
+		"shipping address": {"city": "Leeds"}
+	}`
+	want := StructMatcher{
+		"id":          UUID(),
+		"total":       Like(json.Number("19.98")),
+		"paid":        Like(true),
+		"cancelledAt": nil,
+		"items": EachLike(StructMatcher{
+			"sku":      "ABC-123",
+			"quantity": Like(json.Number("2")),
+		}, 1),
+		"tags":             []interface{}{},
+		"shipping address": StructMatcher{"city": Like("Leeds")},
+	}
+
+	overrides := map[string]interface{}{"$.id": UUID(), "$.items[*].sku": "ABC-123"}
+	for _, example := range []interface{}{captured, []byte(captured)} {
+		if got := LikeJSON(example, overrides); !reflect.DeepEqual(got, want) {
+			t.Errorf("LikeJSON() = %v, want %v", got, want)
+		}
+	}
+
+	rules, err := verifyRequestBody(t, 2, LikeJSON(captured, nil), `{"id": "x", "total": 1, "paid": false, "cancelledAt": null, "items": [{"sku": "Y", "quantity": 1}, {"sku": "Z", "quantity": 3}], "tags": [], "shipping address": {"city": "York"}}`)
+	if err != nil {
+		t.Errorf("LikeJSON() - verification: %v", err)
+	}
+	if rule := rules["$.body.items[*].quantity"]; !reflect.DeepEqual(rule, map[string]interface{}{"match": "type"}) {
+		t.Errorf("LikeJSON() - rule of $.body.items[*].quantity = %v", rule)
+	}
+
+	if got := LikeJSON(map[string]interface{}{"id": 1}, nil); !reflect.DeepEqual(got, StructMatcher{"id": Like(json.Number("1"))}) {
+		t.Errorf("LikeJSON() of a map = %v", got)
+	}
+	if got := LikeJSON(`[1, 2]`, nil); !reflect.DeepEqual(got, EachLike(Like(json.Number("1")), 1)) {
+		t.Errorf("LikeJSON() of an array = %v", got)
+	}
+	if got := LikeJSON(`{"shipping address": {"city": "Leeds"}}`, map[string]interface{}{"$['shipping address'].city": Like("York")}); !reflect.DeepEqual(got, StructMatcher{"shipping address": StructMatcher{"city": Like("York")}}) {
+		t.Errorf("LikeJSON() with an override of a quoted key = %v", got)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		example   interface{}
+		overrides map[string]interface{}
+	}{
+		{name: "invalid JSON", example: `{"id":`},
+		{name: "unknown override path", example: `{"id": 1}`, overrides: map[string]interface{}{"$.ID": Integer()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("LikeJSON() - '%s': expected a panic", tt.name)
+				}
+			}()
+			LikeJSON(tt.example, tt.overrides)
+		})
+	}
+}
+
 func Test_pluckParams(t *testing.T) {
 	type args struct {
 		srcType reflect.Type
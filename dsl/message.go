@@ -47,6 +47,16 @@ type Message struct {
 	Type interface{}
 
 	Args []string `json:"-"`
+
+	// Comments in markdown and the name of the test, set with WithComment and
+	// WithTestName. Only written to version 4 pacts.
+	Comments []string `json:"-"`
+	TestName string   `json:"-"`
+
+	// Pending messages don't fail the verification of the provider until
+	// they're implemented, set with AsPending. Only written to version 4
+	// pacts.
+	Pending bool `json:"-"`
 }
 
 // State specifies how the system should be configured when
@@ -87,6 +97,27 @@ func (p *Message) WithContent(content interface{}) *Message {
 	return p
 }
 
+// WithComment adds a comment, in markdown, to the message in the pact.
+// Optional.
+func (p *Message) WithComment(comment string) *Message {
+	p.Comments = append(p.Comments, comment)
+	return p
+}
+
+// WithTestName records the name of the test that defines the message.
+// Optional.
+func (p *Message) WithTestName(name string) *Message {
+	p.TestName = name
+	return p
+}
+
+// AsPending marks the message as pending, so that it doesn't fail the
+// verification of the provider until it has been implemented. Optional.
+func (p *Message) AsPending() *Message {
+	p.Pending = true
+	return p
+}
+
 // AsType specifies that the content sent through to the
 // consumer handler should be sent as the given type
 func (p *Message) AsType(t interface{}) *Message {
@@ -2,12 +2,16 @@ package dsl
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
+
+	"github.com/pact-foundation/pact-go/mockserver"
 )
 
 // MockService is the HTTP interface to setup the Pact Mock Service
@@ -30,17 +34,36 @@ type MockService struct {
 	// are split over multiple files and instantiations of a Mock Server
 	// See https://github.com/pact-foundation/pact-ruby/blob/master/documentation/configuration.md#pactfile_write_mode
 	PactFileWriteMode string
+
+	// TLSConfig is used to call a Mock Service served over https
+	TLSConfig *tls.Config
 }
 
 // call sends a message to the Pact service
 func (m *MockService) call(method string, url string, content interface{}) error {
+	status, _, body, err := m.send(method, url, content, "")
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		return errors.New(string(body))
+	}
+	return nil
+}
+
+// send sends a message to the Pact service, returning the status, content
+// type and body of the response
+func (m *MockService) send(method string, url string, content interface{}, accept string) (int, string, []byte, error) {
 	body, err := json.Marshal(content)
 	if err != nil {
 		log.Println("[ERROR]", err)
-		return err
+		return 0, "", nil, err
 	}
 
 	client := &http.Client{}
+	if m.TLSConfig != nil {
+		client.Transport = &http.Transport{TLSClientConfig: m.TLSConfig}
+	}
 	var req *http.Request
 	if method == "POST" {
 		req, err = http.NewRequest(method, url, bytes.NewReader(body))
@@ -48,23 +71,23 @@ func (m *MockService) call(method string, url string, content interface{}) error
 		req, err = http.NewRequest(method, url, nil)
 	}
 	if err != nil {
-		return err
+		return 0, "", nil, err
 	}
 
 	req.Header.Set("X-Pact-Mock-Service", "true")
 	req.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
 
 	res, err := client.Do(req)
 	if err != nil {
-		return err
+		return 0, "", nil, err
 	}
 
 	responseBody, err := ioutil.ReadAll(res.Body)
 	res.Body.Close()
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return errors.New(string(responseBody))
-	}
-	return err
+	return res.StatusCode, res.Header.Get("Content-Type"), responseBody, err
 }
 
 // DeleteInteractions removes any previous Mock Service Interactions.
@@ -76,16 +99,48 @@ func (m *MockService) DeleteInteractions() error {
 
 // AddInteraction adds a new Pact Mock Service interaction.
 func (m *MockService) AddInteraction(interaction *Interaction) error {
+	return m.addInteraction(interaction)
+}
+
+// addInteraction adds an interaction in any form that encodes as one
+func (m *MockService) addInteraction(interaction interface{}) error {
 	log.Println("[DEBUG] mock service add interaction")
 	url := fmt.Sprintf("%s/interactions", m.BaseURL)
 	return m.call("POST", url, interaction)
 }
 
-// Verify confirms that all interactions were called.
+// ForbidRequest adds a request which must not be received. Only supported
+// by the native mock server.
+func (m *MockService) ForbidRequest(forbidden *ForbiddenRequest) error {
+	log.Println("[DEBUG] mock service forbid request")
+	url := fmt.Sprintf("%s/interactions/forbidden", m.BaseURL)
+	return m.call("POST", url, forbidden)
+}
+
+// Verify confirms that all interactions were called, returning a
+// *mockserver.VerificationError describing the mismatches from the native
+// mock server.
 func (m *MockService) Verify() error {
 	log.Println("[DEBUG] mock service verify")
 	url := fmt.Sprintf("%s/interactions/verification", m.BaseURL)
-	return m.call("GET", url, nil)
+
+	status, contentType, body, err := m.send("GET", url, nil, "application/json")
+	if err != nil {
+		return err
+	}
+	if status >= 200 && status < 300 {
+		return nil
+	}
+
+	// The native mock server describes the mismatches, for test helpers to
+	// report them. The Pact Mock Service describes them as text.
+	if strings.HasPrefix(contentType, "application/json") {
+		verification := &mockserver.VerificationError{}
+		if json.Unmarshal(body, verification) == nil {
+			return verification
+		}
+	}
+	return errors.New(string(body))
 }
 
 // WritePact writes the pact file to disk.
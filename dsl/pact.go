@@ -5,6 +5,9 @@ collaboration test cases, and Provider contract test verification.
 package dsl
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,12 +19,16 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/logutils"
 	"github.com/pact-foundation/pact-go/install"
+	"github.com/pact-foundation/pact-go/mockserver"
 	"github.com/pact-foundation/pact-go/proxy"
 	"github.com/pact-foundation/pact-go/types"
 	"github.com/pact-foundation/pact-go/utils"
@@ -44,6 +51,10 @@ type Pact struct {
 	// Interactions contains all of the Mock Service Interactions to be setup.
 	Interactions []*Interaction
 
+	// ForbiddenRequests must not be received during the test, set with
+	// ForbidRequest.
+	ForbiddenRequests []*ForbiddenRequest
+
 	// MessageInteractions contains all of the Message based interactions to be setup.
 	MessageInteractions []*Message
 
@@ -66,11 +77,20 @@ type Pact struct {
 	// "overwrite" will always truncate and replace the pact after each run
 	// "merge" will append to the pact file, which is useful if your tests
 	// are split over multiple files and instantiations of a Mock Server
+	// The PACT_FILE_WRITE_MODE environment variable takes precedence, e.g. so
+	// that CI can force clean pacts without stale interactions.
+	// When using the NativeMockServer, only the first "overwrite" of a pact
+	// in a test run replaces it and later writes merge, so that the tests
+	// contributing to a pact don't replace each other's interactions. Writes
+	// are locked, so that test binaries run in parallel can merge safely.
 	// See https://github.com/pact-foundation/pact-ruby/blob/master/documentation/configuration.md#pactfile_write_mode
 	PactFileWriteMode string
 
-	// Specify which version of the Pact Specification should be used (1 or 2).
-	// Defaults to 2.
+	// Specify which version of the Pact Specification should be used (1 to 4).
+	// Defaults to 2. Interactions using matchers or generators the version
+	// doesn't support are rejected, rather than written to the pact.
+	// Version 4 writes HTTP interactions and messages to the same pact, with
+	// their comments and pending flags, and requires the NativeMockServer.
 	SpecificationVersion int
 
 	// Host is the address of the Mock and Verification Service runs on
@@ -87,6 +107,20 @@ type Pact struct {
 	// Example "1234", "12324,5667", "1234-5667"
 	AllowedMockServerPorts string
 
+	// MockServerPort pins the mock server to a port, for clients under test
+	// configured with a static URL. Setup fails to start the mock server if
+	// the port is unavailable. Takes precedence over AllowedMockServerPorts.
+	MockServerPort int
+
+	// MockServerPortFile is written with the port of the mock server once it
+	// has started, and removed by Teardown
+	MockServerPortFile string
+
+	// MockServerPortEnv names an environment variable set to the port of the
+	// mock server once it has started, and unset by Teardown. The environment
+	// is shared by the whole process, so avoid it for parallel tests.
+	MockServerPortEnv string
+
 	// DisableToolValidityCheck prevents CLI version checking - use this carefully!
 	// The ideal situation is to check the tool installation with  before running
 	// the tests, which should speed up large test suites significantly
@@ -97,8 +131,41 @@ type Pact struct {
 	// Defaults to 10s
 	ClientTimeout time.Duration
 
+	// NativeMockServer runs the consumer mock server in process, instead of
+	// the Pact Mock Service of the Pact CLI tools, which then need not be
+	// installed for consumer tests.
+	NativeMockServer bool
+
+	// MockServerTLS serves the mock server over https, for clients that refuse
+	// plain http. The native mock server's certificate is issued by a
+	// generated CA, available from ServerCertificatePEM and trusted by
+	// ServerClient. The Pact Mock Service uses a self-signed certificate.
+	MockServerTLS bool
+
+	// MockServerCORS enables cross-origin requests to the mock server, for
+	// consumers tested from a browser. Preflight requests are answered unless
+	// an interaction expects them. The Pact Mock Service only supports the
+	// permissive zero value.
+	MockServerCORS *mockserver.CORS
+
 	// Check if CLI tools are up to date
 	toolValidityCheck bool
+
+	// Native mock server, when NativeMockServer is set
+	mockServer *mockserver.Server
+
+	// Requests that didn't match an interaction in the last test verified
+	unmatchedRequests []*mockserver.ReceivedRequest
+
+	// Port reserved for the mock server, released by Teardown
+	reservedPort int
+
+	// Guards the defaults applied by Setup, so that verifications can run
+	// in parallel against the same Pact, and the registered state handlers.
+	mu sync.Mutex
+
+	// State handlers registered with AddStateHandler
+	stateHandlers types.StateHandlers
 }
 
 // AddMessage creates a new asynchronous consumer expectation
@@ -121,10 +188,78 @@ func (p *Pact) AddInteraction() *Interaction {
 	return i
 }
 
+// AddStateHandler registers the state handler for a provider state, for
+// every provider verification by this Pact. Handlers can be registered by
+// the packages owning each part of the provider, e.g. from TestMain or init
+// functions, and registration is safe from multiple goroutines. It is an
+// error to register a state twice.
+func (p *Pact) AddStateHandler(state string, handler types.StateHandler) error {
+	if handler == nil {
+		return fmt.Errorf("state handler for '%s' is nil", state)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.stateHandlers[state]; ok {
+		return fmt.Errorf("state handler for '%s' is already registered", state)
+	}
+
+	if p.stateHandlers == nil {
+		p.stateHandlers = types.StateHandlers{}
+	}
+	p.stateHandlers[state] = handler
+
+	return nil
+}
+
+// RegisteredStates returns the states with a handler registered by
+// AddStateHandler, in alphabetical order
+func (p *Pact) RegisteredStates() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	states := make([]string, 0, len(p.stateHandlers))
+	for state := range p.stateHandlers {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+
+	return states
+}
+
+// withStateHandlers adds the registered state handlers to those of the
+// verification request. A state may only have one handler.
+func (p *Pact) withStateHandlers(request types.VerifyRequest) (types.VerifyRequest, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.stateHandlers) == 0 {
+		return request, nil
+	}
+
+	handlers := make(types.StateHandlers, len(request.StateHandlers)+len(p.stateHandlers))
+	for state, handler := range request.StateHandlers {
+		handlers[state] = handler
+	}
+	for state, handler := range p.stateHandlers {
+		if _, ok := handlers[state]; ok {
+			return request, fmt.Errorf("state handler for '%s' is registered and given in StateHandlers", state)
+		}
+		handlers[state] = handler
+	}
+	request.StateHandlers = handlers
+
+	return request, nil
+}
+
 // Setup starts the Pact Mock Server. This is usually called before each test
 // suite begins. AddInteraction() will automatically call this if no Mock Server
 // has been started.
 func (p *Pact) Setup(startMockServer bool) *Pact {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.setupLogging()
 	log.Println("[DEBUG] pact setup")
 	dir, _ := os.Getwd()
@@ -133,7 +268,7 @@ func (p *Pact) Setup(startMockServer bool) *Pact {
 		p.Network = "tcp"
 	}
 
-	if !p.toolValidityCheck && !(p.DisableToolValidityCheck || os.Getenv("PACT_DISABLE_TOOL_VALIDITY_CHECK") != "") {
+	if !p.toolValidityCheck && !(p.NativeMockServer && (startMockServer || p.SpecificationVersion >= 4)) && !(p.DisableToolValidityCheck || os.Getenv("PACT_DISABLE_TOOL_VALIDITY_CHECK") != "") {
 		checkCliCompatibility()
 		p.toolValidityCheck = true
 	}
@@ -164,23 +299,31 @@ func (p *Pact) Setup(startMockServer bool) *Pact {
 		p.pactClient = c
 	}
 
+	if mode := os.Getenv("PACT_FILE_WRITE_MODE"); mode != "" {
+		p.PactFileWriteMode = mode
+	}
 	if p.PactFileWriteMode == "" {
 		p.PactFileWriteMode = "overwrite"
 	}
 
-	// Need to predefine due to scoping
-	var port int
-	var perr error
-	if p.AllowedMockServerPorts != "" {
-		port, perr = utils.FindPortInRange(p.AllowedMockServerPorts)
-	} else {
-		port, perr = utils.GetFreePort()
-	}
-	if perr != nil {
-		log.Println("[ERROR] unable to find free port, mockserver will fail to start")
+	if p.NativeMockServer && p.Server == nil && startMockServer {
+		p.startNativeMockServer()
+		return p
 	}
 
 	if p.Server == nil && startMockServer {
+		// Reserve the port, so that parallel tests don't start their mock
+		// servers on the same one
+		port, err := utils.ReservePort(p.mockServerPorts())
+		if err != nil {
+			if p.MockServerPort != 0 {
+				log.Printf("[ERROR] unable to start the mock server on port %d: %v", p.MockServerPort, err)
+				return p
+			}
+			log.Println("[ERROR] unable to find free port, mockserver will fail to start")
+		}
+		p.reservedPort = port
+
 		log.Println("[DEBUG] starting mock service on port:", port)
 		args := []string{
 			"--pact-specification-version",
@@ -196,13 +339,227 @@ func (p *Pact) Setup(startMockServer bool) *Pact {
 			"--pact-file-write-mode",
 			p.PactFileWriteMode,
 		}
+		if p.MockServerTLS {
+			args = append(args, "--ssl")
+		}
+		if p.MockServerCORS != nil {
+			args = append(args, "--cors")
+		}
 
 		p.Server = p.pactClient.StartServer(args, port)
+		p.publishPort()
 	}
 
 	return p
 }
 
+// mockServerPorts returns the ports the mock server may be started on
+func (p *Pact) mockServerPorts() string {
+	if p.MockServerPort != 0 {
+		return strconv.Itoa(p.MockServerPort)
+	}
+	return p.AllowedMockServerPorts
+}
+
+// publishPort writes the port of the mock server to MockServerPortFile and
+// MockServerPortEnv, for clients under test that read it from their
+// configuration
+func (p *Pact) publishPort() {
+	if p.Server == nil {
+		return
+	}
+	port := strconv.Itoa(p.Server.Port)
+
+	if p.MockServerPortFile != "" {
+		if err := ioutil.WriteFile(p.MockServerPortFile, []byte(port), 0644); err != nil {
+			log.Println("[ERROR] unable to write the mock server port file:", err)
+		}
+	}
+	if p.MockServerPortEnv != "" {
+		os.Setenv(p.MockServerPortEnv, port)
+	}
+}
+
+// unpublishPort removes the port of a stopped mock server
+func (p *Pact) unpublishPort() {
+	if p.MockServerPortFile != "" {
+		os.Remove(p.MockServerPortFile)
+	}
+	if p.MockServerPortEnv != "" {
+		os.Unsetenv(p.MockServerPortEnv)
+	}
+}
+
+// SetupTest starts the mock server for a single test, like Setup(true), and
+// returns its teardown for the test to defer. If the test passes, the pact is
+// written before the mock server is stopped, so neither can be forgotten:
+//
+//	defer pact.SetupTest(t)()
+//
+// Each test owns its mock server, so use a Pact per test, including each
+// parallel subtest. PactFileWriteMode defaults to "merge", so that the tests
+// contributing to a pact don't overwrite each other's interactions.
+func (p *Pact) SetupTest(t *testing.T) func() {
+	t.Helper()
+
+	p.mu.Lock()
+	if p.PactFileWriteMode == "" {
+		p.PactFileWriteMode = "merge"
+	}
+	p.mu.Unlock()
+
+	p.Setup(true)
+	if p.Server == nil {
+		t.Fatal("unable to start the mock server")
+	}
+
+	return func() {
+		defer p.Teardown()
+
+		if t.Failed() {
+			log.Println("[DEBUG] test failed, not writing pact")
+			return
+		}
+		if err := p.WritePact(); err != nil {
+			t.Errorf("unable to write pact: %v", err)
+		}
+	}
+}
+
+// ForTest returns a Pact for a single test, with the configuration of p and
+// its own mock server started by SetupTest, and the teardown for the test to
+// defer. Parallel tests can share the configuration of a package level Pact,
+// without sharing its mock server.
+func (p *Pact) ForTest(t *testing.T) (*Pact, func()) {
+	t.Helper()
+
+	p.mu.Lock()
+	pact := &Pact{
+		pactClient:               p.pactClient,
+		Consumer:                 p.Consumer,
+		Provider:                 p.Provider,
+		LogLevel:                 p.LogLevel,
+		logFilter:                p.logFilter,
+		LogDir:                   p.LogDir,
+		PactDir:                  p.PactDir,
+		PactFileWriteMode:        p.PactFileWriteMode,
+		SpecificationVersion:     p.SpecificationVersion,
+		Host:                     p.Host,
+		Network:                  p.Network,
+		AllowedMockServerPorts:   p.AllowedMockServerPorts,
+		MockServerPort:           p.MockServerPort,
+		MockServerPortFile:       p.MockServerPortFile,
+		MockServerPortEnv:        p.MockServerPortEnv,
+		DisableToolValidityCheck: p.DisableToolValidityCheck,
+		ClientTimeout:            p.ClientTimeout,
+		NativeMockServer:         p.NativeMockServer,
+		MockServerTLS:            p.MockServerTLS,
+		MockServerCORS:           p.MockServerCORS,
+		toolValidityCheck:        p.toolValidityCheck,
+	}
+	p.mu.Unlock()
+
+	return pact, pact.SetupTest(t)
+}
+
+// ServerURL is the base URL of the mock server, e.g. "http://localhost:1234",
+// for the client under test. It is empty until the mock server is started.
+func (p *Pact) ServerURL() string {
+	if p.Server == nil {
+		return ""
+	}
+	scheme := "http"
+	if p.MockServerTLS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, p.Host, p.Server.Port)
+}
+
+// UnmatchedRequests returns the requests received by the native mock server
+// which didn't match an interaction, with their headers and bodies, during
+// the test in progress or else the last test verified. The Pact Mock Service
+// doesn't record them, so there are none without NativeMockServer.
+func (p *Pact) UnmatchedRequests() []*mockserver.ReceivedRequest {
+	if p.mockServer != nil {
+		if unmatched := p.mockServer.UnmatchedRequests(); len(unmatched) > 0 {
+			return unmatched
+		}
+	}
+	return p.unmatchedRequests
+}
+
+// ServerCertificatePEM is the PEM encoded CA of the native mock server's
+// certificate when MockServerTLS is set, for the client under test to trust
+func (p *Pact) ServerCertificatePEM() []byte {
+	if p.mockServer == nil {
+		return nil
+	}
+	return p.mockServer.CertificatePEM
+}
+
+// ServerClient returns an HTTP client for the mock server, which trusts the
+// certificate of the native mock server when MockServerTLS is set
+func (p *Pact) ServerClient() *http.Client {
+	if p.mockServer == nil {
+		return &http.Client{}
+	}
+	return p.mockServer.Client()
+}
+
+// serverTLSConfig configures the calls to the mock server's administration
+// API. The self-signed certificate of the Pact Mock Service can't be verified.
+func (p *Pact) serverTLSConfig() *tls.Config {
+	if !p.MockServerTLS {
+		return nil
+	}
+	if p.mockServer == nil {
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(p.mockServer.CertificatePEM)
+	return &tls.Config{RootCAs: pool}
+}
+
+// startNativeMockServer starts the native mock server on a free port
+func (p *Pact) startNativeMockServer() {
+	// Without allowed ports, the mock server listens on a port chosen by the
+	// kernel, which can't clash with another
+	var port int
+	var err error
+	if ports := p.mockServerPorts(); ports != "" {
+		if port, err = utils.ReservePort(ports); err != nil {
+			if p.MockServerPort != 0 {
+				log.Printf("[ERROR] unable to start the mock server on port %d: %v", p.MockServerPort, err)
+				return
+			}
+			log.Println("[ERROR] unable to find free port, mockserver will fail to start")
+		}
+		p.reservedPort = port
+	}
+
+	log.Println("[DEBUG] starting native mock server")
+	server, err := mockserver.Start(mockserver.Options{
+		Consumer:             p.Consumer,
+		Provider:             p.Provider,
+		PactDir:              p.PactDir,
+		SpecificationVersion: p.SpecificationVersion,
+		PactFileWriteMode:    p.PactFileWriteMode,
+		Host:                 p.Host,
+		Port:                 port,
+		TLS:                  p.MockServerTLS,
+		CORS:                 p.MockServerCORS,
+	})
+	if err != nil {
+		log.Println("[ERROR] unable to start native mock server:", err)
+		p.releasePort()
+		return
+	}
+
+	p.mockServer = server
+	p.Server = &types.MockServer{Port: server.Port}
+	p.publishPort()
+}
+
 // Configure logging
 func (p *Pact) setupLogging() {
 	if p.logFilter == nil {
@@ -214,7 +571,8 @@ func (p *Pact) setupLogging() {
 			MinLevel: logutils.LogLevel(p.LogLevel),
 			Writer:   os.Stderr,
 		}
-		log.SetOutput(p.logFilter)
+		logRedactor.SetWriter(p.logFilter)
+		log.SetOutput(logRedactor)
 	}
 	log.Println("[DEBUG] pact setup logging")
 }
@@ -223,6 +581,16 @@ func (p *Pact) setupLogging() {
 // of each test suite.
 func (p *Pact) Teardown() *Pact {
 	log.Println("[DEBUG] teardown")
+	if p.Server != nil {
+		p.unpublishPort()
+	}
+	if p.mockServer != nil {
+		if err := p.mockServer.Close(); err != nil {
+			log.Println("error:", err)
+		}
+		p.mockServer = nil
+		p.Server = nil
+	}
 	if p.Server != nil {
 		server, err := p.pactClient.StopServer(p.Server)
 
@@ -231,37 +599,68 @@ func (p *Pact) Teardown() *Pact {
 		}
 		p.Server = server
 	}
+	p.releasePort()
 	return p
 }
 
+// releasePort releases the port reserved for the mock server
+func (p *Pact) releasePort() {
+	if p.reservedPort != 0 {
+		utils.ReleasePort(p.reservedPort)
+		p.reservedPort = 0
+	}
+}
+
 // Verify runs the current test case against a Mock Service.
-// Will cleanup interactions between tests within a suite.
+// Will cleanup interactions between tests within a suite. With the native
+// mock server, a failed verification is a *mockserver.VerificationError.
 func (p *Pact) Verify(integrationTest func() error) error {
 	p.Setup(true)
 	log.Println("[DEBUG] pact verify")
-	var err error
 
 	// Check if we are verifying messages or if we actually have interactions
-	if len(p.Interactions) == 0 {
+	if len(p.Interactions) == 0 && len(p.ForbiddenRequests) == 0 {
 		return errors.New("there are no interactions to be verified")
 	}
 
+	contents, err := p.prepareInteractions()
+	if err != nil {
+		return err
+	}
+	if len(p.ForbiddenRequests) > 0 && !p.NativeMockServer {
+		return errors.New("forbidden requests require the NativeMockServer")
+	}
+
 	mockServer := &MockService{
-		BaseURL:  fmt.Sprintf("http://%s:%d", p.Host, p.Server.Port),
-		Consumer: p.Consumer,
-		Provider: p.Provider,
+		BaseURL:   p.ServerURL(),
+		Consumer:  p.Consumer,
+		Provider:  p.Provider,
+		TLSConfig: p.serverTLSConfig(),
 	}
 
 	// Cleanup all interactions
+	p.unmatchedRequests = nil
 	defer func(mockServer *MockService) {
 		log.Println("[DEBUG] clearing interactions")
 
+		if p.mockServer != nil {
+			p.unmatchedRequests = p.mockServer.UnmatchedRequests()
+		}
+
 		p.Interactions = make([]*Interaction, 0)
+		p.ForbiddenRequests = nil
 		err = mockServer.DeleteInteractions()
 	}(mockServer)
 
-	for _, interaction := range p.Interactions {
-		err = mockServer.AddInteraction(interaction)
+	for _, content := range contents {
+		err = mockServer.addInteraction(content)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, forbidden := range p.ForbiddenRequests {
+		err = mockServer.ForbidRequest(forbidden)
 		if err != nil {
 			return err
 		}
@@ -282,6 +681,57 @@ func (p *Pact) Verify(integrationTest func() error) error {
 	return err
 }
 
+// MockServerConfig describes the mock server a consumer test runs against,
+// to configure the client under test
+type MockServerConfig struct {
+	// Host and Port the mock server is listening on
+	Host string
+	Port int
+
+	// URL is the base URL of the mock server, e.g. "http://localhost:1234"
+	URL string
+
+	// TLSConfig trusts the mock server's certificate when MockServerTLS is
+	// set, and is nil otherwise
+	TLSConfig *tls.Config
+}
+
+// ExecuteTest runs a consumer test against the mock server: the interactions
+// are added, the test is run, the interactions are verified and, only if they
+// were, the pact is written. The mock server is started if it isn't running,
+// and left running for later tests; stop it with Teardown, e.g. from
+// TestMain, or use SetupTest to scope it to the test.
+func (p *Pact) ExecuteTest(t *testing.T, test func(MockServerConfig) error) error {
+	t.Helper()
+
+	err := p.executeTest(test)
+	if err != nil {
+		t.Errorf("ExecuteTest failed: %v", err)
+	}
+
+	return err
+}
+
+func (p *Pact) executeTest(test func(MockServerConfig) error) error {
+	p.Setup(true)
+	if p.Server == nil {
+		return errors.New("unable to start the mock server")
+	}
+
+	config := MockServerConfig{
+		Host:      p.Host,
+		Port:      p.Server.Port,
+		URL:       p.ServerURL(),
+		TLSConfig: p.serverTLSConfig(),
+	}
+
+	if err := p.Verify(func() error { return test(config) }); err != nil {
+		return err
+	}
+
+	return p.WritePact()
+}
+
 // WritePact should be called writes when all tests have been performed for a
 // given Consumer <-> Provider pair. It will write out the Pact to the
 // configured file.
@@ -289,25 +739,64 @@ func (p *Pact) WritePact() error {
 	p.Setup(true)
 	log.Println("[DEBUG] pact write Pact file")
 	mockServer := MockService{
-		BaseURL:           fmt.Sprintf("http://%s:%d", p.Host, p.Server.Port),
+		BaseURL:           p.ServerURL(),
 		Consumer:          p.Consumer,
 		Provider:          p.Provider,
 		PactFileWriteMode: p.PactFileWriteMode,
+		TLSConfig:         p.serverTLSConfig(),
 	}
 	err := mockServer.WritePact()
 	if err != nil {
 		return err
 	}
 
+	// The native mock server already writes canonical pacts
+	if !p.NativeMockServer {
+		path := filepath.Join(p.PactDir, mockserver.PactFileName(p.Consumer, p.Provider))
+		if err := mockserver.CanonicalizePactFile(path); err != nil {
+			log.Println("[WARN] unable to canonicalize the pact:", err)
+		}
+	}
+
 	return nil
 }
 
 // VerifyProviderRaw reads the provided pact files and runs verification against
 // a running Provider API, providing raw response from the Verification process.
 //
-// Order of events: BeforeEach, stateHandlers, requestFilter(pre <execute provider> post), AfterEach
+// Order of events: BeforeSuite, then for each interaction BeforeEach, stateHandlers,
+// requestFilter(pre <execute provider> post), AfterEach, and finally AfterSuite
+//
+// Verifications may run in parallel, e.g. against different pact sources. Each
+// has its own proxy, middleware and provider states, so the hooks and handlers
+// of one verification are never called for another.
 func (p *Pact) VerifyProviderRaw(request types.VerifyRequest) ([]types.ProviderVerifierResponse, error) {
 	p.Setup(false)
+
+	if request.BeforeSuite != nil {
+		log.Println("[DEBUG] executing before suite hook")
+		if err := request.BeforeSuite(); err != nil {
+			log.Println("[ERROR] error executing before suite hook:", err)
+			return make([]types.ProviderVerifierResponse, 0), fmt.Errorf("before suite hook failed: %v", err)
+		}
+	}
+
+	res, err := p.verifyProviderRaw(request)
+
+	if request.AfterSuite != nil {
+		log.Println("[DEBUG] executing after suite hook")
+		if afterErr := request.AfterSuite(); afterErr != nil {
+			log.Println("[ERROR] error executing after suite hook:", afterErr)
+			if err == nil {
+				err = fmt.Errorf("after suite hook failed: %v", afterErr)
+			}
+		}
+	}
+
+	return res, err
+}
+
+func (p *Pact) verifyProviderRaw(request types.VerifyRequest) ([]types.ProviderVerifierResponse, error) {
 	res := make([]types.ProviderVerifierResponse, 0)
 
 	u, err := url.Parse(request.ProviderBaseURL)
@@ -316,76 +805,126 @@ func (p *Pact) VerifyProviderRaw(request types.VerifyRequest) ([]types.ProviderV
 		return res, err
 	}
 
-	m := []proxy.Middleware{}
-
-	if request.BeforeEach != nil {
-		m = append(m, BeforeEachMiddleware(request.BeforeEach))
-	}
-
-	if request.AfterEach != nil {
-		m = append(m, AfterEachMiddleware(request.AfterEach))
+	if request, err = p.withStateHandlers(request); err != nil {
+		return res, err
 	}
 
-	if len(request.StateHandlers) > 0 {
-		m = append(m, stateHandlerMiddleware(request.StateHandlers))
+	if _, err := newStateResolver(request); err != nil {
+		return res, err
 	}
 
-	if request.RequestFilter != nil {
-		m = append(m, request.RequestFilter)
-	}
+	catalog := &interactionCatalog{}
+	tracker := &interactionTracker{provider: p.Provider, catalog: catalog}
+	timings := &timingRecorder{}
+	m := verificationMiddleware(request, tracker, timings)
 
 	// Configure HTTP Verification Proxy
 	opts := proxy.Options{
-		TargetAddress:             fmt.Sprintf("%s:%s", u.Hostname(), u.Port()),
+		TargetAddress:             net.JoinHostPort(u.Hostname(), u.Port()),
 		TargetScheme:              u.Scheme,
 		TargetPath:                u.Path,
 		Middleware:                m,
 		InternalRequestPathPrefix: providerStatesSetupPath,
+		InternalHandler:           request.InternalHandler,
+		ProxyPort:                 request.ProxyPort,
+		ProxyPortRange:            request.ProxyPortRange,
+		ProxyBindAddress:          request.ProxyBindAddress,
 		CustomTLSConfig:           request.CustomTLSConfig,
+		Transport:                 request.ProviderTransport,
+		HTTP2:                     request.ProviderHTTP2,
+		DecompressBodies:          request.DecompressBodies,
+		PreserveHostHeader:        request.PreserveHostHeader,
+		ForwardedHeaders:          request.ForwardedHeaders,
+		ReadTimeout:               request.ProxyReadTimeout,
+		WriteTimeout:              request.ProxyWriteTimeout,
+		IdleTimeout:               request.ProxyIdleTimeout,
+		ResponseHeaderTimeout:     request.ProviderResponseHeaderTimeout,
+		ResponseFilter:            request.ResponseFilter,
+		Observer:                  request.Observer,
+		TLS:                       request.ProxyTLS,
+		TLSCertificate:            request.ProxyTLSCertificate,
+	}
+
+	// Log the responses from the provider as they were, before they're filtered
+	if request.ExchangeLogDir != "" && request.ResponseFilter != nil {
+		opts.ResponseFilter = exchangeLogResponseFilter(request.ResponseFilter)
+	}
+
+	// Providers listening on a unix domain socket, e.g. unix:///var/run/app.sock
+	if u.Scheme == "unix" {
+		opts.TargetScheme = "http"
+		opts.TargetAddress = ""
+		opts.TargetPath = ""
+		opts.TargetSocket = u.Path
 	}
 
 	// Starts the message wrapper API with hooks back to the state handlers
 	// This maps the 'description' field of a message pact, to a function handler
 	// that will implement the message producer. This function must return an object and optionally
 	// and error. The object will be marshalled to JSON for comparison.
-	port, err := proxy.HTTPReverseProxy(opts)
+	server, err := proxy.StartHTTPReverseProxy(opts)
+	if err != nil {
+		return res, err
+	}
+	defer shutdownProxy(server)
+	port := server.Port
+
+	// The verifier connects to the proxy on the interface it is bound to
+	proxyHost := proxyConnectHost(request.ProxyHost, request.ProxyBindAddress)
 
 	// Backwards compatibility, setup old provider states URL if given
 	// Otherwise point to proxy
 	setupURL := request.ProviderStatesSetupURL
-	if request.ProviderStatesSetupURL == "" && len(request.StateHandlers) > 0 {
-		setupURL = fmt.Sprintf("http://localhost:%d%s", port, providerStatesSetupPath)
+	if request.ProviderStatesSetupURL == "" && (hasStateHandlers(request) || request.BeforeInteraction != nil || request.ProviderStateHeader != "") {
+		setupURL = fmt.Sprintf("%s://%s%s", server.Scheme, net.JoinHostPort(proxyHost, strconv.Itoa(port)), providerStatesSetupPath)
+	}
+
+	env, removeBundle, err := trustProxyCertificate(server)
+	if err != nil {
+		return res, err
 	}
+	defer removeBundle()
 
 	// Construct verifier request
 	verificationRequest := types.VerifyRequest{
-		ProviderBaseURL:            fmt.Sprintf("http://localhost:%d", port),
+		ProviderBaseURL:            fmt.Sprintf("%s://%s", server.Scheme, net.JoinHostPort(proxyHost, strconv.Itoa(port))),
 		PactURLs:                   request.PactURLs,
 		BrokerURL:                  request.BrokerURL,
 		Tags:                       request.Tags,
 		BrokerUsername:             request.BrokerUsername,
 		BrokerPassword:             request.BrokerPassword,
 		BrokerToken:                request.BrokerToken,
-		PublishVerificationResults: request.PublishVerificationResults,
-		ProviderVersion:            request.ProviderVersion,
+		BrokerTokenSource:          request.BrokerTokenSource,
+		BrokerTLSConfig:            request.BrokerTLSConfig,
+		BrokerHTTPClient:           request.BrokerHTTPClient,
+		BrokerProxyURL:             request.BrokerProxyURL,
+		BrokerRetry:                request.BrokerRetry,
+		BrokerCacheDir:             request.BrokerCacheDir,
+		BrokerOffline:              request.BrokerOffline,
+		PublishVerificationResults: request.PublishMode.Publish(request.PublishVerificationResults),
+		ProviderVersion:            providerVersion(request.ProviderVersion, request.DisableGitProviderVersion),
+		BuildURL:                   request.BuildURL,
 		Provider:                   request.Provider,
 		ProviderStatesSetupURL:     setupURL,
 		CustomProviderHeaders:      request.CustomProviderHeaders,
 		ConsumerVersionSelectors:   request.ConsumerVersionSelectors,
 		EnablePending:              request.EnablePending,
 		ProviderTags:               request.ProviderTags,
+		ProviderBranch:             detectedBranch(request.ProviderBranch, request.DisableBranchDetection),
 		Verbose:                    request.Verbose,
 		FailIfNoPactsFound:         request.FailIfNoPactsFound,
+		SpecificationVersion:       request.SpecificationVersion,
 		IncludeWIPPactsSince:       request.IncludeWIPPactsSince,
 		PactLogDir:                 request.PactLogDir,
 		PactLogLevel:               request.PactLogLevel,
+		Env:                        env,
 	}
 
 	if request.Provider == "" {
 		verificationRequest.Provider = p.Provider
 	}
 
-	portErr := waitForPort(port, "tcp", "localhost", p.ClientTimeout,
+	portErr := waitForPort(port, "tcp", proxyHost, p.ClientTimeout,
 		fmt.Sprintf(`Timed out waiting for http verification proxy on port %d - check for errors`, port))
 
 	if portErr != nil {
@@ -393,9 +932,130 @@ func (p *Pact) VerifyProviderRaw(request types.VerifyRequest) ([]types.ProviderV
 		return res, portErr
 	}
 
+	redactSecrets(verificationRequest)
+	useTagSelectors(&verificationRequest)
+
+	removePacts, err := preparePactURLs(&verificationRequest, v4HTTPInteraction, catalog)
+	if err != nil {
+		return res, err
+	}
+	defer removePacts()
+
+	stopRelay, err := startBrokerRelay(&verificationRequest, catalog.observe(prepareRelayedPacts(verificationRequest.SpecificationVersion, v4HTTPInteraction)))
+	if err != nil {
+		return res, err
+	}
+	defer stopRelay()
+
 	log.Println("[DEBUG] pact provider verification")
 
-	return p.pactClient.VerifyProvider(verificationRequest)
+	res, err = p.pactClient.VerifyProvider(verificationRequest)
+	timings.attach(res)
+	if err != nil && catalog.excusePending(res) {
+		log.Println("[WARN] only pending interactions failed verification")
+		err = nil
+	}
+
+	return res, err
+}
+
+// verificationMiddleware builds the verification proxy middleware chain,
+// ordering the built-in and user supplied middleware by priority. The
+// interaction tracker always runs first, and the timing recorder last,
+// so that they see every request.
+func verificationMiddleware(request types.VerifyRequest, tracker *interactionTracker, timings *timingRecorder) []proxy.Middleware {
+	var chain []types.ProxyMiddleware
+
+	if request.BeforeEach != nil {
+		chain = append(chain, types.ProxyMiddleware{Priority: types.PriorityBeforeEach, Middleware: BeforeEachMiddleware(request.BeforeEach)})
+	}
+
+	if request.AfterEach != nil {
+		chain = append(chain, types.ProxyMiddleware{Priority: types.PriorityAfterEach, Middleware: AfterEachMiddleware(request.AfterEach)})
+	}
+
+	if request.BeforeInteraction != nil || request.AfterInteraction != nil {
+		chain = append(chain, types.ProxyMiddleware{Priority: types.PriorityInteractionHooks, Middleware: interactionHooksMiddleware(tracker, request.BeforeInteraction, request.AfterInteraction)})
+	}
+
+	if hasStateHandlers(request) {
+		chain = append(chain, types.ProxyMiddleware{Priority: types.PriorityStateHandlers, Middleware: stateHandlerMiddleware(request)})
+	}
+
+	if request.ProviderStateHeader != "" {
+		chain = append(chain, types.ProxyMiddleware{Priority: types.PriorityStateHandlers, Middleware: providerStateHeaderMiddleware(request.ProviderStateHeader, tracker)})
+	}
+
+	if request.RequestFilter != nil {
+		chain = append(chain, types.ProxyMiddleware{Priority: types.PriorityRequestFilter, Middleware: request.RequestFilter})
+	}
+
+	if request.ExchangeLogDir != "" {
+		chain = append(chain, types.ProxyMiddleware{Priority: types.PriorityExchangeLog, Middleware: exchangeLogMiddleware(request.ExchangeLogDir, customHeaderNames(request.CustomProviderHeaders))})
+	}
+
+	chain = append(chain, request.Middleware...)
+	sort.SliceStable(chain, func(i, j int) bool {
+		return chain[i].Priority < chain[j].Priority
+	})
+
+	m := []proxy.Middleware{tracker.middleware()}
+	for _, mw := range chain {
+		m = append(m, mw.Middleware)
+	}
+
+	return append(m, timings.middleware(tracker, request.SLOThreshold))
+}
+
+// proxyShutdownTimeout is how long in-flight requests to the verification
+// proxy are given to complete once verification has finished
+var proxyShutdownTimeout = 5 * time.Second
+
+// shutdownProxy gracefully stops the verification proxy
+func shutdownProxy(server *proxy.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), proxyShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Println("[WARN] unable to gracefully stop the verification proxy:", err)
+		server.Close()
+	}
+}
+
+// proxyConnectHost returns the host used to reach a proxy bound to the given
+// address; the configured host if given, otherwise localhost unless it is
+// bound to a specific interface. IPv6 literals are returned without brackets.
+func proxyConnectHost(host string, bindAddress string) string {
+	if host == "" {
+		host = bindAddress
+	}
+
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	switch host {
+	case "", "0.0.0.0", "::":
+		return "localhost"
+	}
+
+	return host
+}
+
+// useTagSelectors converts the consumer version tags of a broker based
+// verification into selectors for the latest pact with each tag. Passing
+// selectors ensures the verifier fetches pacts using the broker's "pacts for
+// verification" API, so that pending and WIP pacts, and the notices explaining
+// why each pact was selected, are handled correctly.
+func useTagSelectors(request *types.VerifyRequest) {
+	if request.BrokerURL == "" || len(request.Tags) == 0 || len(request.ConsumerVersionSelectors) > 0 {
+		return
+	}
+
+	for _, tag := range request.Tags {
+		request.ConsumerVersionSelectors = append(request.ConsumerVersionSelectors, types.ConsumerVersionSelector{
+			Tag:    tag,
+			Latest: true,
+		})
+	}
+	request.Tags = nil
 }
 
 // VerifyProvider accepts an instance of `*testing.T`
@@ -438,7 +1098,8 @@ var checkCliCompatibility = func() {
 }
 
 // BeforeEachMiddleware is invoked before any other, only on the __setup
-// request (to avoid duplication)
+// request (to avoid duplication). If the hook fails the provider states
+// are not set up, and the setup of the interaction fails.
 func BeforeEachMiddleware(BeforeEach types.Hook) proxy.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -449,7 +1110,8 @@ func BeforeEachMiddleware(BeforeEach types.Hook) proxy.Middleware {
 
 				if err != nil {
 					log.Println("[ERROR] error executing before hook:", err)
-					w.WriteHeader(http.StatusInternalServerError)
+					http.Error(w, fmt.Sprintf("before each hook failed: %v", err), http.StatusInternalServerError)
+					return
 				}
 			}
 			next.ServeHTTP(w, r)
@@ -459,21 +1121,33 @@ func BeforeEachMiddleware(BeforeEach types.Hook) proxy.Middleware {
 
 // AfterEachMiddleware is invoked after any other, and is the last
 // function to be called prior to returning to the test suite. It is
-// therefore not invoked on __setup
+// therefore not invoked on __setup. It runs even if a downstream handler
+// panics or the request to the provider fails, so that cleanup is never
+// skipped. An error from the hook is logged, leaving the provider response
+// as it was sent.
 func AfterEachMiddleware(AfterEach types.Hook) proxy.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			next.ServeHTTP(w, r)
+			if isInternalPath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-			if r.URL.Path != providerStatesSetupPath {
-				log.Println("[DEBUG] executing after hook")
-				err := AfterEach()
+			defer func() {
+				// Run the hook before passing a panic on to the recovery middleware
+				recovered := recover()
 
-				if err != nil {
+				log.Println("[DEBUG] executing after hook")
+				if err := AfterEach(); err != nil {
 					log.Println("[ERROR] error executing after hook:", err)
-					w.WriteHeader(http.StatusInternalServerError)
 				}
-			}
+
+				if recovered != nil {
+					panic(recovered)
+				}
+			}()
+
+			next.ServeHTTP(w, r)
 		})
 	}
 }
@@ -484,27 +1158,67 @@ func AfterEachMiddleware(AfterEach types.Hook) proxy.Middleware {
 // statehandler accepts a state object from the verifier and executes
 // any state handlers associated with the provider.
 // It will not execute further middleware if it is the designted "state" request
-func stateHandlerMiddleware(stateHandlers types.StateHandlers) proxy.Middleware {
+func stateHandlerMiddleware(request types.VerifyRequest) proxy.Middleware {
+	resolver, resolverErr := newStateResolver(request)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.URL.Path == providerStatesSetupPath {
+				if resolverErr != nil {
+					log.Println("[ERROR]", resolverErr)
+					http.Error(w, resolverErr.Error(), http.StatusInternalServerError)
+					return
+				}
+
 				var s *types.ProviderState
 				decoder := json.NewDecoder(r.Body)
 				decoder.Decode(&s)
 
-				// Setup any provider state
-				for _, state := range s.States {
-					sf, stateFound := stateHandlers[state]
+				ctx := r.Context()
+				if request.StateHandlerTimeout > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, request.StateHandlerTimeout)
+					defer cancel()
+				}
+
+				if s == nil || isTeardown(*s) {
+					log.Println("[DEBUG] skipping state handlers for teardown")
+					w.WriteHeader(http.StatusOK)
+					return
+				}
 
-					if !stateFound {
+				// Resolve the handler of every state before setting up any, so an
+				// interaction with a missing state doesn't leave the others set up
+				states := providerStateNames(*s)
+				handlers := make([]func(context.Context) error, len(states))
+				for i, state := range states {
+					sf, err := resolver.resolve(state, s.Params)
+
+					if err != nil {
+						log.Println("[ERROR]", err)
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					} else if sf == nil && request.FailOnMissingStateHandler {
+						log.Printf("[ERROR] state handler not found for state: %v", state)
+						http.Error(w, fmt.Sprintf("state handler not found for state: %v", state), http.StatusInternalServerError)
+						return
+					} else if sf == nil {
 						log.Printf("[WARN] state handler not found for state: %v", state)
-					} else {
-						// Execute state handler
-						if err := sf(); err != nil {
-							log.Printf("[ERROR] state handler for '%v' errored: %v", state, err)
-							w.WriteHeader(http.StatusInternalServerError)
-							return
-						}
+					}
+					handlers[i] = sf
+				}
+
+				// Setup any provider state, in the order of the interaction
+				for i, state := range states {
+					if handlers[i] == nil {
+						continue
+					}
+
+					// Execute state handler
+					if err := runStateHandler(ctx, state, handlers[i]); err != nil {
+						log.Printf("[ERROR] state handler for '%v' errored: %v", state, err)
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
 					}
 				}
 
@@ -520,7 +1234,7 @@ func stateHandlerMiddleware(stateHandlers types.StateHandlers) proxy.Middleware
 	}
 }
 
-var messageVerificationHandler = func(messageHandlers MessageHandlers, stateHandlers StateHandlers) http.HandlerFunc {
+var messageVerificationHandler = func(messageHandlers MessageHandlers, stateHandlers StateHandlers, defaultStateHandler StateHandler, failOnMissingStateHandler bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
@@ -541,19 +1255,38 @@ var messageVerificationHandler = func(messageHandlers MessageHandlers, stateHand
 			return
 		}
 
-		// Setup any provider state
-		for _, state := range message.States {
+		// Resolve the handler of every state before setting up any
+		handlers := make([]StateHandler, len(message.States))
+		for i, state := range message.States {
 			sf, stateFound := stateHandlers[state.Name]
 
-			if !stateFound {
+			if !stateFound && defaultStateHandler != nil {
+				log.Printf("[DEBUG] using default state handler for state: %v", state.Name)
+				sf, stateFound = defaultStateHandler, true
+			}
+
+			if !stateFound && failOnMissingStateHandler {
+				log.Printf("[ERROR] state handler not found for state: %v", state.Name)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("state handler not found for state: %v", state.Name)})
+				return
+			} else if !stateFound {
 				log.Printf("[WARN] state handler not found for state: %v", state.Name)
-			} else {
-				// Execute state handler
-				if err = sf(state); err != nil {
-					log.Printf("[WARN] state handler for '%v' return error: %v", state.Name, err)
-					w.WriteHeader(http.StatusInternalServerError)
-					return
-				}
+			}
+			handlers[i] = sf
+		}
+
+		// Setup any provider state, in the order of the message
+		for i, state := range message.States {
+			if handlers[i] == nil {
+				continue
+			}
+
+			// Execute state handler
+			if err = handlers[i](state); err != nil {
+				log.Printf("[WARN] state handler for '%v' return error: %v", state.Name, err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
 			}
 		}
 
@@ -617,7 +1350,7 @@ func runTestCases(t *testing.T, res []types.ProviderVerifierResponse) {
 	for _, test := range res {
 		t.Run(generateTestCaseName(test), func(pactTest *testing.T) {
 			for _, notice := range test.Summary.Notices {
-				if notice.When == "before_verification" {
+				if notice.Before() {
 					t.Logf("notice: %s", notice.Text)
 				}
 			}
@@ -639,8 +1372,15 @@ func runTestCases(t *testing.T, res []types.ProviderVerifierResponse) {
 					}
 				})
 			}
+			for _, timing := range test.Timings {
+				if timing.ExceededSLO {
+					pactTest.Errorf("%s %s took %s to respond, exceeding the SLO threshold", timing.Method, timing.Path, timing.Duration)
+				} else {
+					pactTest.Logf("%s %s responded in %s", timing.Method, timing.Path, timing.Duration)
+				}
+			}
 			for _, notice := range test.Summary.Notices {
-				if notice.When == "after_verification" {
+				if notice.After() {
 					t.Logf("notice: %s", notice.Text)
 				}
 			}
@@ -670,7 +1410,7 @@ func (p *Pact) VerifyMessageProviderRaw(request VerifyMessageRequest) ([]types.P
 
 	// Construct verifier request
 	verificationRequest := types.VerifyRequest{
-		ProviderBaseURL:            fmt.Sprintf("http://localhost:%d", port),
+		ProviderBaseURL:            fmt.Sprintf("http://%s", net.JoinHostPort("localhost", strconv.Itoa(port))),
 		PactURLs:                   request.PactURLs,
 		BrokerURL:                  request.BrokerURL,
 		Tags:                       request.Tags,
@@ -678,13 +1418,23 @@ func (p *Pact) VerifyMessageProviderRaw(request VerifyMessageRequest) ([]types.P
 		BrokerUsername:             request.BrokerUsername,
 		BrokerPassword:             request.BrokerPassword,
 		BrokerToken:                request.BrokerToken,
-		PublishVerificationResults: request.PublishVerificationResults,
-		ProviderVersion:            request.ProviderVersion,
+		BrokerTokenSource:          request.BrokerTokenSource,
+		BrokerTLSConfig:            request.BrokerTLSConfig,
+		BrokerHTTPClient:           request.BrokerHTTPClient,
+		BrokerProxyURL:             request.BrokerProxyURL,
+		BrokerRetry:                request.BrokerRetry,
+		BrokerCacheDir:             request.BrokerCacheDir,
+		BrokerOffline:              request.BrokerOffline,
+		PublishVerificationResults: request.PublishMode.Publish(request.PublishVerificationResults),
+		ProviderVersion:            providerVersion(request.ProviderVersion, request.DisableGitProviderVersion),
+		BuildURL:                   request.BuildURL,
 		ProviderTags:               request.ProviderTags,
+		ProviderBranch:             detectedBranch(request.ProviderBranch, request.DisableBranchDetection),
 		Provider:                   p.Provider,
+		SpecificationVersion:       request.SpecificationVersion,
 	}
 
-	mux.HandleFunc("/", messageVerificationHandler(request.MessageHandlers, request.StateHandlers))
+	mux.HandleFunc("/", messageVerificationHandler(request.MessageHandlers, request.StateHandlers, request.DefaultStateHandler, request.FailOnMissingStateHandler))
 
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
@@ -693,7 +1443,7 @@ func (p *Pact) VerifyMessageProviderRaw(request VerifyMessageRequest) ([]types.P
 	defer ln.Close()
 
 	log.Printf("[DEBUG] API handler starting: port %d (%s)", port, ln.Addr())
-	go http.Serve(ln, mux)
+	go http.Serve(ln, proxy.RecoveryMiddleware(mux))
 
 	portErr := waitForPort(port, "tcp", "localhost", p.ClientTimeout,
 		fmt.Sprintf(`Timed out waiting for pact proxy on port %d - check for errors`, port))
@@ -703,8 +1453,30 @@ func (p *Pact) VerifyMessageProviderRaw(request VerifyMessageRequest) ([]types.P
 		return response, portErr
 	}
 
+	redactSecrets(verificationRequest)
+	useTagSelectors(&verificationRequest)
+
+	catalog := &interactionCatalog{}
+	removePacts, err := preparePactURLs(&verificationRequest, v4MessageInteraction, catalog)
+	if err != nil {
+		return response, err
+	}
+	defer removePacts()
+
+	stopRelay, err := startBrokerRelay(&verificationRequest, catalog.observe(prepareRelayedPacts(verificationRequest.SpecificationVersion, v4MessageInteraction)))
+	if err != nil {
+		return response, err
+	}
+	defer stopRelay()
+
 	log.Println("[DEBUG] pact provider verification")
-	return p.pactClient.VerifyProvider(verificationRequest)
+	response, err = p.pactClient.VerifyProvider(verificationRequest)
+	if err != nil && catalog.excusePending(response) {
+		log.Println("[WARN] only pending messages failed verification")
+		err = nil
+	}
+
+	return response, err
 }
 
 // VerifyMessageConsumerRaw creates a new Pact _message_ interaction to build a testable
@@ -718,11 +1490,13 @@ func (p *Pact) VerifyMessageConsumerRaw(message *Message, handler MessageConsume
 	log.Printf("[DEBUG] verify message")
 	p.Setup(false)
 
-	// Reify the message back to its "example/generated" form
-	reified, err := p.pactClient.ReifyMessage(&types.PactReificationRequest{
-		Message: message.Content,
-	})
+	prepared, err := p.prepareMessage(message)
+	if err != nil {
+		return err
+	}
 
+	// Reify the message back to its "example/generated" form
+	reified, err := p.reifyMessage(prepared)
 	if err != nil {
 		return fmt.Errorf("unable to convert consumer test to a valid JSON representation: %v", err)
 	}
@@ -752,14 +1526,59 @@ func (p *Pact) VerifyMessageConsumerRaw(message *Message, handler MessageConsume
 	}
 
 	// If no errors, update Message Pact
+	if p.SpecificationVersion >= 4 {
+		return p.writeMessage(message)
+	}
 	return p.pactClient.UpdateMessagePact(types.PactMessageRequest{
-		Message:  message,
+		Message:  prepared,
 		Consumer: p.Consumer,
 		Provider: p.Provider,
 		PactDir:  p.PactDir,
 	})
 }
 
+// reifyMessage returns the example content of a message. Version 4 messages
+// are reified natively, as the CLI tools don't support them.
+func (p *Pact) reifyMessage(message *Message) (*types.ReificationResponse, error) {
+	if p.SpecificationVersion < 4 {
+		return p.pactClient.ReifyMessage(&types.PactReificationRequest{
+			Message: message.Content,
+		})
+	}
+
+	content, err := json.Marshal(message.Content)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := mockserver.Reify(content)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &types.ReificationResponse{ResponseRaw: raw}
+	err = json.Unmarshal(raw, &response.Response)
+	return response, err
+}
+
+// writeMessage merges a message into the version 4 pact, alongside its HTTP
+// interactions
+func (p *Pact) writeMessage(message *Message) error {
+	var encoded mockserver.Message
+	content, err := json.Marshal(message)
+	if err == nil {
+		err = json.Unmarshal(content, &encoded)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to encode the message: %v", err)
+	}
+
+	encoded.Comments = message.Comments
+	encoded.TestName = message.TestName
+	encoded.Pending = message.Pending
+
+	return mockserver.WriteMessage(p.PactDir, p.Consumer, p.Provider, &encoded)
+}
+
 // VerifyMessageConsumer is a test convience function for VerifyMessageConsumerRaw,
 // accepting an instance of `*testing.T`
 func (p *Pact) VerifyMessageConsumer(t *testing.T, message *Message, handler MessageConsumer) error {
@@ -773,3 +1592,9 @@ func (p *Pact) VerifyMessageConsumer(t *testing.T, message *Message, handler Mes
 }
 
 const providerStatesSetupPath = "/__setup"
+
+// isInternalPath reports whether a request to the verification proxy is for
+// the state setup or another internal endpoint, rather than the provider
+func isInternalPath(path string) bool {
+	return strings.HasPrefix(path, providerStatesSetupPath)
+}
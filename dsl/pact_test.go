@@ -1,17 +1,27 @@
 package dsl
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pact-foundation/pact-go/mockserver"
+	"github.com/pact-foundation/pact-go/proxy"
 	"github.com/pact-foundation/pact-go/types"
+	"github.com/pact-foundation/pact-go/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -113,6 +123,39 @@ func TestPact_WritePact(t *testing.T) {
 	}
 }
 
+func TestPact_WritePactCanonical(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "my_consumer-my_provider.json")
+	ms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Write the pact as the Ruby mock service might
+		ioutil.WriteFile(path, []byte(`{"interactions": [{"description": "b"}, {"description": "a"}]}`), 0644)
+	}))
+	defer ms.Close()
+
+	pact := &Pact{
+		Server: &types.MockServer{
+			Port: getPort(ms.URL),
+		},
+		Consumer: "My Consumer",
+		Provider: "My Provider",
+		PactDir:  dir,
+	}
+
+	if err := pact.WritePact(); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	content, _ := ioutil.ReadFile(path)
+	if !strings.Contains(string(content), `"description": "a"
+    },
+    {
+      "description": "b"`) {
+		t.Fatalf("Expected a canonical pact, got %s", content)
+	}
+}
+
 func TestPact_WritePactFail(t *testing.T) {
 	ms := setupMockServer(false, t)
 	defer ms.Close()
@@ -214,6 +257,7 @@ func TestPact_Setup(t *testing.T) {
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
 				tt.pact.Setup(tt.setup)
+				defer tt.pact.Teardown()
 
 				if tt.wantNilServer {
 					assert.Nil(t, tt.pact.Server, "expected server to be nil")
@@ -251,6 +295,527 @@ func TestPact_TeardownFail(t *testing.T) {
 	pact.Teardown()
 }
 
+func TestPact_NativeMockServer(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	pact := &Pact{
+		Consumer:         "My Consumer",
+		Provider:         "My Provider",
+		PactDir:          dir,
+		LogLevel:         "ERROR",
+		NativeMockServer: true,
+	}
+	pact.Setup(true)
+	assert.NotNil(t, pact.Server)
+
+	pact.
+		AddInteraction().
+		Given("a user exists").
+		UponReceiving("a request for the user").
+		WithRequest(Request{
+			Method: "GET",
+			Path:   Term("/users/1", `^/users/\d+$`),
+		}).
+		WillRespondWith(Response{
+			Status: 200,
+			Body:   Like(map[string]string{"name": "Billy"}),
+		})
+
+	err := pact.Verify(func() error {
+		res, err := http.Get(fmt.Sprintf("http://localhost:%d/users/42", pact.Server.Port))
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.JSONEq(t, `{"name": "Billy"}`, string(body))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, pact.WritePact())
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "my_consumer-my_provider.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "a request for the user")
+
+	pact.Teardown()
+	assert.Nil(t, pact.Server)
+}
+
+func TestPact_NativeMockServerV4(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	pact := &Pact{
+		Consumer:             "My Consumer",
+		Provider:             "My Provider",
+		PactDir:              dir,
+		LogLevel:             "ERROR",
+		NativeMockServer:     true,
+		SpecificationVersion: 4,
+	}
+	defer pact.Teardown()
+
+	pact.
+		AddInteraction().
+		UponReceiving("a request for the user").
+		WithRequest(Request{Method: "GET", Path: String("/users/1")}).
+		WillRespondWith(Response{Status: 200, Body: Like(map[string]string{"name": "Billy"})}).
+		WithComment("Used by the *profile* page").
+		AsPending()
+
+	err := pact.Verify(func() error {
+		res, err := http.Get(fmt.Sprintf("http://localhost:%d/users/1", pact.Server.Port))
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, pact.WritePact())
+
+	message := pact.AddMessage()
+	message.
+		Given("a user exists").
+		ExpectsToReceive("a user created event").
+		WithContent(map[string]interface{}{"name": Like("Billy")}).
+		WithTestName(t.Name()).
+		AsType(&struct{ Name string }{})
+
+	err = pact.VerifyMessageConsumer(t, message, func(m Message) error {
+		assert.Equal(t, "Billy", m.Content.(*struct{ Name string }).Name)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "my_consumer-my_provider.json"))
+	assert.NoError(t, err)
+
+	var written struct {
+		Interactions []struct {
+			Type        string
+			Description string
+			Pending     bool
+			Comments    struct {
+				Text     []string
+				TestName string `json:"testname"`
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(content, &written))
+	assert.Len(t, written.Interactions, 2)
+	assert.Equal(t, "Synchronous/HTTP", written.Interactions[0].Type)
+	assert.True(t, written.Interactions[0].Pending)
+	assert.Equal(t, []string{"Used by the *profile* page"}, written.Interactions[0].Comments.Text)
+	assert.Equal(t, "Asynchronous/Messages", written.Interactions[1].Type)
+	assert.Equal(t, "TestPact_NativeMockServerV4", written.Interactions[1].Comments.TestName)
+}
+
+func TestPact_NativeMockServerVerifyFail(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	pact := &Pact{Consumer: "My Consumer", Provider: "My Provider", PactDir: dir, LogLevel: "ERROR", NativeMockServer: true}
+	defer pact.Teardown()
+
+	pact.
+		AddInteraction().
+		UponReceiving("a request for the user").
+		WithRequest(Request{Method: "GET", Path: String("/users/1")}).
+		WillRespondWith(Response{Status: 200})
+
+	err := pact.Verify(func() error {
+		res, err := http.Post(pact.ServerURL()+"/users", "application/json", strings.NewReader(`{"name": "Billy"}`))
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	})
+	assert.Error(t, err)
+
+	verification, ok := err.(*mockserver.VerificationError)
+	assert.True(t, ok, "expected a *mockserver.VerificationError")
+	assert.Equal(t, "a request for the user", verification.Missing[0].Description)
+	assert.Equal(t, "POST", verification.Unexpected[0].Method)
+	assert.Equal(t, `{"name": "Billy"}`, string(verification.Unexpected[0].Body))
+
+	unmatched := pact.UnmatchedRequests()
+	assert.Len(t, unmatched, 1)
+	assert.Equal(t, "/users", unmatched[0].Path)
+	assert.Equal(t, "application/json", unmatched[0].Headers.Get("Content-Type"))
+}
+
+func TestPact_SetupTest(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	var servers []*Pact
+	var mu sync.Mutex
+
+	t.Run("group", func(t *testing.T) {
+		for _, name := range []string{"foo", "bar"} {
+			name := name
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				pact := &Pact{
+					Consumer:         "My Consumer",
+					Provider:         "My Provider",
+					PactDir:          dir,
+					LogLevel:         "ERROR",
+					NativeMockServer: true,
+				}
+				defer pact.SetupTest(t)()
+
+				mu.Lock()
+				servers = append(servers, pact)
+				mu.Unlock()
+
+				pact.
+					AddInteraction().
+					UponReceiving("a request for " + name).
+					WithRequest(Request{Method: "GET", Path: String("/" + name)}).
+					WillRespondWith(Response{Status: 200})
+
+				err := pact.Verify(func() error {
+					res, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", pact.Server.Port, name))
+					if err == nil {
+						res.Body.Close()
+					}
+					return err
+				})
+				assert.NoError(t, err)
+			})
+		}
+	})
+
+	for _, pact := range servers {
+		assert.Nil(t, pact.Server)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "my_consumer-my_provider.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "a request for foo")
+	assert.Contains(t, string(content), "a request for bar")
+}
+
+func TestPact_WriteModeEnv(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "my_consumer-my_provider.json")
+	stale := `{"consumer": {"name": "My Consumer"}, "provider": {"name": "My Provider"}, "interactions": [{"description": "a stale request"}]}`
+	assert.NoError(t, ioutil.WriteFile(path, []byte(stale), 0644))
+
+	os.Setenv("PACT_FILE_WRITE_MODE", "overwrite")
+	defer os.Unsetenv("PACT_FILE_WRITE_MODE")
+
+	for _, name := range []string{"foo", "bar"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			pact := &Pact{
+				Consumer:         "My Consumer",
+				Provider:         "My Provider",
+				PactDir:          dir,
+				LogLevel:         "ERROR",
+				NativeMockServer: true,
+			}
+			defer pact.SetupTest(t)()
+			assert.Equal(t, "overwrite", pact.PactFileWriteMode)
+
+			pact.
+				AddInteraction().
+				UponReceiving("a request for " + name).
+				WithRequest(Request{Method: "GET", Path: String("/" + name)}).
+				WillRespondWith(Response{Status: 200})
+
+			err := pact.Verify(func() error {
+				res, err := http.Get(fmt.Sprintf("http://localhost:%d/%s", pact.Server.Port, name))
+				if err == nil {
+					res.Body.Close()
+				}
+				return err
+			})
+			assert.NoError(t, err)
+		})
+	}
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "a stale request")
+	assert.Contains(t, string(content), "a request for foo")
+	assert.Contains(t, string(content), "a request for bar")
+}
+
+func TestPact_SetupReservesPorts(t *testing.T) {
+	defer stubPorts()()
+	c, _ := createMockClient(true)
+
+	first := &Pact{LogLevel: "DEBUG", AllowedMockServerPorts: "32780-32781", pactClient: c}
+	second := &Pact{LogLevel: "DEBUG", AllowedMockServerPorts: "32780-32781", pactClient: c}
+	first.Setup(true)
+	second.Setup(true)
+
+	assert.Equal(t, 32780, first.Server.Port)
+	assert.Equal(t, 32781, second.Server.Port)
+
+	first.Teardown()
+	second.Teardown()
+
+	third := &Pact{LogLevel: "DEBUG", AllowedMockServerPorts: "32780-32781", pactClient: c}
+	third.Setup(true)
+	defer third.Teardown()
+	assert.Equal(t, 32780, third.Server.Port)
+}
+
+func TestPact_ServerURL(t *testing.T) {
+	pact := &Pact{Host: "127.0.0.1"}
+	assert.Equal(t, "", pact.ServerURL())
+
+	pact.Server = &types.MockServer{Port: 1234}
+	assert.Equal(t, "http://127.0.0.1:1234", pact.ServerURL())
+}
+
+func TestPact_ForTest(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	config := &Pact{
+		Consumer:         "My Consumer",
+		Provider:         "My Provider",
+		PactDir:          dir,
+		LogLevel:         "ERROR",
+		NativeMockServer: true,
+	}
+
+	var mu sync.Mutex
+	urls := map[string]bool{}
+
+	t.Run("group", func(t *testing.T) {
+		for _, name := range []string{"foo", "bar", "baz"} {
+			name := name
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				pact, teardown := config.ForTest(t)
+				defer teardown()
+				assert.NotEqual(t, config, pact)
+
+				mu.Lock()
+				urls[pact.ServerURL()] = true
+				mu.Unlock()
+
+				pact.
+					AddInteraction().
+					UponReceiving("a request for " + name).
+					WithRequest(Request{Method: "GET", Path: String("/" + name)}).
+					WillRespondWith(Response{Status: 200})
+
+				err := pact.Verify(func() error {
+					res, err := http.Get(pact.ServerURL() + "/" + name)
+					if err == nil {
+						res.Body.Close()
+					}
+					return err
+				})
+				assert.NoError(t, err)
+			})
+		}
+	})
+
+	assert.Len(t, urls, 3)
+	assert.Nil(t, config.Server)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "my_consumer-my_provider.json"))
+	assert.NoError(t, err)
+	for _, name := range []string{"foo", "bar", "baz"} {
+		assert.Contains(t, string(content), "a request for "+name)
+	}
+}
+
+func TestPact_NativeMockServerTLS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	pact := &Pact{
+		Consumer:         "My Consumer",
+		Provider:         "My Provider",
+		PactDir:          dir,
+		LogLevel:         "ERROR",
+		NativeMockServer: true,
+		MockServerTLS:    true,
+	}
+	defer pact.Teardown()
+
+	pact.
+		AddInteraction().
+		UponReceiving("a request for the user").
+		WithRequest(Request{Method: "GET", Path: String("/users/1")}).
+		WillRespondWith(Response{Status: 200})
+
+	assert.True(t, strings.HasPrefix(pact.ServerURL(), "https://"))
+	assert.Contains(t, string(pact.ServerCertificatePEM()), "BEGIN CERTIFICATE")
+
+	err := pact.Verify(func() error {
+		res, err := pact.ServerClient().Get(pact.ServerURL() + "/users/1")
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, pact.WritePact())
+}
+
+func TestPact_NativeMockServerCORS(t *testing.T) {
+	pact := &Pact{LogLevel: "ERROR", NativeMockServer: true, MockServerCORS: &mockserver.CORS{}}
+	pact.Setup(true)
+	defer pact.Teardown()
+
+	req, _ := http.NewRequest("OPTIONS", pact.ServerURL()+"/users", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Equal(t, "http://localhost:3000", res.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestPact_MockServerPort(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "port")
+	defer os.RemoveAll(dir)
+
+	port, _ := utils.GetFreePort()
+	file := filepath.Join(dir, "port")
+	pact := &Pact{
+		LogLevel:           "ERROR",
+		NativeMockServer:   true,
+		MockServerPort:     port,
+		MockServerPortFile: file,
+		MockServerPortEnv:  "PACT_GO_TEST_MOCK_SERVER_PORT",
+	}
+	pact.Setup(true)
+
+	assert.Equal(t, port, pact.Server.Port)
+	content, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprint(port), string(content))
+	assert.Equal(t, fmt.Sprint(port), os.Getenv("PACT_GO_TEST_MOCK_SERVER_PORT"))
+
+	pact.Teardown()
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err), "expected the port file to be removed")
+	_, ok := os.LookupEnv("PACT_GO_TEST_MOCK_SERVER_PORT")
+	assert.False(t, ok, "expected the port variable to be unset")
+}
+
+func TestPact_MockServerPortUnavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	defer l.Close()
+
+	pact := &Pact{LogLevel: "ERROR", NativeMockServer: true, MockServerPort: l.Addr().(*net.TCPAddr).Port}
+	pact.Setup(true)
+	assert.Nil(t, pact.Server)
+
+	defer stubPorts()()
+	c, _ := createMockClient(true)
+	pact = &Pact{LogLevel: "ERROR", MockServerPort: l.Addr().(*net.TCPAddr).Port, pactClient: c}
+	pact.Setup(true)
+	assert.Nil(t, pact.Server)
+}
+
+func TestPact_NativeMockServerCallCounts(t *testing.T) {
+	pact := &Pact{Consumer: "My Consumer", Provider: "My Provider", LogLevel: "ERROR", NativeMockServer: true}
+	defer pact.Teardown()
+
+	pact.
+		AddInteraction().
+		UponReceiving("a request for the user").
+		WithRequest(Request{Method: "GET", Path: String("/users/1")}).
+		WillRespondWith(Response{Status: 200}).
+		Times(1)
+
+	err := pact.Verify(func() error {
+		for i := 0; i < 2; i++ {
+			res, err := http.Get(pact.ServerURL() + "/users/1")
+			if err != nil {
+				return err
+			}
+			res.Body.Close()
+		}
+		return nil
+	})
+
+	verification, ok := err.(*mockserver.VerificationError)
+	assert.True(t, ok, "expected a *mockserver.VerificationError")
+	assert.Len(t, verification.Calls, 1)
+	assert.Equal(t, 2, verification.Calls[0].Calls)
+}
+
+func TestPact_ExecuteTest(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	pact := &Pact{Consumer: "My Consumer", Provider: "My Provider", PactDir: dir, LogLevel: "ERROR", NativeMockServer: true}
+
+	defer pact.Teardown()
+
+	pact.
+		AddInteraction().
+		UponReceiving("a request for the user").
+		WithRequest(Request{Method: "GET", Path: String("/users/1")}).
+		WillRespondWith(Response{Status: 200})
+
+	err := pact.ExecuteTest(t, func(config MockServerConfig) error {
+		assert.Equal(t, "localhost", config.Host)
+		assert.Equal(t, fmt.Sprintf("http://localhost:%d", config.Port), config.URL)
+		assert.Nil(t, config.TLSConfig)
+
+		res, err := http.Get(config.URL + "/users/1")
+		if err != nil {
+			return err
+		}
+		return res.Body.Close()
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, pact.Interactions)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "my_consumer-my_provider.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "a request for the user")
+}
+
+func TestPact_ExecuteTestFail(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	pact := &Pact{Consumer: "My Consumer", Provider: "My Provider", PactDir: dir, LogLevel: "ERROR", NativeMockServer: true}
+	defer pact.Teardown()
+	interaction := &Interaction{
+		Description: "a request for the user",
+		Request:     Request{Method: "GET", Path: String("/users/1")},
+		Response:    Response{Status: 200},
+	}
+
+	pact.Interactions = []*Interaction{interaction}
+	err := pact.executeTest(func(config MockServerConfig) error { return nil })
+	assert.Error(t, err)
+
+	pact.Interactions = []*Interaction{interaction}
+	err = pact.executeTest(func(config MockServerConfig) error { return errors.New("test failed") })
+	assert.EqualError(t, err, "test failed")
+
+	_, err = os.Stat(filepath.Join(dir, "my_consumer-my_provider.json"))
+	assert.True(t, os.IsNotExist(err), "expected no pact to be written")
+}
+
 func TestPact_VerifyProviderRaw(t *testing.T) {
 	c, _ := createMockClient(true)
 	defer stubPorts()()
@@ -279,6 +844,181 @@ func TestPact_VerifyProviderRaw(t *testing.T) {
 	}
 }
 
+func TestPact_VerifyProviderRawParallel(t *testing.T) {
+	c, _ := createMockClient(true)
+	defer stubPorts()()
+
+	pact := &Pact{LogLevel: "DEBUG", pactClient: c}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := pact.VerifyProviderRaw(types.VerifyRequest{
+				ProviderBaseURL: "http://www.foo.com",
+				PactURLs:        []string{fmt.Sprintf("pact-%d.json", i)},
+				StateHandlers: types.StateHandlers{
+					"state x": func() error {
+						return nil
+					},
+				},
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error("Error:", err)
+		}
+	}
+}
+
+func TestPact_StateHandlerMiddlewareParallel(t *testing.T) {
+	var first, second int32
+	handlers := func(calls *int32) types.StateHandlers {
+		return types.StateHandlers{
+			"state x": func() error {
+				atomic.AddInt32(calls, 1)
+				return nil
+			},
+		}
+	}
+
+	chains := []http.Handler{
+		stateHandlerMiddleware(types.VerifyRequest{StateHandlers: handlers(&first)})(dummyHandler("X-Dummy-Handler")),
+		stateHandlerMiddleware(types.VerifyRequest{StateHandlers: handlers(&second)})(dummyHandler("X-Dummy-Handler")),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(chain http.Handler) {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/__setup", strings.NewReader(`{"states": ["state x"], "consumer": "test", "provider": "provider"}`))
+			chain.ServeHTTP(httptest.NewRecorder(), req)
+		}(chains[i%2])
+	}
+	wg.Wait()
+
+	// each verification only calls its own state handlers
+	if first != 10 || second != 10 {
+		t.Errorf("want each state handler to be called 10 times, got %d and %d", first, second)
+	}
+}
+
+func TestPact_AddStateHandler(t *testing.T) {
+	pact := &Pact{}
+	handler := func() error { return nil }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, pact.AddStateHandler(fmt.Sprintf("state %d", i), handler))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, pact.RegisteredStates(), 10)
+	assert.Equal(t, "state 0", pact.RegisteredStates()[0])
+	assert.Error(t, pact.AddStateHandler("state 1", handler), "expected a duplicate state to be rejected")
+	assert.Error(t, pact.AddStateHandler("state x", nil))
+}
+
+func TestPact_VerifyProviderRawRegisteredStateHandlers(t *testing.T) {
+	pact := &Pact{}
+	assert.NoError(t, pact.AddStateHandler("state x", func() error { return nil }))
+
+	request, err := pact.withStateHandlers(types.VerifyRequest{
+		StateHandlers: types.StateHandlers{
+			"state y": func() error { return nil },
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, request.StateHandlers, 2)
+
+	c, _ := createMockClient(true)
+	defer stubPorts()()
+	pact.pactClient = c
+
+	_, err = pact.VerifyProviderRaw(types.VerifyRequest{
+		ProviderBaseURL: "http://www.foo.com",
+		PactURLs:        []string{"foo.json"},
+		StateHandlers: types.StateHandlers{
+			"state x": func() error { return nil },
+		},
+	})
+	assert.Error(t, err, "expected a state with two handlers to fail verification")
+}
+
+func TestPact_VerifyProviderRawSuiteHooks(t *testing.T) {
+	c, _ := createMockClient(true)
+	defer stubPorts()()
+
+	var calls []string
+	pact := &Pact{LogLevel: "DEBUG", pactClient: c}
+	_, err := pact.VerifyProviderRaw(types.VerifyRequest{
+		ProviderBaseURL: "http://www.foo.com",
+		PactURLs:        []string{"foo.json", "bar.json"},
+		BeforeSuite: func() error {
+			calls = append(calls, "before suite")
+			return nil
+		},
+		AfterSuite: func() error {
+			calls = append(calls, "after suite")
+			return nil
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"before suite", "after suite"}, calls)
+}
+
+func TestPact_VerifyProviderRawBeforeSuiteFail(t *testing.T) {
+	c, _ := createMockClient(true)
+	defer stubPorts()()
+
+	afterSuite := false
+	pact := &Pact{LogLevel: "DEBUG", pactClient: c}
+	_, err := pact.VerifyProviderRaw(types.VerifyRequest{
+		ProviderBaseURL: "http://www.foo.com",
+		PactURLs:        []string{"foo.json", "bar.json"},
+		BeforeSuite: func() error {
+			return errors.New("database unavailable")
+		},
+		AfterSuite: func() error {
+			afterSuite = true
+			return nil
+		},
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "database unavailable")
+	assert.False(t, afterSuite, "expected after suite hook not to run")
+}
+
+func TestPact_VerifyProviderRawAfterSuiteFail(t *testing.T) {
+	c, _ := createMockClient(true)
+	defer stubPorts()()
+
+	pact := &Pact{LogLevel: "DEBUG", pactClient: c}
+	_, err := pact.VerifyProviderRaw(types.VerifyRequest{
+		ProviderBaseURL: "http://www.foo.com",
+		PactURLs:        []string{"foo.json", "bar.json"},
+		AfterSuite: func() error {
+			return errors.New("unable to stop database")
+		},
+	})
+
+	assert.Error(t, err)
+}
+
 func TestPact_VerifyProvider(t *testing.T) {
 	c := newMockClient()
 	c.VerifyProviderResponse = make([]types.ProviderVerifierResponse, 0)
@@ -312,6 +1052,38 @@ func TestPact_VerifyProviderFail(t *testing.T) {
 	}
 }
 
+func TestPact_VerifyProviderPendingFailure(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "billy-bobby.json")
+	if err := ioutil.WriteFile(file, []byte(v4Pact), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newMockClient()
+	if err := json.Unmarshal([]byte(`[{"examples": [
+		{"full_description": "Verifying a pact between billy and bobby a request for a new feature with GET /new returns a response which has status code 200", "status": "failed", "pact": {"consumer_name": "billy"}},
+		{"full_description": "Verifying a pact between billy and bobby a request for an avatar with GET /avatar returns a response which has status code 200", "status": "passed", "pact": {"consumer_name": "billy"}}
+	], "summary": {"failure_count": 1}}]`), &c.VerifyProviderResponse); err != nil {
+		t.Fatal(err)
+	}
+	c.VerifyProviderError = errors.New("error verifying provider: exit status 1")
+	pact := &Pact{LogLevel: "DEBUG", pactClient: c}
+
+	res, err := pact.VerifyProviderRaw(types.VerifyRequest{
+		ProviderBaseURL: "http://www.foo.com",
+		PactURLs:        []string{file},
+	})
+
+	if err != nil {
+		t.Fatal("expected the failure of a pending interaction not to fail the verification:", err)
+	}
+	if res[0].Examples[0].Status != "pending" {
+		t.Fatalf("expected the failed example to be pending, got %q", res[0].Examples[0].Status)
+	}
+}
+
 func TestPact_VerifyProviderFailBadURL(t *testing.T) {
 	c := newMockClient()
 	c.VerifyProviderResponse = make([]types.ProviderVerifierResponse, 0)
@@ -348,6 +1120,81 @@ func TestPact_VerifyProviderBroker(t *testing.T) {
 	}
 }
 
+func TestVerificationMiddleware_Priority(t *testing.T) {
+	var calls []string
+	record := func(name string) proxy.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	request := types.VerifyRequest{
+		BeforeEach: func() error {
+			calls = append(calls, "before each")
+			return nil
+		},
+		StateHandlers: types.StateHandlers{
+			"state x": func() error {
+				calls = append(calls, "state handler")
+				return nil
+			},
+		},
+		Middleware: []types.ProxyMiddleware{
+			{Priority: types.PriorityStateHandlers - 1, Middleware: record("before state handlers")},
+			{Priority: 0, Middleware: record("first")},
+		},
+	}
+
+	m := verificationMiddleware(request, &interactionTracker{}, &timingRecorder{})
+	var handler http.Handler = http.NotFoundHandler()
+	for i := len(m) - 1; i >= 0; i-- {
+		handler = m[i](handler)
+	}
+
+	req := httptest.NewRequest("POST", providerStatesSetupPath, strings.NewReader(`{"states":["state x"]}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, []string{"first", "before each", "before state handlers", "state handler"}, calls)
+}
+
+func TestProxyConnectHost(t *testing.T) {
+	assert.Equal(t, "localhost", proxyConnectHost("", ""))
+	assert.Equal(t, "localhost", proxyConnectHost("", "0.0.0.0"))
+	assert.Equal(t, "localhost", proxyConnectHost("", "::"))
+	assert.Equal(t, "127.0.0.1", proxyConnectHost("", "127.0.0.1"))
+	assert.Equal(t, "10.0.0.5", proxyConnectHost("", "10.0.0.5"))
+	assert.Equal(t, "::1", proxyConnectHost("", "[::1]"))
+	assert.Equal(t, "::1", proxyConnectHost("[::1]", ""))
+	assert.Equal(t, "proxy.internal", proxyConnectHost("proxy.internal", "0.0.0.0"))
+}
+
+func TestUseTagSelectors(t *testing.T) {
+	request := types.VerifyRequest{
+		BrokerURL: "http://broker.local",
+		Tags:      []string{"main", "prod"},
+	}
+	useTagSelectors(&request)
+
+	assert.Empty(t, request.Tags)
+	assert.Equal(t, []types.ConsumerVersionSelector{
+		{Tag: "main", Latest: true},
+		{Tag: "prod", Latest: true},
+	}, request.ConsumerVersionSelectors)
+
+	request = types.VerifyRequest{
+		BrokerURL:                "http://broker.local",
+		Tags:                     []string{"main"},
+		ConsumerVersionSelectors: []types.ConsumerVersionSelector{{Tag: "prod"}},
+	}
+	useTagSelectors(&request)
+
+	assert.Equal(t, []string{"main"}, request.Tags)
+	assert.Len(t, request.ConsumerVersionSelectors, 1)
+}
+
 func TestPact_VerifyProviderRawFail(t *testing.T) {
 	c, _ := createMockClient(false)
 	defer stubPorts()()
@@ -439,6 +1286,34 @@ func TestPact_BeforeEachNotSetupPath(t *testing.T) {
 		t.Error("expected http handler to be invoked")
 	}
 }
+func TestPact_BeforeEachFail(t *testing.T) {
+	req, err := http.NewRequest("GET", "/__setup", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	mw := BeforeEachMiddleware(func() error {
+		return errors.New("unable to create token")
+	})
+	mw(dummyHandler("X-Dummy-Handler")).ServeHTTP(rr, req)
+
+	// expect the state setup to be aborted
+	if h := rr.HeaderMap.Get("X-Dummy-Handler"); h != "" {
+		t.Error("expected http handler not to be invoked")
+	}
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rr.Code)
+	}
+
+	if !strings.Contains(rr.Body.String(), "unable to create token") {
+		t.Errorf("expected the hook error in the response, got '%s'", rr.Body.String())
+	}
+}
+
 func TestPact_AfterEach(t *testing.T) {
 	var called bool
 
@@ -466,6 +1341,99 @@ func TestPact_AfterEach(t *testing.T) {
 		t.Error("expected http handler to be invoked")
 	}
 }
+
+func TestPact_AfterEachFails(t *testing.T) {
+	var called bool
+
+	req, err := http.NewRequest("GET", "/blah", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	mw := AfterEachMiddleware(func() error {
+		called = true
+		return errors.New("unable to reset the database")
+	})
+
+	provider := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"name": "Billy"}`))
+	})
+	mw(provider).ServeHTTP(rr, req)
+
+	// Expect the hook error to leave the provider response untouched
+	assert.True(t, called, "expected after hook to have been called")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{"name": "Billy"}`, rr.Body.String())
+}
+
+func TestPact_AfterEachStreams(t *testing.T) {
+	req, err := http.NewRequest("GET", "/events", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	var called bool
+	mw := AfterEachMiddleware(func() error {
+		called = true
+		return nil
+	})
+
+	provider := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: 1\n\n"))
+
+		flusher, ok := w.(http.Flusher)
+		if assert.True(t, ok, "expected the response writer to be an http.Flusher") {
+			flusher.Flush()
+		}
+		assert.True(t, rr.Flushed, "expected the event to be sent before the handler returns")
+		assert.False(t, called, "expected the after hook to run after the response")
+	})
+	mw(provider).ServeHTTP(rr, req)
+
+	assert.True(t, called)
+	assert.Equal(t, "data: 1\n\n", rr.Body.String())
+}
+
+func TestPact_AfterEachPanic(t *testing.T) {
+	var called bool
+
+	req, err := http.NewRequest("GET", "/blah", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	mw := AfterEachMiddleware(func() error {
+		called = true
+		return nil
+	})
+
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("request filter failed")
+	})
+	proxy.RecoveryMiddleware(mw(panicking)).ServeHTTP(rr, req)
+
+	// Expect hook to be called despite the panic
+	if !called {
+		t.Error("expected after hook to have been called")
+	}
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rr.Code)
+	}
+}
+
 func TestPact_AfterEachSetupPath(t *testing.T) {
 	var called bool
 
@@ -518,7 +1486,7 @@ func TestPact_StateHandlerMiddlewareStateHandlerExists(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	mw := stateHandlerMiddleware(handlers)
+	mw := stateHandlerMiddleware(types.VerifyRequest{StateHandlers: handlers})
 	mw(dummyHandler("X-Dummy-Handler")).ServeHTTP(rr, req)
 
 	// Expect state handler
@@ -549,7 +1517,7 @@ func TestPact_StateHandlerMiddlewareStateHandlerNotExists(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	mw := stateHandlerMiddleware(handlers)
+	mw := stateHandlerMiddleware(types.VerifyRequest{StateHandlers: handlers})
 	mw(dummyHandler("X-Dummy-Handler")).ServeHTTP(rr, req)
 
 	// Expect state handler
@@ -563,6 +1531,71 @@ func TestPact_StateHandlerMiddlewareStateHandlerNotExists(t *testing.T) {
 	}
 }
 
+func TestPact_StateHandlerMiddlewareFailOnMissingStateHandler(t *testing.T) {
+	handlers := map[string]types.StateHandler{}
+
+	req, err := http.NewRequest("POST", "/__setup", strings.NewReader(`{
+		"states": ["state x"],
+		"consumer": "test",
+		"provider": "provider"
+		}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	mw := stateHandlerMiddleware(types.VerifyRequest{StateHandlers: handlers, FailOnMissingStateHandler: true})
+	mw(dummyHandler("X-Dummy-Handler")).ServeHTTP(rr, req)
+
+	// expect 500 naming the missing state
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("want statusCode to be 500, got %v", status)
+	}
+
+	if !strings.Contains(rr.Body.String(), "state x") {
+		t.Errorf("want the missing state in the response, got '%s'", rr.Body.String())
+	}
+}
+
+func TestPact_StateHandlerMiddlewareDefaultStateHandler(t *testing.T) {
+	var state string
+	var params map[string]interface{}
+
+	req, err := http.NewRequest("POST", "/__setup", strings.NewReader(`{
+		"states": ["user exists"],
+		"params": {"id": "1"},
+		"consumer": "test",
+		"provider": "provider"
+		}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	mw := stateHandlerMiddleware(types.VerifyRequest{
+		StateHandlers:             map[string]types.StateHandler{},
+		FailOnMissingStateHandler: true,
+		DefaultStateHandler: func(s string, p map[string]interface{}) error {
+			state = s
+			params = p
+			return nil
+		},
+	})
+	mw(dummyHandler("X-Dummy-Handler")).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("want statusCode to be 200, got %v", status)
+	}
+
+	if state != "user exists" || params["id"] != "1" {
+		t.Errorf("want default state handler to be called with the state and params, got '%s' %v", state, params)
+	}
+}
+
 func TestPact_StateHandlerMiddlewareStateHandlerError(t *testing.T) {
 	handlers := map[string]types.StateHandler{
 		"state x": func() error {
@@ -582,7 +1615,7 @@ func TestPact_StateHandlerMiddlewareStateHandlerError(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	mw := stateHandlerMiddleware(handlers)
+	mw := stateHandlerMiddleware(types.VerifyRequest{StateHandlers: handlers})
 	mw(dummyHandler("X-Dummy-Handler")).ServeHTTP(rr, req)
 
 	// expect 500
@@ -596,6 +1629,106 @@ func TestPact_StateHandlerMiddlewareStateHandlerError(t *testing.T) {
 	}
 }
 
+func TestPact_StateHandlerMiddlewareStateHandlerTimeout(t *testing.T) {
+	handlers := types.ContextStateHandlers{
+		"state x": func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		},
+	}
+
+	req, err := http.NewRequest("POST", "/__setup", strings.NewReader(`{
+		"states": ["state x"],
+		"consumer": "test",
+		"provider": "provider"
+		}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	mw := stateHandlerMiddleware(types.VerifyRequest{ContextStateHandlers: handlers, StateHandlerTimeout: 10 * time.Millisecond})
+	mw(dummyHandler("X-Dummy-Handler")).ServeHTTP(rr, req)
+
+	// expect 500 explaining the timeout
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("want statusCode to be 500, got %v", status)
+	}
+
+	if !strings.Contains(rr.Body.String(), "state setup timed out") {
+		t.Errorf("want a timeout error in the response, got '%s'", rr.Body.String())
+	}
+}
+
+func TestPact_StateHandlerMiddlewareMultipleStates(t *testing.T) {
+	var calls []string
+	handler := func(state string) types.StateHandler {
+		return func() error {
+			calls = append(calls, state)
+			return nil
+		}
+	}
+
+	mw := stateHandlerMiddleware(types.VerifyRequest{StateHandlers: types.StateHandlers{
+		"state x": handler("state x"),
+		"state y": handler("state y"),
+	}})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/__setup", strings.NewReader(`{"states": ["state y", "state x"], "consumer": "test"}`))
+	mw(dummyHandler("X-Dummy-Handler")).ServeHTTP(rr, req)
+
+	// expect each state to be set up, in order
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("want statusCode to be 200, got %v", status)
+	}
+	assert.Equal(t, []string{"state y", "state x"}, calls)
+
+	// older verifiers only send the state
+	calls = nil
+	req = httptest.NewRequest("POST", "/__setup", strings.NewReader(`{"state": "state x", "consumer": "test"}`))
+	mw(dummyHandler("X-Dummy-Handler")).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, []string{"state x"}, calls)
+
+	// teardown requests don't set up the states again
+	calls = nil
+	req = httptest.NewRequest("POST", "/__setup", strings.NewReader(`{"state": "state x", "states": ["state x"], "action": "teardown"}`))
+	rr = httptest.NewRecorder()
+	mw(dummyHandler("X-Dummy-Handler")).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, calls)
+}
+
+func TestPact_StateHandlerMiddlewareMultipleStatesMissing(t *testing.T) {
+	var called bool
+	mw := stateHandlerMiddleware(types.VerifyRequest{
+		StateHandlers: types.StateHandlers{
+			"state x": func() error {
+				called = true
+				return nil
+			},
+		},
+		FailOnMissingStateHandler: true,
+	})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/__setup", strings.NewReader(`{"states": ["state x", "state y"], "consumer": "test"}`))
+	mw(dummyHandler("X-Dummy-Handler")).ServeHTTP(rr, req)
+
+	// expect no state to be set up when any is missing
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("want statusCode to be 500, got %v", status)
+	}
+	if !strings.Contains(rr.Body.String(), "state y") {
+		t.Errorf("want the missing state in the response, got '%s'", rr.Body.String())
+	}
+	if called {
+		t.Error("expected state x not to be set up")
+	}
+}
+
 func TestPact_StateHandlerMiddlewarePassThroughInvalidPath(t *testing.T) {
 	handlers := map[string]types.StateHandler{}
 
@@ -607,7 +1740,7 @@ func TestPact_StateHandlerMiddlewarePassThroughInvalidPath(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	mw := stateHandlerMiddleware(handlers)
+	mw := stateHandlerMiddleware(types.VerifyRequest{StateHandlers: handlers})
 	mw(dummyHandler("X-Dummy-Handler")).ServeHTTP(rr, req)
 
 	// expect http handler to have been called
@@ -680,7 +1813,7 @@ func TestPact_MessageVerification(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 
-		h := messageVerificationHandler(createMessageHandlers(&called, nil), createStateHandlers(&called, nil))
+		h := messageVerificationHandler(createMessageHandlers(&called, nil), createStateHandlers(&called, nil), nil, false)
 		h.ServeHTTP(rr, req)
 
 		// Expect state handler
@@ -704,7 +1837,7 @@ func TestPact_MessageVerification(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 
-		h := messageVerificationHandler(createMessageHandlers(&called, nil), createStateHandlers(&called, nil))
+		h := messageVerificationHandler(createMessageHandlers(&called, nil), createStateHandlers(&called, nil), nil, false)
 		h.ServeHTTP(rr, req)
 
 		// Expect state handler
@@ -724,7 +1857,7 @@ func TestPact_MessageVerification(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 
-		h := messageVerificationHandler(createMessageHandlers(&called, nil), createStateHandlers(&called, errors.New("state handler failed")))
+		h := messageVerificationHandler(createMessageHandlers(&called, nil), createStateHandlers(&called, errors.New("state handler failed")), nil, false)
 		h.ServeHTTP(rr, req)
 
 		// Expect state handler
@@ -744,7 +1877,7 @@ func TestPact_MessageVerification(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 
-		h := messageVerificationHandler(createMessageHandlers(&called, errors.New("message handler failed")), createStateHandlers(&called, nil))
+		h := messageVerificationHandler(createMessageHandlers(&called, errors.New("message handler failed")), createStateHandlers(&called, nil), nil, false)
 		h.ServeHTTP(rr, req)
 
 		// Expect state handler
@@ -932,7 +2065,7 @@ func TestPact_MessageVerification(t *testing.T) {
 
 		rr := httptest.NewRecorder()
 
-		h := messageVerificationHandler(createMessageHandlers(&called, nil), createStateHandlers(&called, nil))
+		h := messageVerificationHandler(createMessageHandlers(&called, nil), createStateHandlers(&called, nil), nil, false)
 		h.ServeHTTP(rr, req)
 
 		// Expect state handler
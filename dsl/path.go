@@ -0,0 +1,98 @@
+package dsl
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PathTerm specifies that a request path must match the regular expression,
+// as Term does, anchoring it to match the whole path, e.g.
+//
+//	Path: PathTerm("/users/1", `/users/\d+`)
+//
+// Panics if the regular expression is invalid, or the example doesn't match
+// it.
+func PathTerm(example string, regex string) Matcher {
+	if !strings.HasPrefix(regex, "^") {
+		regex = "^" + regex
+	}
+	if !strings.HasSuffix(regex, "$") {
+		regex = regex + "$"
+	}
+	return Term(example, regex)
+}
+
+// PathTemplate specifies that a request path must match the template, where
+// each {parameter} is any path segment, or matches its own regular
+// expression, e.g. {id:\d+}. The example is the template with the examples
+// of its parameters, e.g.
+//
+//	Path: PathTemplate("/users/{id:\\d+}/orders/{order}", map[string]string{"id": "1", "order": "a1"})
+//
+// has the example "/users/1/orders/a1". Panics if the template is invalid,
+// a parameter has no example, or an example doesn't match its parameter.
+func PathTemplate(template string, examples map[string]string) Matcher {
+	var example, regex strings.Builder
+	used := map[string]bool{}
+
+	rest := template
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			break
+		}
+		end := closingBrace(rest, start)
+		if end < 0 {
+			panic(fmt.Sprintf("match: unterminated parameter in the path template %q", template))
+		}
+
+		name, pattern := rest[start+1:end], `[^/]+`
+		if i := strings.Index(name, ":"); i >= 0 {
+			name, pattern = name[:i], name[i+1:]
+		}
+		value, ok := examples[name]
+		if !ok {
+			panic(fmt.Sprintf("match: no example of the parameter %q of the path template %q", name, template))
+		}
+		used[name] = true
+
+		example.WriteString(rest[:start] + value)
+		regex.WriteString(regexp.QuoteMeta(rest[:start]) + "(" + pattern + ")")
+		rest = rest[end+1:]
+	}
+	example.WriteString(rest)
+	regex.WriteString(regexp.QuoteMeta(rest))
+
+	var unused []string
+	for name := range examples {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		panic(fmt.Sprintf("match: the path template %q has no parameters %s", template, strings.Join(unused, ", ")))
+	}
+
+	return Term(example.String(), "^"+regex.String()+"$")
+}
+
+// closingBrace returns the index of the brace closing the one at start,
+// allowing the braces of regular expressions such as \d{4}, or -1
+func closingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
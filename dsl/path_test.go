@@ -0,0 +1,71 @@
+package dsl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathTerm(t *testing.T) {
+	assert.Equal(t, Term("/users/1", `^/users/\d+$`), PathTerm("/users/1", `/users/\d+`))
+	assert.Equal(t, Term("/users/1", `^/users/\d+$`), PathTerm("/users/1", `^/users/\d+$`))
+
+	assert.Panics(t, func() {
+		PathTerm("/users/1/orders", `/users/\d+`)
+	})
+}
+
+func TestPathTemplate(t *testing.T) {
+	matcher := PathTemplate(`/users/{id:\d+}/orders/{order}.json`, map[string]string{"id": "1", "order": "a1"})
+	assert.Equal(t, Term("/users/1/orders/a1.json", `^/users/(\d+)/orders/([^/]+)\.json$`), matcher)
+	assert.Equal(t, Term("/years/2020", `^/years/(\d{4})$`), PathTemplate(`/years/{year:\d{4}}`, map[string]string{"year": "2020"}))
+
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	pact := &Pact{
+		Consumer:         "My Consumer",
+		Provider:         "My Provider",
+		PactDir:          dir,
+		LogLevel:         "ERROR",
+		NativeMockServer: true,
+	}
+	defer pact.Teardown()
+
+	verify := func(path string) error {
+		pact.
+			AddInteraction().
+			UponReceiving("a request for an order").
+			WithRequest(Request{Method: "GET", Path: matcher}).
+			WillRespondWith(Response{Status: 200})
+
+		return pact.Verify(func() error {
+			res, err := http.Get(fmt.Sprintf("http://localhost:%d%s", pact.Server.Port, path))
+			if err == nil {
+				res.Body.Close()
+			}
+			return err
+		})
+	}
+	assert.NoError(t, verify("/users/42/orders/b-2.json"))
+	assert.Error(t, verify("/users/x/orders/b-2.json"))
+
+	for name, examples := range map[string]map[string]string{
+		"missing example":   {"id": "1"},
+		"unknown parameter": {"id": "1", "order": "a1", "user": "2"},
+		"example not valid": {"id": "x", "order": "a1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Panics(t, func() {
+				PathTemplate(`/users/{id:\d+}/orders/{order}.json`, examples)
+			})
+		})
+	}
+	assert.Panics(t, func() {
+		PathTemplate(`/users/{id`, map[string]string{"id": "1"})
+	})
+}
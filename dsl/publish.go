@@ -44,6 +44,9 @@ func (p *Publisher) Publish(request types.PublishRequest) error {
 		p.pactClient = c
 	}
 
+	logRedactor.AddSecret(request.BrokerPassword, request.BrokerToken)
+	request.Branch = detectedBranch(request.Branch, request.DisableBranchDetection)
+
 	err := request.Validate()
 
 	if err != nil {
@@ -64,7 +67,8 @@ func (p *Publisher) setupLogging() {
 			MinLevel: logutils.LogLevel(p.LogLevel),
 			Writer:   os.Stderr,
 		}
-		log.SetOutput(p.logFilter)
+		logRedactor.SetWriter(p.logFilter)
+		log.SetOutput(logRedactor)
 	}
 	log.Println("[DEBUG] pact setup logging")
 }
@@ -0,0 +1,32 @@
+package dsl
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/pact-foundation/pact-go/utils"
+)
+
+// logRedactor masks broker credentials and sensitive headers in all log output
+var logRedactor = &utils.Redactor{}
+
+// redactSecrets registers the credentials of a verification with the log
+// redactor, including the values of sensitive custom provider headers
+func redactSecrets(request types.VerifyRequest) {
+	logRedactor.AddSecret(request.BrokerPassword, request.BrokerToken)
+
+	for _, h := range request.CustomProviderHeaders {
+		i := strings.Index(h, ":")
+		if i <= 0 {
+			continue
+		}
+
+		name := http.CanonicalHeaderKey(strings.TrimSpace(h[:i]))
+		for _, sensitive := range utils.SensitiveHeaders {
+			if name == sensitive {
+				logRedactor.AddSecret(strings.TrimSpace(h[i+1:]))
+			}
+		}
+	}
+}
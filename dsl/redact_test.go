@@ -0,0 +1,29 @@
+package dsl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/types"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	redactSecrets(types.VerifyRequest{
+		BrokerPassword: "broker-pa55",
+		BrokerToken:    "broker-t0ken",
+		CustomProviderHeaders: []string{
+			"authorization: Basic cGFjdDpwYWN0",
+			"X-Trace-Id: visible",
+		},
+	})
+
+	out := string(logRedactor.Redact([]byte("args: --broker-password broker-pa55 --broker-token broker-t0ken --custom-provider-header authorization: Basic cGFjdDpwYWN0 --custom-provider-header X-Trace-Id: visible")))
+	for _, secret := range []string{"broker-pa55", "broker-t0ken", "cGFjdDpwYWN0"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("expected %q to be redacted, got %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "X-Trace-Id: visible") {
+		t.Fatalf("expected non sensitive headers to be logged, got %s", out)
+	}
+}
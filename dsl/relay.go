@@ -0,0 +1,56 @@
+package dsl
+
+import (
+	"log"
+
+	"github.com/pact-foundation/pact-go/broker"
+	"github.com/pact-foundation/pact-go/types"
+)
+
+// startBrokerRelay routes the verifier's broker traffic through a local relay
+// when the request uses broker options the verifier CLI can't support itself,
+// such as a refreshing token source, client certificates, a custom HTTP client,
+// retries or caching, or when the pacts it fetches must be transformed, e.g.
+// from version 4 of the specification. The verification request is updated
+// to point at the relay, and the returned function stops it.
+func startBrokerRelay(verificationRequest *types.VerifyRequest, transform func([]byte) ([]byte, error)) (func(), error) {
+	if verificationRequest.BrokerURL == "" || (transform == nil && !requiresBrokerRelay(*verificationRequest)) {
+		return func() {}, nil
+	}
+
+	relay, err := broker.NewTransformingRelay(broker.NewClientFromVerifyRequest(*verificationRequest), transform)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("[DEBUG] pact provider verification: relaying broker requests via", relay.URL)
+
+	pactURLs := make([]string, len(verificationRequest.PactURLs))
+	for i, u := range verificationRequest.PactURLs {
+		pactURLs[i] = relay.RewriteURL(u)
+	}
+
+	verificationRequest.BrokerURL = relay.URL
+	verificationRequest.PactURLs = pactURLs
+	verificationRequest.BrokerUsername = ""
+	verificationRequest.BrokerPassword = ""
+	verificationRequest.BrokerToken = ""
+	verificationRequest.BrokerTokenSource = nil
+	verificationRequest.BrokerTLSConfig = nil
+	verificationRequest.BrokerHTTPClient = nil
+	verificationRequest.BrokerRetry = types.RetryPolicy{}
+	verificationRequest.BrokerCacheDir = ""
+	verificationRequest.BrokerOffline = false
+
+	return func() { relay.Close() }, nil
+}
+
+// requiresBrokerRelay reports whether the request uses broker options
+// that can only be honoured by the relay
+func requiresBrokerRelay(request types.VerifyRequest) bool {
+	return request.BrokerTokenSource != nil ||
+		request.BrokerTLSConfig != nil ||
+		request.BrokerHTTPClient != nil ||
+		request.BrokerRetry.Enabled() ||
+		request.BrokerCacheDir != ""
+}
@@ -0,0 +1,70 @@
+package dsl
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartBrokerRelay(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	request := types.VerifyRequest{
+		BrokerURL:   server.URL,
+		PactURLs:    []string{server.URL + "/pacts/provider/bobby/consumer/billy/latest", "/tmp/local.json"},
+		BrokerToken: "static",
+		BrokerTokenSource: types.TokenSourceFunc(func() (string, error) {
+			return "refreshed", nil
+		}),
+	}
+
+	stop, err := startBrokerRelay(&request, nil)
+	if err != nil {
+		t.Fatalf("unable to start relay: %v", err)
+	}
+	defer stop()
+
+	assert.NotEqual(t, server.URL, request.BrokerURL)
+	assert.True(t, strings.HasPrefix(request.PactURLs[0], request.BrokerURL))
+	assert.Equal(t, "/tmp/local.json", request.PactURLs[1])
+	assert.Empty(t, request.BrokerToken)
+	assert.Nil(t, request.BrokerTokenSource)
+
+	res, err := http.Get(request.PactURLs[0])
+	if err != nil {
+		t.Fatalf("relay request failed: %v", err)
+	}
+	res.Body.Close()
+	assert.Equal(t, "Bearer refreshed", auth)
+}
+
+func TestRequiresBrokerRelay(t *testing.T) {
+	assert.False(t, requiresBrokerRelay(types.VerifyRequest{BrokerToken: "static"}))
+	assert.True(t, requiresBrokerRelay(types.VerifyRequest{BrokerTLSConfig: &tls.Config{}}))
+	assert.True(t, requiresBrokerRelay(types.VerifyRequest{BrokerHTTPClient: &http.Client{}}))
+	assert.True(t, requiresBrokerRelay(types.VerifyRequest{BrokerRetry: types.RetryPolicy{MaxRetries: 3}}))
+	assert.True(t, requiresBrokerRelay(types.VerifyRequest{BrokerCacheDir: "/tmp/pacts"}))
+}
+
+func TestStartBrokerRelay_NotRequired(t *testing.T) {
+	request := types.VerifyRequest{
+		BrokerURL:   "http://broker.local",
+		BrokerToken: "static",
+	}
+
+	stop, err := startBrokerRelay(&request, nil)
+	assert.NoError(t, err)
+	stop()
+
+	assert.Equal(t, "http://broker.local", request.BrokerURL)
+	assert.Equal(t, "static", request.BrokerToken)
+}
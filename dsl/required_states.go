@@ -0,0 +1,178 @@
+package dsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/pact-foundation/pact-go/broker"
+	"github.com/pact-foundation/pact-go/types"
+)
+
+// pactInteraction holds the provider states of an interaction or message,
+// in either the v2 or v3 format of the specification
+type pactInteraction struct {
+	ProviderState  string              `json:"providerState"`
+	ProviderStates []pactProviderState `json:"providerStates"`
+}
+
+type pactProviderState struct {
+	Name   string                 `json:"name"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// RequiredStates loads the pacts selected by the request, either PactURLs or
+// the pacts for the provider in BrokerURL, and returns the distinct provider
+// states they reference, in the order they are first seen. Useful to check
+// that every state has a handler before running the verification.
+func (p *Pact) RequiredStates(request types.VerifyRequest) ([]types.RequiredState, error) {
+	if request.Provider == "" {
+		request.Provider = p.Provider
+	}
+
+	pacts, err := loadPacts(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []types.RequiredState
+	index := map[string]int{}
+	for _, pact := range pacts {
+		for _, raw := range append(pact.Interactions, pact.Messages...) {
+			var interaction pactInteraction
+			if err := json.Unmarshal(raw, &interaction); err != nil {
+				return nil, fmt.Errorf("unable to decode interaction of the pact between %s and %s: %v", pact.Consumer.Name, pact.Provider.Name, err)
+			}
+
+			required := interaction.ProviderStates
+			if len(required) == 0 && interaction.ProviderState != "" {
+				required = append(required, pactProviderState{Name: interaction.ProviderState})
+			}
+
+			for _, s := range required {
+				params, _ := json.Marshal(s.Params)
+				key := s.Name + "\x00" + string(params)
+
+				i, ok := index[key]
+				if !ok {
+					i = len(states)
+					index[key] = i
+					states = append(states, types.RequiredState{Name: s.Name, Params: s.Params})
+				}
+				states[i].Consumers = appendConsumer(states[i].Consumers, pact.Consumer.Name)
+			}
+		}
+	}
+
+	return states, nil
+}
+
+// appendConsumer adds a consumer to a sorted list of distinct consumers
+func appendConsumer(consumers []string, consumer string) []string {
+	i := sort.SearchStrings(consumers, consumer)
+	if i < len(consumers) && consumers[i] == consumer {
+		return consumers
+	}
+
+	consumers = append(consumers, "")
+	copy(consumers[i+1:], consumers[i:])
+	consumers[i] = consumer
+
+	return consumers
+}
+
+// loadPacts reads the pacts a verification would verify. Files are read
+// from disk, while remote pacts are fetched with the broker credentials of
+// the request.
+func loadPacts(request types.VerifyRequest) ([]*broker.Pact, error) {
+	client := broker.NewClientFromVerifyRequest(request)
+
+	var pacts []*broker.Pact
+	for _, u := range request.PactURLs {
+		pact, err := loadPact(client, u)
+		if err != nil {
+			return nil, err
+		}
+		pacts = append(pacts, pact)
+	}
+
+	if request.BrokerURL == "" {
+		return pacts, nil
+	}
+
+	hrefs, err := brokerPactURLs(client, request)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, href := range hrefs {
+		log.Println("[DEBUG] fetching pact for required states:", href)
+		pact, err := client.Pact(href)
+		if err != nil {
+			return nil, err
+		}
+		pacts = append(pacts, pact)
+	}
+
+	return pacts, nil
+}
+
+func loadPact(client *broker.Client, location string) (*broker.Pact, error) {
+	if u, err := url.Parse(location); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return client.Pact(location)
+	}
+
+	raw, err := ioutil.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+
+	pact := &broker.Pact{Raw: raw}
+	if err := json.Unmarshal(raw, pact); err != nil {
+		return nil, fmt.Errorf("unable to decode pact from %s: %v", location, err)
+	}
+
+	return pact, nil
+}
+
+// brokerPactURLs returns the links to the pacts the broker selects for the
+// provider, using the consumer version selectors (or tags) of the request
+func brokerPactURLs(client *broker.Client, request types.VerifyRequest) ([]string, error) {
+	useTagSelectors(&request)
+
+	var hrefs []string
+	if len(request.ConsumerVersionSelectors) == 0 {
+		links, err := client.LatestPacts(request.Provider)
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range links {
+			hrefs = append(hrefs, link.Href)
+		}
+		return hrefs, nil
+	}
+
+	selection := broker.PactsForVerificationRequest{
+		ProviderVersionTags:      request.ProviderTags,
+		ProviderVersionBranch:    detectedBranch(request.ProviderBranch, request.DisableBranchDetection),
+		ConsumerVersionSelectors: request.ConsumerVersionSelectors,
+		IncludePendingStatus:     request.EnablePending,
+	}
+	if request.IncludeWIPPactsSince != nil {
+		selection.IncludeWipPactsSince = request.IncludeWIPPactsSince.Format(time.RFC3339)
+	}
+
+	pacts, err := client.PactsForVerification(request.Provider, selection)
+	if err != nil {
+		return nil, err
+	}
+	for _, pact := range pacts {
+		hrefs = append(hrefs, pact.URL())
+	}
+
+	return hrefs, nil
+}
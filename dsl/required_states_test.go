@@ -0,0 +1,88 @@
+package dsl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPact_RequiredStates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pact-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	v2 := filepath.Join(dir, "billy-bobby.json")
+	ioutil.WriteFile(v2, []byte(`{
+		"consumer": {"name": "billy"},
+		"provider": {"name": "bobby"},
+		"interactions": [
+			{"description": "get user", "providerState": "user 1 exists"},
+			{"description": "list users"}
+		]
+	}`), 0644)
+
+	v3 := filepath.Join(dir, "anne-bobby.json")
+	ioutil.WriteFile(v3, []byte(`{
+		"consumer": {"name": "anne"},
+		"provider": {"name": "bobby"},
+		"interactions": [
+			{"description": "get user", "providerStates": [{"name": "user 1 exists"}]},
+			{"description": "get order", "providerStates": [{"name": "order exists", "params": {"id": 7}}]},
+			{"description": "get other order", "providerStates": [{"name": "order exists", "params": {"id": 8}}]}
+		],
+		"messages": [
+			{"description": "user created", "providerStates": [{"name": "user 1 exists"}]}
+		]
+	}`), 0644)
+
+	pact := &Pact{Provider: "bobby"}
+	states, err := pact.RequiredStates(types.VerifyRequest{PactURLs: []string{v2, v3}})
+	assert.NoError(t, err)
+	assert.Equal(t, []types.RequiredState{
+		{Name: "user 1 exists", Consumers: []string{"anne", "billy"}},
+		{Name: "order exists", Params: map[string]interface{}{"id": float64(7)}, Consumers: []string{"anne"}},
+		{Name: "order exists", Params: map[string]interface{}{"id": float64(8)}, Consumers: []string{"anne"}},
+	}, states)
+}
+
+func TestPact_RequiredStatesBroker(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/hal+json")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, `{"_links": {"pb:provider-pacts-for-verification": {"href": "%s/pacts/provider/{provider}/for-verification", "templated": true}}}`, server.URL)
+		case "/pacts/provider/bobby/for-verification":
+			fmt.Fprintf(w, `{"_embedded": {"pacts": [{"_links": {"self": {"href": "%s/pacts/provider/bobby/consumer/billy/version/1"}}}]}}`, server.URL)
+		case "/pacts/provider/bobby/consumer/billy/version/1":
+			fmt.Fprint(w, `{"consumer": {"name": "billy"}, "provider": {"name": "bobby"}, "interactions": [{"providerState": "user 1 exists"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	pact := &Pact{Provider: "bobby"}
+	states, err := pact.RequiredStates(types.VerifyRequest{
+		BrokerURL:              server.URL,
+		Tags:                   []string{"master"},
+		DisableBranchDetection: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []types.RequiredState{{Name: "user 1 exists", Consumers: []string{"billy"}}}, states)
+}
+
+func TestPact_RequiredStatesMissingPact(t *testing.T) {
+	pact := &Pact{Provider: "bobby"}
+	_, err := pact.RequiredStates(types.VerifyRequest{PactURLs: []string{"/does/not/exist.json"}})
+	assert.Error(t, err)
+}
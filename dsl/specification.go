@@ -0,0 +1,370 @@
+package dsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Versions of the Pact Specification pacts can be written and verified in
+const (
+	minSpecificationVersion = 1
+	maxSpecificationVersion = 4
+)
+
+// matcherVersions are the earliest versions of the specification supporting
+// each matcher, keyed by its "json_class" or "pact:matcher:type" in consumer
+// tests, and its "match" in the matching rules of pacts
+var matcherVersions = map[string]int{
+	"Pact::SomethingLike": 2,
+	"Pact::ArrayLike":     2,
+	"Pact::Term":          2,
+
+	"type":  2,
+	"regex": 2,
+
+	"equality":      3,
+	"include":       3,
+	"integer":       3,
+	"decimal":       3,
+	"number":        3,
+	"timestamp":     3,
+	"datetime":      3,
+	"date":          3,
+	"time":          3,
+	"null":          3,
+	"boolean":       3,
+	"contentType":   3,
+	"values":        3,
+	"arrayContains": 3,
+
+	"statusCode": 4,
+	"notEmpty":   4,
+	"semver":     4,
+	"eachKey":    4,
+	"eachValue":  4,
+}
+
+// generatorVersion is the earliest version of the specification supporting
+// generators
+const generatorVersion = 3
+
+// checkSpecificationVersion rejects versions of the specification that
+// can't be written or verified
+func checkSpecificationVersion(version int) error {
+	if version < minSpecificationVersion || version > maxSpecificationVersion {
+		return fmt.Errorf("unsupported pact specification version %d, expected %d to %d", version, minSpecificationVersion, maxSpecificationVersion)
+	}
+	return nil
+}
+
+// prepareInteractions rejects interactions that can't be written to a pact
+// of the configured version of the specification, and returns their content
+// for the mock server. Matchers of later versions of the specification are
+// replaced by their version 2 equivalents, if any, for pacts of earlier
+// versions and the Ruby mock service.
+func (p *Pact) prepareInteractions() ([]interface{}, error) {
+	if err := checkSpecificationVersion(p.SpecificationVersion); err != nil {
+		return nil, err
+	}
+	if p.SpecificationVersion >= 4 && !p.NativeMockServer {
+		return nil, fmt.Errorf("version %d of the pact specification requires the NativeMockServer", p.SpecificationVersion)
+	}
+
+	contents := make([]interface{}, len(p.Interactions))
+	for n, i := range p.Interactions {
+		content, err := decode(i)
+		if err != nil {
+			return nil, err
+		}
+		content = downgradeMatchers(content, p.supportedVersion(p.NativeMockServer))
+
+		required, err := checkInteractionVersion(i.Description, content, p.SpecificationVersion)
+		if err != nil {
+			return nil, err
+		}
+		if required >= 3 && !p.NativeMockServer {
+			return nil, fmt.Errorf("%q uses matchers or generators of version %d of the pact specification, which require the NativeMockServer", i.Description, required)
+		}
+		contents[n] = content
+	}
+	return contents, nil
+}
+
+// prepareMessage rejects a message that can't be written to a message pact,
+// and returns it with its content prepared as prepareInteractions does.
+// Message pacts are written in version 3 of the specification, unless a
+// later version is configured.
+func (p *Pact) prepareMessage(message *Message) (*Message, error) {
+	if err := checkSpecificationVersion(p.SpecificationVersion); err != nil {
+		return nil, err
+	}
+
+	// Only version 4 messages are written natively
+	native := p.NativeMockServer && p.SpecificationVersion >= 4
+
+	prepared := *message
+	content, err := decode(message.Content)
+	if err != nil {
+		return nil, err
+	}
+	prepared.Content = downgradeMatchers(content, p.supportedVersion(native))
+
+	version := p.SpecificationVersion
+	if version < 3 {
+		version = 3
+	}
+	content, err = decode(&prepared)
+	if err != nil {
+		return nil, err
+	}
+	required, err := checkInteractionVersion(message.Description, content, version)
+	if err != nil {
+		return nil, err
+	}
+	if required >= 3 && !native {
+		return nil, fmt.Errorf("%q uses matchers or generators of version %d of the pact specification, which require the NativeMockServer and version 4 message pacts", message.Description, required)
+	}
+	return &prepared, nil
+}
+
+// supportedVersion returns the latest version of the specification whose
+// matchers can be used: only the native mock server supports matchers of
+// later versions than 2
+func (p *Pact) supportedVersion(native bool) int {
+	if !native {
+		return 2
+	}
+	return p.SpecificationVersion
+}
+
+// decode returns the JSON representation of a value, as decoded by the mock
+// server
+func decode(v interface{}) (interface{}, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	err = json.Unmarshal(content, &decoded)
+	return decoded, err
+}
+
+// v2Equivalents return the version 2 matcher of an example that a decoded
+// matcher of a later version of the specification, or with a generator, is
+// replaced by when it isn't supported, keyed by its "pact:matcher:type"
+var v2Equivalents = map[string]func(example interface{}, m map[string]interface{}) Matcher{
+	"type":    likeExample,
+	"integer": likeExample,
+	"decimal": likeExample,
+	"boolean": likeExample,
+	"semver": func(example interface{}, m map[string]interface{}) Matcher {
+		return Term(fmt.Sprint(example), semver)
+	},
+	"regex": func(example interface{}, m map[string]interface{}) Matcher {
+		return Term(fmt.Sprint(example), fmt.Sprint(m["regex"]))
+	},
+}
+
+// likeExample matches the example of a matcher by type
+func likeExample(example interface{}, m map[string]interface{}) Matcher {
+	return Like(example)
+}
+
+// downgradeMatchers replaces the matchers of a decoded value which require a
+// later version of the specification than supported, and have a version 2
+// equivalent, e.g. integer matchers with type matchers
+func downgradeMatchers(v interface{}, supported int) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		t, _ := value["pact:matcher:type"].(string)
+		required := knownMatcherVersion(t)
+		if _, ok := value["pact:generator:type"]; ok && required < generatorVersion {
+			required = generatorVersion
+		}
+		if equivalent, ok := v2Equivalents[t]; ok && required > supported {
+			example := downgradeMatchers(value["value"], supported)
+			if decoded, err := decode(equivalent(example, value)); err == nil {
+				return decoded
+			}
+		}
+		downgraded := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			downgraded[k] = downgradeMatchers(e, supported)
+		}
+		return downgraded
+	case []interface{}:
+		downgraded := make([]interface{}, len(value))
+		for i, e := range value {
+			downgraded[i] = downgradeMatchers(e, supported)
+		}
+		return downgraded
+	}
+	return v
+}
+
+// checkInteractionVersion rejects a decoded interaction or message using
+// matchers or generators that the version of the specification doesn't
+// support, as they would be written to a pact other Pact implementations
+// can't parse. It returns the version they require.
+func checkInteractionVersion(description string, v interface{}, version int) (int, error) {
+	name, required := requiredVersion(v)
+	if required > version {
+		return required, fmt.Errorf("%q uses %s, which requires version %d of the pact specification, not %d", description, name, required, version)
+	}
+	return required, nil
+}
+
+// requiredVersion returns the matcher or generator of a consumer test
+// requiring the latest version of the specification, and that version
+func requiredVersion(v interface{}) (string, int) {
+	var name string
+	var version int
+	require := func(n string, required int) {
+		if required > version {
+			name, version = n, required
+		}
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if class, ok := value["json_class"].(string); ok && matcherVersions[class] > 0 {
+			require("the "+class+" matcher", matcherVersions[class])
+		}
+		if t, ok := value["pact:matcher:type"].(string); ok {
+			require("the "+t+" matcher", knownMatcherVersion(t))
+		}
+		if t, ok := value["pact:generator:type"].(string); ok {
+			require("the "+t+" generator", generatorVersion)
+		}
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			require(requiredVersion(value[k]))
+		}
+	case []interface{}:
+		for _, e := range value {
+			require(requiredVersion(e))
+		}
+	}
+
+	return name, version
+}
+
+// knownMatcherVersion returns the version of the specification a matcher
+// requires, assuming matchers that aren't known require the latest
+func knownMatcherVersion(match string) int {
+	if version, ok := matcherVersions[match]; ok {
+		return version
+	}
+	return maxSpecificationVersion
+}
+
+// pactVersion returns the major version of the specification a pact declares,
+// defaulting to 2 as the Ruby implementation does
+func pactVersion(pact map[string]interface{}) int {
+	metadata, _ := pact["metadata"].(map[string]interface{})
+
+	var version string
+	for _, key := range []string{"pactSpecification", "pact-specification"} {
+		if specification, ok := metadata[key].(map[string]interface{}); ok {
+			version, _ = specification["version"].(string)
+		}
+	}
+	if version == "" {
+		version, _ = metadata["pactSpecificationVersion"].(string)
+	}
+
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return 2
+	}
+	return major
+}
+
+// checkPactVersion rejects a pact written in a later version of the
+// specification than max, or using matching rules or generators that the
+// version it declares doesn't support
+func checkPactVersion(pact map[string]interface{}, max int) error {
+	version := pactVersion(pact)
+	if version > max {
+		return fmt.Errorf("the pact is written in version %d of the pact specification, expected at most version %d", version, max)
+	}
+
+	for _, key := range []string{"interactions", "messages"} {
+		interactions, _ := pact[key].([]interface{})
+		for _, i := range interactions {
+			interaction, _ := i.(map[string]interface{})
+			description, _ := interaction["description"].(string)
+
+			name, required := pactRulesVersion(interaction)
+			if required > version {
+				return fmt.Errorf("the interaction %q uses %s, which requires version %d of the pact specification, but the pact is written in version %d", description, name, required, version)
+			}
+		}
+	}
+
+	return nil
+}
+
+// pactRulesVersion returns the matching rule or generators of an interaction
+// in a pact requiring the latest version of the specification, and that
+// version
+func pactRulesVersion(interaction map[string]interface{}) (string, int) {
+	var name string
+	var version int
+	require := func(n string, required int) {
+		if required > version {
+			name, version = n, required
+		}
+	}
+
+	parts := []map[string]interface{}{interaction}
+	for _, key := range []string{"request", "response"} {
+		if part, ok := interaction[key].(map[string]interface{}); ok {
+			parts = append(parts, part)
+		}
+	}
+
+	for _, part := range parts {
+		if rules, ok := part["matchingRules"]; ok {
+			require("matching rules", 2)
+			for _, match := range ruleMatches(rules) {
+				require("the "+match+" matcher", knownMatcherVersion(match))
+			}
+		}
+		if generators, ok := part["generators"].(map[string]interface{}); ok && len(generators) > 0 {
+			require("generators", generatorVersion)
+		}
+	}
+
+	return name, version
+}
+
+// ruleMatches returns the kinds of matcher used by matching rules, in either
+// the version 2 or version 3 layout
+func ruleMatches(rules interface{}) []string {
+	var matches []string
+	switch value := rules.(type) {
+	case map[string]interface{}:
+		if match, ok := value["match"].(string); ok {
+			matches = append(matches, match)
+		}
+		for _, e := range value {
+			matches = append(matches, ruleMatches(e)...)
+		}
+	case []interface{}:
+		for _, e := range value {
+			matches = append(matches, ruleMatches(e)...)
+		}
+	}
+
+	sort.Strings(matches)
+	return matches
+}
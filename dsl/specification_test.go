@@ -0,0 +1,224 @@
+package dsl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSpecificationVersion(t *testing.T) {
+	for _, v := range []int{1, 2, 3, 4} {
+		assert.NoError(t, checkSpecificationVersion(v))
+	}
+	assert.EqualError(t, checkSpecificationVersion(0), "unsupported pact specification version 0, expected 1 to 4")
+	assert.EqualError(t, checkSpecificationVersion(5), "unsupported pact specification version 5, expected 1 to 4")
+}
+
+func prepareInteractionsErr(pact *Pact) error {
+	_, err := pact.prepareInteractions()
+	return err
+}
+
+func prepareMessageErr(pact *Pact, message *Message) error {
+	_, err := pact.prepareMessage(message)
+	return err
+}
+
+func TestPact_PrepareInteractions(t *testing.T) {
+	interaction := (&Interaction{}).
+		UponReceiving("a request for a user").
+		WithRequest(Request{Method: "GET", Path: S("/users/1")}).
+		WillRespondWith(Response{Status: 200, Body: Like(map[string]interface{}{"name": "Billy"})})
+	pact := &Pact{SpecificationVersion: 1, Interactions: []*Interaction{interaction}}
+
+	_, err := pact.prepareInteractions()
+	assert.EqualError(t, err, `"a request for a user" uses the Pact::SomethingLike matcher, which requires version 2 of the pact specification, not 1`)
+
+	pact.SpecificationVersion = 2
+	assert.NoError(t, prepareInteractionsErr(pact))
+
+	pact.SpecificationVersion = 4
+	assert.EqualError(t, prepareInteractionsErr(pact), "version 4 of the pact specification requires the NativeMockServer")
+
+	pact.NativeMockServer = true
+	assert.NoError(t, prepareInteractionsErr(pact))
+}
+
+func TestPact_PrepareInteractionsV3Matchers(t *testing.T) {
+	interaction := (&Interaction{}).
+		UponReceiving("a request for a user").
+		WithRequest(Request{Method: "GET", Path: S("/users/1")}).
+		WillRespondWith(Response{Status: 200, Body: map[string]interface{}{
+			"name": map[string]interface{}{"pact:matcher:type": "include", "value": "Bil"},
+		}})
+	pact := &Pact{SpecificationVersion: 2, Interactions: []*Interaction{interaction}}
+
+	_, err := pact.prepareInteractions()
+	assert.EqualError(t, err, `"a request for a user" uses the include matcher, which requires version 3 of the pact specification, not 2`)
+
+	pact.SpecificationVersion = 3
+	_, err = pact.prepareInteractions()
+	assert.EqualError(t, err, `"a request for a user" uses matchers or generators of version 3 of the pact specification, which require the NativeMockServer`)
+
+	pact.NativeMockServer = true
+	assert.NoError(t, prepareInteractionsErr(pact))
+}
+
+func TestPact_PrepareInteractionsDowngradesMatchers(t *testing.T) {
+	interaction := (&Interaction{}).
+		UponReceiving("a request for a user").
+		WithRequest(Request{Method: "GET", Path: S("/users/1")}).
+		WillRespondWith(Response{Status: 200, Body: map[string]interface{}{"id": Integer()}})
+	pact := &Pact{SpecificationVersion: 2, Interactions: []*Interaction{interaction}}
+
+	contents, err := pact.prepareInteractions()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"json_class": "Pact::SomethingLike", "contents": float64(42)},
+		body(contents[0])["id"])
+
+	pact.SpecificationVersion = 3
+	pact.NativeMockServer = true
+	contents, err = pact.prepareInteractions()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"pact:matcher:type": "integer", "value": float64(42)},
+		body(contents[0])["id"])
+
+	// Semver requires version 4, and is a Term in version 3
+	interaction.Response.Body = map[string]interface{}{"id": Semver("1.0.0")}
+	contents, err = pact.prepareInteractions()
+	assert.NoError(t, err)
+	assert.Equal(t, "Pact::Term", body(contents[0])["id"].(map[string]interface{})["json_class"])
+}
+
+// body returns the response body of a prepared interaction
+func body(content interface{}) map[string]interface{} {
+	response, _ := content.(map[string]interface{})["response"].(map[string]interface{})
+	b, _ := response["body"].(map[string]interface{})
+	return b
+}
+
+func TestPact_PrepareMessage(t *testing.T) {
+	pact := &Pact{SpecificationVersion: 2}
+	message := pact.AddMessage().
+		ExpectsToReceive("a user").
+		WithContent(map[string]interface{}{
+			"tags": map[string]interface{}{"pact:matcher:type": "notEmpty", "value": []string{"new"}},
+		})
+
+	_, err := pact.prepareMessage(message)
+	assert.EqualError(t, err, `"a user" uses the notEmpty matcher, which requires version 4 of the pact specification, not 3`)
+
+	pact.SpecificationVersion = 4
+	_, err = pact.prepareMessage(message)
+	assert.EqualError(t, err, `"a user" uses matchers or generators of version 4 of the pact specification, which require the NativeMockServer and version 4 message pacts`)
+
+	pact.NativeMockServer = true
+	assert.NoError(t, prepareMessageErr(pact, message))
+
+	pact.SpecificationVersion = 7
+	assert.Error(t, prepareMessageErr(pact, message))
+}
+
+func TestCheckPactVersion(t *testing.T) {
+	tests := map[string]struct {
+		pact string
+		max  int
+		err  string
+	}{
+		"v2": {
+			pact: `{"metadata": {"pactSpecification": {"version": "2.0.0"}}, "interactions": [
+				{"description": "a", "response": {"matchingRules": {"$.body.name": {"match": "type"}}}}
+			]}`,
+			max: 4,
+		},
+		"no metadata": {
+			pact: `{"interactions": [{"description": "a", "response": {}}]}`,
+			max:  2,
+		},
+		"v2 with v3 matchers": {
+			pact: `{"metadata": {"pactSpecification": {"version": "2.0.0"}}, "interactions": [
+				{"description": "a", "response": {"matchingRules": {"$.body.id": {"match": "integer"}}}}
+			]}`,
+			max: 4,
+			err: `the interaction "a" uses the integer matcher, which requires version 3 of the pact specification, but the pact is written in version 2`,
+		},
+		"v2 with generators": {
+			pact: `{"metadata": {"pactSpecification": {"version": "2.0.0"}}, "interactions": [
+				{"description": "a", "response": {"generators": {"body": {"$.id": {"type": "RandomInt"}}}}}
+			]}`,
+			max: 4,
+			err: `the interaction "a" uses generators, which requires version 3 of the pact specification, but the pact is written in version 2`,
+		},
+		"v1 with matching rules": {
+			pact: `{"metadata": {"pactSpecificationVersion": "1.0.0"}, "interactions": [
+				{"description": "a", "response": {"matchingRules": {"$.body.name": {"match": "type"}}}}
+			]}`,
+			max: 4,
+			err: `the interaction "a" uses matching rules, which requires version 2 of the pact specification, but the pact is written in version 1`,
+		},
+		"v3 messages": {
+			pact: `{"metadata": {"pactSpecification": {"version": "3.0.0"}}, "messages": [
+				{"description": "a", "matchingRules": {"body": {"$.id": {"matchers": [{"match": "integer"}]}}}}
+			]}`,
+			max: 3,
+		},
+		"later than max": {
+			pact: `{"metadata": {"pactSpecification": {"version": "4.0"}}, "interactions": []}`,
+			max:  3,
+			err:  "the pact is written in version 4 of the pact specification, expected at most version 3",
+		},
+		"later than supported": {
+			pact: `{"metadata": {"pactSpecification": {"version": "5.0.0"}}, "interactions": []}`,
+			max:  4,
+			err:  "the pact is written in version 5 of the pact specification, expected at most version 4",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkPactVersion(decodePact(t, []byte(test.pact)), test.max)
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestPreparePactURLs_SpecificationVersion(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "billy-bobby.json")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(v4Pact), 0644))
+
+	request := types.VerifyRequest{PactURLs: []string{file}, SpecificationVersion: 3}
+	_, err := preparePactURLs(&request, v4HTTPInteraction, nil)
+	assert.EqualError(t, err, "unable to verify the pact at "+file+": the pact is written in version 4 of the pact specification, expected at most version 3")
+
+	request.SpecificationVersion = 5
+	_, err = preparePactURLs(&request, v4HTTPInteraction, nil)
+	assert.EqualError(t, err, "unsupported pact specification version 5, expected 1 to 4")
+}
+
+func TestPrepareRelayedPacts(t *testing.T) {
+	prepare := prepareRelayedPacts(3, v4HTTPInteraction)
+
+	_, err := prepare([]byte(v4Pact))
+	assert.EqualError(t, err, "the pact is written in version 4 of the pact specification, expected at most version 3")
+
+	// Other broker resources are relayed unchanged
+	index := []byte(`{"_links": {"pb:latest-provider-pacts": {"href": "/pacts/provider/bobby/latest"}}}`)
+	body, err := prepare(index)
+	assert.NoError(t, err)
+	assert.Equal(t, index, body)
+
+	body, err = prepareRelayedPacts(0, v4HTTPInteraction)([]byte(v4Pact))
+	assert.NoError(t, err)
+	assert.Equal(t, "3.0.0", decodePact(t, body)["metadata"].(map[string]interface{})["pactSpecification"].(map[string]interface{})["version"])
+}
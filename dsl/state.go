@@ -0,0 +1,176 @@
+package dsl
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/pact-foundation/pact-go/proxy"
+	"github.com/pact-foundation/pact-go/types"
+)
+
+// statePattern is a compiled pattern state handler
+type statePattern struct {
+	pattern *regexp.Regexp
+	handler types.PatternStateHandler
+}
+
+// stateResolver finds the handler for each provider state of a verification,
+// in order of preference: an exact StateHandlers or ContextStateHandlers entry,
+// a matching pattern, then the ContextDefaultStateHandler or DefaultStateHandler
+type stateResolver struct {
+	handlers              types.StateHandlers
+	contextHandlers       types.ContextStateHandlers
+	patterns              []statePattern
+	defaultHandler        types.DefaultStateHandler
+	contextDefaultHandler types.ContextDefaultStateHandler
+}
+
+// hasStateHandlers reports whether the request sets up provider states
+func hasStateHandlers(request types.VerifyRequest) bool {
+	return len(request.StateHandlers) > 0 || len(request.ContextStateHandlers) > 0 || len(request.PatternStateHandlers) > 0 || request.DefaultStateHandler != nil || request.ContextDefaultStateHandler != nil
+}
+
+// newStateResolver compiles the state handlers of the request
+func newStateResolver(request types.VerifyRequest) (*stateResolver, error) {
+	resolver := &stateResolver{
+		handlers:              request.StateHandlers,
+		contextHandlers:       request.ContextStateHandlers,
+		defaultHandler:        request.DefaultStateHandler,
+		contextDefaultHandler: request.ContextDefaultStateHandler,
+	}
+
+	keys := make([]string, 0, len(request.PatternStateHandlers))
+	for key := range request.PatternStateHandlers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		pattern, err := regexp.Compile(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state handler pattern '%s': %v", key, err)
+		}
+		resolver.patterns = append(resolver.patterns, statePattern{pattern: pattern, handler: request.PatternStateHandlers[key]})
+	}
+
+	return resolver, nil
+}
+
+// resolve returns the function setting up the given state, or nil if no
+// handler is registered for it. It is an error for a state to match more
+// than one pattern.
+func (r *stateResolver) resolve(state string, params map[string]interface{}) (func(context.Context) error, error) {
+	if sf, ok := r.handlers[state]; ok {
+		return func(context.Context) error {
+			return sf()
+		}, nil
+	}
+
+	if sf, ok := r.contextHandlers[state]; ok {
+		return sf, nil
+	}
+
+	var match func(context.Context) error
+	var matched []string
+	for _, p := range r.patterns {
+		groups := p.pattern.FindStringSubmatch(state)
+		if groups == nil {
+			continue
+		}
+
+		handler := p.handler
+		matched = append(matched, p.pattern.String())
+		match = func(context.Context) error {
+			return handler(groups[1:], params)
+		}
+	}
+
+	if len(matched) > 1 {
+		return nil, fmt.Errorf("state '%s' matches more than one state handler pattern: %v", state, matched)
+	}
+	if match != nil {
+		log.Printf("[DEBUG] using state handler pattern '%s' for state: %v", matched[0], state)
+		return match, nil
+	}
+
+	if r.contextDefaultHandler != nil {
+		log.Printf("[DEBUG] using default state handler for state: %v", state)
+		return func(ctx context.Context) error {
+			return r.contextDefaultHandler(ctx, state, params)
+		}, nil
+	}
+
+	if r.defaultHandler != nil {
+		log.Printf("[DEBUG] using default state handler for state: %v", state)
+		return func(context.Context) error {
+			return r.defaultHandler(state, params)
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// runStateHandler sets up a state, failing once the context is done if the
+// handler hasn't returned. A panicking handler fails the state setup.
+func runStateHandler(ctx context.Context, state string, sf func(context.Context) error) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("state handler for '%s' panicked: %v", state, r)
+			}
+		}()
+		done <- sf(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("state setup timed out for state '%s': %v", state, ctx.Err())
+	}
+}
+
+// providerStateNames returns the states a setup request asks for, which
+// older verifiers only send as a single state
+func providerStateNames(s types.ProviderState) []string {
+	if len(s.States) == 0 && s.State != "" {
+		return []string{s.State}
+	}
+	return s.States
+}
+
+// isTeardown reports whether a setup request asks for its states to be
+// torn down after the interaction, rather than set up
+func isTeardown(s types.ProviderState) bool {
+	return s.Action == "teardown"
+}
+
+// providerStateHeaderMiddleware adds the states of the interaction in
+// progress to each request replayed against the provider, in the given
+// header. Setup requests not handled by state handlers are acknowledged, as
+// the provider has no setup endpoint.
+func providerStateHeaderMiddleware(header string, tracker *interactionTracker) proxy.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == providerStatesSetupPath {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			if !isInternalPath(r.URL.Path) {
+				r.Header.Del(header)
+				_, states := tracker.current()
+				for _, state := range states {
+					r.Header.Add(header, state)
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package dsl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"github.com/pact-foundation/pact-go/types"
+)
+
+// stateCommandData is given to the templates of a command state handler
+type stateCommandData struct {
+	State  string
+	Params map[string]interface{}
+}
+
+// CommandStateHandler returns a state handler that runs an external command,
+// e.g. a script loading fixtures. Each argument of the command is a template,
+// given the .State and its .Params:
+//
+//	./scripts/seed.sh "{{.State}}" --user "{{.Params.id}}"
+//
+// Arguments are separated by spaces, and may be quoted. The command is run
+// directly, not by a shell, so states can't inject commands. The state and
+// its params (as JSON) are also set in the PACT_PROVIDER_STATE and
+// PACT_PROVIDER_STATE_PARAMS environment variables. The state fails to set
+// up if the command exits with an error. Use it as the
+// ContextDefaultStateHandler of a VerifyRequest, so that the command, and any
+// processes it starts, are killed once the StateHandlerTimeout passes.
+func CommandStateHandler(command string) (types.ContextDefaultStateHandler, error) {
+	fields, err := splitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("state handler command is empty")
+	}
+
+	args := make([]*template.Template, len(fields))
+	for i, field := range fields {
+		if args[i], err = template.New(fmt.Sprintf("arg%d", i)).Option("missingkey=zero").Parse(field); err != nil {
+			return nil, fmt.Errorf("invalid state handler command %q: %v", command, err)
+		}
+	}
+
+	return func(ctx context.Context, state string, params map[string]interface{}) error {
+		data := stateCommandData{State: state, Params: params}
+
+		rendered := make([]string, len(args))
+		for i, arg := range args {
+			var buf bytes.Buffer
+			if err := arg.Execute(&buf, data); err != nil {
+				return fmt.Errorf("unable to render state handler command for '%s': %v", state, err)
+			}
+			rendered[i] = buf.String()
+		}
+
+		encoded, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("[DEBUG] running state handler command for '%s': %v", state, rendered)
+		cmd := exec.CommandContext(ctx, rendered[0], rendered[1:]...)
+		cmd.Env = append(os.Environ(),
+			"PACT_PROVIDER_STATE="+state,
+			"PACT_PROVIDER_STATE_PARAMS="+string(encoded),
+		)
+
+		if out, err := runCommand(ctx, cmd); err != nil {
+			return fmt.Errorf("state handler command for '%s' failed: %v\n\n%s", state, err, out)
+		}
+
+		return nil
+	}, nil
+}
+
+// runCommand runs the command and returns its combined output. CommandContext
+// only kills the command once the context is done, leaving anything it started
+// running, and holding its output open, so its process group is killed too
+func runCommand(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	exited := make(chan struct{})
+	defer close(exited)
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-exited:
+		}
+	}()
+
+	err := cmd.Wait()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return out.Bytes(), err
+}
+
+// splitCommand splits a command into its arguments at spaces outside of
+// quotes and template actions, removing the quotes
+func splitCommand(command string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	var quote rune
+	inField := false
+	actions := 0
+
+	for i, c := range command {
+		switch {
+		case actions > 0:
+			if strings.HasPrefix(command[i:], "}}") {
+				actions--
+			}
+			field.WriteRune(c)
+		case strings.HasPrefix(command[i:], "{{"):
+			actions++
+			inField = true
+			field.WriteRune(c)
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				field.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inField = true
+		case c == ' ' || c == '\t':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			inField = true
+			field.WriteRune(c)
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in state handler command %q", command)
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,80 @@
+package dsl
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandStateHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pact-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "state.txt")
+	handler, err := CommandStateHandler(`sh -c 'echo "$1 $PACT_PROVIDER_STATE_PARAMS" > "$2"' seed "{{ .State }}" ` + out)
+	assert.NoError(t, err)
+
+	err = handler(context.Background(), "user 1 exists", map[string]interface{}{"id": 1})
+	assert.NoError(t, err)
+
+	written, _ := ioutil.ReadFile(out)
+	assert.Equal(t, "user 1 exists {\"id\":1}\n", string(written))
+}
+
+func TestCommandStateHandler_Fails(t *testing.T) {
+	handler, err := CommandStateHandler(`sh -c "echo unable to seed; exit 3"`)
+	assert.NoError(t, err)
+
+	err = handler(context.Background(), "user 1 exists", nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "state handler command for 'user 1 exists' failed")
+		assert.Contains(t, err.Error(), "unable to seed")
+	}
+}
+
+func TestCommandStateHandler_Timeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pact-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	marker := filepath.Join(dir, "seeded")
+	handler, err := CommandStateHandler(`sh -c '(sleep 1; touch "$1") & wait' seed ` + marker)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = handler(ctx, "user 1 exists", nil)
+	assert.True(t, time.Since(start) < time.Second, "the command wasn't killed")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	_, err = os.Stat(marker)
+	assert.True(t, os.IsNotExist(err), "the process started by the command wasn't killed")
+}
+
+func TestCommandStateHandler_Invalid(t *testing.T) {
+	for _, command := range []string{"", `seed.sh "{{.State}}`, `seed.sh {{.State`} {
+		_, err := CommandStateHandler(command)
+		assert.Error(t, err, command)
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	fields, err := splitCommand(`./scripts/seed.sh "{{.State}}"  --user '{{ index .Params "id" }}' ""`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"./scripts/seed.sh", "{{.State}}", "--user", `{{ index .Params "id" }}`, ""}, fields)
+}
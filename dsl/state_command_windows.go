@@ -0,0 +1,13 @@
+package dsl
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing, as only the command is killed on Windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command, but not the processes it started
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
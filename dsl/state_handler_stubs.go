@@ -0,0 +1,87 @@
+package dsl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pact-foundation/pact-go/types"
+)
+
+// StateHandlerStubOptions configure the Go file written by GenerateStateHandlers
+type StateHandlerStubOptions struct {
+	// Package of the generated file. Defaults to "provider".
+	Package string
+
+	// Name of the generated types.StateHandlers variable.
+	// Defaults to "stateHandlers".
+	Name string
+}
+
+// GenerateStateHandlers writes a Go file declaring a types.StateHandlers
+// variable, with a stub handler for each of the states (see RequiredStates).
+// Every stub returns an error until it is implemented, so that a missing
+// implementation fails the verification rather than silently passing.
+func GenerateStateHandlers(w io.Writer, states []types.RequiredState, options StateHandlerStubOptions) error {
+	if options.Package == "" {
+		options.Package = "provider"
+	}
+	if options.Name == "" {
+		options.Name = "stateHandlers"
+	}
+
+	// A StateHandler is keyed by name alone, so states that differ only by
+	// their params share one stub
+	var names []string
+	seen := map[string]bool{}
+	consumers := map[string][]string{}
+	params := map[string][]string{}
+	for _, s := range states {
+		if !seen[s.Name] {
+			seen[s.Name] = true
+			names = append(names, s.Name)
+		}
+		for _, c := range s.Consumers {
+			consumers[s.Name] = appendConsumer(consumers[s.Name], c)
+		}
+		if len(s.Params) > 0 {
+			encoded, err := json.Marshal(s.Params)
+			if err != nil {
+				return err
+			}
+			params[s.Name] = append(params[s.Name], string(encoded))
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n\n", options.Package)
+	fmt.Fprint(&buf, "import (\n\t\"errors\"\n\n\t\"github.com/pact-foundation/pact-go/types\"\n)\n\n")
+	fmt.Fprintf(&buf, "// %s set up the provider states required by the pacts of the consumers.\n", options.Name)
+	fmt.Fprint(&buf, "// Generated by pact-go, implement each handler before verifying the provider.\n")
+	fmt.Fprintf(&buf, "var %s = types.StateHandlers{\n", options.Name)
+	for _, name := range names {
+		if len(consumers[name]) > 0 {
+			fmt.Fprintf(&buf, "// Required by %s\n", strings.Join(consumers[name], ", "))
+		}
+		for _, p := range params[name] {
+			fmt.Fprintf(&buf, "// Params %s\n", p)
+		}
+		fmt.Fprintf(&buf, "%s: func() error {\n", strconv.Quote(name))
+		fmt.Fprint(&buf, "// TODO: set up the provider state\n")
+		fmt.Fprintf(&buf, "return errors.New(%s)\n", strconv.Quote("state handler not implemented: "+name))
+		fmt.Fprint(&buf, "},\n")
+	}
+	fmt.Fprint(&buf, "}\n")
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(source)
+	return err
+}
@@ -0,0 +1,51 @@
+package dsl
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateStateHandlers(t *testing.T) {
+	var buf bytes.Buffer
+	err := GenerateStateHandlers(&buf, []types.RequiredState{
+		{Name: "user 1 exists", Consumers: []string{"anne", "billy"}},
+		{Name: "order exists", Params: map[string]interface{}{"id": 7}, Consumers: []string{"anne"}},
+		{Name: "order exists", Params: map[string]interface{}{"id": 8}, Consumers: []string{"billy"}},
+		{Name: `a "quoted" state`},
+	}, StateHandlerStubOptions{Package: "users"})
+	assert.NoError(t, err)
+
+	want := `package users
+
+import (
+	"errors"
+
+	"github.com/pact-foundation/pact-go/types"
+)
+
+// stateHandlers set up the provider states required by the pacts of the consumers.
+// Generated by pact-go, implement each handler before verifying the provider.
+var stateHandlers = types.StateHandlers{
+	// Required by anne, billy
+	"user 1 exists": func() error {
+		// TODO: set up the provider state
+		return errors.New("state handler not implemented: user 1 exists")
+	},
+	// Required by anne, billy
+	// Params {"id":7}
+	// Params {"id":8}
+	"order exists": func() error {
+		// TODO: set up the provider state
+		return errors.New("state handler not implemented: order exists")
+	},
+	"a \"quoted\" state": func() error {
+		// TODO: set up the provider state
+		return errors.New("state handler not implemented: a \"quoted\" state")
+	},
+}
+`
+	assert.Equal(t, want, buf.String())
+}
@@ -0,0 +1,188 @@
+package dsl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateResolver(t *testing.T) {
+	var called string
+	var groups []string
+
+	resolver, err := newStateResolver(types.VerifyRequest{
+		StateHandlers: types.StateHandlers{
+			"user 1 exists": func() error {
+				called = "exact"
+				return nil
+			},
+		},
+		PatternStateHandlers: types.PatternStateHandlers{
+			`^user (\d+) exists$`: func(g []string, params map[string]interface{}) error {
+				called = "pattern"
+				groups = g
+				return nil
+			},
+			`^order (\d+) exists$`: func(g []string, params map[string]interface{}) error {
+				called = "order"
+				return nil
+			},
+		},
+		DefaultStateHandler: func(state string, params map[string]interface{}) error {
+			called = "default"
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		state  string
+		want   string
+		groups []string
+	}{
+		{state: "user 1 exists", want: "exact"},
+		{state: "user 42 exists", want: "pattern", groups: []string{"42"}},
+		{state: "no users", want: "default"},
+	}
+	for _, tt := range tests {
+		called, groups = "", nil
+		sf, err := resolver.resolve(tt.state, nil)
+		assert.NoError(t, err)
+		if assert.NotNil(t, sf, tt.state) {
+			assert.NoError(t, sf(context.Background()))
+		}
+		assert.Equal(t, tt.want, called, tt.state)
+		assert.Equal(t, tt.groups, groups, tt.state)
+	}
+}
+
+func TestStateResolver_NotFound(t *testing.T) {
+	resolver, err := newStateResolver(types.VerifyRequest{})
+	assert.NoError(t, err)
+
+	sf, err := resolver.resolve("user 1 exists", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, sf)
+}
+
+func TestStateResolver_AmbiguousPattern(t *testing.T) {
+	handler := func([]string, map[string]interface{}) error { return nil }
+	resolver, err := newStateResolver(types.VerifyRequest{
+		PatternStateHandlers: types.PatternStateHandlers{
+			`^user (\d+)`: handler,
+			`exists$`:     handler,
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = resolver.resolve("user 1 exists", nil)
+	assert.Error(t, err)
+}
+
+func TestStateResolver_InvalidPattern(t *testing.T) {
+	_, err := newStateResolver(types.VerifyRequest{
+		PatternStateHandlers: types.PatternStateHandlers{
+			`^user (\d+ exists$`: func([]string, map[string]interface{}) error { return nil },
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestStateResolver_ContextStateHandler(t *testing.T) {
+	resolver, err := newStateResolver(types.VerifyRequest{
+		ContextStateHandlers: types.ContextStateHandlers{
+			"user 1 exists": func(ctx context.Context) error {
+				return ctx.Err()
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	sf, err := resolver.resolve("user 1 exists", nil)
+	assert.NoError(t, err)
+	if assert.NotNil(t, sf) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, context.Canceled, sf(ctx))
+	}
+}
+
+func TestStateResolver_ContextDefaultStateHandler(t *testing.T) {
+	var got string
+	resolver, err := newStateResolver(types.VerifyRequest{
+		DefaultStateHandler: func(string, map[string]interface{}) error {
+			return errors.New("DefaultStateHandler called")
+		},
+		ContextDefaultStateHandler: func(ctx context.Context, state string, params map[string]interface{}) error {
+			got = state
+			return ctx.Err()
+		},
+	})
+	assert.NoError(t, err)
+
+	sf, err := resolver.resolve("user 1 exists", nil)
+	assert.NoError(t, err)
+	if assert.NotNil(t, sf) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, context.Canceled, sf(ctx))
+		assert.Equal(t, "user 1 exists", got)
+	}
+}
+
+func TestRunStateHandler_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	block := make(chan struct{})
+	defer close(block)
+
+	err := runStateHandler(ctx, "user 1 exists", func(context.Context) error {
+		<-block
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "state setup timed out for state 'user 1 exists'")
+}
+
+func TestRunStateHandler_Panic(t *testing.T) {
+	err := runStateHandler(context.Background(), "user 1 exists", func(context.Context) error {
+		panic("boom")
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "panicked: boom")
+}
+
+func TestRunStateHandler_Error(t *testing.T) {
+	want := errors.New("unable to seed database")
+	err := runStateHandler(context.Background(), "user 1 exists", func(context.Context) error {
+		return want
+	})
+	assert.Equal(t, want, err)
+}
+
+func TestProviderStateHeaderMiddleware(t *testing.T) {
+	tracker := &interactionTracker{}
+
+	var states []string
+	handler := tracker.middleware()(providerStateHeaderMiddleware("X-Provider-State", tracker)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		states = r.Header["X-Provider-State"]
+	})))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", providerStatesSetupPath, strings.NewReader(`{"consumer":"billy","states":["user 1 exists","order 2 exists"]}`)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Nil(t, states, "expected the setup request not to reach the provider")
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("X-Provider-State", "stale")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, []string{"user 1 exists", "order 2 exists"}, states)
+}
@@ -0,0 +1,125 @@
+package dsl
+
+import "strings"
+
+// InteractionTemplate holds the parts shared by many interactions, such as
+// authentication headers or the shape of an error response, so that they're
+// defined once and the contracts stay consistent. Interactions created from a
+// template with AddInteractionFromTemplate are given its provider state,
+// request and response, and the requests and responses later given to them
+// override the template's.
+type InteractionTemplate struct {
+	// Provider state of the interactions, unless overridden with Given
+	State string
+
+	// Request and Response shared by the interactions. The method, path, body
+	// and status are replaced by those given to the interaction, and the
+	// query and headers are merged with them.
+	Request  Request
+	Response Response
+
+	// Comments added to each interaction. Only written to version 4 pacts.
+	Comments []string
+}
+
+// Extend returns a template with the overrides applied over this one, e.g. to
+// derive the error responses of an API from a template with its
+// authentication headers.
+func (t InteractionTemplate) Extend(overrides InteractionTemplate) InteractionTemplate {
+	state := t.State
+	if overrides.State != "" {
+		state = overrides.State
+	}
+
+	return InteractionTemplate{
+		State:    state,
+		Request:  mergeRequest(t.Request, overrides.Request),
+		Response: mergeResponse(t.Response, overrides.Response),
+		Comments: append(append([]string(nil), t.Comments...), overrides.Comments...),
+	}
+}
+
+// AddInteractionFromTemplate creates a new Pact interaction from a template.
+// Will automatically start a Mock Service if none running.
+func (p *Pact) AddInteractionFromTemplate(template InteractionTemplate) *Interaction {
+	i := p.AddInteraction()
+	i.template = &template
+	i.State = template.State
+	i.Request = mergeRequest(template.Request, Request{})
+	i.Response = mergeResponse(template.Response, Response{})
+	i.Comments = append([]string(nil), template.Comments...)
+
+	return i
+}
+
+// mergeRequest applies the non-empty fields of overrides over a request
+func mergeRequest(request Request, overrides Request) Request {
+	merged := Request{
+		Method:  request.Method,
+		Path:    request.Path,
+		Query:   mergeMapMatcher(request.Query, overrides.Query),
+		Headers: mergeHeaders(request.Headers, overrides.Headers),
+		Body:    request.Body,
+	}
+	if overrides.Method != "" {
+		merged.Method = overrides.Method
+	}
+	if overrides.Path != nil {
+		merged.Path = overrides.Path
+	}
+	if overrides.Body != nil {
+		merged.Body = overrides.Body
+	}
+
+	return merged
+}
+
+// mergeResponse applies the non-empty fields of overrides over a response
+func mergeResponse(response Response, overrides Response) Response {
+	merged := Response{
+		Status:  response.Status,
+		Headers: mergeHeaders(response.Headers, overrides.Headers),
+		Body:    response.Body,
+	}
+	if overrides.Status != 0 {
+		merged.Status = overrides.Status
+	}
+	if overrides.Body != nil {
+		merged.Body = overrides.Body
+	}
+
+	return merged
+}
+
+// mergeMapMatcher returns a copy of m with the entries of overrides, so that
+// interactions created from the same template don't share their headers
+func mergeMapMatcher(m MapMatcher, overrides MapMatcher) MapMatcher {
+	if m == nil && overrides == nil {
+		return nil
+	}
+
+	merged := make(MapMatcher, len(m)+len(overrides))
+	for k, v := range m {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// mergeHeaders merges headers as mergeMapMatcher does, ignoring the case of
+// their names
+func mergeHeaders(headers MapMatcher, overrides MapMatcher) MapMatcher {
+	merged := mergeMapMatcher(headers, nil)
+	for name := range overrides {
+		for k := range merged {
+			if strings.EqualFold(k, name) {
+				delete(merged, k)
+			}
+		}
+	}
+
+	return mergeMapMatcher(merged, overrides)
+}
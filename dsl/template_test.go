@@ -0,0 +1,114 @@
+package dsl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var authenticated = InteractionTemplate{
+	State: "a user is logged in",
+	Request: Request{
+		Method:  "GET",
+		Headers: MapMatcher{"Authorization": Term("Bearer abc123", `Bearer \w+`)},
+	},
+	Response: Response{
+		Status:  200,
+		Headers: MapMatcher{"Content-Type": String("application/json")},
+	},
+	Comments: []string{"Requires a session"},
+}
+
+func TestInteractionTemplate_Extend(t *testing.T) {
+	notFound := authenticated.Extend(InteractionTemplate{
+		Response: Response{
+			Status:  404,
+			Headers: MapMatcher{"content-type": String("application/problem+json")},
+			Body:    map[string]interface{}{"title": Like("Not Found")},
+		},
+		Comments: []string{"Errors are problem details"},
+	})
+
+	assert.Equal(t, "a user is logged in", notFound.State)
+	assert.Equal(t, authenticated.Request, notFound.Request)
+	assert.Equal(t, 404, notFound.Response.Status)
+	assert.Equal(t, MapMatcher{"content-type": String("application/problem+json")}, notFound.Response.Headers)
+	assert.Equal(t, []string{"Requires a session", "Errors are problem details"}, notFound.Comments)
+
+	// The template extended is left unchanged
+	assert.Equal(t, 200, authenticated.Response.Status)
+	assert.Equal(t, MapMatcher{"Content-Type": String("application/json")}, authenticated.Response.Headers)
+	assert.Equal(t, []string{"Requires a session"}, authenticated.Comments)
+}
+
+func TestMergeRequest(t *testing.T) {
+	request := mergeRequest(authenticated.Request, Request{
+		Path:    String("/users/1"),
+		Query:   MapMatcher{"fields": String("name")},
+		Headers: MapMatcher{"Accept": String("application/json")},
+	})
+
+	assert.Equal(t, Request{
+		Method: "GET",
+		Path:   String("/users/1"),
+		Query:  MapMatcher{"fields": String("name")},
+		Headers: MapMatcher{
+			"Authorization": Term("Bearer abc123", `Bearer \w+`),
+			"Accept":        String("application/json"),
+		},
+	}, request)
+
+	request.Headers["Accept"] = String("text/plain")
+	assert.Len(t, authenticated.Request.Headers, 1)
+}
+
+func TestPact_AddInteractionFromTemplate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	pact := &Pact{
+		Consumer:         "My Consumer",
+		Provider:         "My Provider",
+		PactDir:          dir,
+		LogLevel:         "ERROR",
+		NativeMockServer: true,
+	}
+	defer pact.Teardown()
+
+	pact.
+		AddInteractionFromTemplate(authenticated).
+		UponReceiving("a request for the user").
+		WithRequest(Request{Path: String("/users/1")}).
+		WillRespondWith(Response{Body: Like(map[string]string{"name": "Billy"})})
+	pact.
+		AddInteractionFromTemplate(authenticated).
+		Given("no users exist").
+		UponReceiving("a request for a missing user").
+		WithRequest(Request{Path: String("/users/2")}).
+		WillRespondWith(Response{Status: 404})
+
+	assert.Equal(t, "a user is logged in", pact.Interactions[0].State)
+	assert.Equal(t, "no users exist", pact.Interactions[1].State)
+	assert.Equal(t, []string{"Requires a session"}, pact.Interactions[0].Comments)
+
+	err := pact.Verify(func() error {
+		for _, path := range []string{"/users/1", "/users/2"} {
+			req, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d%s", pact.Server.Port, path), nil)
+			req.Header.Set("Authorization", "Bearer xyz789")
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			res.Body.Close()
+			if path == "/users/2" && res.StatusCode != 404 {
+				return fmt.Errorf("expected a 404 for %s, got %d", path, res.StatusCode)
+			}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,55 @@
+package dsl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/pact-foundation/pact-go/proxy"
+)
+
+// systemCertFiles are the usual locations of the system CA bundle
+var systemCertFiles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// trustProxyCertificate writes a CA bundle containing the certificate served
+// by an https verification proxy, along with the system CAs, returning the
+// environment that configures the verifier to trust it and a function to
+// remove the bundle. Nothing is done for a plain http proxy.
+func trustProxyCertificate(server *proxy.Server) ([]string, func(), error) {
+	if server.Scheme != "https" || len(server.CertificatePEM) == 0 {
+		return nil, func() {}, nil
+	}
+
+	bundle := bytes.NewBuffer(append([]byte{}, server.CertificatePEM...))
+	for _, path := range append([]string{os.Getenv("SSL_CERT_FILE")}, systemCertFiles...) {
+		if path == "" {
+			continue
+		}
+		if certs, err := ioutil.ReadFile(path); err == nil {
+			bundle.WriteString("\n")
+			bundle.Write(certs)
+			break
+		}
+	}
+
+	f, err := ioutil.TempFile("", "pact-go-proxy-*.pem")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(bundle.Bytes()); err != nil {
+		os.Remove(f.Name())
+		return nil, nil, err
+	}
+
+	log.Println("[DEBUG] pact provider verification: trusting proxy certificate via", f.Name())
+
+	return []string{"SSL_CERT_FILE=" + f.Name()}, func() { os.Remove(f.Name()) }, nil
+}
@@ -0,0 +1,42 @@
+package dsl
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustProxyCertificate(t *testing.T) {
+	server, err := proxy.StartHTTPReverseProxy(proxy.Options{
+		TargetScheme:  "http",
+		TargetAddress: "localhost:1234",
+		TLS:           true,
+	})
+	assert.NoError(t, err)
+	defer server.Close()
+
+	env, cleanup, err := trustProxyCertificate(server)
+	assert.NoError(t, err)
+	assert.Len(t, env, 1)
+	assert.True(t, strings.HasPrefix(env[0], "SSL_CERT_FILE="))
+
+	path := strings.TrimPrefix(env[0], "SSL_CERT_FILE=")
+	bundle, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(bundle), string(server.CertificatePEM))
+
+	cleanup()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "expected the bundle to be removed")
+}
+
+func TestTrustProxyCertificate_HTTP(t *testing.T) {
+	env, cleanup, err := trustProxyCertificate(&proxy.Server{Scheme: "http"})
+	assert.NoError(t, err)
+	assert.Empty(t, env)
+	cleanup()
+}
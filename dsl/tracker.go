@@ -0,0 +1,486 @@
+package dsl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pact-foundation/pact-go/broker"
+	"github.com/pact-foundation/pact-go/proxy"
+	"github.com/pact-foundation/pact-go/types"
+)
+
+// interactionTracker follows the progress of the verifier through a pact.
+// The verifier sets up provider states before replaying each interaction, so
+// the most recent __setup request tells us which consumer and states apply
+// to the requests that follow it. The verifier may set up each state of an
+// interaction with a separate request, so consecutive setup requests add to
+// the states of the same interaction. The setup requests don't describe the
+// interaction, so it is looked up in the catalog of the pacts being verified
+// once its request is replayed.
+type interactionTracker struct {
+	mu          sync.Mutex
+	provider    string
+	catalog     *interactionCatalog
+	consumer    string
+	states      []string
+	description string
+
+	// setups is the number of setup requests for the interaction in
+	// progress, and teardown whether the last of them was a teardown
+	setups   int
+	teardown bool
+}
+
+type interactionContextKey struct{}
+
+// InteractionFromContext returns the interaction that a request passing
+// through the verification proxy belongs to, allowing a RequestFilter to
+// behave differently for each interaction. The description is set for the
+// requests replayed against the provider, where the interaction is in the
+// pacts being verified. It returns false when the interaction isn't known,
+// e.g. where no provider states were set up.
+func InteractionFromContext(ctx context.Context) (types.InteractionMetadata, bool) {
+	interaction, ok := ctx.Value(interactionContextKey{}).(types.InteractionMetadata)
+	return interaction, ok
+}
+
+// middleware records the consumer and states of each setup request,
+// leaving the request body intact for downstream handlers, and adds the
+// current interaction to the context of every request
+func (t *interactionTracker) middleware() proxy.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == providerStatesSetupPath && r.Body != nil {
+				body, err := ioutil.ReadAll(r.Body)
+				r.Body.Close()
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+				var s types.ProviderState
+				if err == nil && json.Unmarshal(body, &s) == nil {
+					t.setUp(s)
+				}
+			} else if !isInternalPath(r.URL.Path) {
+				t.replayed(r)
+			}
+
+			if interaction := t.metadata(); interaction.Consumer != "" || len(interaction.States) > 0 {
+				r = r.WithContext(context.WithValue(r.Context(), interactionContextKey{}, interaction))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (t *interactionTracker) set(consumer string, states []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.consumer = consumer
+	t.states = states
+}
+
+// setUp records a setup request, starting a new interaction unless the
+// previous request set up another state of the same interaction
+func (t *interactionTracker) setUp(s types.ProviderState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.teardown = isTeardown(s)
+	if t.teardown {
+		return
+	}
+
+	if t.setups == 0 || s.Consumer != t.consumer {
+		t.consumer = s.Consumer
+		t.states = nil
+		t.description = ""
+		t.setups = 0
+	}
+	t.setups++
+
+	states := make([]string, 0, len(t.states)+len(s.States))
+	states = append(states, t.states...)
+	t.states = append(states, providerStateNames(s)...)
+}
+
+// replayed records a request replayed against the provider, describing the
+// interaction it belongs to, after which the next setup request starts a new
+// interaction
+func (t *interactionTracker) replayed(r *http.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.setups = 0
+	t.description = t.catalog.describe(t.consumer, t.states, r.Method, r.URL.Path)
+}
+
+// startsInteraction reports whether the last setup request was the first
+// to set up the states of an interaction
+func (t *interactionTracker) startsInteraction() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.setups <= 1 && !t.teardown
+}
+
+// current returns the consumer and states of the interaction in progress
+func (t *interactionTracker) current() (string, []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.consumer, t.states
+}
+
+// metadata describes the interaction in progress
+func (t *interactionTracker) metadata() types.InteractionMetadata {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return types.InteractionMetadata{
+		Provider:    t.provider,
+		Consumer:    t.consumer,
+		Description: t.description,
+		States:      t.states,
+	}
+}
+
+// interactionCatalog lists the HTTP interactions of the pacts being verified
+// by consumer, in the order the verifier replays them
+type interactionCatalog struct {
+	mu           sync.Mutex
+	interactions map[string][]catalogedInteraction
+
+	// replays counts the requests replayed for each consumer, states and
+	// request, telling apart the interactions of pacts with the same request
+	replays map[string]int
+}
+
+type catalogedInteraction struct {
+	pactInteraction
+	Description string `json:"description"`
+	Pending     bool   `json:"pending"`
+	Request     struct {
+		Method string `json:"method"`
+		Path   string `json:"path"`
+	} `json:"request"`
+}
+
+// states returns the names of the provider states of the interaction
+func (i catalogedInteraction) states() []string {
+	if len(i.ProviderStates) == 0 && i.ProviderState != "" {
+		return []string{i.ProviderState}
+	}
+	states := make([]string, len(i.ProviderStates))
+	for j, s := range i.ProviderStates {
+		states[j] = s.Name
+	}
+	return states
+}
+
+// add catalogs the HTTP interactions and messages of a pact prepared for the
+// verifier. Other documents, and interactions that can't be decoded, are
+// ignored.
+func (c *interactionCatalog) add(raw []byte) {
+	if c == nil {
+		return
+	}
+
+	var pact broker.Pact
+	if err := json.Unmarshal(raw, &pact); err != nil || pact.Consumer.Name == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.interactions == nil {
+		c.interactions = map[string][]catalogedInteraction{}
+	}
+	for _, raw := range append(pact.Interactions, pact.Messages...) {
+		var interaction catalogedInteraction
+		if err := json.Unmarshal(raw, &interaction); err == nil {
+			c.interactions[pact.Consumer.Name] = append(c.interactions[pact.Consumer.Name], interaction)
+		}
+	}
+}
+
+// observe returns the transform of the pacts relayed from the broker, adding
+// the pacts it prepares to the catalog
+func (c *interactionCatalog) observe(transform func([]byte) ([]byte, error)) func([]byte) ([]byte, error) {
+	return func(body []byte) ([]byte, error) {
+		prepared, err := transform(body)
+		if err == nil {
+			c.add(prepared)
+		}
+		return prepared, err
+	}
+}
+
+// describe returns the description of the interaction of the consumer with
+// the states and request, or "" if it isn't in the catalog. Where pacts have
+// the same interaction, e.g. pacts of different versions of the consumer, each
+// replay is given the next of them, in the order of the pacts.
+func (c *interactionCatalog) describe(consumer string, states []string, method string, path string) string {
+	if c == nil {
+		return ""
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var candidates []string
+	for _, i := range c.interactions[consumer] {
+		if strings.EqualFold(i.Request.Method, method) && i.Request.Path == path && equalStrings(i.states(), states) {
+			candidates = append(candidates, i.Description)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	if c.replays == nil {
+		c.replays = map[string]int{}
+	}
+	key := strings.Join(append([]string{consumer, strings.ToUpper(method), path}, states...), "\x00")
+	replay := c.replays[key]
+	c.replays[key]++
+
+	return candidates[replay%len(candidates)]
+}
+
+// excusePending reports the failed examples of pending interactions in the
+// verification results as pending, returning whether they were the only
+// failures
+func (c *interactionCatalog) excusePending(res []types.ProviderVerifierResponse) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	excused := false
+	for i := range res {
+		for j := range res[i].Examples {
+			example := &res[i].Examples[j]
+			if example.Status != "failed" || !c.pending(example.Pact.ConsumerName, example.FullDescription) {
+				continue
+			}
+
+			log.Printf("[WARN] pending interaction failed verification: %s", example.FullDescription)
+			example.Status = "pending"
+			res[i].Summary.FailureCount--
+			res[i].Summary.PendingCount++
+			excused = true
+		}
+	}
+
+	for _, r := range res {
+		if r.Summary.FailureCount > 0 || r.Summary.ErrorsOutsideOfExamplesCount > 0 {
+			return false
+		}
+	}
+
+	return excused
+}
+
+// pending reports whether an example verifies a pending interaction of the
+// consumer. Examples of HTTP interactions are described with the description,
+// method and path of the interaction, as for timings.
+func (c *interactionCatalog) pending(consumer string, fullDescription string) bool {
+	fullDescription = strings.ToLower(fullDescription)
+	for _, i := range c.interactions[consumer] {
+		if !i.Pending {
+			continue
+		}
+
+		interaction := i.Description
+		if i.Request.Method != "" {
+			interaction += " with " + i.Request.Method + " " + i.Request.Path
+		}
+		if strings.Contains(fullDescription, strings.ToLower(interaction)) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalStrings reports whether two lists hold the same strings in order
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// interactionHooksMiddleware invokes the before hook on the setup request of
+// each interaction, once its states are known, and the after hook once the
+// provider has responded to the replayed request
+func interactionHooksMiddleware(tracker *interactionTracker, before types.InteractionHook, after types.InteractionHook) proxy.Middleware {
+	capture := proxy.CaptureHeadersMiddleware(func(e *proxy.Exchange) {
+		interaction := tracker.metadata()
+		interaction.Timing = &types.InteractionTiming{
+			Consumer:    interaction.Consumer,
+			Description: interaction.Description,
+			States:      interaction.States,
+			Method:      e.Request.Method,
+			Path:        e.Request.URL.Path,
+			Status:      e.StatusCode,
+			Duration:    e.Duration,
+		}
+
+		log.Println("[DEBUG] executing after interaction hook")
+		if err := after(interaction); err != nil {
+			log.Println("[ERROR] error executing after interaction hook:", err)
+		}
+	})
+
+	return func(next http.Handler) http.Handler {
+		captured := next
+		if after != nil {
+			captured = capture(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == providerStatesSetupPath {
+				if before != nil && tracker.startsInteraction() {
+					log.Println("[DEBUG] executing before interaction hook")
+					if err := before(tracker.metadata()); err != nil {
+						log.Println("[ERROR] error executing before interaction hook:", err)
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if isInternalPath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			captured.ServeHTTP(w, r)
+		})
+	}
+}
+
+// timingRecorder collects the provider response time of each
+// interaction replayed through the verification proxy
+type timingRecorder struct {
+	mu      sync.Mutex
+	timings []types.InteractionTiming
+
+	// sequences numbers the timings of each interaction, as several pacts
+	// of a consumer may have the same interaction
+	sequences []int
+	replays   map[string]int
+}
+
+// middleware measures each proxied (non-setup) request. Any interaction
+// slower than threshold is flagged, unless threshold is zero
+func (r *timingRecorder) middleware(tracker *interactionTracker, threshold time.Duration) proxy.Middleware {
+	capture := proxy.CaptureHeadersMiddleware(func(e *proxy.Exchange) {
+		interaction := tracker.metadata()
+		timing := types.InteractionTiming{
+			Consumer:    interaction.Consumer,
+			Description: interaction.Description,
+			States:      interaction.States,
+			Method:      e.Request.Method,
+			Path:        e.Request.URL.Path,
+			Status:      e.StatusCode,
+			Duration:    e.Duration,
+		}
+
+		if threshold > 0 && e.Duration > threshold {
+			log.Printf("[WARN] %s %s took %s, exceeding the SLO threshold of %s", timing.Method, timing.Path, e.Duration, threshold)
+			timing.ExceededSLO = true
+		}
+
+		r.mu.Lock()
+		if r.replays == nil {
+			r.replays = map[string]int{}
+		}
+		key := timingKey(timing)
+		r.timings = append(r.timings, timing)
+		r.sequences = append(r.sequences, r.replays[key])
+		r.replays[key]++
+		r.mu.Unlock()
+	})
+
+	return func(next http.Handler) http.Handler {
+		captured := capture(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if isInternalPath(req.URL.Path) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			captured.ServeHTTP(w, req)
+		})
+	}
+}
+
+// attach assigns the recorded timings to the verification results of the
+// pact they belong to. A described timing is assigned to the pact of its
+// consumer that verified the interaction, the nth such pact getting the nth
+// timing of the interaction. Otherwise, timings are assigned by consumer, or
+// where the consumer could not be determined (no state setup calls were made)
+// and there is a single pact, they are all assigned to it.
+func (r *timingRecorder) attach(res []types.ProviderVerifierResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	verified := map[string]int{}
+	for i := range res {
+		consumer := ""
+		if len(res[i].Examples) > 0 {
+			consumer = res[i].Examples[0].Pact.ConsumerName
+		}
+
+		var keys []string
+		for j, timing := range r.timings {
+			if timing.Description == "" {
+				if timing.Consumer == consumer || (timing.Consumer == "" && len(res) == 1) {
+					res[i].Timings = append(res[i].Timings, timing)
+				}
+				continue
+			}
+
+			key := timingKey(timing)
+			if timing.Consumer == consumer && r.sequences[j] == verified[key] && verifies(res[i], timing) {
+				res[i].Timings = append(res[i].Timings, timing)
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			verified[key]++
+		}
+	}
+}
+
+// timingKey identifies the interaction a timing is for, by its consumer,
+// description and provider states
+func timingKey(timing types.InteractionTiming) string {
+	return strings.Join(append([]string{timing.Consumer, timing.Description}, timing.States...), "\x00")
+}
+
+// verifies reports whether the verification result includes the interaction
+// of the timing, whose examples are described with the description, method
+// and path of the interaction, e.g. "... a request for user 1 with GET
+// /users/1 returns a response which has status code 200"
+func verifies(res types.ProviderVerifierResponse, timing types.InteractionTiming) bool {
+	interaction := strings.ToLower(timing.Description + " with " + timing.Method + " " + timing.Path)
+	for _, example := range res.Examples {
+		if strings.Contains(strings.ToLower(example.FullDescription), interaction) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,325 @@
+package dsl
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInteractionTracker(t *testing.T) {
+	tracker := &interactionTracker{}
+
+	req, _ := http.NewRequest("POST", providerStatesSetupPath, strings.NewReader(`{
+		"states": ["state x"],
+		"consumer": "billy"
+		}`))
+
+	var body string
+	tracker.middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := new(strings.Builder)
+		buf := make([]byte, 512)
+		n, _ := r.Body.Read(buf)
+		b.Write(buf[:n])
+		body = b.String()
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	consumer, states := tracker.current()
+	assert.Equal(t, "billy", consumer)
+	assert.Equal(t, []string{"state x"}, states)
+	assert.Contains(t, body, "state x", "expected request body to be passed on")
+}
+
+func TestInteractionTracker_MultipleStates(t *testing.T) {
+	tracker := &interactionTracker{provider: "bobby"}
+
+	var before []types.InteractionMetadata
+	var replayed types.InteractionMetadata
+	mw := interactionHooksMiddleware(tracker, func(i types.InteractionMetadata) error {
+		before = append(before, i)
+		return nil
+	}, nil)
+	handler := tracker.middleware()(mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replayed, _ = InteractionFromContext(r.Context())
+	})))
+
+	setup := func(body string) {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", providerStatesSetupPath, strings.NewReader(body)))
+	}
+
+	// the verifier sets up each state of an interaction separately
+	setup(`{"consumer":"billy","state":"state x","states":["state x"]}`)
+	setup(`{"consumer":"billy","state":"state y"}`)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+	setup(`{"consumer":"billy","state":"state x","action":"teardown"}`)
+	setup(`{"consumer":"billy","state":"state x","action":"teardown"}`)
+
+	assert.Equal(t, []string{"state x", "state y"}, replayed.States)
+
+	// the next interaction starts afresh
+	setup(`{"consumer":"billy","states":["state z"]}`)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+
+	assert.Equal(t, []string{"state z"}, replayed.States)
+	if assert.Len(t, before, 2, "expected the before hook once per interaction") {
+		assert.Equal(t, []string{"state x"}, before[0].States)
+		assert.Equal(t, []string{"state z"}, before[1].States)
+	}
+}
+
+func TestInteractionFromContext(t *testing.T) {
+	catalog := &interactionCatalog{}
+	catalog.add([]byte(`{
+		"consumer": {"name": "billy"},
+		"interactions": [{"description": "a request for the users", "providerState": "state x", "request": {"method": "GET", "path": "/users"}}]
+	}`))
+	tracker := &interactionTracker{provider: "bobby", catalog: catalog}
+	handler := tracker.middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		interaction, ok := InteractionFromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(interaction)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/users", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code, "expected no interaction before states are set up")
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", providerStatesSetupPath, strings.NewReader(`{"consumer":"billy","states":["state x"]}`)))
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/users", nil))
+
+	var interaction types.InteractionMetadata
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &interaction))
+	assert.Equal(t, types.InteractionMetadata{Provider: "bobby", Consumer: "billy", Description: "a request for the users", States: []string{"state x"}}, interaction)
+
+	// a request that isn't in the pacts is only identified by its states
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/unknown", nil))
+
+	interaction = types.InteractionMetadata{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &interaction))
+	assert.Equal(t, types.InteractionMetadata{Provider: "bobby", Consumer: "billy", States: []string{"state x"}}, interaction)
+}
+
+func TestTimingRecorder(t *testing.T) {
+	tracker := &interactionTracker{}
+	tracker.set("billy", []string{"state x"})
+	recorder := &timingRecorder{}
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mw := recorder.middleware(tracker, 10*time.Millisecond)
+
+	req, _ := http.NewRequest("GET", "/users/1", nil)
+	mw(slow).ServeHTTP(httptest.NewRecorder(), req)
+
+	setup, _ := http.NewRequest("POST", providerStatesSetupPath, nil)
+	mw(slow).ServeHTTP(httptest.NewRecorder(), setup)
+
+	if !assert.Len(t, recorder.timings, 1, "expected setup requests not to be timed") {
+		return
+	}
+
+	timing := recorder.timings[0]
+	assert.Equal(t, "billy", timing.Consumer)
+	assert.Equal(t, "GET", timing.Method)
+	assert.Equal(t, "/users/1", timing.Path)
+	assert.Equal(t, http.StatusNotFound, timing.Status)
+	assert.True(t, timing.Duration >= 20*time.Millisecond)
+	assert.True(t, timing.ExceededSLO)
+
+	t.Run("attach by consumer", func(t *testing.T) {
+		var res []types.ProviderVerifierResponse
+		err := json.Unmarshal([]byte(`[{"examples":[{"pact":{"consumer_name":"billy"}}]},{"examples":[{"pact":{"consumer_name":"jessica"}}]}]`), &res)
+		assert.NoError(t, err)
+
+		recorder.attach(res)
+
+		assert.Len(t, res[0].Timings, 1)
+		assert.Len(t, res[1].Timings, 0)
+	})
+}
+
+func TestInteractionCatalog(t *testing.T) {
+	catalog := &interactionCatalog{}
+	catalog.add([]byte(`{
+		"consumer": {"name": "billy"},
+		"interactions": [
+			{"description": "a request for user 1", "providerState": "user 1 exists", "request": {"method": "GET", "path": "/users/1"}},
+			{"description": "a request for a missing user", "request": {"method": "GET", "path": "/users/1"}}
+		]
+	}`))
+	catalog.add([]byte(`{
+		"consumer": {"name": "billy"},
+		"interactions": [
+			{"description": "a request to create a user", "providerStates": [{"name": "no users"}, {"name": "admin"}], "request": {"method": "POST", "path": "/users"}}
+		]
+	}`))
+	catalog.add([]byte(`{"_links": {"pb:latest-provider-pacts": {"href": "/pacts/provider/bobby/latest"}}}`))
+
+	assert.Equal(t, "a request for user 1", catalog.describe("billy", []string{"user 1 exists"}, "get", "/users/1"))
+	assert.Equal(t, "a request for a missing user", catalog.describe("billy", nil, "GET", "/users/1"))
+	assert.Equal(t, "a request to create a user", catalog.describe("billy", []string{"no users", "admin"}, "POST", "/users"))
+	assert.Equal(t, "", catalog.describe("billy", []string{"admin"}, "POST", "/users"))
+	assert.Equal(t, "", catalog.describe("jessica", []string{"user 1 exists"}, "GET", "/users/1"))
+
+	var empty *interactionCatalog
+	assert.Equal(t, "", empty.describe("billy", nil, "GET", "/users/1"))
+}
+
+func TestInteractionCatalog_SameRequest(t *testing.T) {
+	catalog := &interactionCatalog{}
+	for _, version := range []string{"1.0.0", "2.0.0"} {
+		catalog.add([]byte(`{
+			"consumer": {"name": "billy"},
+			"interactions": [{"description": "a request for user 1 (` + version + `)", "providerState": "user 1 exists", "request": {"method": "GET", "path": "/users/1"}}]
+		}`))
+	}
+
+	// each pact is verified in turn
+	assert.Equal(t, "a request for user 1 (1.0.0)", catalog.describe("billy", []string{"user 1 exists"}, "GET", "/users/1"))
+	assert.Equal(t, "a request for user 1 (2.0.0)", catalog.describe("billy", []string{"user 1 exists"}, "GET", "/users/1"))
+}
+
+func TestInteractionCatalog_ExcusePending(t *testing.T) {
+	catalog := &interactionCatalog{}
+	catalog.add([]byte(`{
+		"consumer": {"name": "billy"},
+		"interactions": [
+			{"description": "a request for user 1", "request": {"method": "GET", "path": "/users/1"}},
+			{"description": "a request for a new feature", "pending": true, "request": {"method": "GET", "path": "/new"}}
+		],
+		"messages": [{"description": "a feature enabled event", "pending": true}]
+	}`))
+
+	results := func(failed ...string) []types.ProviderVerifierResponse {
+		var examples []string
+		for _, description := range failed {
+			examples = append(examples, `{"full_description": "Verifying a pact between billy and bobby `+description+` has status code 200", "status": "failed", "pact": {"consumer_name": "billy"}}`)
+		}
+		var res []types.ProviderVerifierResponse
+		err := json.Unmarshal([]byte(`[{"examples": [`+strings.Join(examples, ",")+`], "summary": {"failure_count": `+strconv.Itoa(len(failed))+`}}]`), &res)
+		assert.NoError(t, err)
+		return res
+	}
+
+	res := results("A request for a new feature with GET /new", "a feature enabled event")
+	assert.True(t, catalog.excusePending(res), "expected only pending interactions to have failed")
+	assert.Equal(t, "pending", res[0].Examples[0].Status)
+	assert.Equal(t, "pending", res[0].Examples[1].Status)
+	assert.Equal(t, 0, res[0].Summary.FailureCount)
+	assert.Equal(t, 2, res[0].Summary.PendingCount)
+
+	res = results("a request for a new feature with GET /new", "a request for user 1 with GET /users/1")
+	assert.False(t, catalog.excusePending(res), "expected the failure of a verified interaction to fail the verification")
+	assert.Equal(t, "pending", res[0].Examples[0].Status)
+	assert.Equal(t, "failed", res[0].Examples[1].Status)
+	assert.Equal(t, 1, res[0].Summary.FailureCount)
+
+	var empty *interactionCatalog
+	assert.False(t, empty.excusePending(results("a request for a new feature with GET /new")))
+}
+
+func TestTimingRecorder_MultiplePacts(t *testing.T) {
+	catalog := &interactionCatalog{}
+	for _, consumer := range []string{"billy", "billy", "jessica"} {
+		catalog.add([]byte(`{
+			"consumer": {"name": "` + consumer + `"},
+			"interactions": [{"description": "a request for user 1", "providerState": "user 1 exists", "request": {"method": "GET", "path": "/users/1"}}]
+		}`))
+	}
+	tracker := &interactionTracker{catalog: catalog}
+	recorder := &timingRecorder{}
+
+	status := http.StatusOK
+	handler := tracker.middleware()(recorder.middleware(tracker, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})))
+
+	// the verifier replays the interaction once for each pact, in turn
+	for i, consumer := range []string{"billy", "billy", "jessica"} {
+		status = http.StatusOK + i
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", providerStatesSetupPath, strings.NewReader(`{"consumer":"`+consumer+`","states":["user 1 exists"]}`)))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+	}
+
+	example := func(consumer string, version string) string {
+		return `{"examples":[
+			{"full_description": "Verifying a pact between ` + consumer + ` and bobby Given user 1 exists a request for user 1 with GET /users/1 returns a response which has status code 200", "pact":{"consumer_name":"` + consumer + `","url":"` + version + `"}},
+			{"full_description": "Verifying a pact between ` + consumer + ` and bobby Given user 1 exists a request for user 1 with GET /users/1 returns a response which has a matching body", "pact":{"consumer_name":"` + consumer + `","url":"` + version + `"}}
+		]}`
+	}
+	var res []types.ProviderVerifierResponse
+	err := json.Unmarshal([]byte(`[`+example("billy", "1.0.0")+`,`+example("billy", "2.0.0")+`,`+example("jessica", "1.0.0")+`]`), &res)
+	assert.NoError(t, err)
+
+	recorder.attach(res)
+
+	for i, r := range res {
+		if assert.Len(t, r.Timings, 1, "expected one timing for pact %d", i) {
+			assert.Equal(t, "a request for user 1", r.Timings[0].Description)
+			assert.Equal(t, http.StatusOK+i, r.Timings[0].Status, "expected the timing of pact %d", i)
+		}
+	}
+}
+
+func TestInteractionHooksMiddleware(t *testing.T) {
+	catalog := &interactionCatalog{}
+	catalog.add([]byte(`{
+		"consumer": {"name": "billy"},
+		"interactions": [{"description": "a request to create a user", "providerState": "state x", "request": {"method": "POST", "path": "/users"}}]
+	}`))
+	tracker := &interactionTracker{provider: "bobby", catalog: catalog}
+
+	var before, after []types.InteractionMetadata
+	mw := interactionHooksMiddleware(tracker, func(i types.InteractionMetadata) error {
+		before = append(before, i)
+		return nil
+	}, func(i types.InteractionMetadata) error {
+		after = append(after, i)
+		return nil
+	})
+
+	handler := tracker.middleware()(mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", providerStatesSetupPath, strings.NewReader(`{"consumer":"billy","states":["state x"]}`)))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/users", nil))
+
+	assert.Equal(t, []types.InteractionMetadata{{Provider: "bobby", Consumer: "billy", States: []string{"state x"}}}, before)
+	assert.Len(t, after, 1)
+	assert.Equal(t, "billy", after[0].Consumer)
+	assert.Equal(t, "a request to create a user", after[0].Description)
+	if assert.NotNil(t, after[0].Timing) {
+		assert.Equal(t, "/users", after[0].Timing.Path)
+		assert.Equal(t, http.StatusCreated, after[0].Timing.Status)
+	}
+}
+
+func TestInteractionHooksMiddleware_BeforeFail(t *testing.T) {
+	tracker := &interactionTracker{}
+	mw := interactionHooksMiddleware(tracker, func(types.InteractionMetadata) error {
+		return errors.New("unable to seed data")
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected state setup not to continue")
+	})).ServeHTTP(rr, httptest.NewRequest("POST", providerStatesSetupPath, strings.NewReader(`{}`)))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
@@ -0,0 +1,300 @@
+package dsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pact-foundation/pact-go/mockserver"
+)
+
+var semverRegex = regexp.MustCompile(semver)
+
+// v3Matcher is a matcher of version 3 of the Pact specification, or later,
+// written in the "pact:matcher:type" form of the other Pact implementations.
+// They require the NativeMockServer, and a SpecificationVersion supporting
+// them.
+type v3Matcher struct {
+	// Type of the matcher, e.g. "arrayContains"
+	Type string
+
+	// Value is the example of the matcher, if it has one
+	Value interface{}
+
+	// Attributes of the matcher, e.g. the variants of arrayContains
+	Attributes map[string]interface{}
+
+	// Generator of the matcher's values in place of the example, if any, e.g.
+	// "DateTime"
+	Generator string
+}
+
+func (m v3Matcher) isMatcher() {}
+
+// GetValue returns the raw generated value for the matcher
+// without any of the matching detail context
+func (m v3Matcher) GetValue() interface{} {
+	switch m.Type {
+	case "arrayContains":
+		return m.Attributes["variants"]
+	case "null":
+		if or, ok := m.Attributes["or"].(Matcher); ok {
+			return or.GetValue()
+		}
+	}
+	return m.Value
+}
+
+func (m v3Matcher) MarshalJSON() ([]byte, error) {
+	content := map[string]interface{}{"pact:matcher:type": m.Type}
+	for k, v := range m.Attributes {
+		content[k] = v
+	}
+	if m.Value != nil {
+		content["value"] = m.Value
+	}
+	if m.Generator != "" {
+		content["pact:generator:type"] = m.Generator
+	}
+
+	return json.Marshal(content)
+}
+
+// ArrayContaining specifies that an array must contain an element matching
+// each of the variants, in any order and alongside other elements, e.g. the
+// links of a HAL resource:
+//
+//	"_links": ArrayContaining(
+//		map[string]interface{}{"rel": "self", "href": Like("/orders/1")},
+//		map[string]interface{}{"rel": "payment", "href": Like("/payments/1")},
+//	)
+//
+// The variants are the example of the array. Requires version 3 of the
+// specification.
+func ArrayContaining(variants ...interface{}) Matcher {
+	return v3Matcher{
+		Type:       "arrayContains",
+		Attributes: map[string]interface{}{"variants": variants},
+	}
+}
+
+// EachKeyLike specifies that an object may have any keys, e.g. a map of IDs
+// to orders, as long as each value is like the template:
+//
+//	"orders": EachKeyLike("1234", map[string]interface{}{"total": Decimal()})
+//
+// The key and template are the example of the object. Requires version 3 of
+// the specification.
+func EachKeyLike(key string, template interface{}) Matcher {
+	return v3Matcher{
+		Type:  "values",
+		Value: map[string]interface{}{key: template},
+	}
+}
+
+// EachKeyMatching specifies that each key of an object must match the key
+// matcher, e.g. Regex("1234", `^\d+$`), and each value must be like the
+// template. The example of the key matcher and the template are the example
+// of the object. Requires version 4 of the specification.
+func EachKeyMatching(key Matcher, template interface{}) Matcher {
+	return v3Matcher{
+		Type:       "eachKey",
+		Value:      map[string]interface{}{fmt.Sprint(key.GetValue()): template},
+		Attributes: map[string]interface{}{"rules": []interface{}{key}},
+	}
+}
+
+// Includes specifies that a string must include the substring, which is also
+// its example. Requires version 3 of the specification.
+func Includes(substring string) Matcher {
+	return v3Matcher{
+		Type:  "include",
+		Value: substring,
+	}
+}
+
+// NotEmpty specifies that a value must be of the same type as the example,
+// and not be empty, e.g. a non-empty string, array or object. Requires
+// version 4 of the specification.
+func NotEmpty(example interface{}) Matcher {
+	return v3Matcher{
+		Type:  "notEmpty",
+		Value: example,
+	}
+}
+
+// FromRegex specifies that a string must match the regular expression, as
+// Term does, and has the mock server and providers generate random strings
+// matching it in place of the example, so that the example of the pact isn't
+// relied on, e.g. by other systems using the mock server as a stub. Requires
+// version 3 of the specification, or is written as a Term in version 2.
+// Panics if the regular expression is invalid, or the example doesn't match
+// it.
+func FromRegex(example string, regex string) Matcher {
+	checkRegex(example, regex)
+
+	return v3Matcher{
+		Type:       "regex",
+		Value:      example,
+		Attributes: map[string]interface{}{"regex": regex},
+		Generator:  "Regex",
+	}
+}
+
+// FromProviderState specifies that a value must be like the example, and has
+// providers replace it with the value of the expression, e.g. "${id}" or
+// "/orders/${id}", given the values of the provider state. This allows the
+// ID of a resource created by the provider state, for example, to be used in
+// the path of a request or a Location header. Requires version 3 of the
+// specification, and a verifier supporting provider state injected values,
+// or is written as Like(example) in version 2.
+func FromProviderState(expression string, example interface{}) Matcher {
+	return v3Matcher{
+		Type:       "type",
+		Value:      example,
+		Attributes: map[string]interface{}{"expression": expression},
+		Generator:  "ProviderState",
+	}
+}
+
+// Boolean specifies that a value must be a boolean, e.g. an "active" flag,
+// rather than the example itself. Providers generate a random boolean in
+// their requests. It's written as a version 3 boolean matcher when supported,
+// and otherwise as Like(example).
+func Boolean(example bool) Matcher {
+	return v3Matcher{
+		Type:      "boolean",
+		Value:     example,
+		Generator: "RandomBoolean",
+	}
+}
+
+// Semver specifies that a string must be a semantic version, e.g. "1.2.3" or
+// "2.0.0-rc.1". It's written as a version 4 semver matcher when supported,
+// and otherwise as a Term with the regular expression of semver.org. Panics
+// if the example isn't a semantic version.
+func Semver(example string) Matcher {
+	if !semverRegex.MatchString(example) {
+		panic(fmt.Sprintf("match: %q is not a semantic version", example))
+	}
+
+	return v3Matcher{
+		Type:  "semver",
+		Value: example,
+	}
+}
+
+// ContentType specifies that a string, or a whole body, must be parseable as
+// the content type, e.g. "application/xml" or "image/png", using the example
+// in mock responses. Requires version 3 of the specification.
+func ContentType(contentType string, example string) Matcher {
+	return v3Matcher{
+		Type:       "contentType",
+		Value:      example,
+		Attributes: map[string]interface{}{"contentType": contentType},
+	}
+}
+
+// Null specifies that a value must be null. Requires version 3 of the
+// specification.
+func Null() Matcher {
+	return v3Matcher{Type: "null"}
+}
+
+// Nullable specifies that a value must either match the matcher or be null,
+// e.g. an optional date:
+//
+//	"cancelledAt": Nullable(Timestamp())
+//
+// The example of the matcher is the example of the value. Requires version 3
+// of the specification.
+func Nullable(matcher Matcher) Matcher {
+	return v3Matcher{
+		Type:       "null",
+		Attributes: map[string]interface{}{"or": matcher},
+	}
+}
+
+// DateTime specifies that a string must be a date and time in the format,
+// e.g. time.RFC3339, with the example formatted in it. The format is either a
+// Go time layout or a Java DateTimeFormatter pattern, as used by the other
+// Pact implementations, e.g. "yyyy-MM-dd'T'HH:mm:ssXXX". Providers and the
+// mock server generate the current date and time in place of the example.
+// Requires version 3 of the specification.
+func DateTime(example time.Time, format string) Matcher {
+	return dateTimeMatcher("datetime", "DateTime", example, format, "")
+}
+
+// FormattedDate specifies that a string must be a date in the format, e.g.
+// "2006-01-02" or "yyyy-MM-dd", as DateTime does.
+func FormattedDate(example time.Time, format string) Matcher {
+	return dateTimeMatcher("date", "Date", example, format, "")
+}
+
+// FormattedTime specifies that a string must be a time in the format, e.g.
+// "15:04:05" or "HH:mm:ss", as DateTime does.
+func FormattedTime(example time.Time, format string) Matcher {
+	return dateTimeMatcher("time", "Time", example, format, "")
+}
+
+// DateTimeOffset specifies that a string must be a date and time in the
+// format, as DateTime does, generated from a date expression relative to
+// now, e.g. "+ 1 hour" or "tomorrow - 30 minutes", rather than the current
+// date and time. The example is generated in the same way. Panics if the
+// expression is invalid.
+func DateTimeOffset(expression string, format string) Matcher {
+	return dateTimeMatcher("datetime", "DateTime", dateExpression(expression), format, expression)
+}
+
+// DateOffset specifies that a string must be a date in the format, as
+// FormattedDate does, generated from a date expression as DateTimeOffset
+// does, e.g. "+ 30 days" for a due date.
+func DateOffset(expression string, format string) Matcher {
+	return dateTimeMatcher("date", "Date", dateExpression(expression), format, expression)
+}
+
+// TimeOffset specifies that a string must be a time in the format, as
+// FormattedTime does, generated from a date expression as DateTimeOffset
+// does, e.g. "noon" or "+ 2 hours".
+func TimeOffset(expression string, format string) Matcher {
+	return dateTimeMatcher("time", "Time", dateExpression(expression), format, expression)
+}
+
+// dateExpression returns the time of a date expression, relative to now
+func dateExpression(expression string) time.Time {
+	t, err := mockserver.ParseDateExpression(expression, time.Now())
+	if err != nil {
+		panic(fmt.Sprintf("match: %v", err))
+	}
+	return t
+}
+
+// dateTimeMatcher returns a date, time or datetime matcher of the example,
+// with the expression of its generator, if any. Go time layouts are told
+// apart from Java patterns by their digits.
+func dateTimeMatcher(matcherType string, generator string, example time.Time, format string, expression string) Matcher {
+	layout, pattern := format, format
+	var err error
+	if strings.ContainsAny(format, "0123456789") {
+		pattern, err = mockserver.DateTimeFormat(format)
+	} else {
+		layout, err = mockserver.TimeLayout(format)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("match: invalid %s format %q: %v", matcherType, format, err))
+	}
+
+	attributes := map[string]interface{}{"format": pattern}
+	if expression != "" {
+		attributes["expression"] = expression
+	}
+
+	return v3Matcher{
+		Type:       matcherType,
+		Value:      example.Format(layout),
+		Attributes: attributes,
+		Generator:  generator,
+	}
+}
@@ -0,0 +1,446 @@
+package dsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// verifyRequestBody runs a consumer test of a request expecting the given
+// body against the native mock server, sending body, and returns the
+// verification error and the matching rules of the request in the pact
+func verifyRequestBody(t *testing.T, version int, expected interface{}, body string) (map[string]interface{}, error) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	pact := &Pact{
+		Consumer:             "My Consumer",
+		Provider:             "My Provider",
+		PactDir:              dir,
+		LogLevel:             "ERROR",
+		NativeMockServer:     true,
+		SpecificationVersion: version,
+	}
+	defer pact.Teardown()
+
+	pact.
+		AddInteraction().
+		UponReceiving("a request to create an order").
+		WithRequest(Request{Method: "POST", Path: String("/orders"), Body: expected}).
+		WillRespondWith(Response{Status: 201})
+
+	err := pact.Verify(func() error {
+		res, err := http.Post(fmt.Sprintf("http://localhost:%d/orders", pact.Server.Port), "application/json", strings.NewReader(body))
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := pact.WritePact(); err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "my_consumer-my_provider.json"))
+	if err != nil {
+		return nil, err
+	}
+	var written struct {
+		Interactions []struct {
+			Request struct {
+				MatchingRules map[string]interface{} `json:"matchingRules"`
+			} `json:"request"`
+		} `json:"interactions"`
+	}
+	if err := json.Unmarshal(content, &written); err != nil {
+		return nil, err
+	}
+	return written.Interactions[0].Request.MatchingRules, nil
+}
+
+// bodyRule returns the first matching rule of the body at path
+func bodyRule(rules map[string]interface{}, path string) map[string]interface{} {
+	body, _ := rules["body"].(map[string]interface{})
+	entry, _ := body[path].(map[string]interface{})
+	matchers, _ := entry["matchers"].([]interface{})
+	if len(matchers) == 0 {
+		return nil
+	}
+	rule, _ := matchers[0].(map[string]interface{})
+	return rule
+}
+
+func TestArrayContaining(t *testing.T) {
+	links := ArrayContaining(
+		map[string]interface{}{"rel": "self", "href": Like("/orders/1")},
+		map[string]interface{}{"rel": "payment"},
+	)
+
+	content, err := json.Marshal(links)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"pact:matcher:type": "arrayContains", "variants": [
+		{"rel": "self", "href": {"json_class": "Pact::SomethingLike", "contents": "/orders/1"}},
+		{"rel": "payment"}
+	]}`, string(content))
+	assert.Len(t, links.GetValue(), 2)
+
+	expected := map[string]interface{}{"_links": links}
+
+	rules, err := verifyRequestBody(t, 3, expected, `{"_links": [{"rel": "next"}, {"rel": "payment"}, {"rel": "self", "href": "/orders/2"}]}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "arrayContains", bodyRule(rules, "$._links")["match"])
+
+	_, err = verifyRequestBody(t, 3, expected, `{"_links": [{"rel": "self", "href": "/orders/2"}]}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "expected an element matching variant 1")
+}
+
+func TestEachKeyLike(t *testing.T) {
+	orders := EachKeyLike("1234", map[string]interface{}{"total": Like(9.99)})
+
+	content, err := json.Marshal(orders)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"pact:matcher:type": "values", "value": {"1234": {"total": {"json_class": "Pact::SomethingLike", "contents": 9.99}}}}`, string(content))
+
+	expected := map[string]interface{}{"orders": orders}
+
+	rules, err := verifyRequestBody(t, 3, expected, `{"orders": {"1": {"total": 1.5}, "2": {"total": 3}}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "values"}, bodyRule(rules, "$.orders"))
+	assert.Equal(t, map[string]interface{}{"match": "type"}, bodyRule(rules, "$.orders.*.total"))
+
+	_, err = verifyRequestBody(t, 3, expected, `{"orders": {"1": {"total": "1.5"}}}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "$.body.orders['1'].total: expected a number, got a string")
+}
+
+func TestEachKeyMatching(t *testing.T) {
+	expected := map[string]interface{}{"orders": EachKeyMatching(Regex("1234", `^\d+$`), map[string]interface{}{"total": 9.99})}
+
+	rules, err := verifyRequestBody(t, 4, expected, `{"orders": {"1": {"total": 1.5}, "2": {"total": 3}}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"match": "eachKey",
+		"rules": []interface{}{map[string]interface{}{"match": "regex", "regex": `^\d+$`}},
+	}, bodyRule(rules, "$.orders"))
+	assert.Equal(t, map[string]interface{}{"match": "type"}, bodyRule(rules, "$.orders.*"))
+
+	_, err = verifyRequestBody(t, 4, expected, `{"orders": {"abc": {"total": 1.5}}}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `expected "abc" to match`)
+
+	_, err = verifyRequestBody(t, 3, expected, `{"orders": {}}`)
+	assert.EqualError(t, err, `"a request to create an order" uses the eachKey matcher, which requires version 4 of the pact specification, not 3`)
+}
+
+func TestStringMatchers(t *testing.T) {
+	expected := map[string]interface{}{
+		"greeting": Includes("world"),
+		"document": ContentType("application/xml", "<order/>"),
+	}
+
+	rules, err := verifyRequestBody(t, 3, expected, `{"greeting": "hello world", "document": "<order id=\"1\"/>"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "include", "value": "world"}, bodyRule(rules, "$.greeting"))
+	assert.Equal(t, map[string]interface{}{"match": "contentType", "value": "application/xml"}, bodyRule(rules, "$.document"))
+
+	_, err = verifyRequestBody(t, 3, expected, `{"greeting": "hello", "document": "{}"}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `$.body.document: expected content of type application/xml`)
+	assert.Contains(t, err.Error(), `$.body.greeting: expected "hello" to include "world"`)
+}
+
+func TestNotEmpty(t *testing.T) {
+	expected := map[string]interface{}{"tags": NotEmpty([]string{"new"})}
+
+	rules, err := verifyRequestBody(t, 4, expected, `{"tags": ["sale"]}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "notEmpty"}, bodyRule(rules, "$.tags"))
+
+	_, err = verifyRequestBody(t, 4, expected, `{"tags": []}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "$.body.tags: expected [] not to be empty")
+}
+
+func TestIntegerDecimal(t *testing.T) {
+	expected := map[string]interface{}{"quantity": Integer(), "total": Decimal()}
+
+	rules, err := verifyRequestBody(t, 3, expected, `{"quantity": 2, "total": 19.98}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "integer"}, bodyRule(rules, "$.quantity"))
+	assert.Equal(t, map[string]interface{}{"match": "decimal"}, bodyRule(rules, "$.total"))
+
+	_, err = verifyRequestBody(t, 3, expected, `{"quantity": 2.5, "total": 20}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "$.body.quantity: expected an integer, got 2.5")
+	assert.Contains(t, err.Error(), "$.body.total: expected a decimal, got 20")
+
+	// Version 2 pacts fall back to matching the type
+	rules, err = verifyRequestBody(t, 2, expected, `{"quantity": 2.5, "total": 20}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "type"}, rules["$.body.quantity"])
+}
+
+func TestNull(t *testing.T) {
+	expected := map[string]interface{}{"deletedAt": Null(), "cancelledAt": Nullable(Like("2020-01-01"))}
+
+	for _, body := range []string{`{"deletedAt": null, "cancelledAt": null}`, `{"deletedAt": null, "cancelledAt": "2021-06-30"}`} {
+		rules, err := verifyRequestBody(t, 3, expected, body)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"match": "null"}, bodyRule(rules, "$.deletedAt"))
+	}
+
+	_, err := verifyRequestBody(t, 3, expected, `{"deletedAt": "2021-06-30", "cancelledAt": 1}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "$.body.deletedAt: expected null, got a string")
+	assert.Contains(t, err.Error(), "$.body.cancelledAt: expected a string, got a number")
+
+	assert.Equal(t, "2020-01-01", Nullable(Like("2020-01-01")).GetValue())
+}
+
+func TestDateTime(t *testing.T) {
+	placed := time.Date(2020, 1, 31, 12, 30, 0, 0, time.UTC)
+
+	matcher := DateTime(placed, time.RFC3339).(v3Matcher)
+	assert.Equal(t, "2020-01-31T12:30:00Z", matcher.GetValue())
+	assert.Equal(t, "DateTime", matcher.Generator)
+	assert.Equal(t, "yyyy-MM-dd'T'HH:mm:ssXXX", matcher.Attributes["format"])
+
+	matcher = FormattedDate(placed, "dd/MM/yyyy").(v3Matcher)
+	assert.Equal(t, "31/01/2020", matcher.GetValue())
+	assert.Equal(t, "dd/MM/yyyy", matcher.Attributes["format"])
+
+	assert.Panics(t, func() { DateTime(placed, time.ANSIC) })
+
+	expected := map[string]interface{}{
+		"placedAt":  DateTime(placed, time.RFC3339),
+		"deliverOn": FormattedDate(placed, "2006-01-02"),
+		"slot":      FormattedTime(placed, "HH:mm"),
+	}
+	rules, err := verifyRequestBody(t, 3, expected, `{"placedAt": "2021-06-30T09:15:00+02:00", "deliverOn": "2021-07-01", "slot": "14:00"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "datetime", "format": "yyyy-MM-dd'T'HH:mm:ssXXX"}, bodyRule(rules, "$.placedAt"))
+	assert.Equal(t, map[string]interface{}{"match": "date", "format": "yyyy-MM-dd"}, bodyRule(rules, "$.deliverOn"))
+
+	_, err = verifyRequestBody(t, 3, expected, `{"placedAt": "2021-06-30", "deliverOn": "2021-07-01", "slot": "2pm"}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `$.body.placedAt: expected "2021-06-30" to be a datetime of format yyyy-MM-dd'T'HH:mm:ssXXX`)
+	assert.Contains(t, err.Error(), `$.body.slot: expected "2pm" to be a time of format HH:mm`)
+}
+
+func TestSemver(t *testing.T) {
+	expected := map[string]interface{}{"version": Semver("1.0.0")}
+
+	rules, err := verifyRequestBody(t, 4, expected, `{"version": "2.1.0-rc.1"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "semver"}, bodyRule(rules, "$.version"))
+
+	_, err = verifyRequestBody(t, 4, expected, `{"version": "2.1"}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `$.body.version: expected "2.1" to be a semantic version`)
+
+	// Earlier versions fall back to a regular expression
+	rules, err = verifyRequestBody(t, 3, expected, `{"version": "2.1.0"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "regex", "regex": semver}, bodyRule(rules, "$.version"))
+
+	assert.Panics(t, func() { Semver("1.0") })
+}
+
+func TestBoolean(t *testing.T) {
+	expected := map[string]interface{}{"active": Boolean(true)}
+
+	rules, err := verifyRequestBody(t, 3, expected, `{"active": false}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "boolean"}, bodyRule(rules, "$.active"))
+
+	_, err = verifyRequestBody(t, 3, expected, `{"active": "yes"}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "$.body.active: expected a boolean, got a string")
+
+	// Version 2 pacts fall back to matching the type
+	rules, err = verifyRequestBody(t, 2, expected, `{"active": false}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "type"}, rules["$.body.active"])
+}
+
+func TestFromRegex(t *testing.T) {
+	expected := map[string]interface{}{"reference": FromRegex("ORD-000001", `^ORD-\d{6}$`)}
+
+	rules, err := verifyRequestBody(t, 3, expected, `{"reference": "ORD-123456"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "regex", "regex": `^ORD-\d{6}$`}, bodyRule(rules, "$.reference"))
+
+	_, err = verifyRequestBody(t, 3, expected, `{"reference": "123456"}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `$.body.reference: expected "123456" to match /^ORD-\d{6}$/`)
+
+	// Version 2 pacts fall back to a Term, without the generator
+	rules, err = verifyRequestBody(t, 2, expected, `{"reference": "ORD-123456"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "regex", "regex": `^ORD-\d{6}$`}, rules["$.body.reference"])
+
+	assert.PanicsWithValue(t, `match: the example "123456" doesn't match /^ORD-\d{6}$/`, func() {
+		FromRegex("123456", `^ORD-\d{6}$`)
+	})
+}
+
+func TestUUID(t *testing.T) {
+	expected := map[string]interface{}{"id": UUIDWithExample("2f1f3b9e-6c1a-4d7e-9b8a-0c5d4e3f2a1b")}
+
+	rules, err := verifyRequestBody(t, 3, expected, `{"id": "fc763eba-0905-41c5-a27f-3934ab26786c"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "regex", "regex": uuid}, bodyRule(rules, "$.id"))
+
+	_, err = verifyRequestBody(t, 3, expected, `{"id": "1234"}`)
+	assert.Error(t, err)
+
+	matcher := UUID().(v3Matcher)
+	assert.Equal(t, "Uuid", matcher.Generator)
+	assert.Equal(t, "fc763eba-0905-41c5-a27f-3934ab26786c", matcher.GetValue())
+	assert.Panics(t, func() { UUIDWithExample("not-a-uuid") })
+
+	// Version 2 pacts fall back to a Term, without the generator
+	rules, err = verifyRequestBody(t, 2, map[string]interface{}{"id": UUID()}, `{"id": "2f1f3b9e-6c1a-4d7e-9b8a-0c5d4e3f2a1b"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "regex", "regex": uuid}, rules["$.body.id"])
+}
+
+func TestFromProviderState(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	pact := &Pact{
+		Consumer:             "My Consumer",
+		Provider:             "My Provider",
+		PactDir:              dir,
+		LogLevel:             "ERROR",
+		NativeMockServer:     true,
+		SpecificationVersion: 3,
+	}
+	defer pact.Teardown()
+
+	pact.
+		AddInteraction().
+		Given("an order exists").
+		UponReceiving("a request to cancel the order").
+		WithRequest(Request{Method: "POST", Path: FromProviderState("/orders/${id}/cancel", "/orders/1/cancel")}).
+		WillRespondWith(Response{
+			Status:  303,
+			Headers: MapMatcher{"Location": FromProviderState("/orders/${id}", "/orders/1")},
+		})
+
+	err := pact.Verify(func() error {
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		res, err := client.Post(fmt.Sprintf("http://localhost:%d/orders/2/cancel", pact.Server.Port), "application/json", nil)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if location := res.Header.Get("Location"); location != "/orders/1" {
+			return fmt.Errorf("expected the example Location, got %q", location)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, pact.WritePact())
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "my_consumer-my_provider.json"))
+	assert.NoError(t, err)
+	var written struct {
+		Interactions []struct {
+			Request  struct{ Generators map[string]interface{} } `json:"request"`
+			Response struct{ Generators map[string]interface{} } `json:"response"`
+		} `json:"interactions"`
+	}
+	assert.NoError(t, json.Unmarshal(content, &written))
+	assert.Equal(t, map[string]interface{}{
+		"path": map[string]interface{}{"type": "ProviderState", "expression": "/orders/${id}/cancel"},
+	}, written.Interactions[0].Request.Generators)
+	assert.Equal(t, map[string]interface{}{
+		"header": map[string]interface{}{"Location": map[string]interface{}{"type": "ProviderState", "expression": "/orders/${id}"}},
+	}, written.Interactions[0].Response.Generators)
+}
+
+func TestDateTimeOffset(t *testing.T) {
+	matcher := DateOffset("+ 30 days", "2006-01-02").(v3Matcher)
+	assert.Equal(t, time.Now().AddDate(0, 0, 30).Format("2006-01-02"), matcher.GetValue())
+	assert.Equal(t, "Date", matcher.Generator)
+	assert.Equal(t, map[string]interface{}{"format": "yyyy-MM-dd", "expression": "+ 30 days"}, matcher.Attributes)
+
+	matcher = TimeOffset("noon", "HH:mm").(v3Matcher)
+	assert.Equal(t, "12:00", matcher.GetValue())
+
+	assert.Panics(t, func() { DateTimeOffset("next tuesday", time.RFC3339) })
+
+	expected := map[string]interface{}{"expiresAt": DateTimeOffset("+ 1 hour", time.RFC3339)}
+	rules, err := verifyRequestBody(t, 3, expected, `{"expiresAt": "2021-06-30T09:15:00Z"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "datetime", "format": "yyyy-MM-dd'T'HH:mm:ssXXX"}, bodyRule(rules, "$.expiresAt"))
+}
+
+func TestMatchV3(t *testing.T) {
+	type lineDTO struct {
+		SKU      string  `json:"sku" pact:"example=ABC-123,regex=^[A-Z]+-\\d+$"`
+		Quantity int     `json:"quantity" pact:"example=2"`
+		Price    float64 `json:"price"`
+	}
+	type orderDTO struct {
+		ID          int               `json:"id"`
+		Paid        bool              `json:"paid" pact:"example=false"`
+		Lines       []lineDTO         `json:"lines" pact:"min=2"`
+		PlacedAt    time.Time         `json:"placedAt"`
+		DueOn       time.Time         `json:"dueOn" pact:"format=2006-01-02"`
+		CancelledAt *time.Time        `json:"cancelledAt" pact:"format=2006-01-02"`
+		Notes       *string           `json:"notes"`
+		Labels      map[string]string `json:"labels"`
+		Ignored     string            `json:"-"`
+	}
+
+	line := StructMatcher{
+		"sku":      Term("ABC-123", `^[A-Z]+-\d+$`),
+		"quantity": v3Matcher{Type: "integer", Value: 2},
+		"price":    Decimal(),
+	}
+	want := StructMatcher{
+		"id":          Integer(),
+		"paid":        Boolean(false),
+		"lines":       EachLike(line, 2),
+		"placedAt":    DateTime(timeExample, time.RFC3339),
+		"dueOn":       DateTime(timeExample, "2006-01-02"),
+		"cancelledAt": Nullable(DateTime(timeExample, "2006-01-02")),
+		"notes":       Nullable(Like("string")),
+		"labels":      EachKeyLike("key", Like("string")),
+	}
+
+	assert.Equal(t, want, MatchV3(orderDTO{}))
+	assert.Equal(t, want, MatchV3(&orderDTO{}))
+
+	rules, err := verifyRequestBody(t, 3, MatchV3(lineDTO{}), `{"sku": "XYZ-9", "quantity": 5, "price": 1.5}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"match": "integer"}, bodyRule(rules, "$.quantity"))
+
+	_, err = verifyRequestBody(t, 3, MatchV3(lineDTO{}), `{"sku": "xyz", "quantity": 5.5, "price": 1.5}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "$.body.quantity: expected an integer, got 5.5")
+
+	assert.Panics(t, func() {
+		type invalidDTO struct {
+			At time.Time `json:"at" pact:"example=2000-01-01"`
+		}
+		MatchV3(invalidDTO{})
+	})
+	assert.Panics(t, func() {
+		MatchV3(map[int]string{})
+	})
+}
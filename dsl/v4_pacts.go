@@ -0,0 +1,345 @@
+package dsl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pact-foundation/pact-go/broker"
+	"github.com/pact-foundation/pact-go/types"
+)
+
+// Types of the interactions in a version 4 pact
+const (
+	v4HTTPInteraction    = "Synchronous/HTTP"
+	v4MessageInteraction = "Asynchronous/Messages"
+)
+
+// Categories of matching rules that a version 3 pact can hold
+var v3RuleCategories = map[string]bool{
+	"path":     true,
+	"query":    true,
+	"header":   true,
+	"body":     true,
+	"metadata": true,
+}
+
+// isV4Pact reports whether a pact is written in version 4 of the
+// specification
+func isV4Pact(pact map[string]interface{}) bool {
+	metadata, _ := pact["metadata"].(map[string]interface{})
+	specification, _ := metadata["pactSpecification"].(map[string]interface{})
+	version, _ := specification["version"].(string)
+	return strings.HasPrefix(version, "4")
+}
+
+// downgradePact converts a version 4 pact to a version 3 pact of its
+// interactions of the given type, HTTP interactions or messages, as the
+// verifier CLI only reads up to version 3. Other documents are returned
+// unchanged. Pending interactions keep their pending flag, so that their
+// failures can be told apart from the others once verified.
+func downgradePact(raw []byte, interactionType string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var pact map[string]interface{}
+	if err := decoder.Decode(&pact); err != nil || !isV4Pact(pact) {
+		return raw, nil
+	}
+
+	var converted []interface{}
+	interactions, _ := pact["interactions"].([]interface{})
+	for _, i := range interactions {
+		interaction, _ := i.(map[string]interface{})
+		description, _ := interaction["description"].(string)
+
+		if t, _ := interaction["type"].(string); t != interactionType {
+			if t != v4HTTPInteraction && t != v4MessageInteraction {
+				log.Printf("[WARN] skipping the %s interaction %q, which can't be verified", t, description)
+			}
+			continue
+		}
+		v3, err := downgradeInteraction(interaction)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert the interaction %q to version 3: %v", description, err)
+		}
+		if pending, _ := interaction["pending"].(bool); pending {
+			log.Printf("[INFO] verifying the pending interaction %q, whose failures won't fail the verification", description)
+			v3["pending"] = true
+		}
+		converted = append(converted, v3)
+	}
+	if converted == nil {
+		converted = []interface{}{}
+	}
+
+	delete(pact, "interactions")
+	if interactionType == v4MessageInteraction {
+		pact["messages"] = converted
+	} else {
+		pact["interactions"] = converted
+	}
+
+	metadata := pact["metadata"].(map[string]interface{})
+	metadata["pactSpecification"] = map[string]interface{}{"version": "3.0.0"}
+
+	return json.Marshal(pact)
+}
+
+// downgradeInteraction converts a version 4 HTTP interaction or message to
+// the version 3 format
+func downgradeInteraction(interaction map[string]interface{}) (map[string]interface{}, error) {
+	v3 := map[string]interface{}{}
+	for _, k := range []string{"description", "providerStates", "metadata", "generators"} {
+		if v, ok := interaction[k]; ok {
+			v3[k] = v
+		}
+	}
+
+	if interaction["type"] == v4MessageInteraction {
+		contents, err := downgradeBody(interaction["contents"])
+		if err != nil {
+			return nil, err
+		}
+		if contents != nil {
+			v3["contents"] = contents
+		}
+		if rules := downgradeRules(interaction["matchingRules"]); rules != nil {
+			v3["matchingRules"] = rules
+		}
+		return v3, nil
+	}
+
+	for _, part := range []string{"request", "response"} {
+		v4, _ := interaction[part].(map[string]interface{})
+		converted := map[string]interface{}{}
+		for k, v := range v4 {
+			switch k {
+			case "headers":
+				converted[k] = downgradeHeaders(v)
+			case "body":
+				body, err := downgradeBody(v)
+				if err != nil {
+					return nil, err
+				}
+				if body != nil {
+					converted[k] = body
+				}
+			case "matchingRules":
+				if rules := downgradeRules(v); rules != nil {
+					converted[k] = rules
+				}
+			default:
+				converted[k] = v
+			}
+		}
+		v3[part] = converted
+	}
+
+	return v3, nil
+}
+
+// downgradeHeaders joins the lists of header values of a version 4 pact
+func downgradeHeaders(headers interface{}) interface{} {
+	h, ok := headers.(map[string]interface{})
+	if !ok {
+		return headers
+	}
+
+	joined := make(map[string]interface{}, len(h))
+	for k, v := range h {
+		if values, ok := v.([]interface{}); ok {
+			list := make([]string, len(values))
+			for i, e := range values {
+				list[i] = fmt.Sprint(e)
+			}
+			joined[k] = strings.Join(list, ", ")
+			continue
+		}
+		joined[k] = v
+	}
+	return joined
+}
+
+// downgradeBody returns the content of a version 4 body, decoding it if it
+// was encoded
+func downgradeBody(body interface{}) (interface{}, error) {
+	b, ok := body.(map[string]interface{})
+	if !ok {
+		return body, nil
+	}
+
+	content := b["content"]
+	encoded, _ := b["encoded"].(string)
+	if flag, _ := b["encoded"].(bool); flag {
+		encoded = "base64"
+	}
+
+	switch strings.ToLower(encoded) {
+	case "":
+		return content, nil
+	case "base64":
+		s, _ := content.(string)
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode the body: %v", err)
+		}
+		contentType, _ := b["contentType"].(string)
+		if strings.Contains(contentType, "json") {
+			var v interface{}
+			if err := json.Unmarshal(decoded, &v); err == nil {
+				return v, nil
+			}
+		}
+		return string(decoded), nil
+	case "json":
+		s, _ := content.(string)
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, fmt.Errorf("unable to decode the body: %v", err)
+		}
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("unsupported body encoding %q", encoded)
+}
+
+// downgradeRules drops the categories of matching rules, such as status
+// code matchers, which can't be written to a version 3 pact
+func downgradeRules(rules interface{}) interface{} {
+	r, ok := rules.(map[string]interface{})
+	if !ok {
+		return rules
+	}
+
+	kept := map[string]interface{}{}
+	for category, v := range r {
+		if !v3RuleCategories[category] {
+			log.Printf("[WARN] ignoring the %s matching rules, which can't be verified", category)
+			continue
+		}
+		kept[category] = v
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
+
+// preparePactURLs checks the specification version of the pacts among the
+// PactURLs of a verification, and replaces version 4 pacts with version 3
+// pacts of their interactions of the given type. Pacts on the broker are
+// prepared by the broker relay instead. The prepared pacts are added to the
+// catalog, if given. The returned function removes the converted pacts.
+func preparePactURLs(request *types.VerifyRequest, interactionType string, catalog *interactionCatalog) (func(), error) {
+	var dir string
+	cleanup := func() {
+		if dir != "" {
+			os.RemoveAll(dir)
+		}
+	}
+
+	max, err := maxPactVersion(request.SpecificationVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	client := broker.NewClientFromVerifyRequest(*request)
+	base := strings.TrimSuffix(request.BrokerURL, "/")
+
+	pactURLs := make([]string, len(request.PactURLs))
+	for i, location := range request.PactURLs {
+		pactURLs[i] = location
+
+		u, err := url.Parse(location)
+		remote := err == nil && (u.Scheme == "http" || u.Scheme == "https")
+		if remote && base != "" && (location == base || strings.HasPrefix(location, base+"/")) {
+			continue
+		}
+
+		// Pacts that can't be read are left for the verifier to report
+		pact, err := loadPact(client, location)
+		if err != nil {
+			log.Printf("[DEBUG] unable to check the specification version of %s: %v", location, err)
+			continue
+		}
+
+		converted, err := preparePact(pact.Raw, max, interactionType)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("unable to verify the pact at %s: %v", location, err)
+		}
+		catalog.add(converted)
+		if bytes.Equal(converted, pact.Raw) {
+			continue
+		}
+
+		if dir == "" {
+			if dir, err = ioutil.TempDir("", "pact-go"); err != nil {
+				return nil, err
+			}
+		}
+		name := filepath.Base(location)
+		if remote {
+			name = path.Base(u.Path)
+		}
+		pactURLs[i] = filepath.Join(dir, fmt.Sprintf("%d-%s", i, name))
+		if err := ioutil.WriteFile(pactURLs[i], converted, 0644); err != nil {
+			cleanup()
+			return nil, err
+		}
+		log.Printf("[DEBUG] verifying the version 4 pact at %s as %s", location, pactURLs[i])
+	}
+
+	request.PactURLs = pactURLs
+	return cleanup, nil
+}
+
+// prepareRelayedPacts checks and converts the pacts fetched through the
+// broker relay, leaving other responses unchanged
+func prepareRelayedPacts(specificationVersion int, interactionType string) func([]byte) ([]byte, error) {
+	return func(body []byte) ([]byte, error) {
+		max, err := maxPactVersion(specificationVersion)
+		if err != nil {
+			return nil, err
+		}
+		return preparePact(body, max, interactionType)
+	}
+}
+
+// maxPactVersion returns the latest version of the specification a
+// verification accepts
+func maxPactVersion(specificationVersion int) (int, error) {
+	if specificationVersion == 0 {
+		return maxSpecificationVersion, nil
+	}
+	return specificationVersion, checkSpecificationVersion(specificationVersion)
+}
+
+// preparePact checks the specification version of a pact, and converts it
+// for the verifier if it's a version 4 pact. Documents that aren't pacts,
+// such as other broker resources, are returned unchanged.
+func preparePact(raw []byte, max int, interactionType string) ([]byte, error) {
+	var pact map[string]interface{}
+	if err := json.Unmarshal(raw, &pact); err != nil {
+		return raw, nil
+	}
+	if _, ok := pact["interactions"]; !ok {
+		if _, ok := pact["messages"]; !ok {
+			return raw, nil
+		}
+	}
+
+	if err := checkPactVersion(pact, max); err != nil {
+		return nil, err
+	}
+	return downgradePact(raw, interactionType)
+}
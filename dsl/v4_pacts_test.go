@@ -0,0 +1,239 @@
+package dsl
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pact-foundation/pact-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var v4Pact = `{
+	"consumer": {"name": "billy"},
+	"provider": {"name": "bobby"},
+	"interactions": [
+		{
+			"type": "Synchronous/HTTP",
+			"key": "a1b2c3d4e5f60718",
+			"description": "a request for a user",
+			"providerStates": [{"name": "user 1 exists", "params": {"id": 1}}],
+			"pending": false,
+			"comments": {"text": ["See the *users* API"]},
+			"request": {
+				"method": "GET",
+				"path": "/users/1",
+				"query": {"fields": ["name"]},
+				"headers": {"Accept": ["application/json", "text/plain"]}
+			},
+			"response": {
+				"status": 200,
+				"headers": {"Content-Type": ["application/json"]},
+				"body": {"content": {"name": "Billy"}, "contentType": "application/json", "encoded": false},
+				"matchingRules": {
+					"status": {"$": {"matchers": [{"match": "statusCode", "status": "success"}]}},
+					"body": {"$.name": {"matchers": [{"match": "type"}]}}
+				}
+			}
+		},
+		{
+			"type": "Synchronous/HTTP",
+			"key": "0807060504030201",
+			"description": "a request for an avatar",
+			"pending": false,
+			"request": {"method": "GET", "path": "/avatar"},
+			"response": {"status": 200, "body": {"content": "aGVsbG8=", "contentType": "text/plain", "encoded": "base64"}}
+		},
+		{
+			"type": "Synchronous/HTTP",
+			"key": "1122334455667788",
+			"description": "a request for a new feature",
+			"pending": true,
+			"request": {"method": "GET", "path": "/new"},
+			"response": {"status": 200}
+		},
+		{
+			"type": "Asynchronous/Messages",
+			"key": "8877665544332211",
+			"description": "a user created event",
+			"pending": false,
+			"contents": {"content": {"name": "Billy"}, "contentType": "application/json", "encoded": false},
+			"metadata": {"topic": "users"},
+			"matchingRules": {"body": {"$.name": {"matchers": [{"match": "type"}]}}}
+		},
+		{
+			"type": "Synchronous/Messages",
+			"key": "1111111111111111",
+			"description": "a user query",
+			"pending": false
+		}
+	],
+	"metadata": {"pactSpecification": {"version": "4.0"}, "pactRust": {"models": "1.0.0"}},
+	"_links": {"pb:publish-verification-results": {"href": "http://broker/verification-results"}}
+}`
+
+func decodePact(t *testing.T, content []byte) map[string]interface{} {
+	var pact map[string]interface{}
+	assert.NoError(t, json.Unmarshal(content, &pact))
+	return pact
+}
+
+func TestDowngradePact_HTTP(t *testing.T) {
+	converted, err := downgradePact([]byte(v4Pact), v4HTTPInteraction)
+	assert.NoError(t, err)
+
+	pact := decodePact(t, converted)
+	assert.Equal(t, map[string]interface{}{"version": "3.0.0"}, pact["metadata"].(map[string]interface{})["pactSpecification"])
+	assert.Contains(t, pact["metadata"], "pactRust")
+	assert.Contains(t, pact, "_links")
+	assert.NotContains(t, pact, "messages")
+
+	interactions := pact["interactions"].([]interface{})
+	assert.Len(t, interactions, 3)
+
+	user := interactions[0].(map[string]interface{})
+	assert.Equal(t, "a request for a user", user["description"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "user 1 exists", "params": map[string]interface{}{"id": 1.0}}}, user["providerStates"])
+	assert.NotContains(t, user, "type")
+	assert.NotContains(t, user, "key")
+	assert.NotContains(t, user, "comments")
+
+	request := user["request"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"fields": []interface{}{"name"}}, request["query"])
+	assert.Equal(t, map[string]interface{}{"Accept": "application/json, text/plain"}, request["headers"])
+
+	response := user["response"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"name": "Billy"}, response["body"])
+	assert.Equal(t, map[string]interface{}{"body": map[string]interface{}{"$.name": map[string]interface{}{"matchers": []interface{}{map[string]interface{}{"match": "type"}}}}}, response["matchingRules"])
+
+	assert.NotContains(t, user, "pending")
+
+	avatar := interactions[1].(map[string]interface{})
+	assert.Equal(t, "hello", avatar["response"].(map[string]interface{})["body"])
+
+	feature := interactions[2].(map[string]interface{})
+	assert.Equal(t, "a request for a new feature", feature["description"])
+	assert.Equal(t, true, feature["pending"], "expected pending interactions to be verified, flagged as pending")
+}
+
+func TestDowngradePact_Messages(t *testing.T) {
+	converted, err := downgradePact([]byte(v4Pact), v4MessageInteraction)
+	assert.NoError(t, err)
+
+	pact := decodePact(t, converted)
+	assert.NotContains(t, pact, "interactions")
+
+	messages := pact["messages"].([]interface{})
+	assert.Len(t, messages, 1)
+	assert.Equal(t, map[string]interface{}{
+		"description":   "a user created event",
+		"contents":      map[string]interface{}{"name": "Billy"},
+		"metadata":      map[string]interface{}{"topic": "users"},
+		"matchingRules": map[string]interface{}{"body": map[string]interface{}{"$.name": map[string]interface{}{"matchers": []interface{}{map[string]interface{}{"match": "type"}}}}},
+	}, messages[0])
+}
+
+func TestDowngradePact_NotV4(t *testing.T) {
+	for _, content := range []string{
+		`{"interactions": [], "metadata": {"pactSpecification": {"version": "3.0.0"}}}`,
+		`{"_links": {}}`,
+		`not json`,
+	} {
+		converted, err := downgradePact([]byte(content), v4HTTPInteraction)
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(converted))
+	}
+}
+
+func TestDowngradePact_UnsupportedEncoding(t *testing.T) {
+	_, err := downgradePact([]byte(`{
+		"interactions": [{"type": "Synchronous/HTTP", "description": "a request", "request": {"method": "GET", "path": "/"}, "response": {"status": 200, "body": {"content": "x", "encoded": "rot13"}}}],
+		"metadata": {"pactSpecification": {"version": "4.0"}}
+	}`), v4HTTPInteraction)
+	assert.EqualError(t, err, `unable to convert the interaction "a request" to version 3: unsupported body encoding "rot13"`)
+}
+
+func TestPreparePactURLs(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	v4 := filepath.Join(dir, "billy-bobby.json")
+	v3 := filepath.Join(dir, "billy-jimmy.json")
+	assert.NoError(t, ioutil.WriteFile(v4, []byte(v4Pact), 0644))
+	assert.NoError(t, ioutil.WriteFile(v3, []byte(`{"interactions": [], "metadata": {"pactSpecification": {"version": "3.0.0"}}}`), 0644))
+
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(v4Pact))
+	}))
+	defer remote.Close()
+
+	request := types.VerifyRequest{
+		PactURLs:  []string{v4, v3, filepath.Join(dir, "missing.json"), remote.URL + "/pacts/billy-bobby.json", "http://broker.local/pacts/latest"},
+		BrokerURL: "http://broker.local",
+	}
+	cleanup, err := preparePactURLs(&request, v4MessageInteraction, nil)
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, v4, request.PactURLs[0])
+	assert.Equal(t, v3, request.PactURLs[1])
+	assert.Equal(t, filepath.Join(dir, "missing.json"), request.PactURLs[2])
+	assert.NotEqual(t, remote.URL+"/pacts/billy-bobby.json", request.PactURLs[3])
+	assert.Equal(t, "http://broker.local/pacts/latest", request.PactURLs[4], "pacts on the broker are converted by the relay")
+
+	for _, converted := range []string{request.PactURLs[0], request.PactURLs[3]} {
+		content, err := ioutil.ReadFile(converted)
+		assert.NoError(t, err)
+		assert.Len(t, decodePact(t, content)["messages"], 1)
+	}
+
+	cleanup()
+	_, err = os.Stat(request.PactURLs[0])
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPreparePactURLs_Catalog(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pacts")
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "billy-bobby.json")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(v4Pact), 0644))
+
+	catalog := &interactionCatalog{}
+	request := types.VerifyRequest{PactURLs: []string{file}}
+	cleanup, err := preparePactURLs(&request, v4HTTPInteraction, catalog)
+	assert.NoError(t, err)
+	defer cleanup()
+
+	assert.Equal(t, "a request for a user", catalog.describe("billy", []string{"user 1 exists"}, "GET", "/users/1"))
+	assert.Equal(t, "a request for a new feature", catalog.describe("billy", nil, "GET", "/new"))
+}
+
+func TestStartBrokerRelay_PreparesPacts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(v4Pact))
+	}))
+	defer server.Close()
+
+	request := types.VerifyRequest{
+		BrokerURL:   server.URL,
+		BrokerToken: "static",
+	}
+
+	stop, err := startBrokerRelay(&request, prepareRelayedPacts(0, v4HTTPInteraction))
+	assert.NoError(t, err)
+	defer stop()
+	assert.NotEqual(t, server.URL, request.BrokerURL)
+
+	res, err := http.Get(request.BrokerURL + "/pacts/provider/bobby/consumer/billy/latest")
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	content, _ := ioutil.ReadAll(res.Body)
+
+	pact := decodePact(t, content)
+	assert.Equal(t, map[string]interface{}{"version": "3.0.0"}, pact["metadata"].(map[string]interface{})["pactSpecification"])
+	assert.Len(t, pact["interactions"], 3)
+}
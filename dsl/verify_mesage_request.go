@@ -1,7 +1,9 @@
 package dsl
 
 import (
+	"crypto/tls"
 	"fmt"
+	"net/http"
 
 	"github.com/pact-foundation/pact-go/types"
 )
@@ -32,15 +34,75 @@ type VerifyMessageRequest struct {
 	// BrokerToken is required when authenticating using the Bearer token mechanism
 	BrokerToken string
 
+	// BrokerTokenSource supplies bearer tokens for authenticating to a Pact Broker,
+	// and takes precedence over BrokerToken. Tokens are fetched for every broker
+	// request so they may be refreshed mid-run.
+	BrokerTokenSource types.TokenSource
+
+	// BrokerTLSConfig is the TLS configuration used when communicating with
+	// the Pact Broker, e.g. to present a client certificate to brokers
+	// deployed behind mutual TLS.
+	BrokerTLSConfig *tls.Config
+
+	// BrokerHTTPClient is used for all communication with the Pact Broker,
+	// allowing custom proxies, tracing or CA pools to be configured.
+	// When set, BrokerTLSConfig is ignored.
+	BrokerHTTPClient *http.Client
+
+	// BrokerProxyURL is an HTTP(S) proxy to use for requests to the broker
+	// and to remote PactURLs. The HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+	// environment variables are honoured when it isn't set.
+	BrokerProxyURL string
+
+	// BrokerRetry configures retries of broker requests that fail with a
+	// connection error or a retryable status code, such as 502 or 429.
+	BrokerRetry types.RetryPolicy
+
+	// BrokerCacheDir is a directory used to cache pacts fetched from the broker,
+	// keyed by URL and revalidated with their ETag on subsequent runs
+	BrokerCacheDir string
+
+	// BrokerOffline verifies against the pacts in BrokerCacheDir when the
+	// broker can't be reached
+	BrokerOffline bool
+
+	// SpecificationVersion is the latest version of the Pact Specification,
+	// 1 to 4, the pacts verified may be written in. Pacts written in a later
+	// version, or using matchers or generators the version they're written
+	// in doesn't support, are rejected before they're verified. Defaults to 4.
+	SpecificationVersion int
+
 	// PublishVerificationResults to the Pact Broker.
 	PublishVerificationResults bool
 
+	// PublishMode controls when verification results are published, e.g.
+	// PublishModeCI to only publish from CI servers. Defaults to
+	// PublishVerificationResults; detecting CI servers is deliberately opt-in,
+	// so that leaving both unset never publishes.
+	PublishMode types.PublishMode
+
 	// ProviderVersion is the semantical version of the Provider API.
+	// When empty, the SHA of the current git commit is used, suffixed with
+	// "-dirty" if there are uncommitted changes.
 	ProviderVersion string
 
+	// DisableGitProviderVersion turns off detection of the ProviderVersion from git
+	DisableGitProviderVersion bool
+
+	// BuildURL is the URL of the CI build that produced the verification,
+	// published with the verification results
+	BuildURL string
+
 	// ProviderTags is the set of tags to apply to the provider application version when results are published to the broker
 	ProviderTags []string
 
+	// ProviderBranch is the branch of the provider application version when results are published to the broker.
+	// Defaults to the branch detected from git or the CI environment.
+	ProviderBranch string
+
+	// DisableBranchDetection turns off detection of the ProviderBranch
+	DisableBranchDetection bool
+
 	// MessageHandlers contains a mapped list of message handlers for a provider
 	// that will be rable to produce the correct message format for a given
 	// consumer interaction
@@ -51,6 +113,15 @@ type VerifyMessageRequest struct {
 	// verification step.
 	StateHandlers StateHandlers
 
+	// FailOnMissingStateHandler fails the setup of any interaction with a
+	// provider state that has no state handler, rather than logging a warning
+	// and verifying the interaction against unprepared state.
+	FailOnMissingStateHandler bool
+
+	// DefaultStateHandler sets up any provider state without an entry in
+	// StateHandlers. It takes precedence over FailOnMissingStateHandler.
+	DefaultStateHandler StateHandler
+
 	// Arguments to the VerificationProvider
 	// Deprecated: This will be deleted after the native library replaces Ruby deps.
 	Args []string
@@ -0,0 +1,47 @@
+package dsl
+
+import (
+	"log"
+
+	"github.com/pact-foundation/pact-go/utils"
+)
+
+// gitVersion is used to detect the provider version, and may be replaced in tests
+var gitVersion = utils.GitVersion
+
+// detectBranch is used to detect the current branch, and may be replaced in tests
+var detectBranch = utils.DetectBranch
+
+// providerVersion returns the configured provider version, falling back to
+// the current git commit unless detection is disabled
+func providerVersion(version string, disableGit bool) string {
+	if version != "" || disableGit {
+		return version
+	}
+
+	detected, err := gitVersion("")
+	if err != nil {
+		log.Println("[DEBUG] unable to detect provider version from git:", err)
+		return ""
+	}
+
+	log.Println("[DEBUG] using provider version from git:", detected)
+	return detected
+}
+
+// detectedBranch returns the configured branch, falling back to the branch
+// detected from git or the CI environment unless detection is disabled
+func detectedBranch(branch string, disabled bool) string {
+	if branch != "" || disabled {
+		return branch
+	}
+
+	detected, err := detectBranch("")
+	if err != nil {
+		log.Println("[DEBUG] unable to detect branch:", err)
+		return ""
+	}
+
+	log.Println("[DEBUG] using detected branch:", detected)
+	return detected
+}
@@ -0,0 +1,58 @@
+package dsl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func stubDetectBranch(branch string, err error) func() {
+	old := detectBranch
+	detectBranch = func(string) (string, error) {
+		return branch, err
+	}
+
+	return func() {
+		detectBranch = old
+	}
+}
+
+func stubGitVersion(version string, err error) func() {
+	old := gitVersion
+	gitVersion = func(string) (string, error) {
+		return version, err
+	}
+
+	return func() {
+		gitVersion = old
+	}
+}
+
+func TestProviderVersion(t *testing.T) {
+	defer stubGitVersion("abc123-dirty", nil)()
+
+	assert.Equal(t, "1.0.0", providerVersion("1.0.0", false))
+	assert.Equal(t, "abc123-dirty", providerVersion("", false))
+	assert.Equal(t, "", providerVersion("", true))
+}
+
+func TestProviderVersion_NotARepository(t *testing.T) {
+	defer stubGitVersion("", errors.New("not a git repository"))()
+
+	assert.Equal(t, "", providerVersion("", false))
+}
+
+func TestDetectedBranch(t *testing.T) {
+	defer stubDetectBranch("feat/foo", nil)()
+
+	assert.Equal(t, "main", detectedBranch("main", false))
+	assert.Equal(t, "feat/foo", detectedBranch("", false))
+	assert.Equal(t, "", detectedBranch("", true))
+}
+
+func TestDetectedBranch_Undetectable(t *testing.T) {
+	defer stubDetectBranch("", errors.New("git HEAD is detached"))()
+
+	assert.Equal(t, "", detectedBranch("", false))
+}
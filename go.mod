@@ -15,6 +15,7 @@ require (
 	github.com/spf13/cobra v0.0.0-20160604044732-f447048345b6
 	github.com/spf13/pflag v0.0.0-20160427162146-cb88ea77998c // indirect
 	github.com/stretchr/testify v1.4.0
+	golang.org/x/net v0.0.0-20200707034311-ab3426394381
 	golang.org/x/sys v0.0.0-20200625212154-ddb9806d33ae // indirect
 	google.golang.org/protobuf v1.25.0 // indirect
 	gopkg.in/yaml.v2 v2.3.0 // indirect
@@ -0,0 +1,109 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// Canonicalize returns a pact in its canonical form, so that writing the same
+// pact always produces the same file. Interactions (and messages) are sorted
+// by description, then provider state, and object keys, such as headers and
+// matching rules, are sorted. Arrays are otherwise left in order, as their
+// order is significant.
+func Canonicalize(content []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var pact map[string]interface{}
+	if err := decoder.Decode(&pact); err != nil {
+		return nil, fmt.Errorf("unable to read the pact: %v", err)
+	}
+
+	for _, key := range []string{"interactions", "messages"} {
+		if interactions, ok := pact[key].([]interface{}); ok {
+			sortInteractions(interactions)
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(pact); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// CanonicalizePactFile rewrites a pact file in its canonical form, e.g. one
+// written by an earlier version of Pact Go or by another tool
+func CanonicalizePactFile(path string) error {
+	pactFiles.Lock()
+	defer pactFiles.Unlock()
+
+	unlock, err := lockPactFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	canonical, err := Canonicalize(content)
+	if err != nil {
+		return fmt.Errorf("unable to canonicalize %s: %v", path, err)
+	}
+	if bytes.Equal(content, canonical) {
+		return nil
+	}
+
+	return writeFileAtomic(path, canonical)
+}
+
+// sortInteractions sorts interactions by description, then provider state,
+// falling back to their content so that the order is total
+func sortInteractions(interactions []interface{}) {
+	keys := make([]string, len(interactions))
+	for i, interaction := range interactions {
+		content, _ := json.Marshal(interaction)
+		keys[i] = interactionSortKey(interaction) + "\x00" + string(content)
+	}
+
+	sort.Sort(byKey{interactions, keys})
+}
+
+func interactionSortKey(interaction interface{}) string {
+	i, _ := interaction.(map[string]interface{})
+	description, _ := i["description"].(string)
+
+	state, _ := i["providerState"].(string)
+	if states, ok := i["providerStates"].([]interface{}); ok {
+		for _, s := range states {
+			if s, ok := s.(map[string]interface{}); ok {
+				name, _ := s["name"].(string)
+				state += "\x00" + name
+			}
+		}
+	}
+
+	return description + "\x00" + state
+}
+
+type byKey struct {
+	values []interface{}
+	keys   []string
+}
+
+func (s byKey) Len() int           { return len(s.values) }
+func (s byKey) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s byKey) Swap(i, j int) {
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
@@ -0,0 +1,119 @@
+package mockserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalize(t *testing.T) {
+	content := []byte(`{
+		"provider": {"name": "Provider"},
+		"consumer": {"name": "Consumer"},
+		"interactions": [
+			{"description": "b request", "request": {"method": "GET", "path": "/b"}},
+			{"description": "a request", "providerState": "user 2 exists", "request": {"path": "/a/2", "method": "GET"}},
+			{"description": "a request", "providerState": "user 1 exists", "request": {"method": "GET", "path": "/a/1", "headers": {"X-B": "b", "X-A": "a"}}}
+		],
+		"metadata": {"pactSpecification": {"version": "2.0.0"}}
+	}`)
+
+	canonical, err := Canonicalize(content)
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "consumer": {
+    "name": "Consumer"
+  },
+  "interactions": [
+    {
+      "description": "a request",
+      "providerState": "user 1 exists",
+      "request": {
+        "headers": {
+          "X-A": "a",
+          "X-B": "b"
+        },
+        "method": "GET",
+        "path": "/a/1"
+      }
+    },
+    {
+      "description": "a request",
+      "providerState": "user 2 exists",
+      "request": {
+        "method": "GET",
+        "path": "/a/2"
+      }
+    },
+    {
+      "description": "b request",
+      "request": {
+        "method": "GET",
+        "path": "/b"
+      }
+    }
+  ],
+  "metadata": {
+    "pactSpecification": {
+      "version": "2.0.0"
+    }
+  },
+  "provider": {
+    "name": "Provider"
+  }
+}
+`, string(canonical))
+
+	again, err := Canonicalize(canonical)
+	assert.NoError(t, err)
+	assert.Equal(t, string(canonical), string(again))
+}
+
+func TestCanonicalize_PreservesValues(t *testing.T) {
+	canonical, err := Canonicalize([]byte(`{"interactions": [{"description": "a", "response": {"body": {"id": 12345678901234567890, "html": "<b>&</b>", "tags": ["z", "a"]}}}]}`))
+	assert.NoError(t, err)
+	assert.Contains(t, string(canonical), `"id": 12345678901234567890`)
+	assert.Contains(t, string(canonical), `"html": "<b>&</b>"`)
+	assert.Contains(t, string(canonical), `"z",`)
+}
+
+func TestCanonicalize_Invalid(t *testing.T) {
+	_, err := Canonicalize([]byte(`[`))
+	assert.Error(t, err)
+}
+
+func TestCanonicalizePactFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "consumer-provider.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"interactions": [{"description": "b"}, {"description": "a"}]}`), 0644))
+	assert.NoError(t, CanonicalizePactFile(path))
+
+	pact := readPact(t, path)
+	interactions := pact["interactions"].([]interface{})
+	assert.Equal(t, "a", interactions[0].(map[string]interface{})["description"])
+	assert.Equal(t, "b", interactions[1].(map[string]interface{})["description"])
+}
+
+func TestWritePact_Canonical(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	a := testInteraction(t, "a")
+	a.Request.Headers = map[string]interface{}{"X-B": "b", "X-A": "a"}
+	b := testInteraction(t, "b")
+
+	first, err := writePact(dir, "Consumer", "Provider", 3, "merge", []*Interaction{b, a})
+	assert.NoError(t, err)
+	second, err := writePact(dir, "Consumer", "Provider", 3, "merge", []*Interaction{a, b})
+	assert.NoError(t, err)
+	assert.Equal(t, string(first), string(second))
+
+	canonical, err := Canonicalize(first)
+	assert.NoError(t, err)
+	assert.Equal(t, string(first), string(canonical))
+}
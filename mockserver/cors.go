@@ -0,0 +1,109 @@
+package mockserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS configures the responses of the mock server to cross-origin requests,
+// for consumers running in a browser. The zero value is permissive, allowing
+// any origin, method and header requested.
+type CORS struct {
+	// AllowedOrigins that may call the mock server, or "*" for any.
+	// Defaults to the origin of each request.
+	AllowedOrigins []string
+
+	// AllowedMethods in response to a preflight request.
+	// Defaults to the method requested.
+	AllowedMethods []string
+
+	// AllowedHeaders in response to a preflight request.
+	// Defaults to the headers requested.
+	AllowedHeaders []string
+
+	// ExposedHeaders of responses, readable by the consumer
+	ExposedHeaders []string
+
+	// AllowCredentials allows requests with cookies or authorization
+	AllowCredentials bool
+
+	// MaxAge preflight responses may be cached for
+	MaxAge time.Duration
+}
+
+// isPreflight reports whether a request is a CORS preflight request
+func isPreflight(r *http.Request) bool {
+	return r.Method == "OPTIONS" && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// allowOrigin sets the headers allowing the origin of a request, returning
+// false if the origin isn't allowed
+func (c *CORS) allowOrigin(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	allowed := origin
+	if len(c.AllowedOrigins) > 0 {
+		allowed = ""
+		for _, o := range c.AllowedOrigins {
+			if o == "*" || o == origin {
+				allowed = o
+				break
+			}
+		}
+		if allowed == "" {
+			return false
+		}
+	}
+	if allowed == "*" && c.AllowCredentials {
+		// Browsers reject credentials with a wildcard origin
+		allowed = origin
+	}
+
+	h := w.Header()
+	if h.Get("Access-Control-Allow-Origin") == "" {
+		h.Set("Access-Control-Allow-Origin", allowed)
+	}
+	if allowed != "*" {
+		h.Add("Vary", "Origin")
+	}
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if len(c.ExposedHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+	}
+
+	return true
+}
+
+// preflight responds to a CORS preflight request
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request) {
+	if c.allowOrigin(w, r) {
+		h := w.Header()
+
+		methods := r.Header.Get("Access-Control-Request-Method")
+		if len(c.AllowedMethods) > 0 {
+			methods = strings.Join(c.AllowedMethods, ", ")
+		}
+		h.Set("Access-Control-Allow-Methods", methods)
+
+		headers := r.Header.Get("Access-Control-Request-Headers")
+		if len(c.AllowedHeaders) > 0 {
+			headers = strings.Join(c.AllowedHeaders, ", ")
+		}
+		if headers != "" {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+
+		if c.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
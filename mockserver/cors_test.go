@@ -0,0 +1,99 @@
+package mockserver
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func preflight(t *testing.T, s *Server, origin string) *http.Response {
+	req, _ := http.NewRequest("OPTIONS", s.URL()+"/users", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type, Authorization")
+
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+	return res
+}
+
+func TestServer_CORSPermissive(t *testing.T) {
+	s, err := Start(Options{CORS: &CORS{}})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	res := preflight(t, s, "http://localhost:3000")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Equal(t, "http://localhost:3000", res.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "POST", res.Header.Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type, Authorization", res.Header.Get("Access-Control-Allow-Headers"))
+
+	assert.NoError(t, s.AddInteraction(&Interaction{
+		Description: "create a user",
+		Request:     Request{Method: "POST", Path: "/users"},
+		Response:    Response{Status: 201},
+	}))
+
+	req, _ := http.NewRequest("POST", s.URL()+"/users", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "http://localhost:3000", res.Header.Get("Access-Control-Allow-Origin"))
+
+	assert.NoError(t, s.Verify(), "expected the preflight request not to be unexpected")
+}
+
+func TestServer_CORSConfigured(t *testing.T) {
+	s, err := Start(Options{CORS: &CORS{
+		AllowedOrigins:   []string{"http://example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           time.Minute,
+	}})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	res := preflight(t, s, "http://example.com")
+	assert.Equal(t, "http://example.com", res.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", res.Header.Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type", res.Header.Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "true", res.Header.Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "60", res.Header.Get("Access-Control-Max-Age"))
+
+	res = preflight(t, s, "http://other.com")
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Empty(t, res.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestServer_CORSExpectedPreflight(t *testing.T) {
+	s, err := Start(Options{CORS: &CORS{}})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	assert.NoError(t, s.AddInteraction(&Interaction{
+		Description: "a preflight request",
+		Request:     Request{Method: "OPTIONS", Path: "/users"},
+		Response:    Response{Status: 200, Headers: map[string]interface{}{"Access-Control-Allow-Origin": "*"}},
+	}))
+
+	res := preflight(t, s, "http://localhost:3000")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "*", res.Header.Get("Access-Control-Allow-Origin"))
+	assert.NoError(t, s.Verify())
+}
+
+func TestServer_CORSDisabled(t *testing.T) {
+	s, err := Start(Options{})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	res := preflight(t, s, "http://localhost:3000")
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+	assert.Empty(t, res.Header.Get("Access-Control-Allow-Origin"))
+}
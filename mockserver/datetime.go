@@ -0,0 +1,328 @@
+package mockserver
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Default formats of the date and time matchers without one, as used by the
+// other Pact implementations
+var defaultDateTimeFormats = map[string]string{
+	"date":      "yyyy-MM-dd",
+	"time":      "HH:mm:ss",
+	"datetime":  "yyyy-MM-dd'T'HH:mm:ss",
+	"timestamp": "yyyy-MM-dd'T'HH:mm:ss",
+}
+
+// layoutChunks are the elements of Go time layouts and the Java
+// DateTimeFormatter patterns they correspond to, longest first where one is
+// the prefix of another
+var layoutChunks = [][2]string{
+	{"January", "MMMM"},
+	{"Jan", "MMM"},
+	{"Monday", "EEEE"},
+	{"Mon", "EEE"},
+	{"MST", "z"},
+	{"2006", "yyyy"},
+	{"002", "DDD"},
+	{"01", "MM"},
+	{"02", "dd"},
+	{"03", "hh"},
+	{"04", "mm"},
+	{"05", "ss"},
+	{"06", "yy"},
+	{"15", "HH"},
+	{"1", "M"},
+	{"2", "d"},
+	{"3", "h"},
+	{"4", "m"},
+	{"5", "s"},
+	{"PM", "a"},
+	{"Z07:00", "XXX"},
+	{"Z0700", "XX"},
+	{"Z07", "X"},
+	{"-07:00", "xxx"},
+	{"-0700", "xx"},
+	{"-07", "x"},
+}
+
+// DateTimeFormat returns the Java DateTimeFormatter pattern, as written to
+// pacts, of a Go time layout, e.g. "yyyy-MM-dd'T'HH:mm:ssXXX" for
+// time.RFC3339
+func DateTimeFormat(layout string) (string, error) {
+	var format, literal strings.Builder
+	flush := func() {
+		if literal.Len() == 0 {
+			return
+		}
+		text := strings.Replace(literal.String(), "'", "''", -1)
+		if strings.IndexFunc(text, unicode.IsLetter) >= 0 {
+			text = "'" + text + "'"
+		}
+		format.WriteString(text)
+		literal.Reset()
+	}
+
+	for rest := layout; rest != ""; {
+		if digits := fractionalSeconds(rest); digits > 0 {
+			if rest[1] == '9' {
+				return "", fmt.Errorf("the fractional seconds of %q are optional, which can't be written as a pact format", layout)
+			}
+			flush()
+			format.WriteString(rest[:1] + strings.Repeat("S", digits))
+			rest = rest[digits+1:]
+			continue
+		}
+
+		chunk := ""
+		for _, c := range layoutChunks {
+			if strings.HasPrefix(rest, c[0]) {
+				chunk = c[0]
+				flush()
+				format.WriteString(c[1])
+				break
+			}
+		}
+		if chunk == "" {
+			if strings.HasPrefix(rest, "_2") || strings.HasPrefix(rest, "pm") {
+				return "", fmt.Errorf("the %q element of %q can't be written as a pact format", rest[:2], layout)
+			}
+			literal.WriteByte(rest[0])
+			chunk = rest[:1]
+		}
+		rest = rest[len(chunk):]
+	}
+	flush()
+
+	return format.String(), nil
+}
+
+// fractionalSeconds returns the number of digits of the fractional seconds
+// at the start of a layout, e.g. 3 for ".000"
+func fractionalSeconds(layout string) int {
+	if len(layout) < 2 || (layout[0] != '.' && layout[0] != ',') || (layout[1] != '0' && layout[1] != '9') {
+		return 0
+	}
+	n := 1
+	for n < len(layout) && layout[n] == layout[1] {
+		n++
+	}
+	return n - 1
+}
+
+// TimeLayout returns the Go time layout of a Java DateTimeFormatter pattern,
+// as written to pacts, e.g. time.RFC3339 for "yyyy-MM-dd'T'HH:mm:ssXXX"
+func TimeLayout(format string) (string, error) {
+	var layout strings.Builder
+	for rest := format; rest != ""; {
+		letter := rest[0]
+		switch {
+		case strings.HasPrefix(rest, "''"):
+			layout.WriteByte('\'')
+			rest = rest[2:]
+			continue
+		case letter == '\'':
+			text, n, err := quotedText(rest)
+			if err != nil {
+				return "", fmt.Errorf("%v in %q", err, format)
+			}
+			layout.WriteString(text)
+			rest = rest[n:]
+			continue
+		case !('a' <= letter && letter <= 'z' || 'A' <= letter && letter <= 'Z'):
+			layout.WriteByte(letter)
+			rest = rest[1:]
+			continue
+		}
+
+		n := 1
+		for n < len(rest) && rest[n] == letter {
+			n++
+		}
+		element, err := layoutElement(letter, n)
+		if err != nil {
+			return "", fmt.Errorf("%v in %q", err, format)
+		}
+		layout.WriteString(element)
+		rest = rest[n:]
+	}
+
+	return layout.String(), nil
+}
+
+// quotedText returns the text quoted at the start of a Java pattern, where
+// a doubled quote is a quote, and the length of the pattern quoting it
+func quotedText(pattern string) (string, int, error) {
+	var text strings.Builder
+	for n := 1; n < len(pattern); n++ {
+		if pattern[n] != '\'' {
+			text.WriteByte(pattern[n])
+			continue
+		}
+		if n+1 < len(pattern) && pattern[n+1] == '\'' {
+			text.WriteByte('\'')
+			n++
+			continue
+		}
+		return text.String(), n + 1, nil
+	}
+	return "", 0, errors.New("unterminated quoted text")
+}
+
+// layoutElement returns the Go time layout element of n repetitions of a
+// Java DateTimeFormatter pattern letter
+func layoutElement(letter byte, n int) (string, error) {
+	pick := func(elements ...string) (string, error) {
+		if n > len(elements) || elements[n-1] == "" {
+			return "", fmt.Errorf("unsupported pattern %q", strings.Repeat(string(letter), n))
+		}
+		return elements[n-1], nil
+	}
+
+	switch letter {
+	case 'y', 'u':
+		return pick("2006", "06", "2006", "2006")
+	case 'M', 'L':
+		return pick("1", "01", "Jan", "January")
+	case 'd':
+		return pick("2", "02")
+	case 'D':
+		return pick("", "", "002")
+	case 'E':
+		return pick("Mon", "Mon", "Mon", "Monday")
+	case 'a':
+		return pick("PM")
+	case 'H':
+		return pick("15", "15")
+	case 'h':
+		return pick("3", "03")
+	case 'm':
+		return pick("4", "04")
+	case 's':
+		return pick("5", "05")
+	case 'S':
+		return strings.Repeat("0", n), nil
+	case 'X':
+		return pick("Z07", "Z0700", "Z07:00")
+	case 'x':
+		return pick("-07", "-0700", "-07:00")
+	case 'Z':
+		return pick("-0700", "-0700", "-0700", "", "Z07:00")
+	case 'z':
+		return pick("MST", "MST", "MST")
+	}
+	return pick()
+}
+
+// compareDateTime matches actual against a date, time or datetime matcher
+func (c *comparison) compareDateTime(path string, t string, m map[string]interface{}, actual interface{}) {
+	format, _ := m["format"].(string)
+	if format == "" {
+		format = defaultDateTimeFormats[t]
+	}
+
+	value, ok := actual.(string)
+	if !ok {
+		c.mismatch(path, reifyV3(m), actual, "expected a %s of format %s, got %s", t, format, jsonType(actual))
+		return
+	}
+	layout, err := TimeLayout(format)
+	if err != nil {
+		c.mismatch(path, reifyV3(m), actual, "invalid %s format: %v", t, err)
+		return
+	}
+	if _, err := time.Parse(layout, value); err != nil {
+		c.mismatch(path, reifyV3(m), actual, "expected %q to be a %s of format %s", value, t, format)
+	}
+}
+
+// dateExpressionUnits are the units of the offsets of date expressions
+var dateExpressionUnits = map[string]func(t time.Time, n int) time.Time{
+	"year":        func(t time.Time, n int) time.Time { return t.AddDate(n, 0, 0) },
+	"month":       func(t time.Time, n int) time.Time { return t.AddDate(0, n, 0) },
+	"week":        func(t time.Time, n int) time.Time { return t.AddDate(0, 0, 7*n) },
+	"day":         func(t time.Time, n int) time.Time { return t.AddDate(0, 0, n) },
+	"hour":        func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Hour) },
+	"minute":      func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Minute) },
+	"second":      func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Second) },
+	"millisecond": func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * time.Millisecond) },
+}
+
+// ParseDateExpression returns the time of a date expression, as written to
+// the Date, Time and DateTime generators of pacts, relative to now: a base of
+// now, today, yesterday, tomorrow, midnight or noon, followed by any offsets,
+// e.g. "tomorrow + 2 hours" or "- 1 week". An empty expression is now.
+func ParseDateExpression(expression string, now time.Time) (time.Time, error) {
+	fields := strings.Fields(strings.NewReplacer("+", " + ", "-", " - ").Replace(strings.ToLower(expression)))
+
+	t := now
+	if len(fields) > 0 && fields[0] != "+" && fields[0] != "-" {
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		switch fields[0] {
+		case "now", "today":
+		case "yesterday":
+			t = now.AddDate(0, 0, -1)
+		case "tomorrow":
+			t = now.AddDate(0, 0, 1)
+		case "midnight":
+			t = midnight
+		case "noon":
+			t = midnight.Add(12 * time.Hour)
+		default:
+			return now, fmt.Errorf("unknown base %q of the date expression %q", fields[0], expression)
+		}
+		fields = fields[1:]
+	}
+
+	for len(fields) > 0 {
+		if len(fields) < 3 || (fields[0] != "+" && fields[0] != "-") {
+			return now, fmt.Errorf("expected offsets such as \"+ 1 day\" in the date expression %q", expression)
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return now, fmt.Errorf("invalid offset %q in the date expression %q", fields[1], expression)
+		}
+		if fields[0] == "-" {
+			n = -n
+		}
+		add, ok := dateExpressionUnits[strings.TrimSuffix(fields[2], "s")]
+		if !ok {
+			return now, fmt.Errorf("unknown unit %q in the date expression %q", fields[2], expression)
+		}
+		t = add(t, n)
+		fields = fields[3:]
+	}
+
+	return t, nil
+}
+
+// dateTimeGenerators are the matchers of the Date, Time and DateTime
+// generators, whose default formats they share
+var dateTimeGenerators = map[string]string{
+	"Date":     "date",
+	"Time":     "time",
+	"DateTime": "datetime",
+}
+
+// generateDateTime generates the time of the expression of a Date, Time or
+// DateTime generator, in its format
+func generateDateTime(m map[string]interface{}) (interface{}, error) {
+	format, _ := m["format"].(string)
+	if format == "" {
+		format = defaultDateTimeFormats[dateTimeGenerators[m[generatorTypeKey].(string)]]
+	}
+	layout, err := TimeLayout(format)
+	if err != nil {
+		return nil, err
+	}
+	expression, _ := m["expression"].(string)
+	t, err := ParseDateExpression(expression, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return t.Format(layout), nil
+}
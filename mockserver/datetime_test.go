@@ -0,0 +1,147 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDateTimeFormat(t *testing.T) {
+	tests := map[string]string{
+		time.RFC3339:                   "yyyy-MM-dd'T'HH:mm:ssXXX",
+		"2006-01-02":                   "yyyy-MM-dd",
+		"15:04:05.000":                 "HH:mm:ss.SSS",
+		time.RFC1123Z:                  "EEE, dd MMM yyyy HH:mm:ss xx",
+		"Monday, January 2 3:04PM MST": "EEEE, MMMM d h:mma z",
+		"2006-01-02T15:04:05Z":         "yyyy-MM-dd'T'HH:mm:ss'Z'",
+		"06/002 o'clock":               "yy/DDD' o''clock'",
+	}
+	for layout, format := range tests {
+		converted, err := DateTimeFormat(layout)
+		assert.NoError(t, err, layout)
+		assert.Equal(t, format, converted, layout)
+	}
+
+	_, err := DateTimeFormat(time.RFC3339Nano)
+	assert.Error(t, err)
+	_, err = DateTimeFormat(time.ANSIC)
+	assert.EqualError(t, err, `the "_2" element of "Mon Jan _2 15:04:05 2006" can't be written as a pact format`)
+}
+
+func TestTimeLayout(t *testing.T) {
+	tests := map[string]string{
+		"yyyy-MM-dd'T'HH:mm:ssXXX":     time.RFC3339,
+		"yyyy-MM-dd":                   "2006-01-02",
+		"HH:mm:ss.SSS":                 "15:04:05.000",
+		"EEE, dd MMM yyyy HH:mm:ss Z":  time.RFC1123Z,
+		"EEEE, MMMM d h:mma z":         "Monday, January 2 3:04PM MST",
+		"yyyy-MM-dd'T'HH:mm:ss'Z'":     "2006-01-02T15:04:05Z",
+		"uuuu-MM-dd'T'HH:mm:ss.SSSxxx": "2006-01-02T15:04:05.000-07:00",
+		"'o''clock' h":                 "o'clock 3",
+	}
+	for format, layout := range tests {
+		converted, err := TimeLayout(format)
+		assert.NoError(t, err, format)
+		assert.Equal(t, layout, converted, format)
+	}
+
+	_, err := TimeLayout("yyyy-MM-dd G")
+	assert.EqualError(t, err, `unsupported pattern "G" in "yyyy-MM-dd G"`)
+	_, err = TimeLayout("yyyy-MM-dd'T")
+	assert.EqualError(t, err, `unterminated quoted text in "yyyy-MM-dd'T"`)
+}
+
+func TestComparison_CompareDateTime(t *testing.T) {
+	datetime := decode(t, `{"pact:matcher:type": "datetime", "format": "yyyy-MM-dd'T'HH:mm:ssXXX", "value": "2020-01-01T12:00:00Z"}`)
+	date := decode(t, `{"pact:matcher:type": "date", "value": "2020-01-01"}`)
+
+	tests := []struct {
+		name     string
+		expected interface{}
+		actual   interface{}
+		reason   string
+	}{
+		{"datetime", datetime, "2021-06-30T09:15:00+02:00", ""},
+		{"not in the format", datetime, "2021-06-30 09:15:00", `expected "2021-06-30 09:15:00" to be a datetime of format yyyy-MM-dd'T'HH:mm:ssXXX`},
+		{"not a string", datetime, 1.0, "expected a datetime of format yyyy-MM-dd'T'HH:mm:ssXXX, got a number"},
+		{"default format", date, "2021-06-30", ""},
+		{"invalid date", date, "2021-02-30", `expected "2021-02-30" to be a date of format yyyy-MM-dd`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &comparison{}
+			c.compare("$", tt.expected, tt.actual, false)
+
+			var reason string
+			if len(c.mismatches) > 0 {
+				reason = c.mismatches[0].Reason
+			}
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+}
+
+func TestRules_ExtractDateTime(t *testing.T) {
+	r := newRules()
+	r.extract("body", "$", decode(t, `{
+		"createdAt": {"pact:matcher:type": "datetime", "format": "yyyy-MM-dd'T'HH:mm:ssXXX", "value": "2020-01-01T12:00:00Z", "pact:generator:type": "DateTime"},
+		"dueOn": {"pact:matcher:type": "date", "value": "2020-01-01"}
+	}`))
+
+	formatted, _ := json.Marshal(r.format(3))
+	assert.JSONEq(t, `{"body": {
+		"$.createdAt": {"matchers": [{"match": "datetime", "format": "yyyy-MM-dd'T'HH:mm:ssXXX"}]},
+		"$.dueOn": {"matchers": [{"match": "date"}]}
+	}}`, string(formatted))
+
+	generators, _ := json.Marshal(r.formatGenerators(3))
+	assert.JSONEq(t, `{"body": {"$.createdAt": {"type": "DateTime", "format": "yyyy-MM-dd'T'HH:mm:ssXXX"}}}`, string(generators))
+	assert.Nil(t, r.formatGenerators(2))
+}
+
+func TestParseDateExpression(t *testing.T) {
+	now := time.Date(2020, 1, 31, 9, 30, 0, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"":                         now,
+		"now":                      now,
+		"+ 1 day":                  time.Date(2020, 2, 1, 9, 30, 0, 0, time.UTC),
+		"today - 2 weeks":          time.Date(2020, 1, 17, 9, 30, 0, 0, time.UTC),
+		"tomorrow+3 hours":         time.Date(2020, 2, 1, 12, 30, 0, 0, time.UTC),
+		"yesterday":                time.Date(2020, 1, 30, 9, 30, 0, 0, time.UTC),
+		"midnight":                 time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC),
+		"Noon + 1 hour - 1 minute": time.Date(2020, 1, 31, 12, 59, 0, 0, time.UTC),
+		"now + 1 year + 1 month":   time.Date(2021, 3, 3, 9, 30, 0, 0, time.UTC),
+	}
+	for expression, expected := range tests {
+		parsed, err := ParseDateExpression(expression, now)
+		assert.NoError(t, err, expression)
+		assert.Equal(t, expected, parsed, expression)
+	}
+
+	for expression, err := range map[string]string{
+		"later":       `unknown base "later" of the date expression "later"`,
+		"+ 1":         `expected offsets such as "+ 1 day" in the date expression "+ 1"`,
+		"+ one day":   `invalid offset "one" in the date expression "+ one day"`,
+		"+ 1 century": `unknown unit "century" in the date expression "+ 1 century"`,
+	} {
+		_, parseErr := ParseDateExpression(expression, now)
+		assert.EqualError(t, parseErr, err)
+	}
+}
+
+func TestGenerateDateTime(t *testing.T) {
+	generated, err := generateDateTime(decode(t, `{"pact:matcher:type": "date", "format": "yyyy-MM-dd", "pact:generator:type": "Date", "expression": "+ 1 day"}`).(map[string]interface{}))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Now().AddDate(0, 0, 1).Format("2006-01-02"), generated)
+
+	generated, err = generateDateTime(decode(t, `{"pact:matcher:type": "time", "pact:generator:type": "Time", "expression": "noon"}`).(map[string]interface{}))
+	assert.NoError(t, err)
+	assert.Equal(t, "12:00:00", generated)
+
+	_, err = generateDateTime(decode(t, `{"pact:matcher:type": "datetime", "pact:generator:type": "DateTime", "expression": "later"}`).(map[string]interface{}))
+	assert.Error(t, err)
+}
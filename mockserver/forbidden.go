@@ -0,0 +1,57 @@
+package mockserver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ForbiddenRequest is a request which must not be received during the test,
+// e.g. one that a client's cache or feature flags should suppress. Only the
+// parts of the request given are matched: a request without a method or path
+// forbids any method or path, and one without a query or body forbids any
+// query or body. Forbidden requests aren't written to the pact.
+type ForbiddenRequest struct {
+	// Description of the request, e.g. "deleting a user"
+	Description string `json:"description,omitempty"`
+
+	// Request which must not be received
+	Request Request `json:"request"`
+}
+
+// ForbiddenCall is a forbidden request which was received
+type ForbiddenCall struct {
+	// Request received
+	Request *ReceivedRequest `json:"request"`
+
+	// Forbidden request it matched
+	Forbidden *ForbiddenRequest `json:"forbidden"`
+}
+
+// matches reports whether a received request is forbidden
+func (f *ForbiddenRequest) matches(request *ReceivedRequest) bool {
+	expected := f.Request
+	actual := *request
+	if expected.Method == "" {
+		expected.Method = actual.Method
+	}
+	if expected.Path == nil {
+		expected.Path = actual.Path
+	}
+	if expected.Query == nil {
+		actual.Query = nil
+	}
+
+	return len(match(expected, &actual)) == 0
+}
+
+// String describes the forbidden request, e.g. "DELETE /users/1"
+func (f *ForbiddenRequest) String() string {
+	method := strings.ToUpper(f.Request.Method)
+	if method == "" {
+		method = "*"
+	}
+	if f.Request.Path == nil {
+		return fmt.Sprintf("%s *", method)
+	}
+	return fmt.Sprintf("%s %v", method, reify(f.Request.Path))
+}
@@ -0,0 +1,199 @@
+package mockserver
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// generatorTypeKey marks a version 3 matcher with a generator, which
+// generates the value in place of the example, e.g.
+// {"pact:matcher:type": "datetime", "pact:generator:type": "DateTime", ...}
+const generatorTypeKey = "pact:generator:type"
+
+// generatorAttributes are the attributes of a matcher which configure its
+// generator
+var generatorAttributes = []string{"format", "regex", "expression"}
+
+// extractGenerator records the generator of a version 3 matcher at path, if
+// it has one
+func (r *rules) extractGenerator(category string, path string, m map[string]interface{}) {
+	t, ok := m[generatorTypeKey].(string)
+	if !ok {
+		return
+	}
+
+	generator := map[string]interface{}{"type": t}
+	for _, k := range generatorAttributes {
+		if v, ok := m[k]; ok {
+			generator[k] = v
+		}
+	}
+	if r.generators[category] == nil {
+		r.generators[category] = map[string]map[string]interface{}{}
+	}
+	r.generators[category][path] = generator
+}
+
+// formatGenerators returns the generators in the layout of version 3 of the
+// specification, grouped by category. Earlier versions have no generators.
+func (r *rules) formatGenerators(specificationVersion int) interface{} {
+	if len(r.generators) == 0 || specificationVersion < 3 {
+		return nil
+	}
+
+	formatted := map[string]interface{}{}
+	for category, paths := range r.generators {
+		if category == "path" {
+			formatted[category] = paths[""]
+			continue
+		}
+		entries := map[string]interface{}{}
+		for path, generator := range paths {
+			entries[path] = generator
+		}
+		formatted[category] = entries
+	}
+	return formatted
+}
+
+// responseGenerators are the generators the mock server runs in place of
+// the examples of its responses, keyed by type
+var responseGenerators = map[string]func(m map[string]interface{}) (interface{}, error){
+	"Regex":    generateRegex,
+	"Date":     generateDateTime,
+	"Time":     generateDateTime,
+	"DateTime": generateDateTime,
+}
+
+// generate replaces the matchers in a value whose generator the mock server
+// runs with generated values, so that the examples of a pact aren't relied on
+// by the systems the mock server stands in for the provider of. Values which
+// can't be generated are left with their examples.
+func generate(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if t, ok := value[generatorTypeKey].(string); ok && responseGenerators[t] != nil {
+			generated, err := responseGenerators[t](value)
+			if err == nil {
+				return generated
+			}
+			log.Printf("[WARN] mock server: unable to run the %s generator, using the example: %v", t, err)
+		}
+		generated := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			generated[k] = generate(e)
+		}
+		return generated
+	case []interface{}:
+		generated := make([]interface{}, len(value))
+		for i, e := range value {
+			generated[i] = generate(e)
+		}
+		return generated
+	}
+	return v
+}
+
+// maxRepeat is the most repetitions of an unbounded part of a regular
+// expression in generated strings
+const maxRepeat = 10
+
+// generateRegex generates a random string matching the regex of a matcher
+func generateRegex(m map[string]interface{}) (interface{}, error) {
+	regex, _ := m["regex"].(string)
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := syntax.Parse(regex, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	parsed = parsed.Simplify()
+
+	// Anchors and word boundaries aren't generated, so retry until they hold
+	for attempt := 0; attempt < 10; attempt++ {
+		var generated strings.Builder
+		if err := generateSyntax(&generated, parsed); err != nil {
+			return nil, err
+		}
+		if re.MatchString(generated.String()) {
+			return generated.String(), nil
+		}
+	}
+	return nil, fmt.Errorf("no string matching /%s/ was generated", regex)
+}
+
+// generateSyntax writes a random string matching a parsed regular expression
+func generateSyntax(b *strings.Builder, re *syntax.Regexp) error {
+	repeat := func(min int, max int) error {
+		if max < 0 {
+			max = min + maxRepeat
+		}
+		for n := min + rand.Intn(max-min+1); n > 0; n-- {
+			if err := generateSyntax(b, re.Sub[0]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return errors.New("the regular expression matches nothing")
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return errors.New("the regular expression has an empty character class")
+		}
+		i := rand.Intn(len(re.Rune)/2) * 2
+		lo, hi := re.Rune[i], re.Rune[i+1]
+
+		// Prefer printable ASCII, e.g. for negated classes
+		if lo <= '~' && hi >= ' ' {
+			lo, hi = maxRune(lo, ' '), minRune(hi, '~')
+		}
+		b.WriteRune(lo + rune(rand.Intn(int(hi-lo)+1)))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteRune(rune('a' + rand.Intn(26)))
+	case syntax.OpCapture:
+		return generateSyntax(b, re.Sub[0])
+	case syntax.OpStar:
+		return repeat(0, -1)
+	case syntax.OpPlus:
+		return repeat(1, -1)
+	case syntax.OpQuest:
+		return repeat(0, 1)
+	case syntax.OpRepeat:
+		return repeat(re.Min, re.Max)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if err := generateSyntax(b, sub); err != nil {
+				return err
+			}
+		}
+	case syntax.OpAlternate:
+		return generateSyntax(b, re.Sub[rand.Intn(len(re.Sub))])
+	}
+	return nil
+}
+
+func minRune(a rune, b rune) rune {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxRune(a rune, b rune) rune {
+	if a > b {
+		return a
+	}
+	return b
+}
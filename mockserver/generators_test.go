@@ -0,0 +1,130 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePact_Generators(t *testing.T) {
+	createdAt := decode(t, `{"pact:matcher:type": "datetime", "format": "yyyy-MM-dd", "value": "2020-01-01", "pact:generator:type": "Date"}`)
+	interaction := &Interaction{
+		Description: "create an order",
+		Request:     Request{Method: "POST", Path: "/orders", Body: map[string]interface{}{"placedOn": createdAt}},
+		Response:    Response{Status: 201, Headers: map[string]interface{}{"Date": createdAt}},
+	}
+
+	for _, version := range []int{2, 3, 4} {
+		dir, _ := ioutil.TempDir("", "mockserver")
+		defer os.RemoveAll(dir)
+
+		_, err := writePact(dir, "Consumer", "Provider", version, "overwrite", []*Interaction{interaction})
+		assert.NoError(t, err)
+
+		pact := readPact(t, filepath.Join(dir, "consumer-provider.json"))
+		written := pact["interactions"].([]interface{})[0].(map[string]interface{})
+		request := written["request"].(map[string]interface{})
+		response := written["response"].(map[string]interface{})
+
+		generator := map[string]interface{}{"type": "Date", "format": "yyyy-MM-dd"}
+		if version < 3 {
+			assert.Nil(t, request["generators"])
+			continue
+		}
+		assert.Equal(t, map[string]interface{}{"body": map[string]interface{}{"$.placedOn": generator}}, request["generators"], "version %d", version)
+		assert.Equal(t, map[string]interface{}{"header": map[string]interface{}{"Date": generator}}, response["generators"], "version %d", version)
+	}
+}
+
+func TestGenerateRegex(t *testing.T) {
+	for _, regex := range []string{
+		`^\d{4}-\d{2}-\d{2}$`,
+		`^[A-Z]{2}[0-9]+$`,
+		`(GET|POST) /orders/\w+`,
+		`^[^,]+,[^,]*$`,
+		`^ab?c*\.$`,
+		`\bend\b`,
+	} {
+		for n := 0; n < 20; n++ {
+			generated, err := generateRegex(map[string]interface{}{"regex": regex})
+			assert.NoError(t, err, regex)
+			assert.Regexp(t, regexp.MustCompile(regex), generated)
+		}
+	}
+
+	_, err := generateRegex(map[string]interface{}{"regex": `(?=a)`})
+	assert.Error(t, err)
+	_, err = generateRegex(map[string]interface{}{"regex": `^a$b`})
+	assert.EqualError(t, err, "no string matching /^a$b/ was generated")
+}
+
+func TestGenerate(t *testing.T) {
+	generated := generate(decode(t, `{
+		"id": {"pact:matcher:type": "regex", "regex": "^ORD-\\d{6}$", "value": "ORD-000001", "pact:generator:type": "Regex"},
+		"name": {"json_class": "Pact::SomethingLike", "contents": {
+			"code": {"pact:matcher:type": "regex", "regex": "^[A-Z]{3}$", "value": "ABC", "pact:generator:type": "Regex"}
+		}},
+		"invalid": {"pact:matcher:type": "regex", "regex": "(?=a)", "value": "a", "pact:generator:type": "Regex"},
+		"unsupported": {"pact:matcher:type": "type", "value": 1, "pact:generator:type": "Unknown"}
+	}`))
+
+	body := reify(generated).(map[string]interface{})
+	assert.Regexp(t, `^ORD-\d{6}$`, body["id"])
+	assert.Regexp(t, `^[A-Z]{3}$`, body["name"].(map[string]interface{})["code"])
+	assert.Equal(t, "a", body["invalid"])
+	assert.Equal(t, 1.0, body["unsupported"])
+}
+
+func TestServer_ResponseGenerators(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	id := decode(t, `{"pact:matcher:type": "regex", "regex": "^[0-9a-f]{8}$", "value": "00000000", "pact:generator:type": "Regex"}`)
+	assert.NoError(t, s.AddInteraction(&Interaction{
+		Description: "create an order",
+		Request:     Request{Method: "POST", Path: "/orders"},
+		Response: Response{
+			Status:  201,
+			Headers: map[string]interface{}{"X-Order-Id": id},
+			Body:    map[string]interface{}{"id": id},
+		},
+	}))
+
+	res, err := http.Post(s.URL()+"/orders", "application/json", nil)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+
+	var body map[string]interface{}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Regexp(t, `^[0-9a-f]{8}$`, body["id"])
+	assert.Regexp(t, `^[0-9a-f]{8}$`, res.Header.Get("X-Order-Id"))
+}
+
+func TestRules_ExtractProviderStateGenerator(t *testing.T) {
+	r := newRules()
+	r.extract("path", "", decode(t, `{"pact:matcher:type": "type", "value": "/orders/1", "pact:generator:type": "ProviderState", "expression": "/orders/${id}"}`))
+	r.extract("body", "$", decode(t, `{"id": {"pact:matcher:type": "type", "value": 1, "pact:generator:type": "ProviderState", "expression": "${id}"}}`))
+
+	formatted, _ := json.Marshal(r.format(3))
+	assert.JSONEq(t, `{
+		"path": {"matchers": [{"match": "type"}]},
+		"body": {"$.id": {"matchers": [{"match": "type"}]}}
+	}`, string(formatted))
+
+	generators, _ := json.Marshal(r.formatGenerators(3))
+	assert.JSONEq(t, `{
+		"path": {"type": "ProviderState", "expression": "/orders/${id}"},
+		"body": {"$.id": {"type": "ProviderState", "expression": "${id}"}}
+	}`, string(generators))
+
+	c := &comparison{}
+	c.compare("$", decode(t, `{"pact:matcher:type": "type", "value": 1, "pact:generator:type": "ProviderState", "expression": "${id}"}`), "1", false)
+	assert.Len(t, c.mismatches, 1)
+}
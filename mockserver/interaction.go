@@ -0,0 +1,144 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Interaction is an expected request and the response to it, in the format
+// sent to the Pact Mock Service. Values may contain matchers, encoded as JSON
+// objects with a "json_class" of Pact::SomethingLike, Pact::ArrayLike or
+// Pact::Term, as produced by the dsl package.
+type Interaction struct {
+	// Description of the interaction, unique within a pact
+	Description string `json:"description"`
+
+	// ProviderState the provider must be in for the interaction
+	ProviderState string `json:"providerState,omitempty"`
+
+	// Request the consumer is expected to send
+	Request Request `json:"request"`
+
+	// Response returned by the mock server
+	Response Response `json:"response"`
+
+	// MinCalls and MaxCalls bound the number of times the request must be
+	// received. Defaults to at least once. They aren't written to the pact.
+	MinCalls *int `json:"minCalls,omitempty"`
+	MaxCalls *int `json:"maxCalls,omitempty"`
+
+	// Sequence names the scenario the interaction is a step of. The steps of
+	// a sequence are expected in the order they are added, so that the same
+	// request can have a different response each time, e.g. while polling.
+	// Each step is served until it has been received MaxCalls times, which
+	// defaults to once. It isn't written to the pact.
+	Sequence string `json:"sequence,omitempty"`
+
+	// PrecededBy are the descriptions of interactions which must first be
+	// requested before this one. It isn't written to the pact.
+	PrecededBy []string `json:"precededBy,omitempty"`
+
+	// Delay before the response is sent, to test client timeouts and retries.
+	// It isn't written to the pact.
+	Delay time.Duration `json:"responseDelay,omitempty"`
+
+	// Comments about the interaction, in markdown, and the name of the test
+	// that defines it. Only written to version 4 pacts.
+	Comments []string `json:"comments,omitempty"`
+	TestName string   `json:"testName,omitempty"`
+
+	// Pending interactions don't fail the verification of the provider until
+	// they have been implemented. Only written to version 4 pacts.
+	Pending bool `json:"pending,omitempty"`
+}
+
+// Request is the expected request of an interaction
+type Request struct {
+	Method  string                 `json:"method"`
+	Path    interface{}            `json:"path"`
+	Query   interface{}            `json:"query,omitempty"`
+	Headers map[string]interface{} `json:"headers,omitempty"`
+	Body    interface{}            `json:"body,omitempty"`
+}
+
+// Response is the response to an interaction
+type Response struct {
+	Status  int                    `json:"status"`
+	Headers map[string]interface{} `json:"headers,omitempty"`
+	Body    interface{}            `json:"body,omitempty"`
+}
+
+// validate checks that the interaction can be served by the mock server. A
+// response status of 0 is allowed, and defaults to 200.
+func (i *Interaction) validate() error {
+	if i.Description == "" {
+		return errors.New("interaction requires a description")
+	}
+	if status := i.Response.Status; status != 0 && (status < 100 || status > 999) {
+		return fmt.Errorf("interaction %q has an invalid response status %d", i.Description, status)
+	}
+	return nil
+}
+
+// key identifies an interaction in a pact
+func (i *Interaction) key() string {
+	return i.Description + "\x00" + i.ProviderState
+}
+
+// equal reports whether two interactions are the same in a pact, including
+// matchers
+func (i *Interaction) equal(other *Interaction) bool {
+	a, _ := json.Marshal(i.pactContent())
+	b, _ := json.Marshal(other.pactContent())
+	return string(a) == string(b)
+}
+
+// pactContent returns the interaction without the fields only used by
+// consumer tests
+func (i *Interaction) pactContent() Interaction {
+	content := *i
+	content.MinCalls = nil
+	content.MaxCalls = nil
+	content.Sequence = ""
+	content.PrecededBy = nil
+	content.Delay = 0
+	return content
+}
+
+// callBounds returns the minimum and maximum number of calls expected, with
+// a maximum of -1 for no limit
+func (i *Interaction) callBounds() (int, int) {
+	min, max := 1, -1
+	if i.Sequence != "" {
+		max = 1
+	}
+	if i.MinCalls != nil {
+		min = *i.MinCalls
+	}
+	if i.MaxCalls != nil {
+		max = *i.MaxCalls
+	}
+	return min, max
+}
+
+// expectedCalls describes the number of calls expected, e.g. "at least 2"
+func (i *Interaction) expectedCalls() string {
+	switch min, max := i.callBounds(); {
+	case min == max:
+		return fmt.Sprintf("exactly %d", min)
+	case max < 0:
+		return fmt.Sprintf("at least %d", min)
+	case min <= 0:
+		return fmt.Sprintf("at most %d", max)
+	default:
+		return fmt.Sprintf("between %d and %d", min, max)
+	}
+}
+
+// String describes the expected request, e.g. "GET /users"
+func (i *Interaction) String() string {
+	return fmt.Sprintf("%s %v", strings.ToUpper(i.Request.Method), reify(i.Request.Path))
+}
@@ -0,0 +1,44 @@
+package mockserver
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Timings of the locks taken on pact files
+var (
+	lockTimeout = 10 * time.Second
+	lockRetry   = 10 * time.Millisecond
+	lockStale   = time.Minute
+)
+
+// lockPactFile takes a lock on a pact file, shared with the other processes
+// writing it, e.g. the test binaries of several packages, returning the
+// function that releases it. Locks left by a process that died are removed
+// once stale.
+func lockPactFile(path string) (func(), error) {
+	lock := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for the lock on %s, remove %s if no tests are running", path, lock)
+		}
+		time.Sleep(lockRetry)
+	}
+}
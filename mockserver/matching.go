@@ -0,0 +1,266 @@
+package mockserver
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Classes of the matchers understood by the mock server
+const (
+	likeClass      = "Pact::SomethingLike"
+	arrayLikeClass = "Pact::ArrayLike"
+	termClass      = "Pact::Term"
+)
+
+// Mismatch describes how part of a request differs from an interaction
+type Mismatch struct {
+	// Path to the value that differs, e.g. "$.body.user.name"
+	Path string `json:"path"`
+
+	// Expected value, or a description of it
+	Expected interface{} `json:"expected"`
+
+	// Actual value received
+	Actual interface{} `json:"actual"`
+
+	// Reason the values don't match
+	Reason string `json:"reason"`
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: %s", m.Path, m.Reason)
+}
+
+// matcherClass returns the class of a matcher, v3Class for the matchers of
+// later versions of the specification, or "" for a plain value
+func matcherClass(v interface{}) (string, map[string]interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return "", nil
+	}
+	if v3Type(m) != "" {
+		return v3Class, m
+	}
+	class, _ := m["json_class"].(string)
+	switch class {
+	case likeClass, arrayLikeClass, termClass:
+		return class, m
+	}
+	return "", nil
+}
+
+// termRegex returns the generated value and regular expression of a term
+func termRegex(m map[string]interface{}) (interface{}, string) {
+	data, _ := m["data"].(map[string]interface{})
+	matcher, _ := data["matcher"].(map[string]interface{})
+	regex, _ := matcher["s"].(string)
+	return data["generate"], regex
+}
+
+// arrayLikeMin returns the minimum length of an array matcher
+func arrayLikeMin(m map[string]interface{}) int {
+	min, _ := m["min"].(float64)
+	if min < 1 {
+		return 1
+	}
+	return int(min)
+}
+
+// reify replaces the matchers in a value with their examples
+func reify(v interface{}) interface{} {
+	switch class, m := matcherClass(v); class {
+	case likeClass:
+		return reify(m["contents"])
+	case arrayLikeClass:
+		contents := reify(m["contents"])
+		values := make([]interface{}, arrayLikeMin(m))
+		for i := range values {
+			values[i] = contents
+		}
+		return values
+	case termClass:
+		generate, _ := termRegex(m)
+		return generate
+	case v3Class:
+		return reifyV3(m)
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		reified := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			reified[k] = reify(e)
+		}
+		return reified
+	case []interface{}:
+		reified := make([]interface{}, len(value))
+		for i, e := range value {
+			reified[i] = reify(e)
+		}
+		return reified
+	}
+
+	return v
+}
+
+// comparison collects the mismatches between expected and actual values
+type comparison struct {
+	// allowUnexpectedKeys permits objects with keys that aren't expected
+	allowUnexpectedKeys bool
+
+	mismatches []Mismatch
+}
+
+func (c *comparison) mismatch(path string, expected interface{}, actual interface{}, format string, args ...interface{}) {
+	c.mismatches = append(c.mismatches, Mismatch{
+		Path:     path,
+		Expected: expected,
+		Actual:   actual,
+		Reason:   fmt.Sprintf(format, args...),
+	})
+}
+
+// compare matches actual against expected, which may contain matchers. When
+// byType is set, values only need to be of the same type as expected.
+func (c *comparison) compare(path string, expected interface{}, actual interface{}, byType bool) {
+	switch class, m := matcherClass(expected); class {
+	case likeClass:
+		c.compare(path, m["contents"], actual, true)
+		return
+	case arrayLikeClass:
+		values, ok := actual.([]interface{})
+		if !ok {
+			c.mismatch(path, reify(expected), actual, "expected an array, got %s", jsonType(actual))
+			return
+		}
+		if min := arrayLikeMin(m); len(values) < min {
+			c.mismatch(path, reify(expected), actual, "expected an array with at least %d elements, got %d", min, len(values))
+		}
+		for i, value := range values {
+			c.compare(fmt.Sprintf("%s[%d]", path, i), m["contents"], value, true)
+		}
+		return
+	case termClass:
+		generate, regex := termRegex(m)
+		c.compareRegex(path, generate, regex, actual)
+		return
+	case v3Class:
+		c.compareV3(path, m, actual, byType)
+		return
+	}
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			c.mismatch(path, reify(expected), actual, "expected an object, got %s", jsonType(actual))
+			return
+		}
+		for _, k := range sortedKeys(e) {
+			value, ok := a[k]
+			if !ok {
+				c.mismatch(jsonPath(path, k), reify(e[k]), nil, "expected %q to be present", k)
+				continue
+			}
+			c.compare(jsonPath(path, k), e[k], value, byType)
+		}
+		if !c.allowUnexpectedKeys {
+			for _, k := range sortedKeys(a) {
+				if _, ok := e[k]; !ok {
+					c.mismatch(jsonPath(path, k), nil, a[k], "unexpected key %q", k)
+				}
+			}
+		}
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			c.mismatch(path, reify(expected), actual, "expected an array, got %s", jsonType(actual))
+			return
+		}
+		if len(a) != len(e) {
+			c.mismatch(path, reify(expected), actual, "expected an array with %d elements, got %d", len(e), len(a))
+			return
+		}
+		for i := range e {
+			c.compare(fmt.Sprintf("%s[%d]", path, i), e[i], a[i], byType)
+		}
+	default:
+		if byType {
+			if jsonType(expected) != jsonType(actual) {
+				c.mismatch(path, expected, actual, "expected %s, got %s", jsonType(expected), jsonType(actual))
+			}
+		} else if !reflect.DeepEqual(expected, actual) {
+			c.mismatch(path, expected, actual, "expected %s, got %s", describe(expected), describe(actual))
+		}
+	}
+}
+
+// compareRegex matches actual against a regular expression
+func (c *comparison) compareRegex(path string, example interface{}, regex string, actual interface{}) {
+	value, ok := actual.(string)
+	if !ok {
+		c.mismatch(path, example, actual, "expected a string matching /%s/, got %s", regex, jsonType(actual))
+		return
+	}
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		// Go doesn't support every Ruby regular expression, e.g. lookaheads
+		log.Printf("[WARN] unable to match %s against /%s/, matching by type: %v", path, regex, err)
+		return
+	}
+	if !re.MatchString(value) {
+		c.mismatch(path, example, actual, "expected %q to match /%s/", value, regex)
+	}
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func describe(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	if v == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// jsonPath appends a key to a path, quoting keys that aren't identifiers
+func jsonPath(path string, key string) string {
+	if identifier.MatchString(key) {
+		return path + "." + key
+	}
+	return path + "['" + strings.Replace(key, "'", `\'`, -1) + "']"
+}
+
+var identifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
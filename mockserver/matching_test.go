@@ -0,0 +1,74 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", s, err)
+	}
+	return v
+}
+
+func TestReify(t *testing.T) {
+	expected := decode(t, `{
+		"name": {"json_class": "Pact::SomethingLike", "contents": "Billy"},
+		"tags": {"json_class": "Pact::ArrayLike", "contents": "tag", "min": 2},
+		"date": {"json_class": "Pact::Term", "data": {"generate": "2020-01-01", "matcher": {"json_class": "Regexp", "o": 0, "s": "\\d{4}-\\d{2}-\\d{2}"}}}
+	}`)
+
+	assert.Equal(t, decode(t, `{"name": "Billy", "tags": ["tag", "tag"], "date": "2020-01-01"}`), reify(expected))
+}
+
+func TestComparison_Compare(t *testing.T) {
+	expected := decode(t, `{
+		"name": {"json_class": "Pact::SomethingLike", "contents": "Billy"},
+		"tags": {"json_class": "Pact::ArrayLike", "contents": {"id": 1}, "min": 1},
+		"date": {"json_class": "Pact::Term", "data": {"generate": "2020-01-01", "matcher": {"json_class": "Regexp", "o": 0, "s": "^\\d{4}-\\d{2}-\\d{2}$"}}},
+		"exact": "value"
+	}`)
+
+	tests := []struct {
+		name   string
+		actual string
+		paths  []string
+	}{
+		{"matches", `{"name": "Sally", "tags": [{"id": 2}, {"id": 3}], "date": "2021-12-31", "exact": "value"}`, nil},
+		{"wrong type", `{"name": 1, "tags": [{"id": 2}], "date": "2021-12-31", "exact": "value"}`, []string{"$.name"}},
+		{"empty array", `{"name": "Sally", "tags": [], "date": "2021-12-31", "exact": "value"}`, []string{"$.tags"}},
+		{"array element type", `{"name": "Sally", "tags": [{"id": "2"}], "date": "2021-12-31", "exact": "value"}`, []string{"$.tags[0].id"}},
+		{"regex", `{"name": "Sally", "tags": [{"id": 2}], "date": "31/12/2021", "exact": "value"}`, []string{"$.date"}},
+		{"exact value", `{"name": "Sally", "tags": [{"id": 2}], "date": "2021-12-31", "exact": "other"}`, []string{"$.exact"}},
+		{"missing key", `{"tags": [{"id": 2}], "date": "2021-12-31", "exact": "value"}`, []string{"$.name"}},
+		{"unexpected key", `{"name": "Sally", "tags": [{"id": 2}], "date": "2021-12-31", "exact": "value", "my key": 1}`, []string{"$['my key']"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &comparison{}
+			c.compare("$", expected, decode(t, tt.actual), false)
+
+			var paths []string
+			for _, m := range c.mismatches {
+				paths = append(paths, m.Path)
+			}
+			assert.Equal(t, tt.paths, paths)
+		})
+	}
+}
+
+func TestComparison_CompareUnsupportedRegex(t *testing.T) {
+	expected := decode(t, `{"json_class": "Pact::Term", "data": {"generate": "abc", "matcher": {"json_class": "Regexp", "o": 0, "s": "(?=a)abc"}}}`)
+
+	c := &comparison{}
+	c.compare("$", expected, "xyz", false)
+	assert.Empty(t, c.mismatches)
+
+	c.compare("$", expected, 1.0, false)
+	assert.Len(t, c.mismatches, 1)
+}
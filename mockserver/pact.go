@@ -0,0 +1,512 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// pactFile is a pact, as written by the mock server
+type pactFile struct {
+	Consumer     pacticipant       `json:"consumer"`
+	Provider     pacticipant       `json:"provider"`
+	Interactions []json.RawMessage `json:"interactions"`
+	Metadata     pactMetadata      `json:"metadata"`
+}
+
+type pacticipant struct {
+	Name string `json:"name"`
+}
+
+type pactMetadata struct {
+	PactSpecification struct {
+		Version string `json:"version"`
+	} `json:"pactSpecification"`
+}
+
+type pactInteraction struct {
+	Description    string          `json:"description"`
+	ProviderState  string          `json:"providerState,omitempty"`
+	ProviderStates []ProviderState `json:"providerStates,omitempty"`
+	Request        pactRequest     `json:"request"`
+	Response       pactResponse    `json:"response"`
+}
+
+// ProviderState is a provider state, with its parameters
+type ProviderState struct {
+	Name   string                 `json:"name"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+type pactRequest struct {
+	Method        string                 `json:"method"`
+	Path          string                 `json:"path"`
+	Query         interface{}            `json:"query,omitempty"`
+	Headers       map[string]interface{} `json:"headers,omitempty"`
+	Body          interface{}            `json:"body,omitempty"`
+	MatchingRules interface{}            `json:"matchingRules,omitempty"`
+	Generators    interface{}            `json:"generators,omitempty"`
+}
+
+type pactResponse struct {
+	Status        int                    `json:"status"`
+	Headers       map[string]interface{} `json:"headers,omitempty"`
+	Body          interface{}            `json:"body,omitempty"`
+	MatchingRules interface{}            `json:"matchingRules,omitempty"`
+	Generators    interface{}            `json:"generators,omitempty"`
+}
+
+// PactFileName returns the conventional file name of a pact, as written by
+// the mock server and the Ruby mock service
+func PactFileName(consumer string, provider string) string {
+	return fmt.Sprintf("%s-%s.json", fileNamePart(consumer), fileNamePart(provider))
+}
+
+var whitespace = regexp.MustCompile(`\s`)
+
+func fileNamePart(name string) string {
+	return whitespace.ReplaceAllString(strings.ToLower(name), "_")
+}
+
+// pactFiles serializes writes to pact files, so that mock servers in the same
+// process can merge into a pact without losing each other's interactions
+var pactFiles sync.Mutex
+
+// writtenPacts are the pacts written by this process. Later writes merge into
+// them, even in "overwrite" mode, so that the mock servers and message tests
+// of several tests replace the pact of a previous run without replacing each
+// other's.
+var writtenPacts = map[string]bool{}
+
+// writePact writes interactions to the pact between consumer and provider in
+// dir, in its canonical form. In "merge" (or "update") mode, the interactions
+// already in the pact are kept unless replaced by an interaction with the
+// same description and provider state. In "overwrite" mode they are
+// replaced, the first time the pact is written by this process. Messages can
+// only be combined with HTTP interactions in a version 4 pact.
+func writePact(dir string, consumer string, provider string, specificationVersion int, mode string, interactions []*Interaction, messages ...*Message) ([]byte, error) {
+	if consumer == "" || provider == "" {
+		return nil, fmt.Errorf("consumer and provider names are required to write a pact")
+	}
+	if len(messages) > 0 && specificationVersion < 4 {
+		return nil, fmt.Errorf("messages can only be written to a version 4 pact, not version %d", specificationVersion)
+	}
+
+	pact := pactFile{
+		Consumer: pacticipant{Name: consumer},
+		Provider: pacticipant{Name: provider},
+	}
+	pact.Metadata.PactSpecification.Version = fmt.Sprintf("%d.0.0", specificationVersion)
+	if specificationVersion >= 4 {
+		pact.Metadata.PactSpecification.Version = "4.0"
+	}
+
+	var keys []string
+	for _, i := range interactions {
+		var serialized []byte
+		var err error
+		if specificationVersion >= 4 {
+			serialized, err = serializeV4Interaction(i)
+		} else {
+			serialized, err = json.Marshal(serializeInteraction(i, specificationVersion))
+		}
+		if err != nil {
+			return nil, err
+		}
+		pact.Interactions = append(pact.Interactions, serialized)
+		keys = append(keys, i.key())
+	}
+	for _, m := range messages {
+		serialized, err := serializeV4Message(m)
+		if err != nil {
+			return nil, err
+		}
+		pact.Interactions = append(pact.Interactions, serialized)
+		keys = append(keys, m.key())
+	}
+
+	switch mode {
+	case "overwrite", "merge", "update":
+	default:
+		return nil, fmt.Errorf("unknown pact file write mode %q, expected overwrite or merge", mode)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	pactFiles.Lock()
+	defer pactFiles.Unlock()
+
+	path := filepath.Join(dir, PactFileName(consumer, provider))
+	unlock, err := lockPactFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if mode != "overwrite" || writtenPacts[path] {
+		existing, err := readPactInteractions(path)
+		if err != nil {
+			return nil, err
+		}
+		pact.Interactions = mergeInteractions(existing, pact.Interactions, keys)
+	}
+	if pact.Interactions == nil {
+		pact.Interactions = []json.RawMessage{}
+	}
+
+	content, err := json.Marshal(pact)
+	if err != nil {
+		return nil, err
+	}
+	if content, err = Canonicalize(content); err != nil {
+		return nil, err
+	}
+
+	log.Println("[DEBUG] mock server: writing pact to", path)
+	if err := writeFileAtomic(path, content); err != nil {
+		return nil, err
+	}
+	writtenPacts[path] = true
+
+	return content, nil
+}
+
+// writeFileAtomic replaces a file, so that it's never seen partly written
+func writeFileAtomic(path string, content []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// readPactInteractions returns the interactions of an existing pact file
+func readPactInteractions(path string) ([]json.RawMessage, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pact pactFile
+	if err := json.Unmarshal(content, &pact); err != nil {
+		return nil, fmt.Errorf("unable to merge into the pact at %s: %v", path, err)
+	}
+	return pact.Interactions, nil
+}
+
+// mergeInteractions replaces existing interactions with the same key as an
+// updated interaction, appending the rest
+func mergeInteractions(existing []json.RawMessage, updated []json.RawMessage, keys []string) []json.RawMessage {
+	index := map[string]int{}
+	for i, k := range keys {
+		index[k] = i
+	}
+
+	merged := make([]json.RawMessage, 0, len(existing)+len(updated))
+	written := map[string]bool{}
+	for _, raw := range existing {
+		var i pactInteraction
+		json.Unmarshal(raw, &i)
+		state := i.ProviderState
+		if state == "" && len(i.ProviderStates) > 0 {
+			state = i.ProviderStates[0].Name
+		}
+
+		k := i.Description + "\x00" + state
+		if n, ok := index[k]; ok {
+			if !written[k] {
+				merged = append(merged, updated[n])
+				written[k] = true
+			}
+			continue
+		}
+		merged = append(merged, raw)
+	}
+
+	for n, k := range keys {
+		if !written[k] {
+			merged = append(merged, updated[n])
+			written[k] = true
+		}
+	}
+
+	return merged
+}
+
+// serializeInteraction converts an interaction to the format of the given
+// version of the pact specification, extracting its matching rules
+func serializeInteraction(i *Interaction, specificationVersion int) pactInteraction {
+	request := newRules()
+	response := newRules()
+
+	request.extract("path", "", i.Request.Path)
+	query := queryValues(i.Request.Query)
+	for _, k := range sortedKeys(query) {
+		if class, _ := matcherClass(query[k]); class != "" {
+			request.extract("query", k, query[k])
+			continue
+		}
+		for _, v := range query[k].([]interface{}) {
+			request.extract("query", k, v)
+		}
+	}
+	for k, v := range i.Request.Headers {
+		request.extract("header", k, v)
+	}
+	request.extract("body", "$", i.Request.Body)
+
+	for k, v := range i.Response.Headers {
+		response.extract("header", k, v)
+	}
+	response.extract("body", "$", i.Response.Body)
+
+	serialized := pactInteraction{
+		Description: i.Description,
+		Request: pactRequest{
+			Method:        strings.ToUpper(i.Request.Method),
+			Path:          fmt.Sprint(reify(i.Request.Path)),
+			Headers:       listHeaders(reifyHeaders(i.Request.Headers), specificationVersion),
+			Body:          reify(i.Request.Body),
+			MatchingRules: request.format(specificationVersion),
+			Generators:    request.formatGenerators(specificationVersion),
+		},
+		Response: pactResponse{
+			Status:        i.Response.Status,
+			Headers:       listHeaders(reifyHeaders(i.Response.Headers), specificationVersion),
+			Body:          reify(i.Response.Body),
+			MatchingRules: response.format(specificationVersion),
+			Generators:    response.formatGenerators(specificationVersion),
+		},
+	}
+
+	if len(query) > 0 {
+		values := url.Values{}
+		for k, v := range reify(query).(map[string]interface{}) {
+			for _, e := range v.([]interface{}) {
+				values.Add(k, fmt.Sprint(e))
+			}
+		}
+		if specificationVersion >= 3 {
+			serialized.Request.Query = values
+		} else {
+			serialized.Request.Query = values.Encode()
+		}
+	}
+
+	if specificationVersion >= 3 {
+		if i.ProviderState != "" {
+			serialized.ProviderStates = []ProviderState{{Name: i.ProviderState}}
+		}
+	} else {
+		serialized.ProviderState = i.ProviderState
+	}
+
+	return serialized
+}
+
+func reifyHeaders(headers map[string]interface{}) map[string]interface{} {
+	if len(headers) == 0 {
+		return nil
+	}
+	return reify(headers).(map[string]interface{})
+}
+
+// listHeaders returns headers in the layout of the specification version:
+// the values of a header are listed by version 4, and joined by earlier ones
+func listHeaders(headers map[string]interface{}, specificationVersion int) map[string]interface{} {
+	if specificationVersion >= 4 {
+		return headers
+	}
+	for k, v := range headers {
+		if values, ok := v.([]interface{}); ok {
+			headers[k] = joinHeaderValues(values)
+		}
+	}
+	return headers
+}
+
+// joinHeaderValues joins the values of a header, e.g. "gzip, deflate"
+func joinHeaderValues(values []interface{}) string {
+	joined := make([]string, len(values))
+	for i, v := range values {
+		joined[i] = fmt.Sprint(v)
+	}
+	return strings.Join(joined, ", ")
+}
+
+// rules collects the matching rules and generators of a request or response,
+// by category (path, query, header or body) and the key or path within the
+// category
+type rules struct {
+	categories map[string]map[string]map[string]interface{}
+	generators map[string]map[string]map[string]interface{}
+}
+
+func newRules() *rules {
+	return &rules{
+		categories: map[string]map[string]map[string]interface{}{},
+		generators: map[string]map[string]map[string]interface{}{},
+	}
+}
+
+func (r *rules) add(category string, path string, rule map[string]interface{}) {
+	if r.categories[category] == nil {
+		r.categories[category] = map[string]map[string]interface{}{}
+	}
+	if r.categories[category][path] == nil {
+		r.categories[category][path] = map[string]interface{}{}
+	}
+	for k, v := range rule {
+		r.categories[category][path][k] = v
+	}
+}
+
+// extract records the matching rules of the matchers in v, at path
+func (r *rules) extract(category string, path string, v interface{}) {
+	switch class, m := matcherClass(v); class {
+	case likeClass:
+		r.add(category, path, map[string]interface{}{"match": "type"})
+		r.extract(category, path, m["contents"])
+		return
+	case arrayLikeClass:
+		r.add(category, path, map[string]interface{}{"match": "type", "min": arrayLikeMin(m)})
+		r.extract(category, path+"[*]", m["contents"])
+		return
+	case termClass:
+		_, regex := termRegex(m)
+		r.add(category, path, map[string]interface{}{"match": "regex", "regex": regex})
+		return
+	case v3Class:
+		r.extractV3(category, path, m)
+		r.extractGenerator(category, path, m)
+		return
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, e := range value {
+			r.extract(category, jsonPath(path, k), e)
+		}
+	case []interface{}:
+		for i, e := range value {
+			r.extract(category, fmt.Sprintf("%s[%d]", path, i), e)
+		}
+	}
+}
+
+// format returns the rules in the layout of the specification version:
+// keyed by path for version 2, and grouped by category for version 3. Version
+// 1 has no matching rules.
+func (r *rules) format(specificationVersion int) interface{} {
+	if len(r.categories) == 0 || specificationVersion < 2 {
+		return nil
+	}
+
+	if specificationVersion >= 3 {
+		formatted := map[string]interface{}{}
+		for category, paths := range r.categories {
+			if category == "path" {
+				formatted[category] = ruleEntry(paths[""])
+				continue
+			}
+			entries := map[string]interface{}{}
+			for path, rule := range paths {
+				entries[path] = ruleEntry(rule)
+			}
+			if category == "query" || category == "header" {
+				entries = valueEntries(entries)
+			}
+			formatted[category] = entries
+		}
+		return formatted
+	}
+
+	formatted := map[string]interface{}{}
+	for category, paths := range r.categories {
+		for path, rule := range paths {
+			formatted[v2RulePath(category, path)] = rule
+		}
+	}
+	return formatted
+}
+
+// ruleEntry returns the entry of a rule in a version 3 pact. Rules combining
+// several matchers, e.g. with OR, are already entries.
+func ruleEntry(rule map[string]interface{}) map[string]interface{} {
+	if _, ok := rule["combine"]; ok {
+		return rule
+	}
+	return map[string]interface{}{"matchers": []interface{}{rule}}
+}
+
+// valueEntries combines the entries of the rules of query parameters or
+// headers, whose values are listed, with the entries of the rules of each of
+// their values,
+// e.g. of an EachLike matcher, which version 3 pacts key by the parameter:
+//
+//	{"id": {"combine": "AND", "matchers": [{"match": "type", "min": 1}, {"match": "regex", ...}]}}
+//
+// Entries combining matchers with OR are left keyed by their path.
+func valueEntries(entries map[string]interface{}) map[string]interface{} {
+	combined := map[string]interface{}{}
+	for key, entry := range entries {
+		combined[key] = entry
+	}
+	for key, entry := range entries {
+		values := entry.(map[string]interface{})
+		parameter := strings.TrimSuffix(key, "[*]")
+		if parameter == key || entries[parameter] == nil {
+			continue
+		}
+		rules := entries[parameter].(map[string]interface{})
+		if values["combine"] == "OR" || rules["combine"] == "OR" {
+			continue
+		}
+
+		matchers := append([]interface{}{}, rules["matchers"].([]interface{})...)
+		matchers = append(matchers, values["matchers"].([]interface{})...)
+		combined[parameter] = map[string]interface{}{"combine": "AND", "matchers": matchers}
+		delete(combined, key)
+	}
+	return combined
+}
+
+// v2RulePath returns the path of a rule in a version 2 pact
+func v2RulePath(category string, path string) string {
+	switch category {
+	case "path":
+		return "$.path"
+	case "query":
+		parameter := strings.TrimSuffix(path, "[*]")
+		return jsonPath("$.query", parameter) + path[len(parameter):]
+	case "header":
+		header := strings.TrimSuffix(path, "[*]")
+		return jsonPath("$.headers", header) + path[len(header):]
+	}
+	return "$.body" + strings.TrimPrefix(path, "$")
+}
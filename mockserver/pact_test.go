@@ -0,0 +1,262 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testInteraction(t *testing.T, description string) *Interaction {
+	var i Interaction
+	err := json.Unmarshal([]byte(`{
+		"description": "`+description+`",
+		"providerState": "a user exists",
+		"request": {
+			"method": "get",
+			"path": {"json_class": "Pact::Term", "data": {"generate": "/users/1", "matcher": {"json_class": "Regexp", "o": 0, "s": "^/users/\\d+$"}}},
+			"query": "fields=name"
+		},
+		"response": {
+			"status": 200,
+			"headers": {"Content-Type": "application/json"},
+			"body": {"name": {"json_class": "Pact::SomethingLike", "contents": "Billy"}}
+		}
+	}`), &i)
+	assert.NoError(t, err)
+	return &i
+}
+
+func readPact(t *testing.T, path string) map[string]interface{} {
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+
+	var pact map[string]interface{}
+	assert.NoError(t, json.Unmarshal(content, &pact))
+	return pact
+}
+
+func TestPactFileName(t *testing.T) {
+	assert.Equal(t, "my_consumer-my_provider.json", PactFileName("My Consumer", "My Provider"))
+}
+
+func TestWritePact_V2(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	_, err := writePact(dir, "Consumer", "Provider", 2, "overwrite", []*Interaction{testInteraction(t, "get a user")})
+	assert.NoError(t, err)
+
+	pact := readPact(t, filepath.Join(dir, "consumer-provider.json"))
+	interaction := pact["interactions"].([]interface{})[0].(map[string]interface{})
+	request := interaction["request"].(map[string]interface{})
+	response := interaction["response"].(map[string]interface{})
+
+	assert.Equal(t, "2.0.0", pact["metadata"].(map[string]interface{})["pactSpecification"].(map[string]interface{})["version"])
+	assert.Equal(t, "a user exists", interaction["providerState"])
+	assert.Equal(t, "GET", request["method"])
+	assert.Equal(t, "/users/1", request["path"])
+	assert.Equal(t, "fields=name", request["query"])
+	assert.Equal(t, map[string]interface{}{"$.path": map[string]interface{}{"match": "regex", "regex": `^/users/\d+$`}}, request["matchingRules"])
+	assert.Equal(t, map[string]interface{}{"name": "Billy"}, response["body"])
+	assert.Equal(t, map[string]interface{}{"$.body.name": map[string]interface{}{"match": "type"}}, response["matchingRules"])
+}
+
+func TestWritePact_V3(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	_, err := writePact(dir, "Consumer", "Provider", 3, "overwrite", []*Interaction{testInteraction(t, "get a user")})
+	assert.NoError(t, err)
+
+	pact := readPact(t, filepath.Join(dir, "consumer-provider.json"))
+	interaction := pact["interactions"].([]interface{})[0].(map[string]interface{})
+	request := interaction["request"].(map[string]interface{})
+	response := interaction["response"].(map[string]interface{})
+
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "a user exists"}}, interaction["providerStates"])
+	assert.Equal(t, map[string]interface{}{"fields": []interface{}{"name"}}, request["query"])
+	assert.Equal(t, map[string]interface{}{
+		"path": map[string]interface{}{"matchers": []interface{}{map[string]interface{}{"match": "regex", "regex": `^/users/\d+$`}}},
+	}, request["matchingRules"])
+	assert.Equal(t, map[string]interface{}{
+		"body": map[string]interface{}{"$.name": map[string]interface{}{"matchers": []interface{}{map[string]interface{}{"match": "type"}}}},
+	}, response["matchingRules"])
+}
+
+func TestWritePact_QueryMatchers(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	i := testInteraction(t, "list orders")
+	i.Request.Query = decode(t, `{
+		"id": {"json_class": "Pact::ArrayLike", "contents": {"json_class": "Pact::Term", "data": {"generate": "1", "matcher": {"json_class": "Regexp", "o": 0, "s": "^\\d+$"}}}, "min": 2},
+		"sort": {"json_class": "Pact::SomethingLike", "contents": "name"}
+	}`)
+
+	_, err := writePact(dir, "Consumer", "Provider", 3, "overwrite", []*Interaction{i})
+	assert.NoError(t, err)
+
+	pact := readPact(t, filepath.Join(dir, "consumer-provider.json"))
+	request := pact["interactions"].([]interface{})[0].(map[string]interface{})["request"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"id": []interface{}{"1", "1"}, "sort": []interface{}{"name"}}, request["query"])
+	assert.Equal(t, map[string]interface{}{
+		"id": map[string]interface{}{"combine": "AND", "matchers": []interface{}{
+			map[string]interface{}{"match": "type", "min": 2.0},
+			map[string]interface{}{"match": "regex", "regex": `^\d+$`},
+		}},
+		"sort": map[string]interface{}{"matchers": []interface{}{map[string]interface{}{"match": "type"}}},
+	}, request["matchingRules"].(map[string]interface{})["query"])
+
+	// Version 2 pacts key the rules of each value by path
+	_, err = writePact(dir, "Consumer", "Provider", 2, "overwrite", []*Interaction{i})
+	assert.NoError(t, err)
+
+	pact = readPact(t, filepath.Join(dir, "consumer-provider.json"))
+	request = pact["interactions"].([]interface{})[0].(map[string]interface{})["request"].(map[string]interface{})
+	assert.Equal(t, "id=1&id=1&sort=name", request["query"])
+	assert.Equal(t, map[string]interface{}{"match": "regex", "regex": `^\d+$`}, request["matchingRules"].(map[string]interface{})["$.query.id[*]"])
+}
+
+func TestWritePact_ListedHeaders(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	i := testInteraction(t, "list orders")
+	i.Request.Headers = map[string]interface{}{
+		"Accept": decode(t, `{"json_class": "Pact::ArrayLike", "contents": {"json_class": "Pact::Term", "data": {"generate": "application/json", "matcher": {"json_class": "Regexp", "o": 0, "s": "json$"}}}, "min": 2}`),
+	}
+
+	_, err := writePact(dir, "Consumer", "Provider", 3, "overwrite", []*Interaction{i})
+	assert.NoError(t, err)
+
+	pact := readPact(t, filepath.Join(dir, "consumer-provider.json"))
+	request := pact["interactions"].([]interface{})[0].(map[string]interface{})["request"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"Accept": "application/json, application/json"}, request["headers"])
+	assert.Equal(t, map[string]interface{}{
+		"Accept": map[string]interface{}{"combine": "AND", "matchers": []interface{}{
+			map[string]interface{}{"match": "type", "min": 2.0},
+			map[string]interface{}{"match": "regex", "regex": "json$"},
+		}},
+	}, request["matchingRules"].(map[string]interface{})["header"])
+
+	_, err = writePact(dir, "Consumer", "Provider", 2, "overwrite", []*Interaction{i})
+	assert.NoError(t, err)
+
+	pact = readPact(t, filepath.Join(dir, "consumer-provider.json"))
+	request = pact["interactions"].([]interface{})[0].(map[string]interface{})["request"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"match": "regex", "regex": "json$"}, request["matchingRules"].(map[string]interface{})["$.headers.Accept[*]"])
+
+	// Version 4 pacts list the values
+	_, err = writePact(dir, "Consumer", "Provider", 4, "overwrite", []*Interaction{i})
+	assert.NoError(t, err)
+
+	pact = readPact(t, filepath.Join(dir, "consumer-provider.json"))
+	request = pact["interactions"].([]interface{})[0].(map[string]interface{})["request"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"Accept": []interface{}{"application/json", "application/json"}}, request["headers"])
+}
+
+func TestWritePact_Merge(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	_, err := writePact(dir, "Consumer", "Provider", 2, "overwrite", []*Interaction{testInteraction(t, "one"), testInteraction(t, "two")})
+	assert.NoError(t, err)
+
+	updated := testInteraction(t, "two")
+	updated.Response.Status = 404
+	_, err = writePact(dir, "Consumer", "Provider", 2, "merge", []*Interaction{updated, testInteraction(t, "three")})
+	assert.NoError(t, err)
+
+	pact := readPact(t, filepath.Join(dir, "consumer-provider.json"))
+	interactions := pact["interactions"].([]interface{})
+	assert.Len(t, interactions, 3)
+	assert.Equal(t, "one", interactions[0].(map[string]interface{})["description"])
+	assert.Equal(t, "three", interactions[1].(map[string]interface{})["description"])
+	assert.Equal(t, 404.0, interactions[2].(map[string]interface{})["response"].(map[string]interface{})["status"])
+
+	// A later run replaces the pact
+	pactFiles.Lock()
+	delete(writtenPacts, filepath.Join(dir, "consumer-provider.json"))
+	pactFiles.Unlock()
+
+	_, err = writePact(dir, "Consumer", "Provider", 2, "overwrite", []*Interaction{testInteraction(t, "four")})
+	assert.NoError(t, err)
+	assert.Len(t, readPact(t, filepath.Join(dir, "consumer-provider.json"))["interactions"], 1)
+}
+
+func TestWritePact_OverwriteOncePerProcess(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "consumer-provider.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"interactions": [{"description": "stale"}]}`), 0644))
+
+	_, err := writePact(dir, "Consumer", "Provider", 2, "overwrite", []*Interaction{testInteraction(t, "one")})
+	assert.NoError(t, err)
+	_, err = writePact(dir, "Consumer", "Provider", 2, "overwrite", []*Interaction{testInteraction(t, "two")})
+	assert.NoError(t, err)
+
+	interactions := readPact(t, path)["interactions"].([]interface{})
+	assert.Len(t, interactions, 2)
+	assert.Equal(t, "one", interactions[0].(map[string]interface{})["description"])
+	assert.Equal(t, "two", interactions[1].(map[string]interface{})["description"])
+
+	_, err = os.Stat(path + ".lock")
+	assert.True(t, os.IsNotExist(err), "expected the lock to be released")
+}
+
+func TestWritePact_UnknownMode(t *testing.T) {
+	_, err := writePact(".", "Consumer", "Provider", 2, "append", nil)
+	assert.EqualError(t, err, `unknown pact file write mode "append", expected overwrite or merge`)
+}
+
+func TestLockPactFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	defer func(timeout time.Duration) { lockTimeout = timeout }(lockTimeout)
+	lockTimeout = 50 * time.Millisecond
+
+	path := filepath.Join(dir, "consumer-provider.json")
+	unlock, err := lockPactFile(path)
+	assert.NoError(t, err)
+
+	_, err = lockPactFile(path)
+	assert.Error(t, err, "expected the pact to be locked")
+
+	unlock()
+	unlock, err = lockPactFile(path)
+	assert.NoError(t, err)
+	unlock()
+
+	// Stale locks are removed
+	assert.NoError(t, ioutil.WriteFile(path+".lock", nil, 0644))
+	old := time.Now().Add(-2 * lockStale)
+	assert.NoError(t, os.Chtimes(path+".lock", old, old))
+	unlock, err = lockPactFile(path)
+	assert.NoError(t, err)
+	unlock()
+}
+
+func TestWritePact_MissingNames(t *testing.T) {
+	_, err := writePact(".", "", "Provider", 2, "overwrite", nil)
+	assert.Error(t, err)
+}
+
+func TestWritePact_V1(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	_, err := writePact(dir, "Consumer", "Provider", 1, "overwrite", []*Interaction{testInteraction(t, "get a user")})
+	assert.NoError(t, err)
+
+	pact := readPact(t, filepath.Join(dir, "consumer-provider.json"))
+	interaction := pact["interactions"].([]interface{})[0].(map[string]interface{})
+	assert.NotContains(t, interaction["request"], "matchingRules")
+	assert.NotContains(t, interaction["response"], "matchingRules")
+}
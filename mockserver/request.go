@@ -0,0 +1,189 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ReceivedRequest is a request received by the mock server
+type ReceivedRequest struct {
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Query   url.Values  `json:"query,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    []byte      `json:"body,omitempty"`
+}
+
+func (r *ReceivedRequest) String() string {
+	if len(r.Query) > 0 {
+		return fmt.Sprintf("%s %s?%s", r.Method, r.Path, r.Query.Encode())
+	}
+	return fmt.Sprintf("%s %s", r.Method, r.Path)
+}
+
+// match compares a request against the expected request of an interaction
+func match(expected Request, actual *ReceivedRequest) []Mismatch {
+	c := &comparison{}
+
+	if !strings.EqualFold(expected.Method, actual.Method) {
+		c.mismatch("$.method", strings.ToUpper(expected.Method), actual.Method, "expected method %s, got %s", strings.ToUpper(expected.Method), actual.Method)
+	}
+
+	c.compare("$.path", expected.Path, actual.Path, false)
+	matchQuery(c, expected.Query, actual.Query)
+	matchHeaders(c, expected.Headers, actual.Headers)
+	matchBody(c, expected.Body, actual)
+
+	return c.mismatches
+}
+
+// matchQuery requires the query to have exactly the expected parameters
+func matchQuery(c *comparison, expected interface{}, actual url.Values) {
+	want := queryValues(expected)
+	if want == nil {
+		want = map[string]interface{}{}
+	}
+
+	for _, k := range sortedKeys(want) {
+		path := jsonPath("$.query", k)
+		values, ok := actual[k]
+		if !ok {
+			c.mismatch(path, reify(want[k]), nil, "expected query parameter %q to be present", k)
+			continue
+		}
+
+		got := make([]interface{}, len(values))
+		for i, v := range values {
+			got[i] = v
+		}
+		c.compare(path, want[k], got, false)
+	}
+
+	for k, values := range actual {
+		if _, ok := want[k]; !ok {
+			c.mismatch(jsonPath("$.query", k), nil, values, "unexpected query parameter %q", k)
+		}
+	}
+}
+
+// queryValues normalises an expected query, which may be a query string or
+// an object of strings, matchers or arrays, to an object of arrays
+func queryValues(query interface{}) map[string]interface{} {
+	switch q := query.(type) {
+	case string:
+		parsed, _ := url.ParseQuery(q)
+		values := map[string]interface{}{}
+		for k, vs := range parsed {
+			list := make([]interface{}, len(vs))
+			for i, v := range vs {
+				list[i] = v
+			}
+			values[k] = list
+		}
+		return values
+	case map[string]interface{}:
+		values := map[string]interface{}{}
+		for k, v := range q {
+			if class, _ := matcherClass(v); class == arrayLikeClass {
+				values[k] = v
+			} else if list, ok := v.([]interface{}); ok {
+				values[k] = list
+			} else {
+				values[k] = []interface{}{v}
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// matchHeaders requires the expected headers to be present, allowing others.
+// Headers expected as a list, e.g. by EachLike, are matched value by value.
+func matchHeaders(c *comparison, expected map[string]interface{}, actual http.Header) {
+	for _, k := range sortedKeys(expected) {
+		path := jsonPath("$.headers", k)
+		values, ok := actual[http.CanonicalHeaderKey(k)]
+		if !ok {
+			c.mismatch(path, reify(expected[k]), nil, "expected header %q to be present", k)
+			continue
+		}
+		if !isListed(expected[k]) {
+			c.compare(path, expected[k], strings.Join(values, ", "), false)
+			continue
+		}
+
+		var got []interface{}
+		for _, v := range values {
+			for _, e := range splitHeaderValues(v) {
+				got = append(got, e)
+			}
+		}
+		c.compare(path, expected[k], got, false)
+	}
+}
+
+// isListed reports whether the values of a header are expected as a list
+func isListed(expected interface{}) bool {
+	if class, _ := matcherClass(expected); class == arrayLikeClass {
+		return true
+	}
+	_, ok := expected.([]interface{})
+	return ok
+}
+
+// splitHeaderValues splits a header into its comma separated values, leaving
+// the commas of quoted strings and of the URLs of Link headers, e.g.
+// <https://example.com/a,b>; rel="next"
+func splitHeaderValues(header string) []string {
+	var values []string
+	quoted, bracketed, start := false, false, 0
+	for i := 0; i < len(header); i++ {
+		switch c := header[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == '<' && !quoted:
+			bracketed = true
+		case c == '>' && !quoted:
+			bracketed = false
+		case c == ',' && !quoted && !bracketed:
+			values = append(values, strings.TrimSpace(header[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, strings.TrimSpace(header[start:]))
+}
+
+// matchBody compares the body of a request, when one is expected. Bodies
+// expected as a string must match exactly, bodies expected to be of a content
+// type must be parseable as it, and anything else is compared as JSON.
+func matchBody(c *comparison, expected interface{}, actual *ReceivedRequest) {
+	if expected == nil {
+		return
+	}
+
+	// A body matching a content type, such as an image, needn't be JSON
+	if class, m := matcherClass(expected); class == v3Class && v3Type(m) == "contentType" {
+		c.compare("$.body", expected, string(actual.Body), false)
+		return
+	}
+
+	if s, ok := expected.(string); ok {
+		if s != string(actual.Body) {
+			c.mismatch("$.body", s, string(actual.Body), "expected body %q, got %q", s, string(actual.Body))
+		}
+		return
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(actual.Body, &body); err != nil {
+		c.mismatch("$.body", reify(expected), string(actual.Body), "expected a JSON body: %v", err)
+		return
+	}
+
+	c.compare("$.body", expected, body, false)
+}
@@ -0,0 +1,146 @@
+package mockserver
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	expected := Request{
+		Method: "get",
+		Path:   "/users",
+		Query:  "page=1&sort=name",
+		Headers: map[string]interface{}{
+			"Accept": "application/json",
+		},
+		Body: map[string]interface{}{"name": "Billy"},
+	}
+
+	request := func() *ReceivedRequest {
+		return &ReceivedRequest{
+			Method:  "GET",
+			Path:    "/users",
+			Query:   url.Values{"page": {"1"}, "sort": {"name"}},
+			Headers: http.Header{"Accept": {"application/json"}, "User-Agent": {"test"}},
+			Body:    []byte(`{"name": "Billy"}`),
+		}
+	}
+
+	paths := func(mismatches []Mismatch) []string {
+		var paths []string
+		for _, m := range mismatches {
+			paths = append(paths, m.Path)
+		}
+		return paths
+	}
+
+	assert.Empty(t, match(expected, request()))
+
+	r := request()
+	r.Method = "POST"
+	assert.Equal(t, []string{"$.method"}, paths(match(expected, r)))
+
+	r = request()
+	r.Path = "/user"
+	assert.Equal(t, []string{"$.path"}, paths(match(expected, r)))
+
+	r = request()
+	r.Query.Add("extra", "1")
+	assert.Equal(t, []string{"$.query.extra"}, paths(match(expected, r)))
+
+	r = request()
+	r.Query.Del("page")
+	assert.Equal(t, []string{"$.query.page"}, paths(match(expected, r)))
+
+	r = request()
+	r.Headers.Del("Accept")
+	assert.Equal(t, []string{"$.headers.Accept"}, paths(match(expected, r)))
+
+	r = request()
+	r.Body = []byte(`{"name": "Sally"}`)
+	assert.Equal(t, []string{"$.body.name"}, paths(match(expected, r)))
+
+	r = request()
+	r.Body = []byte(`not json`)
+	assert.Equal(t, []string{"$.body"}, paths(match(expected, r)))
+}
+
+func TestMatch_QueryMatchers(t *testing.T) {
+	expected := Request{
+		Method: "GET",
+		Path:   "/users",
+		Query: map[string]interface{}{
+			"id": map[string]interface{}{
+				"json_class": "Pact::Term",
+				"data": map[string]interface{}{
+					"generate": "1",
+					"matcher":  map[string]interface{}{"json_class": "Regexp", "o": 0.0, "s": `^\d+$`},
+				},
+			},
+		},
+	}
+
+	r := &ReceivedRequest{Method: "GET", Path: "/users", Query: url.Values{"id": {"42"}}}
+	assert.Empty(t, match(expected, r))
+
+	r.Query = url.Values{"id": {"abc"}}
+	assert.Len(t, match(expected, r), 1)
+}
+
+func TestMatch_RepeatedQueryParameters(t *testing.T) {
+	expected := Request{
+		Method: "GET",
+		Path:   "/orders",
+		Query:  decode(t, `{"id": {"json_class": "Pact::ArrayLike", "contents": {"json_class": "Pact::Term", "data": {"generate": "1", "matcher": {"json_class": "Regexp", "o": 0, "s": "^\\d+$"}}}, "min": 1}}`),
+	}
+
+	r := &ReceivedRequest{Method: "GET", Path: "/orders", Query: url.Values{"id": {"1", "22", "333"}}}
+	assert.Empty(t, match(expected, r))
+
+	r.Query = url.Values{"id": {"1", "abc"}}
+	mismatches := match(expected, r)
+	assert.Len(t, mismatches, 1)
+	assert.Equal(t, "$.query.id[1]", mismatches[0].Path)
+}
+
+func TestMatch_ListedHeaders(t *testing.T) {
+	expected := Request{
+		Method: "GET",
+		Path:   "/orders",
+		Headers: map[string]interface{}{
+			"Accept": decode(t, `{"json_class": "Pact::ArrayLike", "contents": {"json_class": "Pact::Term", "data": {"generate": "application/json", "matcher": {"json_class": "Regexp", "o": 0, "s": "^application/[a-z+]*json"}}}, "min": 1}`),
+			"Link":   decode(t, `["<https://example.com/orders?page=2>; rel=\"next\"", "<https://example.com/orders?page=9>; rel=\"last\""]`),
+		},
+	}
+
+	r := &ReceivedRequest{Method: "GET", Path: "/orders", Headers: http.Header{
+		"Accept": {"application/json, application/hal+json;q=0.9", "application/problem+json"},
+		"Link":   {`<https://example.com/orders?page=2>; rel="next", <https://example.com/orders?page=9>; rel="last"`},
+	}}
+	assert.Empty(t, match(expected, r))
+
+	r.Headers.Set("Accept", "application/json, text/html")
+	mismatches := match(expected, r)
+	assert.Len(t, mismatches, 1)
+	assert.Equal(t, "$.headers.Accept[1]", mismatches[0].Path)
+}
+
+func TestSplitHeaderValues(t *testing.T) {
+	assert.Equal(t, []string{"gzip", "deflate"}, splitHeaderValues("gzip,deflate"))
+	assert.Equal(t, []string{`<https://example.com/a,b>; rel="next"`, `<https://example.com/c>; rel="last"`}, splitHeaderValues(`<https://example.com/a,b>; rel="next", <https://example.com/c>; rel="last"`))
+	assert.Equal(t, []string{`attachment; filename="a, \"b\".csv"`}, splitHeaderValues(`attachment; filename="a, \"b\".csv"`))
+}
+
+func TestMatch_ContentTypeBody(t *testing.T) {
+	expected := Request{
+		Method: "POST",
+		Path:   "/documents",
+		Body:   decode(t, `{"pact:matcher:type": "contentType", "contentType": "application/xml", "value": "<a/>"}`),
+	}
+
+	assert.Empty(t, match(expected, &ReceivedRequest{Method: "POST", Path: "/documents", Body: []byte(`<document><title>Pact</title></document>`)}))
+	assert.Len(t, match(expected, &ReceivedRequest{Method: "POST", Path: "/documents", Body: []byte(`not xml`)}), 1)
+}
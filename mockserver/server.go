@@ -0,0 +1,620 @@
+// Package mockserver is a native implementation of the Pact Mock Service,
+// serving the interactions of consumer tests without the Pact CLI tools.
+//
+// The server is administered with the HTTP API of the Pact Mock Service
+// (see https://github.com/pact-foundation/pact-mock_service), so it works
+// with the dsl package's MockService client, or directly with its methods.
+package mockserver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// adminHeader marks requests to the administration API of the mock server
+const adminHeader = "X-Pact-Mock-Service"
+
+// Options for the mock server
+type Options struct {
+	// Consumer and Provider names, used to name the pact
+	Consumer string
+	Provider string
+
+	// PactDir is the directory pacts are written to.
+	// Defaults to the current directory.
+	PactDir string
+
+	// SpecificationVersion of the pacts written, 1 to 4. Defaults to 2.
+	SpecificationVersion int
+
+	// PactFileWriteMode is "overwrite" to replace the pact each time it is
+	// written, or "merge" to add to the interactions already in the pact.
+	// Defaults to "overwrite".
+	PactFileWriteMode string
+
+	// Host is the address the mock server listens on. Defaults to localhost.
+	Host string
+
+	// Port the mock server listens on. Defaults to a random free port.
+	Port int
+
+	// TLS serves the mock server over https, for clients that refuse plain
+	// http. The certificate is issued by a generated CA, which clients can be
+	// configured to trust, unless TLSCertificate is set.
+	TLS bool
+
+	// TLSCertificate is the certificate served when TLS is set
+	TLSCertificate *tls.Certificate
+
+	// CORS enables responses to cross-origin requests, including preflight
+	// requests that aren't themselves expected by an interaction
+	CORS *CORS
+}
+
+// Server is a running mock server
+type Server struct {
+	// Port the mock server is listening on
+	Port int
+
+	// Scheme the mock server is served with, http or https
+	Scheme string
+
+	// CertificatePEM is the PEM encoded CA of the certificate served over
+	// https, or the certificate itself when given by Options.TLSCertificate
+	CertificatePEM []byte
+
+	options Options
+	server  *http.Server
+
+	mu sync.Mutex
+
+	// interactions expected by the test in progress, and the number of times
+	// each has been received
+	interactions []*Interaction
+	calls        map[*Interaction]int
+
+	// requests which must not be received by the test in progress, and those
+	// received
+	forbidden      []*ForbiddenRequest
+	forbiddenCalls []ForbiddenCall
+
+	// order each interaction was first received in, counting from 1
+	first map[*Interaction]int
+
+	// requests that didn't match an interaction, in the order received, and
+	// whether each was unexpected or incorrect
+	unmatched  []*ReceivedRequest
+	unexpected []*ReceivedRequest
+	incorrect  []IncorrectRequest
+
+	// verified interactions, to be written to the pact
+	verified []*Interaction
+}
+
+// Start starts a mock server
+func Start(options Options) (*Server, error) {
+	if options.Host == "" {
+		options.Host = "localhost"
+	}
+	if options.SpecificationVersion == 0 {
+		options.SpecificationVersion = 2
+	}
+	if options.SpecificationVersion < 1 || options.SpecificationVersion > 4 {
+		return nil, fmt.Errorf("unsupported pact specification version %d, expected 1 to 4", options.SpecificationVersion)
+	}
+	if options.PactFileWriteMode == "" {
+		options.PactFileWriteMode = "overwrite"
+	}
+	if options.PactDir == "" {
+		options.PactDir = "."
+	}
+
+	host := strings.TrimSuffix(strings.TrimPrefix(options.Host, "["), "]")
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(options.Port)))
+	if err != nil {
+		log.Println("[ERROR] unable to start mock server:", err)
+		return nil, err
+	}
+
+	s := &Server{
+		Port:    ln.Addr().(*net.TCPAddr).Port,
+		Scheme:  "http",
+		options: options,
+		calls:   map[*Interaction]int{},
+		first:   map[*Interaction]int{},
+	}
+	s.server = &http.Server{Handler: s}
+
+	if options.TLS {
+		if err := s.configureTLS(options.TLSCertificate, host); err != nil {
+			ln.Close()
+			log.Println("[ERROR] unable to configure TLS for the mock server:", err)
+			return nil, err
+		}
+		ln = tls.NewListener(ln, s.server.TLSConfig)
+	}
+
+	log.Println("[DEBUG] starting mock server on port", s.Port)
+	go func() {
+		if err := s.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Println("[ERROR] mock server:", err)
+		}
+	}()
+
+	return s, nil
+}
+
+// URL is the base URL of the mock server, for the client under test
+func (s *Server) URL() string {
+	host := strings.TrimSuffix(strings.TrimPrefix(s.options.Host, "["), "]")
+	return fmt.Sprintf("%s://%s", s.Scheme, net.JoinHostPort(host, strconv.Itoa(s.Port)))
+}
+
+// Shutdown gracefully stops the mock server
+func (s *Server) Shutdown(ctx context.Context) error {
+	log.Println("[DEBUG] shutting down mock server on port", s.Port)
+	return s.server.Shutdown(ctx)
+}
+
+// Close immediately stops the mock server
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// AddInteraction adds an interaction expected by the test in progress. The
+// response status defaults to 200.
+func (s *Server) AddInteraction(interaction *Interaction) error {
+	if err := interaction.validate(); err != nil {
+		return err
+	}
+	if interaction.Response.Status == 0 {
+		interaction.Response.Status = http.StatusOK
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.interactions {
+		if existing.key() == interaction.key() {
+			return fmt.Errorf("interaction %q with provider state %q has already been added", interaction.Description, interaction.ProviderState)
+		}
+	}
+
+	log.Println("[DEBUG] mock server: adding interaction", interaction)
+	s.interactions = append(s.interactions, interaction)
+	return nil
+}
+
+// ForbidRequest adds a request which must not be received by the test in
+// progress
+func (s *Server) ForbidRequest(forbidden *ForbiddenRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log.Println("[DEBUG] mock server: forbidding request", forbidden)
+	s.forbidden = append(s.forbidden, forbidden)
+	return nil
+}
+
+// DeleteInteractions removes the interactions and forbidden requests of the
+// test in progress, and the requests received
+func (s *Server) DeleteInteractions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.interactions = nil
+	s.forbidden = nil
+	s.forbiddenCalls = nil
+	s.calls = map[*Interaction]int{}
+	s.first = map[*Interaction]int{}
+	s.unmatched = nil
+	s.unexpected = nil
+	s.incorrect = nil
+}
+
+// UnmatchedRequests returns the requests of the test in progress which didn't
+// match an interaction, with their headers and bodies, in the order received
+func (s *Server) UnmatchedRequests() []*ReceivedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*ReceivedRequest{}, s.unmatched...)
+}
+
+// Verify checks that every interaction was received, and that no other
+// requests, including forbidden requests, were, returning a *VerificationError otherwise. Verified
+// interactions are added to the pact.
+func (s *Server) Verify() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verification := &VerificationError{
+		Unexpected: s.unexpected,
+		Incorrect:  s.incorrect,
+		Forbidden:  s.forbiddenCalls,
+	}
+	for _, i := range s.interactions {
+		calls := s.calls[i]
+		min, max := i.callBounds()
+		switch {
+		case calls == 0 && min > 0:
+			verification.Missing = append(verification.Missing, i)
+		case calls < min || (max >= 0 && calls > max):
+			verification.Calls = append(verification.Calls, CallCount{Interaction: i, Calls: calls})
+		}
+	}
+
+	order, err := s.verifyOrder()
+	if err != nil {
+		return err
+	}
+	verification.Order = order
+
+	if len(verification.Missing) > 0 || len(verification.Unexpected) > 0 || len(verification.Incorrect) > 0 || len(verification.Calls) > 0 || len(verification.Order) > 0 || len(verification.Forbidden) > 0 {
+		return verification
+	}
+
+	for _, i := range s.interactions {
+		if err := s.record(i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyOrder checks that each interaction requested was first preceded by
+// the interactions it requires
+func (s *Server) verifyOrder() ([]OrderMismatch, error) {
+	byDescription := map[string]*Interaction{}
+	for _, i := range s.interactions {
+		byDescription[i.Description] = i
+	}
+
+	var mismatches []OrderMismatch
+	for _, i := range s.interactions {
+		for _, description := range i.PrecededBy {
+			before, ok := byDescription[description]
+			if !ok {
+				return nil, fmt.Errorf("interaction %q must be preceded by the unknown interaction %q", i.Description, description)
+			}
+			if s.first[i] == 0 {
+				continue
+			}
+			if s.first[before] == 0 || s.first[before] > s.first[i] {
+				mismatches = append(mismatches, OrderMismatch{Before: before, After: i})
+			}
+		}
+	}
+
+	return mismatches, nil
+}
+
+// record adds a verified interaction to the pact. An interaction with the
+// same description and provider state must be identical.
+func (s *Server) record(interaction *Interaction) error {
+	for n, existing := range s.verified {
+		if existing.key() != interaction.key() {
+			continue
+		}
+		if !existing.equal(interaction) {
+			return fmt.Errorf("interaction %q with provider state %q differs from an earlier interaction with the same description and provider state", interaction.Description, interaction.ProviderState)
+		}
+		s.verified[n] = interaction
+		return nil
+	}
+
+	s.verified = append(s.verified, interaction)
+	return nil
+}
+
+// WritePact writes the verified interactions to the pact file
+func (s *Server) WritePact() error {
+	_, err := s.writePact(s.options.Consumer, s.options.Provider, s.options.PactFileWriteMode)
+	return err
+}
+
+func (s *Server) writePact(consumer string, provider string, mode string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return writePact(s.options.PactDir, consumer, provider, s.options.SpecificationVersion, mode, s.verified)
+}
+
+// ServeHTTP serves the administration API, and the interactions
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if cors := s.options.CORS; cors != nil {
+		if isPreflight(r) && !s.expects(r) {
+			cors.preflight(w, r)
+			return
+		}
+		cors.allowOrigin(w, r)
+	}
+
+	if r.Header.Get(adminHeader) != "" {
+		s.serveAdmin(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request := &ReceivedRequest{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   r.URL.Query(),
+		Headers: r.Header,
+		Body:    body,
+	}
+
+	interaction, err := s.match(request)
+	if err != nil {
+		log.Println("[ERROR] mock server:", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": err.Error()})
+		return
+	}
+
+	if interaction.Delay > 0 {
+		select {
+		case <-time.After(interaction.Delay):
+		case <-r.Context().Done():
+			log.Println("[DEBUG] mock server: request cancelled during the response delay:", request)
+			return
+		}
+	}
+
+	writeResponse(w, interaction.Response)
+}
+
+// match finds the interaction a request is for, recording requests that
+// are forbidden or don't match exactly one interaction
+func (s *Server) match(request *ReceivedRequest) (*Interaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, f := range s.forbidden {
+		if f.matches(request) {
+			s.unmatched = append(s.unmatched, request)
+			s.forbiddenCalls = append(s.forbiddenCalls, ForbiddenCall{Request: request, Forbidden: f})
+			return nil, fmt.Errorf("forbidden request %s", request)
+		}
+	}
+
+	steps := s.currentSteps()
+
+	var matches []*Interaction
+	var closest *IncorrectRequest
+	for _, i := range s.interactions {
+		mismatches := match(i.Request, request)
+		if len(mismatches) == 0 && i.Sequence != "" && steps[i.Sequence] != i {
+			mismatches = []Mismatch{outOfSequence(i, steps[i.Sequence])}
+		}
+		if len(mismatches) == 0 {
+			matches = append(matches, i)
+			continue
+		}
+
+		if closest == nil && !hasMismatch(mismatches, "$.method", "$.path") {
+			closest = &IncorrectRequest{Request: request, Interaction: i, Mismatches: mismatches}
+		}
+	}
+
+	if len(matches) == 1 {
+		if s.calls[matches[0]] == 0 {
+			s.first[matches[0]] = len(s.first) + 1
+		}
+		s.calls[matches[0]]++
+		return matches[0], nil
+	}
+
+	s.unmatched = append(s.unmatched, request)
+	switch {
+	case len(matches) > 1:
+		s.unexpected = append(s.unexpected, request)
+		return nil, fmt.Errorf("multiple interactions found for %s", request)
+	case closest != nil:
+		s.incorrect = append(s.incorrect, *closest)
+	default:
+		s.unexpected = append(s.unexpected, request)
+	}
+
+	return nil, fmt.Errorf("no interaction found for %s", request)
+}
+
+// expects reports whether an interaction expects the method and path of a
+// request, so that preflight requests can be tested explicitly
+func (s *Server) expects(r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request := &ReceivedRequest{Method: r.Method, Path: r.URL.Path}
+	for _, i := range s.interactions {
+		if !hasMismatch(match(i.Request, request), "$.method", "$.path") {
+			return true
+		}
+	}
+	return false
+}
+
+// currentSteps returns the step of each sequence to be served next, which is
+// the first not yet received as many times as it can be. Completed sequences
+// have no current step.
+func (s *Server) currentSteps() map[string]*Interaction {
+	steps := map[string]*Interaction{}
+	done := map[string]bool{}
+	for _, i := range s.interactions {
+		if i.Sequence == "" || done[i.Sequence] {
+			continue
+		}
+		if _, max := i.callBounds(); max < 0 || s.calls[i] < max {
+			steps[i.Sequence] = i
+			done[i.Sequence] = true
+		}
+	}
+	return steps
+}
+
+// outOfSequence describes a request for a step of a sequence other than the
+// current one
+func outOfSequence(step *Interaction, current *Interaction) Mismatch {
+	if current == nil {
+		return Mismatch{Path: "$", Reason: fmt.Sprintf("sequence %q is already complete", step.Sequence)}
+	}
+	return Mismatch{
+		Path:     "$",
+		Expected: current.Description,
+		Actual:   step.Description,
+		Reason:   fmt.Sprintf("out of sequence %q: expected %q before %q", step.Sequence, current.Description, step.Description),
+	}
+}
+
+func hasMismatch(mismatches []Mismatch, paths ...string) bool {
+	for _, m := range mismatches {
+		for _, p := range paths {
+			if m.Path == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeResponse serves the response of an interaction, with the examples
+// of any matchers, or the values of their generators
+func writeResponse(w http.ResponseWriter, response Response) {
+	headers, _ := generate(response.Headers).(map[string]interface{})
+	for k, v := range reifyHeaders(headers) {
+		values, ok := v.([]interface{})
+		if !ok {
+			w.Header().Set(k, fmt.Sprint(v))
+			continue
+		}
+		for _, e := range values {
+			w.Header().Add(k, fmt.Sprint(e))
+		}
+	}
+
+	var body []byte
+	if response.Body != nil {
+		if s, ok := response.Body.(string); ok {
+			body = []byte(s)
+		} else {
+			body, _ = json.Marshal(reify(generate(response.Body)))
+			if w.Header().Get("Content-Type") == "" {
+				w.Header().Set("Content-Type", "application/json")
+			}
+		}
+	}
+
+	w.WriteHeader(response.Status)
+	w.Write(body)
+}
+
+// serveAdmin implements the administration API of the Pact Mock Service
+func (s *Server) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/interactions" && r.Method == "DELETE":
+		s.DeleteInteractions()
+	case r.URL.Path == "/interactions" && r.Method == "POST":
+		var interaction Interaction
+		if err := json.NewDecoder(r.Body).Decode(&interaction); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := interaction.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.AddInteraction(&interaction); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case r.URL.Path == "/interactions" && r.Method == "PUT":
+		var body struct {
+			Interactions []*Interaction `json:"interactions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, interaction := range body.Interactions {
+			if err := interaction.validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		s.DeleteInteractions()
+		for _, interaction := range body.Interactions {
+			if err := s.AddInteraction(interaction); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	case r.URL.Path == "/interactions/forbidden" && r.Method == "POST":
+		var forbidden ForbiddenRequest
+		if err := json.NewDecoder(r.Body).Decode(&forbidden); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.ForbidRequest(&forbidden); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case r.URL.Path == "/interactions/unmatched" && r.Method == "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.UnmatchedRequests())
+	case r.URL.Path == "/interactions/verification" && r.Method == "GET":
+		if err := s.Verify(); err != nil {
+			if verification, ok := err.(*VerificationError); ok && r.Header.Get("Accept") == "application/json" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(verification)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("Interactions matched"))
+	case r.URL.Path == "/pact" && r.Method == "POST":
+		var body struct {
+			Consumer          pacticipant `json:"consumer"`
+			Provider          pacticipant `json:"provider"`
+			PactFileWriteMode string      `json:"pactFileWriteMode"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Consumer.Name == "" {
+			body.Consumer.Name = s.options.Consumer
+		}
+		if body.Provider.Name == "" {
+			body.Provider.Name = s.options.Provider
+		}
+		if body.PactFileWriteMode == "" {
+			body.PactFileWriteMode = s.options.PactFileWriteMode
+		}
+
+		pact, err := s.writePact(body.Consumer.Name, body.Provider.Name, body.PactFileWriteMode)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(pact)
+	default:
+		http.NotFound(w, r)
+	}
+}
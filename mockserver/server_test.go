@@ -0,0 +1,510 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startServer(t *testing.T) (*Server, string) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	s, err := Start(Options{Consumer: "Consumer", Provider: "Provider", PactDir: dir})
+	if err != nil {
+		t.Fatalf("unable to start mock server: %v", err)
+	}
+	return s, dir
+}
+
+func admin(t *testing.T, s *Server, method string, path string, body interface{}) (int, string) {
+	var content []byte
+	if body != nil {
+		content, _ = json.Marshal(body)
+	}
+	req, _ := http.NewRequest(method, s.URL()+path, bytes.NewReader(content))
+	req.Header.Set(adminHeader, "true")
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("admin request failed: %v", err)
+	}
+	defer res.Body.Close()
+	response, _ := ioutil.ReadAll(res.Body)
+	return res.StatusCode, string(response)
+}
+
+func TestServer_RoundTrip(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	status, _ := admin(t, s, "POST", "/interactions", testInteraction(t, "get a user"))
+	assert.Equal(t, http.StatusOK, status)
+
+	res, err := http.Get(s.URL() + "/users/42?fields=name")
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"name": "Billy"}`, string(body))
+
+	status, body2 := admin(t, s, "GET", "/interactions/verification", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Interactions matched", body2)
+
+	status, _ = admin(t, s, "DELETE", "/interactions", nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = admin(t, s, "POST", "/pact", map[string]interface{}{
+		"consumer": map[string]string{"name": "Consumer"},
+		"provider": map[string]string{"name": "Provider"},
+	})
+	assert.Equal(t, http.StatusOK, status)
+
+	pact := readPact(t, filepath.Join(dir, "consumer-provider.json"))
+	assert.Len(t, pact["interactions"], 1)
+}
+
+func TestServer_VerifyFail(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, s.AddInteraction(testInteraction(t, "get a user")))
+	assert.NoError(t, s.AddInteraction(&Interaction{
+		Description: "create a user",
+		Request:     Request{Method: "POST", Path: "/users"},
+		Response:    Response{Status: 201},
+	}))
+
+	res, err := http.Get(s.URL() + "/users/42?fields=email")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+	res, err = http.Get(s.URL() + "/unknown")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+	err = s.Verify()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "missing request: GET /users/1 (get a user)")
+	assert.Contains(t, err.Error(), "missing request: POST /users (create a user)")
+	assert.Contains(t, err.Error(), "incorrect request: GET /users/42?fields=email (get a user)")
+	assert.Contains(t, err.Error(), "unexpected request: GET /unknown")
+
+	verification, ok := err.(*VerificationError)
+	assert.True(t, ok, "expected a *VerificationError")
+	assert.Len(t, verification.Missing, 2)
+	assert.Equal(t, "/unknown", verification.Unexpected[0].Path)
+	assert.Equal(t, "get a user", verification.Incorrect[0].Interaction.Description)
+	assert.Equal(t, "$.query.fields[0]", verification.Incorrect[0].Mismatches[0].Path)
+
+	status, body := admin(t, s, "GET", "/interactions/verification", nil)
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.True(t, strings.Contains(body, "pact verification failed"))
+
+	req, _ := http.NewRequest("GET", s.URL()+"/interactions/verification", nil)
+	req.Header.Set(adminHeader, "true")
+	req.Header.Set("Accept", "application/json")
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+
+	decoded := &VerificationError{}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(decoded))
+	assert.Equal(t, verification.Error(), decoded.Error())
+
+	assert.NoError(t, s.WritePact())
+	assert.Len(t, readPact(t, filepath.Join(dir, "consumer-provider.json"))["interactions"], 0)
+}
+
+func TestServer_ListedResponseHeaders(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, s.AddInteraction(&Interaction{
+		Description: "get the allowed methods",
+		Request:     Request{Method: "OPTIONS", Path: "/orders"},
+		Response: Response{
+			Status:  204,
+			Headers: map[string]interface{}{"Allow": decode(t, `["GET", "POST"]`)},
+		},
+	}))
+
+	req, _ := http.NewRequest("OPTIONS", s.URL()+"/orders", nil)
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, []string{"GET", "POST"}, res.Header["Allow"])
+}
+
+func TestServer_AddInteractionDuplicate(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, s.AddInteraction(testInteraction(t, "get a user")))
+	assert.Error(t, s.AddInteraction(testInteraction(t, "get a user")))
+	assert.Error(t, s.AddInteraction(&Interaction{}))
+}
+
+func TestServer_DefaultStatus(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	status, _ := admin(t, s, "POST", "/interactions", map[string]interface{}{
+		"description": "delete a user",
+		"request":     map[string]interface{}{"method": "DELETE", "path": "/users/1"},
+		"response":    map[string]interface{}{},
+	})
+	assert.Equal(t, http.StatusOK, status)
+
+	req, _ := http.NewRequest("DELETE", s.URL()+"/users/1", nil)
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	assert.NoError(t, s.Verify())
+	assert.NoError(t, s.WritePact())
+	interaction := readPact(t, filepath.Join(dir, "consumer-provider.json"))["interactions"].([]interface{})[0]
+	assert.Equal(t, float64(200), interaction.(map[string]interface{})["response"].(map[string]interface{})["status"])
+}
+
+func TestServer_InvalidStatus(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	interaction := map[string]interface{}{
+		"description": "delete a user",
+		"request":     map[string]interface{}{"method": "DELETE", "path": "/users/1"},
+		"response":    map[string]interface{}{"status": 42},
+	}
+	status, body := admin(t, s, "POST", "/interactions", interaction)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, `interaction "delete a user" has an invalid response status 42`)
+
+	status, _ = admin(t, s, "PUT", "/interactions", map[string]interface{}{
+		"interactions": []interface{}{interaction},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	assert.Error(t, s.AddInteraction(&Interaction{Description: "a teapot", Response: Response{Status: 1000}}))
+	assert.Empty(t, s.interactions)
+}
+
+func TestServer_ConflictingInteractions(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	interaction := &Interaction{
+		Description: "create a user",
+		Request:     Request{Method: "POST", Path: "/users"},
+		Response:    Response{Status: 201},
+	}
+	assert.NoError(t, s.AddInteraction(interaction))
+	res, err := http.Post(s.URL()+"/users", "application/json", nil)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.NoError(t, s.Verify())
+
+	s.DeleteInteractions()
+	changed := *interaction
+	changed.Response.Status = 200
+	assert.NoError(t, s.AddInteraction(&changed))
+	res, err = http.Post(s.URL()+"/users", "application/json", nil)
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Error(t, s.Verify())
+}
+
+func TestServer_UnmatchedRequests(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, s.AddInteraction(testInteraction(t, "get a user")))
+
+	req, _ := http.NewRequest("POST", s.URL()+"/users", strings.NewReader(`{"name": "Billy"}`))
+	req.Header.Set("Authorization", "Bearer 1234")
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+
+	for _, path := range []string{"/users/1?fields=name", "/users/1?fields=email"} {
+		res, err := http.Get(s.URL() + path)
+		assert.NoError(t, err)
+		res.Body.Close()
+	}
+
+	unmatched := s.UnmatchedRequests()
+	assert.Len(t, unmatched, 2)
+	assert.Equal(t, "POST", unmatched[0].Method)
+	assert.Equal(t, "Bearer 1234", unmatched[0].Headers.Get("Authorization"))
+	assert.Equal(t, `{"name": "Billy"}`, string(unmatched[0].Body))
+	assert.Equal(t, "email", unmatched[1].Query.Get("fields"))
+
+	status, body := admin(t, s, "GET", "/interactions/unmatched", nil)
+	assert.Equal(t, http.StatusOK, status)
+	var decoded []*ReceivedRequest
+	assert.NoError(t, json.Unmarshal([]byte(body), &decoded))
+	assert.Len(t, decoded, 2)
+	assert.Equal(t, unmatched[0].Body, decoded[0].Body)
+	assert.Equal(t, unmatched[0].Headers, decoded[0].Headers)
+	assert.Equal(t, unmatched[1].Query, decoded[1].Query)
+
+	s.DeleteInteractions()
+	assert.Empty(t, s.UnmatchedRequests())
+}
+
+func TestServer_CallCounts(t *testing.T) {
+	one, two := 1, 2
+	none := 0
+
+	tests := []struct {
+		name     string
+		min, max *int
+		calls    int
+		problem  string
+	}{
+		{name: "default", calls: 3},
+		{name: "default missing", calls: 0, problem: "missing request"},
+		{name: "exactly", min: &one, max: &one, calls: 1},
+		{name: "exactly too many", min: &one, max: &one, calls: 2, problem: "received 2 times, expected exactly 1"},
+		{name: "at least", min: &two, calls: 1, problem: "received 1 times, expected at least 2"},
+		{name: "at most", min: &none, max: &two, calls: 0},
+		{name: "at most too many", min: &none, max: &two, calls: 3, problem: "received 3 times, expected at most 2"},
+		{name: "between", min: &one, max: &two, calls: 3, problem: "received 3 times, expected between 1 and 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := startServer(t)
+			defer s.Close()
+			defer os.RemoveAll(dir)
+
+			assert.NoError(t, s.AddInteraction(&Interaction{
+				Description: "create a user",
+				Request:     Request{Method: "POST", Path: "/users"},
+				Response:    Response{Status: 201},
+				MinCalls:    tt.min,
+				MaxCalls:    tt.max,
+			}))
+
+			for i := 0; i < tt.calls; i++ {
+				res, err := http.Post(s.URL()+"/users", "application/json", nil)
+				assert.NoError(t, err)
+				res.Body.Close()
+			}
+
+			err := s.Verify()
+			if tt.problem == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.problem)
+		})
+	}
+}
+
+func TestServer_Delay(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, s.AddInteraction(&Interaction{
+		Description: "a slow request",
+		Request:     Request{Method: "GET", Path: "/slow"},
+		Response:    Response{Status: 200},
+		Delay:       100 * time.Millisecond,
+	}))
+
+	start := time.Now()
+	res, err := http.Get(s.URL() + "/slow")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.True(t, time.Since(start) >= 100*time.Millisecond, "expected the response to be delayed")
+
+	client := &http.Client{Timeout: 20 * time.Millisecond}
+	_, err = client.Get(s.URL() + "/slow")
+	assert.Error(t, err, "expected the client to time out")
+}
+
+func TestServer_Sequence(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	two := 2
+	for _, i := range []*Interaction{
+		{Description: "create a job", Request: Request{Method: "POST", Path: "/jobs"}, Response: Response{Status: 201}, Sequence: "job"},
+		{Description: "job in progress", Request: Request{Method: "GET", Path: "/jobs/1"}, Response: Response{Status: 202}, Sequence: "job", MaxCalls: &two},
+		{Description: "job complete", Request: Request{Method: "GET", Path: "/jobs/1"}, Response: Response{Status: 200}, Sequence: "job"},
+	} {
+		assert.NoError(t, s.AddInteraction(i))
+	}
+
+	get := func(method string, path string) int {
+		req, _ := http.NewRequest(method, s.URL()+path, nil)
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	assert.Equal(t, http.StatusCreated, get("POST", "/jobs"))
+	assert.Equal(t, http.StatusAccepted, get("GET", "/jobs/1"))
+	assert.Equal(t, http.StatusAccepted, get("GET", "/jobs/1"))
+	assert.Equal(t, http.StatusOK, get("GET", "/jobs/1"))
+	assert.NoError(t, s.Verify())
+
+	assert.Equal(t, http.StatusInternalServerError, get("GET", "/jobs/1"))
+	err := s.Verify()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `sequence "job" is already complete`)
+}
+
+func TestServer_SequenceOutOfOrder(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, s.AddInteraction(&Interaction{Description: "log in", Request: Request{Method: "POST", Path: "/login"}, Response: Response{Status: 200}, Sequence: "auth"}))
+	assert.NoError(t, s.AddInteraction(&Interaction{Description: "get profile", Request: Request{Method: "GET", Path: "/profile"}, Response: Response{Status: 200}, Sequence: "auth"}))
+
+	res, err := http.Get(s.URL() + "/profile")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+	err = s.Verify()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `out of sequence "auth": expected "log in" before "get profile"`)
+}
+
+func TestServer_Order(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests []string
+		problem  string
+	}{
+		{name: "in order", requests: []string{"/login", "/profile", "/login"}},
+		{name: "out of order", requests: []string{"/profile", "/login"}, problem: "requests out of order: POST /login (log in) must be requested before GET /profile (get profile)"},
+		{name: "not preceded", requests: []string{"/profile"}, problem: "requests out of order"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := startServer(t)
+			defer s.Close()
+			defer os.RemoveAll(dir)
+
+			none := 0
+			assert.NoError(t, s.AddInteraction(&Interaction{Description: "log in", Request: Request{Method: "POST", Path: "/login"}, Response: Response{Status: 200}, MinCalls: &none}))
+			assert.NoError(t, s.AddInteraction(&Interaction{Description: "get profile", Request: Request{Method: "GET", Path: "/profile"}, Response: Response{Status: 200}, PrecededBy: []string{"log in"}}))
+
+			for _, path := range tt.requests {
+				method := "GET"
+				if path == "/login" {
+					method = "POST"
+				}
+				req, _ := http.NewRequest(method, s.URL()+path, nil)
+				res, err := http.DefaultClient.Do(req)
+				assert.NoError(t, err)
+				res.Body.Close()
+				assert.Equal(t, http.StatusOK, res.StatusCode)
+			}
+
+			err := s.Verify()
+			if tt.problem == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.problem)
+		})
+	}
+}
+
+func TestServer_OrderUnknownInteraction(t *testing.T) {
+	s, dir := startServer(t)
+	defer s.Close()
+	defer os.RemoveAll(dir)
+
+	none := 0
+	assert.NoError(t, s.AddInteraction(&Interaction{Description: "get profile", Request: Request{Method: "GET", Path: "/profile"}, Response: Response{Status: 200}, PrecededBy: []string{"log in"}, MinCalls: &none}))
+	assert.EqualError(t, s.Verify(), `interaction "get profile" must be preceded by the unknown interaction "log in"`)
+}
+
+func TestStart_SpecificationVersion(t *testing.T) {
+	_, err := Start(Options{SpecificationVersion: 5})
+	assert.EqualError(t, err, "unsupported pact specification version 5, expected 1 to 4")
+}
+
+func TestServer_ForbiddenRequests(t *testing.T) {
+	users := map[string]interface{}{"json_class": "Pact::Term", "data": map[string]interface{}{"generate": "/users/1", "matcher": map[string]interface{}{"json_class": "Regexp", "o": 0, "s": "^/users/\\d+$"}}}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		problem string
+	}{
+		{name: "allowed", method: "GET", path: "/users/1"},
+		{name: "other path", method: "DELETE", path: "/groups/1"},
+		{name: "forbidden", method: "DELETE", path: "/users/2", problem: "forbidden request: DELETE /users/2 (deleting a user)"},
+		{name: "any method", method: "POST", path: "/audit", problem: "forbidden request: POST /audit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := startServer(t)
+			defer s.Close()
+			defer os.RemoveAll(dir)
+
+			none := 0
+			assert.NoError(t, s.AddInteraction(&Interaction{Description: "get user", Request: Request{Method: "GET", Path: users}, Response: Response{Status: 200}, MinCalls: &none}))
+			assert.NoError(t, s.ForbidRequest(&ForbiddenRequest{Description: "deleting a user", Request: Request{Method: "DELETE", Path: users}}))
+			status, _ := admin(t, s, "POST", "/interactions/forbidden", ForbiddenRequest{Request: Request{Path: "/audit"}})
+			assert.Equal(t, http.StatusOK, status)
+
+			req, _ := http.NewRequest(tt.method, s.URL()+tt.path, nil)
+			res, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+			res.Body.Close()
+
+			err = s.Verify()
+			switch {
+			case tt.problem != "":
+				assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.problem)
+				assert.Len(t, err.(*VerificationError).Forbidden, 1)
+				assert.Empty(t, err.(*VerificationError).Unexpected)
+			case tt.method == "GET":
+				assert.NoError(t, err)
+			default:
+				assert.Contains(t, err.Error(), "unexpected request")
+			}
+
+			s.DeleteInteractions()
+			assert.Empty(t, s.forbidden)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package mockserver
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_TLS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	s, err := Start(Options{Consumer: "Consumer", Provider: "Provider", PactDir: dir, TLS: true})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	assert.Equal(t, "https", s.Scheme)
+	assert.True(t, strings.HasPrefix(s.URL(), "https://localhost:"))
+
+	block, _ := pem.Decode(s.CertificatePEM)
+	ca, err := x509.ParseCertificate(block.Bytes)
+	assert.NoError(t, err)
+	assert.True(t, ca.IsCA)
+
+	assert.NoError(t, s.AddInteraction(&Interaction{
+		Description: "get a user",
+		Request:     Request{Method: "GET", Path: "/users/1"},
+		Response:    Response{Status: 200},
+	}))
+
+	_, err = http.Get(s.URL() + "/users/1")
+	assert.Error(t, err, "expected an untrusted certificate")
+
+	res, err := s.Client().Get(s.URL() + "/users/1")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NoError(t, s.Verify())
+}
+
+func TestServer_TLSCertificate(t *testing.T) {
+	cert, _, err := generateCertificate("127.0.0.1")
+	assert.NoError(t, err)
+
+	s, err := Start(Options{Host: "127.0.0.1", TLS: true, TLSCertificate: &cert})
+	assert.NoError(t, err)
+	defer s.Close()
+
+	block, _ := pem.Decode(s.CertificatePEM)
+	assert.Equal(t, cert.Certificate[0], block.Bytes)
+}
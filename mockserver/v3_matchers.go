@@ -0,0 +1,307 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// matcherTypeKey marks a matcher of version 3 of the specification, or
+// later, e.g. {"pact:matcher:type": "arrayContains", "variants": [...]}, as
+// written by the other Pact implementations
+const matcherTypeKey = "pact:matcher:type"
+
+// v3Class is the class matcherClass returns for the matchers marked by
+// matcherTypeKey
+const v3Class = "pact:matcher"
+
+// semverRegex matches a semantic version, as specified by semver.org
+var semverRegex = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// v3Type returns the type of a version 3 matcher
+func v3Type(m map[string]interface{}) string {
+	t, _ := m[matcherTypeKey].(string)
+	return t
+}
+
+// reifyV3 returns the example of a version 3 matcher
+func reifyV3(m map[string]interface{}) interface{} {
+	switch v3Type(m) {
+	case "arrayContains":
+		variants, _ := m["variants"].([]interface{})
+		return reify(variants)
+	case "null":
+		return reify(m["or"])
+	}
+	return reify(m["value"])
+}
+
+// compareV3 matches actual against a version 3 matcher
+func (c *comparison) compareV3(path string, m map[string]interface{}, actual interface{}, byType bool) {
+	switch t := v3Type(m); t {
+	case "arrayContains":
+		values, ok := actual.([]interface{})
+		if !ok {
+			c.mismatch(path, reifyV3(m), actual, "expected an array, got %s", jsonType(actual))
+			return
+		}
+		variants, _ := m["variants"].([]interface{})
+		for n, variant := range variants {
+			if !c.containsMatch(variant, values) {
+				c.mismatch(fmt.Sprintf("%s[*]", path), reify(variant), actual, "expected an element matching variant %d", n)
+			}
+		}
+	case "values", "eachKey":
+		values, ok := actual.(map[string]interface{})
+		if !ok {
+			c.mismatch(path, reifyV3(m), actual, "expected an object, got %s", jsonType(actual))
+			return
+		}
+		keyRules, _ := m["rules"].([]interface{})
+		template := v3Template(m)
+		for _, k := range sortedKeys(values) {
+			for _, rule := range keyRules {
+				c.compare(jsonPath(path, k), rule, k, false)
+			}
+			c.compare(jsonPath(path, k), template, values[k], true)
+		}
+	case "integer", "decimal":
+		example := reifyV3(m)
+		number, ok := actual.(float64)
+		if !ok {
+			c.mismatch(path, example, actual, "expected %s, got %s", article(t), jsonType(actual))
+			return
+		}
+		if integer := number == math.Trunc(number); integer != (t == "integer") {
+			c.mismatch(path, example, actual, "expected %s, got %v", article(t), number)
+		}
+	case "null":
+		if actual == nil {
+			return
+		}
+		if or := m["or"]; or != nil {
+			c.compare(path, or, actual, byType)
+			return
+		}
+		c.mismatch(path, nil, actual, "expected null, got %s", jsonType(actual))
+	case "date", "time", "datetime", "timestamp":
+		c.compareDateTime(path, t, m, actual)
+	case "type":
+		c.compare(path, m["value"], actual, true)
+	case "regex":
+		regex, _ := m["regex"].(string)
+		c.compareRegex(path, reifyV3(m), regex, actual)
+	case "boolean":
+		if _, ok := actual.(bool); !ok {
+			c.mismatch(path, reifyV3(m), actual, "expected a boolean, got %s", jsonType(actual))
+		}
+	case "semver":
+		value, ok := actual.(string)
+		if !ok {
+			c.mismatch(path, reifyV3(m), actual, "expected a semantic version, got %s", jsonType(actual))
+			return
+		}
+		if !semverRegex.MatchString(value) {
+			c.mismatch(path, reifyV3(m), actual, "expected %q to be a semantic version", value)
+		}
+	case "include":
+		substring, _ := m["value"].(string)
+		value, ok := actual.(string)
+		if !ok {
+			c.mismatch(path, substring, actual, "expected a string including %q, got %s", substring, jsonType(actual))
+			return
+		}
+		if !strings.Contains(value, substring) {
+			c.mismatch(path, substring, actual, "expected %q to include %q", value, substring)
+		}
+	case "notEmpty":
+		example := reifyV3(m)
+		if jsonType(example) != jsonType(actual) {
+			c.mismatch(path, example, actual, "expected %s, got %s", jsonType(example), jsonType(actual))
+			return
+		}
+		if isEmpty(actual) {
+			c.mismatch(path, example, actual, "expected %s not to be empty", describe(actual))
+		}
+	case "contentType":
+		contentType, _ := m["contentType"].(string)
+		value, ok := actual.(string)
+		if !ok {
+			c.mismatch(path, reifyV3(m), actual, "expected content of type %s, got %s", contentType, jsonType(actual))
+			return
+		}
+		if !hasContentType(value, contentType) {
+			c.mismatch(path, reifyV3(m), actual, "expected content of type %s", contentType)
+		}
+	default:
+		c.mismatch(path, reifyV3(m), actual, "unsupported matcher %q", t)
+	}
+}
+
+// article describes a type of number with its indefinite article
+func article(t string) string {
+	if t == "integer" {
+		return "an integer"
+	}
+	return "a " + t
+}
+
+// isEmpty reports whether a value is null, or an empty string, array or
+// object
+func isEmpty(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case []interface{}:
+		return len(value) == 0
+	case map[string]interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+// hasContentType reports whether content can be parsed as the content type
+func hasContentType(content string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return json.Valid([]byte(content))
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return isXML(content)
+	case strings.HasPrefix(mediaType, "text/"):
+		return utf8.ValidString(content)
+	}
+
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType([]byte(content)))
+	return detected == mediaType
+}
+
+// isXML reports whether content is a well formed XML document
+func isXML(content string) bool {
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	elements := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return elements > 0
+		}
+		if err != nil {
+			return false
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			elements++
+		}
+	}
+}
+
+// v3Template returns the template every value of an object must match, which
+// is the value of the first key of the example
+func v3Template(m map[string]interface{}) interface{} {
+	example, _ := m["value"].(map[string]interface{})
+	for _, k := range sortedKeys(example) {
+		return example[k]
+	}
+	return nil
+}
+
+// containsMatch reports whether any of the values matches expected
+func (c *comparison) containsMatch(expected interface{}, values []interface{}) bool {
+	for _, value := range values {
+		element := &comparison{allowUnexpectedKeys: c.allowUnexpectedKeys}
+		element.compare("$", expected, value, false)
+		if len(element.mismatches) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// extractV3 records the matching rule of a version 3 matcher at path
+func (r *rules) extractV3(category string, path string, m map[string]interface{}) {
+	switch t := v3Type(m); t {
+	case "arrayContains":
+		variants, _ := m["variants"].([]interface{})
+		formatted := make([]interface{}, len(variants))
+		for n, variant := range variants {
+			element := newRules()
+			element.extract(category, "$", variant)
+			rules, _ := element.format(3).(map[string]interface{})
+			if rules[category] == nil {
+				rules = map[string]interface{}{category: map[string]interface{}{}}
+			}
+			formatted[n] = map[string]interface{}{"index": n, "rules": rules[category], "generators": map[string]interface{}{}}
+		}
+		r.add(category, path, map[string]interface{}{"match": t, "variants": formatted})
+	case "values", "eachKey":
+		rule := map[string]interface{}{"match": t}
+		if t == "eachKey" {
+			keyRules, _ := m["rules"].([]interface{})
+			formatted := []interface{}{}
+			for _, keyRule := range keyRules {
+				key := newRules()
+				key.extract(category, "", keyRule)
+				if extracted := key.categories[category][""]; extracted != nil {
+					formatted = append(formatted, extracted)
+				}
+			}
+			rule["rules"] = formatted
+		}
+		r.add(category, path, rule)
+
+		// The rules of the template apply to every value
+		r.add(category, path+".*", map[string]interface{}{"match": "type"})
+		r.extract(category, path+".*", v3Template(m))
+	case "integer", "decimal", "boolean", "semver":
+		r.add(category, path, map[string]interface{}{"match": t})
+	case "null":
+		if m["or"] == nil {
+			r.add(category, path, map[string]interface{}{"match": t})
+			return
+		}
+
+		// A value matching the other matcher, or null
+		r.extract(category, path, m["or"])
+		r.add(category, path, map[string]interface{}{})
+		rule := r.categories[category][path]
+		if len(rule) == 0 {
+			rule = map[string]interface{}{"match": "equality"}
+		}
+		r.categories[category][path] = map[string]interface{}{
+			"combine":  "OR",
+			"matchers": []interface{}{rule, map[string]interface{}{"match": t}},
+		}
+	case "date", "time", "datetime", "timestamp":
+		rule := map[string]interface{}{"match": t}
+		if format, ok := m["format"]; ok {
+			rule["format"] = format
+		}
+		r.add(category, path, rule)
+	case "type":
+		r.add(category, path, map[string]interface{}{"match": t})
+		r.extract(category, path, m["value"])
+	case "regex":
+		r.add(category, path, map[string]interface{}{"match": t, "regex": m["regex"]})
+	case "include":
+		r.add(category, path, map[string]interface{}{"match": t, "value": m["value"]})
+	case "notEmpty":
+		r.add(category, path, map[string]interface{}{"match": t})
+		if class, _ := matcherClass(m["value"]); class == "" {
+			r.extract(category, path, m["value"])
+		}
+	case "contentType":
+		r.add(category, path, map[string]interface{}{"match": t, "value": m["contentType"]})
+	}
+}
@@ -0,0 +1,310 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReifyV3_ArrayContains(t *testing.T) {
+	expected := decode(t, `{"links": {"pact:matcher:type": "arrayContains", "variants": [
+		{"rel": "self", "href": {"json_class": "Pact::SomethingLike", "contents": "/orders/1"}},
+		{"rel": "payment"}
+	]}}`)
+
+	assert.Equal(t, decode(t, `{"links": [{"rel": "self", "href": "/orders/1"}, {"rel": "payment"}]}`), reify(expected))
+}
+
+func TestComparison_CompareArrayContains(t *testing.T) {
+	expected := decode(t, `{"pact:matcher:type": "arrayContains", "variants": [
+		{"rel": "self", "href": {"json_class": "Pact::SomethingLike", "contents": "/orders/1"}},
+		{"rel": "payment"}
+	]}`)
+
+	tests := []struct {
+		name   string
+		actual string
+		paths  []string
+	}{
+		{"in order", `[{"rel": "self", "href": "/orders/2"}, {"rel": "payment"}]`, nil},
+		{"any order, other elements", `[{"rel": "next"}, {"rel": "payment"}, {"rel": "self", "href": "/orders/2"}]`, nil},
+		{"missing variant", `[{"rel": "self", "href": "/orders/2"}]`, []string{"$[*]"}},
+		{"variant not matched", `[{"rel": "self", "href": 2}, {"rel": "payment"}]`, []string{"$[*]"}},
+		{"not an array", `{"rel": "self"}`, []string{"$"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &comparison{}
+			c.compare("$", expected, decode(t, tt.actual), false)
+
+			var paths []string
+			for _, m := range c.mismatches {
+				paths = append(paths, m.Path)
+			}
+			assert.Equal(t, tt.paths, paths)
+		})
+	}
+}
+
+func TestComparison_CompareUnsupportedV3Matcher(t *testing.T) {
+	c := &comparison{}
+	c.compare("$", decode(t, `{"pact:matcher:type": "unknown", "value": 1}`), 1.0, false)
+	assert.Len(t, c.mismatches, 1)
+	assert.Equal(t, `unsupported matcher "unknown"`, c.mismatches[0].Reason)
+}
+
+func TestRules_ExtractArrayContains(t *testing.T) {
+	r := newRules()
+	r.extract("body", "$", decode(t, `{"links": {"pact:matcher:type": "arrayContains", "variants": [
+		{"rel": "self", "href": {"json_class": "Pact::SomethingLike", "contents": "/orders/1"}},
+		{"rel": "payment"}
+	]}}`))
+
+	formatted, _ := json.Marshal(r.format(3))
+	assert.JSONEq(t, `{"body": {"$.links": {"matchers": [{"match": "arrayContains", "variants": [
+		{"index": 0, "rules": {"$.href": {"matchers": [{"match": "type"}]}}, "generators": {}},
+		{"index": 1, "rules": {}, "generators": {}}
+	]}]}}}`, string(formatted))
+}
+
+func TestComparison_CompareEachKey(t *testing.T) {
+	values := decode(t, `{"pact:matcher:type": "values", "value": {"1234": {"total": 9.99}}}`)
+	eachKey := decode(t, `{"pact:matcher:type": "eachKey", "value": {"1234": {"total": 9.99}}, "rules": [
+		{"json_class": "Pact::Term", "data": {"generate": "1234", "matcher": {"json_class": "Regexp", "o": 0, "s": "^\\d+$"}}}
+	]}`)
+
+	tests := []struct {
+		name     string
+		expected interface{}
+		actual   string
+		paths    []string
+	}{
+		{"values", values, `{"1": {"total": 1.5}, "abc": {"total": 3}}`, nil},
+		{"empty", values, `{}`, nil},
+		{"value type", values, `{"1": {"total": "1.5"}}`, []string{"$['1'].total"}},
+		{"not an object", values, `[]`, []string{"$"}},
+		{"keys", eachKey, `{"1": {"total": 1.5}, "2": {"total": 3}}`, nil},
+		{"key mismatch", eachKey, `{"1": {"total": 1.5}, "abc": {"total": 3}}`, []string{"$.abc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &comparison{}
+			c.compare("$", tt.expected, decode(t, tt.actual), false)
+
+			var paths []string
+			for _, m := range c.mismatches {
+				paths = append(paths, m.Path)
+			}
+			assert.Equal(t, tt.paths, paths)
+		})
+	}
+
+	assert.Equal(t, decode(t, `{"1234": {"total": 9.99}}`), reify(eachKey))
+}
+
+func TestRules_ExtractEachKey(t *testing.T) {
+	r := newRules()
+	r.extract("body", "$", decode(t, `{"orders": {"pact:matcher:type": "eachKey", "value": {"1234": {"id": {"json_class": "Pact::SomethingLike", "contents": 1}}}, "rules": [
+		{"json_class": "Pact::Term", "data": {"generate": "1234", "matcher": {"json_class": "Regexp", "o": 0, "s": "^\\d+$"}}}
+	]}}`))
+
+	formatted, _ := json.Marshal(r.format(3))
+	assert.JSONEq(t, `{"body": {
+		"$.orders": {"matchers": [{"match": "eachKey", "rules": [{"match": "regex", "regex": "^\\d+$"}]}]},
+		"$.orders.*": {"matchers": [{"match": "type"}]},
+		"$.orders.*.id": {"matchers": [{"match": "type"}]}
+	}}`, string(formatted))
+}
+
+func TestComparison_CompareStringMatchers(t *testing.T) {
+	include := decode(t, `{"pact:matcher:type": "include", "value": "world"}`)
+	notEmpty := decode(t, `{"pact:matcher:type": "notEmpty", "value": ["tag"]}`)
+	xmlContent := decode(t, `{"pact:matcher:type": "contentType", "contentType": "application/xml", "value": "<a/>"}`)
+	jsonContent := decode(t, `{"pact:matcher:type": "contentType", "contentType": "application/json; charset=utf-8", "value": "{}"}`)
+	png := decode(t, `{"pact:matcher:type": "contentType", "contentType": "image/png", "value": ""}`)
+
+	tests := []struct {
+		name     string
+		expected interface{}
+		actual   interface{}
+		matches  bool
+	}{
+		{"includes", include, "hello world", true},
+		{"doesn't include", include, "hello", false},
+		{"include a number", include, 1.0, false},
+		{"not empty", notEmpty, []interface{}{"a"}, true},
+		{"empty", notEmpty, []interface{}{}, false},
+		{"not empty of another type", notEmpty, "a", false},
+		{"xml", xmlContent, `<order id="1"><total>9.99</total></order>`, true},
+		{"not xml", xmlContent, `{"id": 1}`, false},
+		{"json", jsonContent, `{"id": 1}`, true},
+		{"not json", jsonContent, `<order/>`, false},
+		{"png", png, "\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR", true},
+		{"not png", png, "GIF89a", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &comparison{}
+			c.compare("$", tt.expected, tt.actual, false)
+			assert.Equal(t, tt.matches, len(c.mismatches) == 0, "%v", c.mismatches)
+		})
+	}
+}
+
+func TestRules_ExtractStringMatchers(t *testing.T) {
+	r := newRules()
+	r.extract("body", "$", decode(t, `{
+		"greeting": {"pact:matcher:type": "include", "value": "world"},
+		"tags": {"pact:matcher:type": "notEmpty", "value": [{"json_class": "Pact::SomethingLike", "contents": "tag"}]},
+		"document": {"pact:matcher:type": "contentType", "contentType": "application/xml", "value": "<a/>"}
+	}`))
+
+	formatted, _ := json.Marshal(r.format(3))
+	assert.JSONEq(t, `{"body": {
+		"$.greeting": {"matchers": [{"match": "include", "value": "world"}]},
+		"$.tags": {"matchers": [{"match": "notEmpty"}]},
+		"$.tags[0]": {"matchers": [{"match": "type"}]},
+		"$.document": {"matchers": [{"match": "contentType", "value": "application/xml"}]}
+	}}`, string(formatted))
+}
+
+func TestComparison_CompareNumberMatchers(t *testing.T) {
+	integer := decode(t, `{"pact:matcher:type": "integer", "value": 42}`)
+	decimal := decode(t, `{"pact:matcher:type": "decimal", "value": 42.5}`)
+
+	tests := []struct {
+		name     string
+		expected interface{}
+		actual   interface{}
+		reason   string
+	}{
+		{"integer", integer, 10.0, ""},
+		{"not an integer", integer, 10.5, "expected an integer, got 10.5"},
+		{"integer string", integer, "10", "expected an integer, got a string"},
+		{"decimal", decimal, 10.5, ""},
+		{"not a decimal", decimal, 10.0, "expected a decimal, got 10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &comparison{}
+			c.compare("$", tt.expected, tt.actual, false)
+
+			var reason string
+			if len(c.mismatches) > 0 {
+				reason = c.mismatches[0].Reason
+			}
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+}
+
+func TestRules_ExtractNumberMatchers(t *testing.T) {
+	r := newRules()
+	r.extract("body", "$", decode(t, `{
+		"quantity": {"pact:matcher:type": "integer", "value": 42},
+		"total": {"pact:matcher:type": "decimal", "value": 42.5}
+	}`))
+
+	formatted, _ := json.Marshal(r.format(3))
+	assert.JSONEq(t, `{"body": {
+		"$.quantity": {"matchers": [{"match": "integer"}]},
+		"$.total": {"matchers": [{"match": "decimal"}]}
+	}}`, string(formatted))
+}
+
+func TestComparison_CompareNull(t *testing.T) {
+	null := decode(t, `{"pact:matcher:type": "null"}`)
+	nullable := decode(t, `{"pact:matcher:type": "null", "or": {"json_class": "Pact::SomethingLike", "contents": "2020-01-01"}}`)
+
+	tests := []struct {
+		name     string
+		expected interface{}
+		actual   interface{}
+		reason   string
+	}{
+		{"null", null, nil, ""},
+		{"not null", null, "a", "expected null, got a string"},
+		{"nullable null", nullable, nil, ""},
+		{"nullable value", nullable, "2021-06-30", ""},
+		{"nullable mismatch", nullable, 1.0, "expected a string, got a number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &comparison{}
+			c.compare("$", tt.expected, tt.actual, false)
+
+			var reason string
+			if len(c.mismatches) > 0 {
+				reason = c.mismatches[0].Reason
+			}
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+
+	assert.Equal(t, "2020-01-01", reify(nullable))
+	assert.Nil(t, reify(null))
+}
+
+func TestRules_ExtractNull(t *testing.T) {
+	r := newRules()
+	r.extract("body", "$", decode(t, `{
+		"deletedAt": {"pact:matcher:type": "null"},
+		"cancelledAt": {"pact:matcher:type": "null", "or": {"json_class": "Pact::SomethingLike", "contents": "2020-01-01"}},
+		"reason": {"pact:matcher:type": "null", "or": "expired"}
+	}`))
+
+	formatted, _ := json.Marshal(r.format(3))
+	assert.JSONEq(t, `{"body": {
+		"$.deletedAt": {"matchers": [{"match": "null"}]},
+		"$.cancelledAt": {"combine": "OR", "matchers": [{"match": "type"}, {"match": "null"}]},
+		"$.reason": {"combine": "OR", "matchers": [{"match": "equality"}, {"match": "null"}]}
+	}}`, string(formatted))
+}
+
+func TestComparison_CompareSemver(t *testing.T) {
+	expected := decode(t, `{"pact:matcher:type": "semver", "value": "1.0.0"}`)
+
+	for actual, matches := range map[string]bool{
+		"2.10.3":             true,
+		"1.0.0-rc.1+build.5": true,
+		"1.0":                false,
+		"01.0.0":             false,
+		"v1.0.0":             false,
+	} {
+		c := &comparison{}
+		c.compare("$", expected, actual, false)
+		assert.Equal(t, matches, len(c.mismatches) == 0, actual)
+	}
+
+	r := newRules()
+	r.extract("body", "$.version", expected)
+	assert.Equal(t, map[string]interface{}{"match": "semver"}, r.categories["body"]["$.version"])
+}
+
+func TestComparison_CompareBoolean(t *testing.T) {
+	expected := decode(t, `{"pact:matcher:type": "boolean", "value": true, "pact:generator:type": "RandomBoolean"}`)
+
+	for _, actual := range []interface{}{true, false} {
+		c := &comparison{}
+		c.compare("$", expected, actual, false)
+		assert.Empty(t, c.mismatches)
+	}
+
+	c := &comparison{}
+	c.compare("$", expected, "true", false)
+	assert.Len(t, c.mismatches, 1)
+	assert.Equal(t, "expected a boolean, got a string", c.mismatches[0].Reason)
+
+	r := newRules()
+	r.extract("body", "$.active", expected)
+	formatted, _ := json.Marshal(r.format(3))
+	assert.JSONEq(t, `{"body": {"$.active": {"matchers": [{"match": "boolean"}]}}}`, string(formatted))
+	generators, _ := json.Marshal(r.formatGenerators(3))
+	assert.JSONEq(t, `{"body": {"$.active": {"type": "RandomBoolean"}}}`, string(generators))
+}
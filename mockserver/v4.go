@@ -0,0 +1,231 @@
+package mockserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Types of the interactions in a version 4 pact
+const (
+	httpInteractionType    = "Synchronous/HTTP"
+	messageInteractionType = "Asynchronous/Messages"
+)
+
+// Message is an asynchronous message, which is combined with the HTTP
+// interactions of a version 4 pact. Values may contain matchers, like those
+// of an Interaction.
+type Message struct {
+	// Description of the message, unique within a pact
+	Description string `json:"description"`
+
+	// ProviderStates the provider must be in to produce the message
+	ProviderStates []ProviderState `json:"providerStates,omitempty"`
+
+	// Contents of the message
+	Contents interface{} `json:"contents"`
+
+	// Metadata of the message, e.g. its topic or content type
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+
+	// Comments about the message, in markdown, and the name of the test that
+	// defines it
+	Comments []string `json:"comments,omitempty"`
+	TestName string   `json:"testName,omitempty"`
+
+	// Pending messages don't fail the verification of the provider until
+	// they have been implemented
+	Pending bool `json:"pending,omitempty"`
+}
+
+// key identifies a message in a pact
+func (m *Message) key() string {
+	var state string
+	if len(m.ProviderStates) > 0 {
+		state = m.ProviderStates[0].Name
+	}
+	return m.Description + "\x00" + state
+}
+
+// WriteMessage merges a message into the version 4 pact between consumer
+// and provider in dir, alongside the HTTP interactions of the pact
+func WriteMessage(dir string, consumer string, provider string, message *Message) error {
+	_, err := writePact(dir, consumer, provider, 4, "merge", nil, message)
+	return err
+}
+
+// Reify replaces the matchers in JSON content with their examples
+func Reify(content []byte) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(content, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(reify(v))
+}
+
+type v4Interaction struct {
+	Type           string                 `json:"type"`
+	Key            string                 `json:"key,omitempty"`
+	Description    string                 `json:"description"`
+	ProviderStates []ProviderState        `json:"providerStates,omitempty"`
+	Request        *v4Request             `json:"request,omitempty"`
+	Response       *v4Response            `json:"response,omitempty"`
+	Contents       *v4Body                `json:"contents,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	MatchingRules  interface{}            `json:"matchingRules,omitempty"`
+	Generators     interface{}            `json:"generators,omitempty"`
+	Pending        bool                   `json:"pending"`
+	Comments       *v4Comments            `json:"comments,omitempty"`
+}
+
+type v4Request struct {
+	Method        string              `json:"method"`
+	Path          string              `json:"path"`
+	Query         interface{}         `json:"query,omitempty"`
+	Headers       map[string][]string `json:"headers,omitempty"`
+	Body          *v4Body             `json:"body,omitempty"`
+	MatchingRules interface{}         `json:"matchingRules,omitempty"`
+	Generators    interface{}         `json:"generators,omitempty"`
+}
+
+type v4Response struct {
+	Status        int                 `json:"status"`
+	Headers       map[string][]string `json:"headers,omitempty"`
+	Body          *v4Body             `json:"body,omitempty"`
+	MatchingRules interface{}         `json:"matchingRules,omitempty"`
+	Generators    interface{}         `json:"generators,omitempty"`
+}
+
+type v4Body struct {
+	Content     interface{} `json:"content"`
+	ContentType string      `json:"contentType"`
+	Encoded     bool        `json:"encoded"`
+}
+
+type v4Comments struct {
+	Text     []string `json:"text,omitempty"`
+	TestName string   `json:"testname,omitempty"`
+}
+
+// serializeV4Interaction converts an interaction to the format of a version
+// 4 pact
+func serializeV4Interaction(i *Interaction) ([]byte, error) {
+	v3 := serializeInteraction(i, 4)
+
+	request := &v4Request{
+		Method:        v3.Request.Method,
+		Path:          v3.Request.Path,
+		Query:         v3.Request.Query,
+		Headers:       v4Headers(v3.Request.Headers),
+		MatchingRules: v3.Request.MatchingRules,
+		Generators:    v3.Request.Generators,
+	}
+	request.Body = v4BodyOf(v3.Request.Body, request.Headers)
+
+	response := &v4Response{
+		Status:        v3.Response.Status,
+		Headers:       v4Headers(v3.Response.Headers),
+		MatchingRules: v3.Response.MatchingRules,
+		Generators:    v3.Response.Generators,
+	}
+	response.Body = v4BodyOf(v3.Response.Body, response.Headers)
+
+	return marshalV4(v4Interaction{
+		Type:           httpInteractionType,
+		Description:    i.Description,
+		ProviderStates: v3.ProviderStates,
+		Request:        request,
+		Response:       response,
+	}, i.Pending, i.Comments, i.TestName)
+}
+
+// serializeV4Message converts a message to the format of a version 4 pact
+func serializeV4Message(m *Message) ([]byte, error) {
+	rules := newRules()
+	rules.extract("body", "$", m.Contents)
+	for k, v := range m.Metadata {
+		rules.extract("metadata", k, v)
+	}
+
+	var metadata map[string]interface{}
+	if len(m.Metadata) > 0 {
+		metadata = reify(m.Metadata).(map[string]interface{})
+	}
+
+	contentType := "application/json"
+	if t, ok := metadata["contentType"].(string); ok {
+		contentType = t
+	}
+
+	return marshalV4(v4Interaction{
+		Type:           messageInteractionType,
+		Description:    m.Description,
+		ProviderStates: m.ProviderStates,
+		Contents:       &v4Body{Content: reify(m.Contents), ContentType: contentType},
+		Metadata:       metadata,
+		MatchingRules:  rules.format(4),
+		Generators:     rules.formatGenerators(4),
+	}, m.Pending, m.Comments, m.TestName)
+}
+
+// marshalV4 encodes an interaction with its key, which is derived from its
+// content so that it's stable across runs
+func marshalV4(i v4Interaction, pending bool, comments []string, testName string) ([]byte, error) {
+	content, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+
+	i.Key = hex.EncodeToString(sum[:8])
+	i.Pending = pending
+	if len(comments) > 0 || testName != "" {
+		i.Comments = &v4Comments{Text: comments, TestName: testName}
+	}
+
+	return json.Marshal(i)
+}
+
+// v4Headers lists the values of each header, as version 4 pacts do
+func v4Headers(headers map[string]interface{}) map[string][]string {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	listed := make(map[string][]string, len(headers))
+	for k, v := range headers {
+		if values, ok := v.([]interface{}); ok {
+			for _, e := range values {
+				listed[k] = append(listed[k], fmt.Sprint(e))
+			}
+			continue
+		}
+		listed[k] = []string{fmt.Sprint(v)}
+	}
+	return listed
+}
+
+// v4BodyOf describes a body with its content type, taken from the
+// Content-Type header if there is one
+func v4BodyOf(body interface{}, headers map[string][]string) *v4Body {
+	if body == nil {
+		return nil
+	}
+
+	var contentType string
+	for k, values := range headers {
+		if http.CanonicalHeaderKey(k) == "Content-Type" && len(values) > 0 {
+			contentType = values[0]
+		}
+	}
+	if contentType == "" {
+		contentType = "application/json"
+		if _, ok := body.(string); ok {
+			contentType = "text/plain"
+		}
+	}
+
+	return &v4Body{Content: body, ContentType: contentType}
+}
@@ -0,0 +1,136 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testMessage(t *testing.T, description string) *Message {
+	var m Message
+	err := json.Unmarshal([]byte(`{
+		"description": "`+description+`",
+		"providerStates": [{"name": "a user exists", "params": {"id": 1}}],
+		"contents": {"name": {"json_class": "Pact::SomethingLike", "contents": "Billy"}},
+		"metadata": {"topic": "users"}
+	}`), &m)
+	assert.NoError(t, err)
+	return &m
+}
+
+func TestWritePact_V4(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	i := testInteraction(t, "get a user")
+	i.Comments = []string{"See the *users* API"}
+	i.TestName = "TestUsers"
+	i.Pending = true
+
+	_, err := writePact(dir, "Consumer", "Provider", 4, "overwrite", []*Interaction{i}, testMessage(t, "a user created event"))
+	assert.NoError(t, err)
+
+	pact := readPact(t, filepath.Join(dir, "consumer-provider.json"))
+	assert.Equal(t, "4.0", pact["metadata"].(map[string]interface{})["pactSpecification"].(map[string]interface{})["version"])
+
+	interactions := pact["interactions"].([]interface{})
+	assert.Len(t, interactions, 2)
+
+	message := interactions[0].(map[string]interface{})
+	assert.Equal(t, "Asynchronous/Messages", message["type"])
+	assert.Len(t, message["key"], 16)
+	assert.Equal(t, false, message["pending"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "a user exists", "params": map[string]interface{}{"id": 1.0}}}, message["providerStates"])
+	assert.Equal(t, map[string]interface{}{"content": map[string]interface{}{"name": "Billy"}, "contentType": "application/json", "encoded": false}, message["contents"])
+	assert.Equal(t, map[string]interface{}{"topic": "users"}, message["metadata"])
+	assert.Equal(t, map[string]interface{}{"body": map[string]interface{}{"$.name": map[string]interface{}{"matchers": []interface{}{map[string]interface{}{"match": "type"}}}}}, message["matchingRules"])
+
+	http := interactions[1].(map[string]interface{})
+	request := http["request"].(map[string]interface{})
+	response := http["response"].(map[string]interface{})
+	assert.Equal(t, "Synchronous/HTTP", http["type"])
+	assert.Len(t, http["key"], 16)
+	assert.Equal(t, true, http["pending"])
+	assert.Equal(t, map[string]interface{}{"text": []interface{}{"See the *users* API"}, "testname": "TestUsers"}, http["comments"])
+	assert.Equal(t, "GET", request["method"])
+	assert.Equal(t, "/users/1", request["path"])
+	assert.Equal(t, map[string]interface{}{"fields": []interface{}{"name"}}, request["query"])
+	assert.Nil(t, request["body"])
+	assert.Equal(t, map[string]interface{}{"Content-Type": []interface{}{"application/json"}}, response["headers"])
+	assert.Equal(t, map[string]interface{}{"content": map[string]interface{}{"name": "Billy"}, "contentType": "application/json", "encoded": false}, response["body"])
+	assert.Contains(t, response["matchingRules"], "body")
+}
+
+func TestWritePact_V4Keys(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	key := func(i *Interaction) string {
+		content, err := serializeV4Interaction(i)
+		assert.NoError(t, err)
+		var serialized v4Interaction
+		assert.NoError(t, json.Unmarshal(content, &serialized))
+		return serialized.Key
+	}
+
+	i := testInteraction(t, "get a user")
+	commented := testInteraction(t, "get a user")
+	commented.Comments = []string{"a comment"}
+	commented.Pending = true
+	other := testInteraction(t, "get a user")
+	other.Response.Status = 404
+
+	assert.Equal(t, key(i), key(testInteraction(t, "get a user")))
+	assert.Equal(t, key(i), key(commented), "comments and pending flags shouldn't change the key")
+	assert.NotEqual(t, key(i), key(other))
+}
+
+func TestWritePact_V4BodyContentType(t *testing.T) {
+	i := testInteraction(t, "get a user")
+	i.Response.Headers = nil
+	i.Response.Body = "Billy"
+	i.Request.Headers = map[string]interface{}{"content-type": "application/xml"}
+	i.Request.Body = "<user/>"
+
+	content, err := serializeV4Interaction(i)
+	assert.NoError(t, err)
+
+	var serialized v4Interaction
+	assert.NoError(t, json.Unmarshal(content, &serialized))
+	assert.Equal(t, "application/xml", serialized.Request.Body.ContentType)
+	assert.Equal(t, "text/plain", serialized.Response.Body.ContentType)
+}
+
+func TestWriteMessage(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mockserver")
+	defer os.RemoveAll(dir)
+
+	_, err := writePact(dir, "Consumer", "Provider", 4, "overwrite", []*Interaction{testInteraction(t, "get a user")})
+	assert.NoError(t, err)
+	assert.NoError(t, WriteMessage(dir, "Consumer", "Provider", testMessage(t, "a user created event")))
+
+	// Later writes of the HTTP interactions keep the message
+	_, err = writePact(dir, "Consumer", "Provider", 4, "overwrite", []*Interaction{testInteraction(t, "get a user")})
+	assert.NoError(t, err)
+
+	interactions := readPact(t, filepath.Join(dir, "consumer-provider.json"))["interactions"].([]interface{})
+	assert.Len(t, interactions, 2)
+}
+
+func TestWritePact_MessagesBeforeV4(t *testing.T) {
+	_, err := writePact(".", "Consumer", "Provider", 3, "merge", nil, testMessage(t, "a user created event"))
+	assert.EqualError(t, err, "messages can only be written to a version 4 pact, not version 3")
+}
+
+func TestReifyJSON(t *testing.T) {
+	reified, err := Reify([]byte(`{"name": {"json_class": "Pact::SomethingLike", "contents": "Billy"}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name": "Billy"}`, string(reified))
+
+	_, err = Reify([]byte(`{`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package mockserver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VerificationError is returned when the requests received by the mock server
+// don't match its interactions
+type VerificationError struct {
+	// Missing interactions, which weren't requested
+	Missing []*Interaction `json:"missing,omitempty"`
+
+	// Unexpected requests, which didn't resemble any interaction
+	Unexpected []*ReceivedRequest `json:"unexpected,omitempty"`
+
+	// Incorrect requests, with the method and path of an interaction but
+	// which didn't match it
+	Incorrect []IncorrectRequest `json:"incorrect,omitempty"`
+
+	// Calls of the interactions received too few or too many times
+	Calls []CallCount `json:"calls,omitempty"`
+
+	// Order of the interactions first requested out of order
+	Order []OrderMismatch `json:"order,omitempty"`
+
+	// Forbidden requests which were received
+	Forbidden []ForbiddenCall `json:"forbidden,omitempty"`
+}
+
+// OrderMismatch is an interaction first requested before an interaction it
+// must be preceded by
+type OrderMismatch struct {
+	// Interaction which must be requested first
+	Before *Interaction `json:"before"`
+
+	// Interaction which was requested first
+	After *Interaction `json:"after"`
+}
+
+// CallCount is the number of times an interaction was received, when outside
+// of its MinCalls and MaxCalls
+type CallCount struct {
+	Interaction *Interaction `json:"interaction"`
+	Calls       int          `json:"calls"`
+}
+
+// IncorrectRequest is a request resembling an interaction, which didn't match
+type IncorrectRequest struct {
+	// Request received
+	Request *ReceivedRequest `json:"request"`
+
+	// Interaction the request resembles
+	Interaction *Interaction `json:"interaction"`
+
+	// Mismatches between the request and the interaction
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+func (e *VerificationError) Error() string {
+	var problems []string
+	for _, i := range e.Missing {
+		problems = append(problems, fmt.Sprintf("missing request: %s (%s)", i, i.Description))
+	}
+	for _, r := range e.Unexpected {
+		problems = append(problems, fmt.Sprintf("unexpected request: %s", r))
+	}
+	for _, r := range e.Incorrect {
+		reasons := make([]string, len(r.Mismatches))
+		for i, m := range r.Mismatches {
+			reasons[i] = m.String()
+		}
+		problems = append(problems, fmt.Sprintf("incorrect request: %s (%s): %s", r.Request, r.Interaction.Description, strings.Join(reasons, "; ")))
+	}
+
+	for _, c := range e.Calls {
+		problems = append(problems, fmt.Sprintf("wrong number of requests: %s (%s) was received %d times, expected %s", c.Interaction, c.Interaction.Description, c.Calls, c.Interaction.expectedCalls()))
+	}
+
+	for _, o := range e.Order {
+		problems = append(problems, fmt.Sprintf("requests out of order: %s (%s) must be requested before %s (%s)", o.Before, o.Before.Description, o.After, o.After.Description))
+	}
+
+	for _, f := range e.Forbidden {
+		if f.Forbidden.Description != "" {
+			problems = append(problems, fmt.Sprintf("forbidden request: %s (%s)", f.Request, f.Forbidden.Description))
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("forbidden request: %s", f.Request))
+	}
+
+	return fmt.Sprintf("pact verification failed:\n\t%s", strings.Join(problems, "\n\t"))
+}
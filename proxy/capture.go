@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Exchange is a single request/response pair that passed through the proxy
+type Exchange struct {
+	// Request is the request as it was received by the middleware
+	Request *http.Request
+
+	// RequestBody is a copy of the request body
+	RequestBody []byte
+
+	// StatusCode is the status returned by the downstream handler
+	StatusCode int
+
+	// ResponseHeader contains the headers returned by the downstream handler
+	ResponseHeader http.Header
+
+	// ResponseBody is a copy of the body returned by the downstream handler
+	ResponseBody []byte
+
+	// Duration is the time taken by the downstream handler to respond
+	Duration time.Duration
+}
+
+// Observer is notified of each exchange passing through the proxy, after the
+// response has been written. Observers must not modify the exchange.
+type Observer interface {
+	Observe(*Exchange)
+}
+
+// ObserverFunc adapts a function to the Observer interface
+type ObserverFunc func(*Exchange)
+
+// Observe calls f(e)
+func (f ObserverFunc) Observe(e *Exchange) {
+	f(e)
+}
+
+// CaptureMiddleware records each request and response passing through it,
+// handing the completed Exchange to the given function once the downstream
+// handler has returned. The request body is buffered and restored so that
+// later handlers are unaffected.
+func CaptureMiddleware(fn func(*Exchange)) Middleware {
+	return capture(fn, true)
+}
+
+// CaptureHeadersMiddleware records each request and response passing through
+// it like CaptureMiddleware, without copying the bodies. Bodies are streamed
+// through untouched, keeping memory use flat for large payloads.
+func CaptureHeadersMiddleware(fn func(*Exchange)) Middleware {
+	return capture(fn, false)
+}
+
+func capture(fn func(*Exchange), bodies bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			exchange := &Exchange{
+				Request: r,
+			}
+
+			if bodies && r.Body != nil {
+				body, err := ioutil.ReadAll(r.Body)
+				r.Body.Close()
+				if err == nil {
+					exchange.RequestBody = body
+				}
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, discardBody: !bodies}
+			start := time.Now()
+			next.ServeHTTP(rec, r)
+			exchange.Duration = time.Since(start)
+
+			exchange.StatusCode = rec.status()
+			exchange.ResponseHeader = w.Header()
+			if bodies {
+				exchange.ResponseBody = rec.body.Bytes()
+			}
+
+			fn(exchange)
+		})
+	}
+}
+
+// responseRecorder wraps an http.ResponseWriter, keeping a copy
+// of the status code and body written to it
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	body        bytes.Buffer
+	discardBody bool
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 {
+		r.statusCode = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	if !r.discardBody {
+		r.body.Write(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying writer supports it
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying writer supports it,
+// allowing upgraded connections such as WebSockets to pass through
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("proxy: response writer of type %T does not support hijacking", r.ResponseWriter)
+	}
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer, for use by http.ResponseController
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *responseRecorder) status() int {
+	if r.statusCode == 0 {
+		return http.StatusOK
+	}
+	return r.statusCode
+}
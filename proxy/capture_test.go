@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCaptureMiddleware(t *testing.T) {
+	var captured *Exchange
+
+	req, err := http.NewRequest("POST", "/users", strings.NewReader(`{"name":"billy"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	target := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != `{"name":"billy"}` {
+			t.Errorf("expected request body to be passed through but got '%s'", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	})
+
+	CaptureMiddleware(func(e *Exchange) {
+		captured = e
+	})(target).ServeHTTP(rr, req)
+
+	if captured == nil {
+		t.Fatal("expected exchange to be captured")
+	}
+
+	if string(captured.RequestBody) != `{"name":"billy"}` {
+		t.Errorf("want request body '%s', got '%s'", `{"name":"billy"}`, captured.RequestBody)
+	}
+
+	if captured.StatusCode != http.StatusCreated {
+		t.Errorf("want status 201, got %d", captured.StatusCode)
+	}
+
+	if string(captured.ResponseBody) != `{"id":1}` {
+		t.Errorf("want response body '%s', got '%s'", `{"id":1}`, captured.ResponseBody)
+	}
+
+	if captured.ResponseHeader.Get("Content-Type") != "application/json" {
+		t.Errorf("want response Content-Type header, got '%v'", captured.ResponseHeader)
+	}
+
+	if rr.Body.String() != `{"id":1}` {
+		t.Errorf("expected response to be written to the client, got '%s'", rr.Body.String())
+	}
+}
+
+func TestCaptureHeadersMiddleware(t *testing.T) {
+	var captured *Exchange
+
+	req, err := http.NewRequest("POST", "/users", strings.NewReader(`{"name":"billy"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	target := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != `{"name":"billy"}` {
+			t.Errorf("expected request body to be passed through but got '%s'", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	})
+
+	CaptureHeadersMiddleware(func(e *Exchange) {
+		captured = e
+	})(target).ServeHTTP(rr, req)
+
+	if captured == nil {
+		t.Fatal("expected exchange to be captured")
+	}
+
+	if captured.StatusCode != http.StatusCreated {
+		t.Errorf("want status 201, got %d", captured.StatusCode)
+	}
+
+	if captured.RequestBody != nil || captured.ResponseBody != nil {
+		t.Errorf("want bodies not to be captured, got '%s' and '%s'", captured.RequestBody, captured.ResponseBody)
+	}
+
+	if rr.Body.String() != `{"id":1}` {
+		t.Errorf("expected response to be written to the client, got '%s'", rr.Body.String())
+	}
+}
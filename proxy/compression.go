@@ -0,0 +1,221 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type compressionContextKey struct{}
+
+// compressionState records the encodings removed from a proxied exchange,
+// so they can be restored on the way out
+type compressionState struct {
+	requestEncoding  string
+	responseEncoding string
+}
+
+// compressionMiddleware decompresses gzip and deflate request bodies before
+// they reach the rest of the middleware chain, and compresses the response
+// again after the chain, if it was compressed by the provider
+func compressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := &compressionState{}
+
+		if encoding := contentEncoding(r.Header); encoding != "" && r.Body != nil {
+			body, err := decompress(encoding, r.Body)
+			r.Body.Close()
+			if err != nil {
+				log.Println("[ERROR] unable to decompress request body:", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Println("[DEBUG] decompressed", encoding, "request body")
+
+			state.requestEncoding = encoding
+			setBody(r.Header, body)
+			r.ContentLength = int64(len(body))
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		cw := &compressingWriter{ResponseWriter: w, state: state}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r.WithContext(context.WithValue(r.Context(), compressionContextKey{}, state)))
+	})
+}
+
+// compressRequest restores the original encoding of a request body on its
+// way to the provider
+func compressRequest(r *http.Request) {
+	state, ok := r.Context().Value(compressionContextKey{}).(*compressionState)
+	if !ok || state.requestEncoding == "" || r.Body == nil {
+		return
+	}
+
+	plain, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		log.Println("[ERROR] unable to read request body:", err)
+		return
+	}
+
+	body, err := compress(state.requestEncoding, plain)
+	if err != nil {
+		log.Println("[ERROR] unable to compress request body:", err)
+		body = plain
+	} else {
+		r.Header.Set("Content-Encoding", state.requestEncoding)
+	}
+
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+}
+
+// decompressResponse removes the encoding of a provider response, so that
+// the middleware chain sees the plain body
+func decompressResponse(res *http.Response) error {
+	state, ok := res.Request.Context().Value(compressionContextKey{}).(*compressionState)
+	if !ok {
+		return nil
+	}
+
+	encoding := contentEncoding(res.Header)
+	if encoding == "" {
+		return nil
+	}
+
+	body, err := decompress(encoding, res.Body)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+	log.Println("[DEBUG] decompressed", encoding, "response body")
+
+	state.responseEncoding = encoding
+	setBody(res.Header, body)
+	res.ContentLength = int64(len(body))
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return nil
+}
+
+// compressingWriter compresses the response written to it if the provider
+// response was decompressed by the proxy
+type compressingWriter struct {
+	http.ResponseWriter
+	state       *compressionState
+	encoder     io.WriteCloser
+	wroteHeader bool
+}
+
+func (c *compressingWriter) WriteHeader(statusCode int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+
+	if c.state.responseEncoding != "" && statusCode != http.StatusNoContent && statusCode != http.StatusNotModified {
+		c.Header().Set("Content-Encoding", c.state.responseEncoding)
+		c.Header().Del("Content-Length")
+
+		if c.state.responseEncoding == "gzip" {
+			c.encoder = gzip.NewWriter(c.ResponseWriter)
+		} else {
+			c.encoder = zlib.NewWriter(c.ResponseWriter)
+		}
+	}
+
+	c.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (c *compressingWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.encoder != nil {
+		return c.encoder.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying writer supports it
+func (c *compressingWriter) Flush() {
+	if f, ok := c.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer, for use by http.ResponseController
+func (c *compressingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Close flushes any compressed data to the underlying writer
+func (c *compressingWriter) Close() error {
+	if c.encoder == nil {
+		return nil
+	}
+	return c.encoder.Close()
+}
+
+// contentEncoding returns the supported encoding of a message, if any
+func contentEncoding(header http.Header) string {
+	encoding := strings.ToLower(strings.TrimSpace(header.Get("Content-Encoding")))
+	if encoding == "gzip" || encoding == "deflate" {
+		return encoding
+	}
+	return ""
+}
+
+// setBody updates the headers of a message for its decompressed body
+func setBody(header http.Header, body []byte) {
+	header.Del("Content-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+}
+
+func decompress(encoding string, r io.Reader) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	if encoding == "gzip" {
+		reader, err = gzip.NewReader(r)
+	} else {
+		reader, err = zlib.NewReader(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
+
+func compress(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	if encoding == "gzip" {
+		writer = gzip.NewWriter(&buf)
+	} else {
+		writer = zlib.NewWriter(&buf)
+	}
+
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
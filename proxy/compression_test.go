@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStartHTTPReverseProxy_DecompressBodies(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("want request to be compressed again for the target, got encoding %q", r.Header.Get("Content-Encoding"))
+		}
+		body, err := decompress("gzip", r.Body)
+		if err != nil || string(body) != `{"name":"billy"}` {
+			t.Errorf("want target to receive the original body, got '%s' (%v)", body, err)
+		}
+
+		res, _ := compress("gzip", []byte(`{"id":1}`))
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(res)
+	}))
+	defer target.Close()
+
+	var captured *Exchange
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:     "http",
+		TargetAddress:    target.Listener.Addr().String(),
+		DecompressBodies: true,
+		Middleware: []Middleware{CaptureMiddleware(func(e *Exchange) {
+			captured = e
+		})},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	body, _ := compress("gzip", []byte(`{"name":"billy"}`))
+	req, _ := http.NewRequest("POST", fmt.Sprintf("http://localhost:%d/users", server.Port), bytes.NewReader(body))
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer res.Body.Close()
+
+	if captured == nil {
+		t.Fatal("expected exchange to be captured")
+	}
+	if string(captured.RequestBody) != `{"name":"billy"}` {
+		t.Errorf("want middleware to see the decompressed request body, got '%s'", captured.RequestBody)
+	}
+	if string(captured.ResponseBody) != `{"id":1}` {
+		t.Errorf("want middleware to see the decompressed response body, got '%s'", captured.ResponseBody)
+	}
+
+	if res.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("want response to be compressed again, got encoding %q", res.Header.Get("Content-Encoding"))
+	}
+	resBody, err := decompress("gzip", res.Body)
+	if err != nil || string(resBody) != `{"id":1}` {
+		t.Errorf("want client to receive the original response, got '%s' (%v)", resBody, err)
+	}
+}
+
+func TestCompressionMiddleware_InvalidBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "/users", bytes.NewReader([]byte("not gzip")))
+	req.Header.Set("Content-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected request to be rejected")
+	})).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("want status 400, got %d", rr.Code)
+	}
+}
+
+func TestCompressDeflate(t *testing.T) {
+	body, err := compress("deflate", []byte("hello"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	res, err := decompress("deflate", ioutil.NopCloser(bytes.NewReader(body)))
+	if err != nil || string(res) != "hello" {
+		t.Errorf("want 'hello', got '%s' (%v)", res, err)
+	}
+}
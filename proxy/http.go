@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"log"
@@ -8,10 +9,15 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pact-foundation/pact-go/utils"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // Middleware is a way to use composition to add functionality
@@ -20,6 +26,12 @@ import (
 // http.Handler, allowing a simple way to chain functionality together
 type Middleware func(http.Handler) http.Handler
 
+// ResponseFilter is called with each response from the target, before it is
+// returned through the Middleware chain, and may modify it in place. When the
+// body is replaced, ContentLength and the Content-Length header must be
+// updated to match (or removed). Returning an error responds with a 502.
+type ResponseFilter func(*http.Response) error
+
 // Options for the Reverse Proxy configuration
 type Options struct {
 
@@ -32,19 +44,127 @@ type Options struct {
 	// TargetPath is the path on the target to proxy
 	TargetPath string
 
+	// TargetSocket is the path of a unix domain socket the target listens on,
+	// instead of connecting to the TargetAddress. The TargetAddress is still
+	// used for the Host header, and defaults to localhost.
+	TargetSocket string
+
 	// ProxyPort is the port to make available for proxying
 	// Defaults to a random port
 	ProxyPort int
 
+	// ProxyPortRange is an inclusive range of ports from which the first
+	// free port is used, when ProxyPort isn't set. e.g. [2]int{8000, 8010}
+	ProxyPortRange [2]int
+
+	// ProxyBindAddress is the address of the interface the proxy listens on,
+	// e.g. 127.0.0.1. Defaults to all interfaces.
+	ProxyBindAddress string
+
 	// Middleware to apply to the Proxy
 	Middleware []Middleware
 
 	// Internal request prefix for proxy to not rewrite
 	InternalRequestPathPrefix string
 
+	// InternalHandler serves requests under the InternalRequestPathPrefix that
+	// weren't handled by the Middleware, e.g. health checks or debug endpoints.
+	// Defaults to responding with a 404.
+	InternalHandler http.Handler
+
 	// Custom TLS Configuration for communicating with a Provider
 	// Useful when verifying self-signed services, MASSL etc.
 	CustomTLSConfig *tls.Config
+
+	// Transport used for proxy-to-provider traffic, e.g. a tuned *http.Transport
+	// or an instrumented RoundTripper. CustomTLSConfig is not applied to it.
+	// Defaults to a transport shared by all requests to the provider.
+	Transport http.RoundTripper
+
+	// HTTP2 enables HTTP/2 over cleartext (h2c) for providers that only speak
+	// HTTP/2, both for requests to an http provider and on the proxy listener.
+	// HTTP/2 is always negotiated with https providers that support it.
+	HTTP2 bool
+
+	// DecompressBodies transparently decompresses gzip and deflate encoded
+	// request and response bodies before they reach the Middleware, so that
+	// filters can inspect and modify them. Bodies are compressed again before
+	// they leave the proxy.
+	DecompressBodies bool
+
+	// PreserveHostHeader sends the Host header of the incoming request to the
+	// target, rather than the target address. Useful for providers that route
+	// requests by virtual host.
+	PreserveHostHeader bool
+
+	// ForwardedHeaders adds X-Forwarded-Proto and X-Forwarded-Host headers to
+	// requests sent to the target, as a load balancer would. X-Forwarded-For is
+	// always set to the address of the client.
+	ForwardedHeaders *ForwardedHeaders
+
+	// ReadTimeout is the maximum duration for reading an entire request,
+	// including the body. Defaults to no timeout.
+	ReadTimeout time.Duration
+
+	// WriteTimeout is the maximum duration before timing out writes of the
+	// response, including the time taken by the target. Defaults to no timeout.
+	WriteTimeout time.Duration
+
+	// IdleTimeout is the maximum time to wait for the next request on a
+	// keep-alive connection. Defaults to the ReadTimeout.
+	IdleTimeout time.Duration
+
+	// ResponseHeaderTimeout is the maximum time to wait for the target's
+	// response headers after sending the request. Not applied to a custom
+	// Transport, or to http providers with HTTP2. Defaults to no timeout.
+	ResponseHeaderTimeout time.Duration
+
+	// ResponseFilter is applied to each response from the target, after the
+	// body has been decompressed if DecompressBodies is set
+	ResponseFilter ResponseFilter
+
+	// Observer is notified of every exchange with the target, seeing the
+	// request as sent after the Middleware and the response after any
+	// ResponseFilter. Useful to log or capture traffic without modifying it.
+	Observer Observer
+
+	// TLS serves the proxy over https, for pacts that are scheme sensitive.
+	// A self-signed certificate is generated unless TLSCertificate is given.
+	TLS bool
+
+	// TLSCertificate is the certificate served by the proxy when TLS is set
+	TLSCertificate *tls.Certificate
+}
+
+// ForwardedHeaders configures the X-Forwarded-* headers sent to the target
+type ForwardedHeaders struct {
+	// Proto is the value of the X-Forwarded-Proto header, e.g. https.
+	// Defaults to the scheme of the request received by the proxy.
+	Proto string
+
+	// Host is the value of the X-Forwarded-Host header.
+	// Defaults to the Host of the request received by the proxy.
+	Host string
+}
+
+// apply sets the X-Forwarded-* headers on a request, before it is rewritten
+// for the target
+func (f *ForwardedHeaders) apply(req *http.Request) {
+	proto := f.Proto
+	if proto == "" {
+		proto = "http"
+		if req.TLS != nil {
+			proto = "https"
+		}
+	}
+
+	host := f.Host
+	if host == "" {
+		host = req.Host
+	}
+
+	req.Header.Set("X-Forwarded-Proto", proto)
+	req.Header.Set("X-Forwarded-Host", host)
 }
 
 // loggingMiddleware logs requests to the proxy
@@ -55,6 +175,24 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// RecoveryMiddleware recovers from a panic in the handlers it wraps, such as
+// a user supplied request filter or state handler, logging the stack trace
+// and responding with a 500 so that only the current interaction fails
+func RecoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				log.Printf("[ERROR] recovered from panic handling request to %s: %v\n%s", r.URL.Path, err, debug.Stack())
+				http.Error(w, fmt.Sprintf("pact-go proxy: panic handling request: %v", err), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
 // chainHandlers takes a set of middleware and joins them together
 // into a single Middleware, making it much simpler to compose middleware
 // together
@@ -70,92 +208,325 @@ func chainHandlers(mw ...Middleware) Middleware {
 	}
 }
 
+// Server is a running reverse proxy
+type Server struct {
+	// Port the proxy is listening on
+	Port int
+
+	// Scheme the proxy is served with, http or https
+	Scheme string
+
+	// CertificatePEM is the PEM encoded certificate served by the proxy when
+	// TLS is enabled, so that clients can be configured to trust it
+	CertificatePEM []byte
+
+	server *http.Server
+}
+
+// Shutdown gracefully stops the proxy, waiting for in-flight requests to
+// complete until the context is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	log.Println("[DEBUG] shutting down reverse proxy on port", s.Port)
+	return s.server.Shutdown(ctx)
+}
+
+// Close immediately stops the proxy, closing any active connections
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
 // HTTPReverseProxy provides a default setup for proxying
-// internal components within the framework
+// internal components within the framework. The proxy runs until the
+// process exits, use StartHTTPReverseProxy to be able to stop it.
 func HTTPReverseProxy(options Options) (int, error) {
+	server, err := StartHTTPReverseProxy(options)
+	if err != nil {
+		return 0, err
+	}
+
+	return server.Port, nil
+}
+
+// StartHTTPReverseProxy starts a reverse proxy, returning a handle
+// that can be used to shut it down
+func StartHTTPReverseProxy(options Options) (*Server, error) {
 	log.Println("[DEBUG] starting new proxy with opts", options)
 	port := options.ProxyPort
 	var err error
 
+	if options.TargetSocket != "" && options.TargetAddress == "" {
+		options.TargetAddress = "localhost"
+	}
+
 	url := &url.URL{
 		Scheme: options.TargetScheme,
 		Host:   options.TargetAddress,
 		Path:   options.TargetPath,
 	}
 
-	proxy := createProxy(url, options.InternalRequestPathPrefix)
-	proxy.Transport = customTransport{tlsConfig: options.CustomTLSConfig}
+	proxy := createProxy(url, options.InternalRequestPathPrefix, options.PreserveHostHeader, options.ForwardedHeaders)
+	transport := options.Transport
+	if transport == nil && options.HTTP2 && options.TargetScheme != "https" {
+		transport = h2cTransport(options.TargetSocket)
+	} else if transport == nil {
+		t := defaultTransport(options.CustomTLSConfig, options.TargetSocket)
+		t.ResponseHeaderTimeout = options.ResponseHeaderTimeout
+		transport = t
+	}
+	proxy.Transport = customTransport{transport: transport}
+	proxy.ErrorHandler = errorHandler
+	proxy.BufferPool = bufferPool{}
+
+	if port == 0 && options.ProxyPortRange != [2]int{} {
+		port, err = utils.FindPortInRange(fmt.Sprintf("%d-%d", options.ProxyPortRange[0], options.ProxyPortRange[1]))
+		if err != nil {
+			log.Println("[ERROR] unable to find a free port in range for reverse proxy server:", err)
+			return nil, err
+		}
+	}
 
 	if port == 0 {
 		port, err = utils.GetFreePort()
 		if err != nil {
 			log.Println("[ERROR] unable to start reverse proxy server:", err)
-			return 0, err
+			return nil, err
+		}
+	}
+
+	middleware := append(options.Middleware, loggingMiddleware)
+	if options.Observer != nil {
+		middleware = append(middleware, CaptureMiddleware(options.Observer.Observe))
+	}
+	if options.DecompressBodies {
+		director := proxy.Director
+		proxy.Director = func(r *http.Request) {
+			director(r)
+			compressRequest(r)
 		}
+		proxy.ModifyResponse = decompressResponse
+		middleware = append([]Middleware{compressionMiddleware}, middleware...)
+	}
+	if options.ResponseFilter != nil {
+		proxy.ModifyResponse = filterResponse(proxy.ModifyResponse, options.ResponseFilter)
+	}
+	wrapper := chainHandlers(append([]Middleware{RecoveryMiddleware}, middleware...)...)
+
+	bindAddress := strings.TrimSuffix(strings.TrimPrefix(options.ProxyBindAddress, "["), "]")
+	ln, err := net.Listen("tcp", net.JoinHostPort(bindAddress, strconv.Itoa(port)))
+	if err != nil {
+		log.Println("[ERROR] unable to start reverse proxy server:", err)
+		return nil, err
 	}
 
-	wrapper := chainHandlers(append(options.Middleware, loggingMiddleware)...)
+	var handler http.Handler = proxy
+	if options.InternalRequestPathPrefix != "" {
+		handler = internalRouter(options.InternalRequestPathPrefix, options.InternalHandler, proxy)
+	}
+	handler = wrapper(handler)
+	if options.HTTP2 {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	server := &Server{
+		Port:   port,
+		Scheme: "http",
+		server: &http.Server{
+			Handler:      handler,
+			ReadTimeout:  options.ReadTimeout,
+			WriteTimeout: options.WriteTimeout,
+			IdleTimeout:  options.IdleTimeout,
+		},
+	}
+
+	if options.TLS {
+		if err := server.configureTLS(options.TLSCertificate, bindAddress); err != nil {
+			ln.Close()
+			log.Println("[ERROR] unable to configure TLS for reverse proxy server:", err)
+			return nil, err
+		}
+
+		log.Println("[DEBUG] starting reverse proxy with TLS on", ln.Addr())
+		go server.server.ServeTLS(ln, "", "")
+		return server, nil
+	}
 
-	log.Println("[DEBUG] starting reverse proxy on port", port)
-	go http.ListenAndServe(fmt.Sprintf(":%d", port), wrapper(proxy))
+	log.Println("[DEBUG] starting reverse proxy on", ln.Addr())
+	go server.server.Serve(ln)
 
-	return port, nil
+	return server, nil
 }
 
 // https://stackoverflow.com/questions/52986853/how-to-debug-httputil-newsinglehostreverseproxy
 // Set the proxy.Transport field to an implementation that dumps the request before delegating to the default transport:
 
 type customTransport struct {
-	tlsConfig *tls.Config
+	transport http.RoundTripper
 }
 
-func (c customTransport) RoundTrip(r *http.Request) (*http.Response, error) {
-	b, err := httputil.DumpRequestOut(r, false)
-	if err != nil {
-		return nil, err
-	}
-	log.Println("[TRACE] proxy outgoing request\n", string(b))
+// defaultTransport creates the transport used to communicate with the provider
+// when no custom transport is given, connecting to the socket if set
+func defaultTransport(tlsConfig *tls.Config, socket string) *http.Transport {
+	dialer := providerDialer()
 
 	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 
-	if c.tlsConfig != nil {
+	if socket != "" {
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	}
+
+	if tlsConfig != nil {
 		log.Println("[DEBUG] applying custom TLS config")
-		transport.TLSClientConfig = c.tlsConfig
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	// A custom dialer or TLS config disables HTTP/2 unless it is configured
+	if err := http2.ConfigureTransport(transport); err != nil {
+		log.Println("[WARN] unable to enable HTTP/2 for https providers:", err)
+	}
+
+	return transport
+}
+
+// h2cTransport creates the transport used to communicate with http providers
+// over HTTP/2. Providers that only speak h2c don't support an upgrade from
+// HTTP/1.1, so it connects with prior knowledge, to the socket if set.
+func h2cTransport(socket string) *http2.Transport {
+	dialer := providerDialer()
+
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network string, addr string, _ *tls.Config) (net.Conn, error) {
+			if socket != "" {
+				return dialer.Dial("unix", socket)
+			}
+			return dialer.Dial(network, addr)
+		},
+	}
+}
+
+// providerDialer creates the dialer of connections to the provider
+func providerDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		DualStack: true,
+	}
+}
+
+func (c customTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	b, err := httputil.DumpRequestOut(r, false)
+	if err != nil {
+		return nil, err
 	}
-	var DefaultTransport http.RoundTripper = transport
+	log.Println("[TRACE] proxy outgoing request\n", string(b))
 
-	res, err := DefaultTransport.RoundTrip(r)
+	res, err := c.transport.RoundTrip(r)
 	if err != nil {
 		log.Println("[ERROR]", err)
 		return nil, err
 	}
-	b, err = httputil.DumpResponse(res, true)
+	// Only the headers are dumped, so that the body is streamed to the client
+	// rather than buffered. This also leaves the body of an upgraded connection,
+	// e.g. a WebSocket, untouched.
+	b, err = httputil.DumpResponse(res, false)
 	log.Println("[TRACE] proxied server response\n", string(b))
 
 	return res, err
 }
 
+// internalRouter sends internal requests that reach the end of the middleware
+// chain to the internal handler, rather than proxying them, and everything
+// else to the target
+func internalRouter(prefix string, internal http.Handler, target http.Handler) http.Handler {
+	if internal == nil {
+		internal = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			log.Println("[WARN] no handler for internal proxy request", r.URL.Path)
+			http.Error(w, fmt.Sprintf("pact-go proxy: no handler for internal path %s", r.URL.Path), http.StatusNotFound)
+		})
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			internal.ServeHTTP(w, r)
+			return
+		}
+		target.ServeHTTP(w, r)
+	})
+}
+
+// filterResponse applies the filter to each response from the target, after
+// any existing response modifier. Upgraded connections are not filtered.
+func filterResponse(modify func(*http.Response) error, filter ResponseFilter) func(*http.Response) error {
+	return func(res *http.Response) error {
+		if modify != nil {
+			if err := modify(res); err != nil {
+				return err
+			}
+		}
+		if res.StatusCode == http.StatusSwitchingProtocols {
+			return nil
+		}
+		return filter(res)
+	}
+}
+
+// errorHandler responds to a failed request to the target, describing the
+// cause so that it appears in the verification output
+func errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	log.Println("[ERROR] http reverse proxy error:", err)
+
+	status := http.StatusBadGateway
+	if netErr, ok := err.(net.Error); (ok && netErr.Timeout()) || err == context.DeadlineExceeded {
+		status = http.StatusGatewayTimeout
+	}
+
+	http.Error(w, fmt.Sprintf("pact-go proxy: error proxying request to the provider: %v", err), status)
+}
+
+// copyBuffers are reused when copying bodies between the client and target
+var copyBuffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 32*1024)
+		return &b
+	},
+}
+
+// bufferPool implements httputil.BufferPool with fixed size buffers
+type bufferPool struct{}
+
+func (bufferPool) Get() []byte {
+	return *copyBuffers.Get().(*[]byte)
+}
+
+func (bufferPool) Put(b []byte) {
+	copyBuffers.Put(&b)
+}
+
 // Adapted from https://github.com/golang/go/blob/master/src/net/http/httputil/reverseproxy.go
-func createProxy(target *url.URL, ignorePrefix string) *httputil.ReverseProxy {
+func createProxy(target *url.URL, ignorePrefix string, preserveHost bool, forwarded *ForwardedHeaders) *httputil.ReverseProxy {
 	targetQuery := target.RawQuery
 	director := func(req *http.Request) {
-		if !strings.HasPrefix(req.URL.Path, ignorePrefix) {
+		if ignorePrefix == "" || !strings.HasPrefix(req.URL.Path, ignorePrefix) {
 			log.Println("[DEBUG] setting proxy to target")
 			log.Println("[DEBUG] incoming request", req.URL)
+			if forwarded != nil {
+				forwarded.apply(req)
+			}
 			req.URL.Scheme = target.Scheme
 			req.URL.Host = target.Host
-			req.Host = target.Host
+			if !preserveHost {
+				req.Host = target.Host
+			}
 
 			req.URL.Path = singleJoiningSlash(target.Path, req.URL.Path)
 			log.Println("[DEBUG] outgoing request to target", req.URL)
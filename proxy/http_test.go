@@ -1,10 +1,25 @@
 package proxy
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/pact-foundation/pact-go/utils"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func dummyHandler(header string) http.HandlerFunc {
@@ -38,6 +53,26 @@ func TestLoggingMiddleware(t *testing.T) {
 	}
 }
 
+func TestRecoveryMiddleware(t *testing.T) {
+	req, err := http.NewRequest("GET", "/x", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("filter failed")
+	})).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("want status 500, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "filter failed") {
+		t.Errorf("want the panic in the response, got '%s'", rr.Body.String())
+	}
+}
+
 func TestChainHandlers(t *testing.T) {
 	req, err := http.NewRequest("GET", "/health-check", nil)
 	if err != nil {
@@ -89,3 +124,604 @@ func TestHTTPReverseProxy(t *testing.T) {
 		t.Errorf("want non-zero port, got %v", port)
 	}
 }
+
+func TestHTTPReverseProxy_PortRange(t *testing.T) {
+	lower, err := utils.GetFreePort()
+	if err != nil {
+		t.Fatalf("unable to find a free port: %v", err)
+	}
+
+	port, err := HTTPReverseProxy(Options{
+		TargetScheme:   "http",
+		TargetAddress:  "127.0.0.1:1234",
+		ProxyPortRange: [2]int{lower, lower + 5},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if port < lower || port > lower+5 {
+		t.Errorf("want port in range %d-%d, got %v", lower, lower+5, port)
+	}
+}
+
+func TestHTTPReverseProxy_PortInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer ln.Close()
+
+	_, err = HTTPReverseProxy(Options{
+		TargetScheme:  "http",
+		TargetAddress: "127.0.0.1:1234",
+		ProxyPort:     ln.Addr().(*net.TCPAddr).Port,
+	})
+
+	if err == nil {
+		t.Errorf("want error when the proxy port is in use")
+	}
+}
+
+func TestHTTPReverseProxy_BindAddress(t *testing.T) {
+	port, err := HTTPReverseProxy(Options{
+		TargetScheme:     "http",
+		TargetAddress:    "127.0.0.1:1234",
+		ProxyBindAddress: "127.0.0.1",
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatalf("want proxy to be listening on 127.0.0.1, got %v", err)
+	}
+	conn.Close()
+}
+
+func TestStartHTTPReverseProxy_Shutdown(t *testing.T) {
+	target := httptest.NewServer(dummyHandler("X-Target"))
+	defer target.Close()
+
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:  "http",
+		TargetAddress: target.Listener.Addr().String(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	res, err := http.Get(fmt.Sprintf("http://localhost:%d/", server.Port))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	res.Body.Close()
+	if res.Header.Get("X-Target") != "true" {
+		t.Errorf("want request to be proxied to the target")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", server.Port)); err == nil {
+		t.Errorf("want proxy port to be released after shutdown")
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestStartHTTPReverseProxy_Transport(t *testing.T) {
+	target := httptest.NewServer(dummyHandler("X-Target"))
+	defer target.Close()
+
+	transport := &countingTransport{}
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:  "http",
+		TargetAddress: target.Listener.Addr().String(),
+		Transport:     transport,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	res, err := http.Get(fmt.Sprintf("http://localhost:%d/", server.Port))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	res.Body.Close()
+
+	if res.Header.Get("X-Target") != "true" {
+		t.Errorf("want request to be proxied to the target")
+	}
+	if transport.requests != 1 {
+		t.Errorf("want 1 request through the custom transport, got %d", transport.requests)
+	}
+}
+
+func TestStartHTTPReverseProxy_HTTP2(t *testing.T) {
+	target := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Target-Proto", r.Proto)
+	}), &http2.Server{}))
+	defer target.Close()
+
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:  "http",
+		TargetAddress: target.Listener.Addr().String(),
+		HTTP2:         true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network string, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+
+	res, err := client.Get(fmt.Sprintf("http://localhost:%d/", server.Port))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	res.Body.Close()
+
+	if res.ProtoMajor != 2 {
+		t.Errorf("want proxy to accept h2c, got %s", res.Proto)
+	}
+	if res.Header.Get("X-Target-Proto") != "HTTP/2.0" {
+		t.Errorf("want request to reach the target over HTTP/2, got %q", res.Header.Get("X-Target-Proto"))
+	}
+}
+
+func TestStartHTTPReverseProxy_Upgrade(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			t.Errorf("want Upgrade header to be passed to the target, got %q", r.Header.Get("Upgrade"))
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+			return
+		}
+		defer conn.Close()
+
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+		buf.Flush()
+
+		msg, err := buf.ReadString('\n')
+		if err == nil {
+			buf.WriteString("echo " + msg)
+			buf.Flush()
+		}
+	}))
+	defer target.Close()
+
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:  "http",
+		TargetAddress: target.Listener.Addr().String(),
+		Middleware:    []Middleware{CaptureMiddleware(func(*Exchange) {})},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", server.Port))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprint(conn, "GET /ws HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("want status 101, got %d", res.StatusCode)
+	}
+
+	fmt.Fprint(conn, "ping\n")
+	msg, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if msg != "echo ping\n" {
+		t.Errorf("want upgraded connection to be proxied, got %q", msg)
+	}
+}
+
+func TestStartHTTPReverseProxy_PreserveHostHeader(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Target-Host", r.Host)
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		preserve bool
+		want     string
+	}{
+		{preserve: false, want: target.Listener.Addr().String()},
+		{preserve: true, want: "api.example.com"},
+	}
+	for _, tt := range tests {
+		server, err := StartHTTPReverseProxy(Options{
+			TargetScheme:       "http",
+			TargetAddress:      target.Listener.Addr().String(),
+			PreserveHostHeader: tt.preserve,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		req, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/", server.Port), nil)
+		req.Host = "api.example.com"
+		res, err := http.DefaultClient.Do(req)
+		server.Close()
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		res.Body.Close()
+
+		if host := res.Header.Get("X-Target-Host"); host != tt.want {
+			t.Errorf("want Host %q when preserve is %v, got %q", tt.want, tt.preserve, host)
+		}
+	}
+}
+
+func TestStartHTTPReverseProxy_ForwardedHeaders(t *testing.T) {
+	received := http.Header{}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range r.Header {
+			received[k] = v
+		}
+	}))
+	defer target.Close()
+
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:     "http",
+		TargetAddress:    target.Listener.Addr().String(),
+		ForwardedHeaders: &ForwardedHeaders{Proto: "https"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/", server.Port), nil)
+	req.Host = "api.example.com"
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	res.Body.Close()
+
+	if proto := received.Get("X-Forwarded-Proto"); proto != "https" {
+		t.Errorf("want X-Forwarded-Proto https, got %q", proto)
+	}
+	if host := received.Get("X-Forwarded-Host"); host != "api.example.com" {
+		t.Errorf("want X-Forwarded-Host api.example.com, got %q", host)
+	}
+	if ip := received.Get("X-Forwarded-For"); ip == "" {
+		t.Errorf("want X-Forwarded-For to be set")
+	}
+}
+
+func TestStartHTTPReverseProxy_ResponseHeaderTimeout(t *testing.T) {
+	done := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer target.Close()
+	defer close(done)
+
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:          "http",
+		TargetAddress:         target.Listener.Addr().String(),
+		ResponseHeaderTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	res, err := http.Get(fmt.Sprintf("http://localhost:%d/", server.Port))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("want status 504, got %d", res.StatusCode)
+	}
+}
+
+func TestStartHTTPReverseProxy_TargetUnavailable(t *testing.T) {
+	port, _ := utils.GetFreePort()
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:  "http",
+		TargetAddress: fmt.Sprintf("localhost:%d", port),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	res, err := http.Get(fmt.Sprintf("http://localhost:%d/", server.Port))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("want status 502, got %d", res.StatusCode)
+	}
+	if !strings.Contains(string(body), "error proxying request to the provider") {
+		t.Errorf("want the cause of the error in the response, got '%s'", body)
+	}
+}
+
+func TestStartHTTPReverseProxy_TargetSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pact-go")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "app.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	target := &http.Server{Handler: dummyHandler("X-Target")}
+	go target.Serve(ln)
+	defer target.Close()
+
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme: "http",
+		TargetSocket: socket,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	res, err := http.Get(fmt.Sprintf("http://localhost:%d/", server.Port))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	res.Body.Close()
+
+	if res.Header.Get("X-Target") != "true" {
+		t.Errorf("want request to be proxied to the unix socket, got status %d", res.StatusCode)
+	}
+}
+
+func TestStartHTTPReverseProxy_ResponseFilter(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "1234")
+		w.Write([]byte(`{"host":"internal.example.com"}`))
+	}))
+	defer target.Close()
+
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:  "http",
+		TargetAddress: target.Listener.Addr().String(),
+		ResponseFilter: func(res *http.Response) error {
+			res.Header.Del("X-Request-Id")
+
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				return err
+			}
+			res.Body.Close()
+			body = []byte(strings.Replace(string(body), "internal.example.com", "localhost", -1))
+			res.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+			res.ContentLength = int64(len(body))
+			res.Header.Set("Content-Length", strconv.Itoa(len(body)))
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	res, err := http.Get(fmt.Sprintf("http://localhost:%d/", server.Port))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+
+	if res.Header.Get("X-Request-Id") != "" {
+		t.Errorf("want header to be removed by the filter")
+	}
+	if string(body) != `{"host":"localhost"}` {
+		t.Errorf("want body to be rewritten by the filter, got '%s'", body)
+	}
+}
+
+func TestStartHTTPReverseProxy_ResponseFilterError(t *testing.T) {
+	target := httptest.NewServer(dummyHandler("X-Target"))
+	defer target.Close()
+
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:  "http",
+		TargetAddress: target.Listener.Addr().String(),
+		ResponseFilter: func(res *http.Response) error {
+			return fmt.Errorf("filter failed")
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	res, err := http.Get(fmt.Sprintf("http://localhost:%d/", server.Port))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("want status 502, got %d", res.StatusCode)
+	}
+}
+
+func TestStartHTTPReverseProxy_Observer(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer target.Close()
+
+	exchanges := make(chan *Exchange, 1)
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:  "http",
+		TargetAddress: target.Listener.Addr().String(),
+		Observer: ObserverFunc(func(e *Exchange) {
+			exchanges <- e
+		}),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	res, err := http.Post(fmt.Sprintf("http://localhost:%d/users", server.Port), "application/json", strings.NewReader(`{"name":"billy"}`))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	res.Body.Close()
+
+	select {
+	case e := <-exchanges:
+		if e.Request.URL.Path != "/users" || string(e.RequestBody) != `{"name":"billy"}` {
+			t.Errorf("want observed request to POST /users, got %s '%s'", e.Request.URL.Path, e.RequestBody)
+		}
+		if e.StatusCode != http.StatusCreated || string(e.ResponseBody) != `{"id":1}` {
+			t.Errorf("want observed response 201 '{\"id\":1}', got %d '%s'", e.StatusCode, e.ResponseBody)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected exchange to be observed")
+	}
+}
+
+func TestStartHTTPReverseProxy_LargeBody(t *testing.T) {
+	payload := strings.Repeat("pact", 2*1024*1024)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer target.Close()
+
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:  "http",
+		TargetAddress: target.Listener.Addr().String(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	res, err := http.Post(fmt.Sprintf("http://localhost:%d/", server.Port), "text/plain", strings.NewReader(payload))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+
+	if len(body) != len(payload) || string(body) != payload {
+		t.Errorf("want %d byte body to be streamed through the proxy, got %d bytes", len(payload), len(body))
+	}
+}
+
+func TestStartHTTPReverseProxy_InternalHandler(t *testing.T) {
+	target := httptest.NewServer(dummyHandler("X-Target"))
+	defer target.Close()
+
+	internal := http.NewServeMux()
+	internal.HandleFunc("/__internal/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:              "http",
+		TargetAddress:             target.Listener.Addr().String(),
+		InternalRequestPathPrefix: "/__internal",
+		InternalHandler:           internal,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	tests := []struct {
+		path   string
+		status int
+		target bool
+	}{
+		{path: "/__internal/health", status: http.StatusOK},
+		{path: "/__internal/unknown", status: http.StatusNotFound},
+		{path: "/users", status: http.StatusOK, target: true},
+	}
+	for _, tt := range tests {
+		res, err := http.Get(fmt.Sprintf("http://localhost:%d%s", server.Port, tt.path))
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != tt.status {
+			t.Errorf("want status %d for %s, got %d", tt.status, tt.path, res.StatusCode)
+		}
+		if proxied := res.Header.Get("X-Target") == "true"; proxied != tt.target {
+			t.Errorf("want %s to be proxied to the target: %v, got %v", tt.path, tt.target, proxied)
+		}
+	}
+}
+
+func TestStartHTTPReverseProxy_DefaultInternalHandler(t *testing.T) {
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:              "http",
+		TargetAddress:             "localhost:1234",
+		InternalRequestPathPrefix: "/__internal",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	res, err := http.Get(fmt.Sprintf("http://localhost:%d/__internal/unknown", server.Port))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("want status 404, got %d", res.StatusCode)
+	}
+}
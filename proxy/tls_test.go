@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStartHTTPReverseProxy_TLS(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Forwarded-Proto-Seen", r.Header.Get("X-Forwarded-Proto"))
+	}))
+	defer target.Close()
+
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:     "http",
+		TargetAddress:    target.Listener.Addr().String(),
+		TLS:              true,
+		ForwardedHeaders: &ForwardedHeaders{},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	if server.Scheme != "https" {
+		t.Errorf("want scheme https, got %s", server.Scheme)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(server.CertificatePEM) {
+		t.Fatalf("want a PEM encoded certificate, got '%s'", server.CertificatePEM)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	for _, host := range []string{"localhost", "127.0.0.1"} {
+		res, err := client.Get(fmt.Sprintf("https://%s:%d/", host, server.Port))
+		if err != nil {
+			t.Fatalf("unexpected error connecting to %s: %v", host, err)
+		}
+		res.Body.Close()
+
+		if proto := res.Header.Get("X-Forwarded-Proto-Seen"); proto != "https" {
+			t.Errorf("want target to see X-Forwarded-Proto https, got %q", proto)
+		}
+	}
+}
+
+func TestStartHTTPReverseProxy_TLSCertificate(t *testing.T) {
+	cert, _, err := selfSignedCertificate("api.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	server, err := StartHTTPReverseProxy(Options{
+		TargetScheme:   "http",
+		TargetAddress:  "localhost:1234",
+		TLS:            true,
+		TLSCertificate: &cert,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer server.Close()
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	conn, err := tls.Dial("tcp", fmt.Sprintf("localhost:%d", server.Port), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer conn.Close()
+
+	if served := conn.ConnectionState().PeerCertificates[0]; !served.Equal(leaf) {
+		t.Errorf("want the given certificate to be served")
+	}
+}
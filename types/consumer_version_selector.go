@@ -11,6 +11,25 @@ type ConsumerVersionSelector struct {
 	Version     string `json:"version"`
 	Latest      bool   `json:"latest"`
 	All         bool   `json:"all"`
+
+	// FallbackTag is used when no pact exists with Tag, e.g. to verify the
+	// consumer's main line when there is no pact for a matching feature tag.
+	// Requires Tag and Latest.
+	FallbackTag string `json:"fallbackTag,omitempty"`
+
+	// Branch selects the latest pact on the consumer branch
+	Branch string `json:"branch,omitempty"`
+
+	// MainBranch selects the latest pact on the consumer's main branch
+	MainBranch bool `json:"mainBranch,omitempty"`
+
+	// MatchingBranch selects the latest pact on the consumer branch with the
+	// same name as the provider branch being verified. Requires ProviderBranch.
+	MatchingBranch bool `json:"matchingBranch,omitempty"`
+
+	// FallbackBranch is used when no pact exists on Branch or the matching
+	// branch, e.g. to fall back to the consumer's main branch
+	FallbackBranch string `json:"fallbackBranch,omitempty"`
 }
 
 // Validate the selector configuration
@@ -19,13 +38,29 @@ func (c *ConsumerVersionSelector) Validate() error {
 		return fmt.Errorf("must provide a Pacticpant")
 	}
 
-	if c.Pacticipant != "" && c.Tag == "" {
-		return fmt.Errorf("must provide at least a Tag if Pacticpant specified")
+	if c.Pacticipant != "" && c.Tag == "" && c.Branch == "" && !c.MainBranch && !c.MatchingBranch {
+		return fmt.Errorf("must provide at least a Tag or Branch if Pacticpant specified")
 	}
 
 	if c.All && c.Latest {
 		return fmt.Errorf("cannot select both All and Latest")
 	}
 
+	if c.FallbackTag != "" && (c.Tag == "" || !c.Latest) {
+		return fmt.Errorf("a FallbackTag can only be used with a Tag and Latest")
+	}
+
+	if c.FallbackBranch != "" && c.Branch == "" && !c.MatchingBranch {
+		return fmt.Errorf("a FallbackBranch can only be used with a Branch or MatchingBranch")
+	}
+
+	if c.Branch != "" && (c.MainBranch || c.MatchingBranch) {
+		return fmt.Errorf("only one of Branch, MainBranch or MatchingBranch may be selected")
+	}
+
+	if c.MainBranch && c.MatchingBranch {
+		return fmt.Errorf("only one of Branch, MainBranch or MatchingBranch may be selected")
+	}
+
 	return nil
 }
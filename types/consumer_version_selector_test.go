@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,6 +19,14 @@ func TestConsumerVersionSelectorValidate(t *testing.T) {
 		{name: "pacticipant only", selector: ConsumerVersionSelector{Pacticipant: "foo"}, err: true},
 		{name: "pacticipant and tag", selector: ConsumerVersionSelector{Pacticipant: "foo", Tag: "foo"}, err: false},
 		{name: "pacticipant, tag and all set", selector: ConsumerVersionSelector{Pacticipant: "foo", Tag: "foo", All: true}, err: false},
+		{name: "pacticipant and branch", selector: ConsumerVersionSelector{Pacticipant: "foo", Branch: "main"}, err: false},
+		{name: "fallback tag", selector: ConsumerVersionSelector{Tag: "feat/foo", Latest: true, FallbackTag: "main"}, err: false},
+		{name: "fallback tag without latest", selector: ConsumerVersionSelector{Tag: "feat/foo", FallbackTag: "main"}, err: true},
+		{name: "fallback tag without tag", selector: ConsumerVersionSelector{Latest: true, FallbackTag: "main"}, err: true},
+		{name: "matching branch with fallback", selector: ConsumerVersionSelector{MatchingBranch: true, FallbackBranch: "main"}, err: false},
+		{name: "fallback branch without branch", selector: ConsumerVersionSelector{FallbackBranch: "main"}, err: true},
+		{name: "branch and main branch", selector: ConsumerVersionSelector{Branch: "feat/foo", MainBranch: true}, err: true},
+		{name: "main and matching branch", selector: ConsumerVersionSelector{MainBranch: true, MatchingBranch: true}, err: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -30,3 +39,9 @@ func TestConsumerVersionSelectorValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestConsumerVersionSelectorJSON(t *testing.T) {
+	body, err := json.Marshal(ConsumerVersionSelector{Tag: "feat/foo", Latest: true, FallbackTag: "main"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"pacticipant":"","tag":"feat/foo","version":"","latest":true,"all":false,"fallbackTag":"main"}`, string(body))
+}
@@ -1,5 +1,7 @@
 package types
 
+import "context"
+
 // StateHandler is a provider function that sets up a given state before
 // the provider interaction is validated
 type StateHandler func() error
@@ -7,6 +9,31 @@ type StateHandler func() error
 // StateHandlers is a list of StateHandler's
 type StateHandlers map[string]StateHandler
 
+// ContextStateHandler is a provider function that sets up a given state,
+// stopping when the context is done, e.g. when the StateHandlerTimeout passes
+type ContextStateHandler func(ctx context.Context) error
+
+// ContextStateHandlers is a list of ContextStateHandler's
+type ContextStateHandlers map[string]ContextStateHandler
+
+// DefaultStateHandler is a provider function that sets up any state without
+// a StateHandler of its own, given the name and parameters of the state.
+// e.g. to load fixtures by a naming convention
+type DefaultStateHandler func(state string, params map[string]interface{}) error
+
+// ContextDefaultStateHandler is a DefaultStateHandler given a context, which is
+// done when the StateHandlerTimeout passes. e.g. a CommandStateHandler
+type ContextDefaultStateHandler func(ctx context.Context, state string, params map[string]interface{}) error
+
+// PatternStateHandler is a provider function that sets up the states matching
+// a regular expression, given the groups captured from the state name and the
+// parameters of the state. e.g. "^user (\d+) exists$" is called with the user id
+type PatternStateHandler func(groups []string, params map[string]interface{}) error
+
+// PatternStateHandlers maps regular expressions to the PatternStateHandler
+// setting up states that match them
+type PatternStateHandlers map[string]PatternStateHandler
+
 // State specifies how the system should be configured when
 // verified. e.g. "user A exists"
 type State struct {
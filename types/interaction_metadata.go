@@ -0,0 +1,27 @@
+package types
+
+// InteractionMetadata describes the interaction a request to the provider belongs to.
+type InteractionMetadata struct {
+	// Provider being verified
+	Provider string
+
+	// Consumer of the pact containing the interaction
+	Consumer string
+
+	// Description of the interaction. The verifier doesn't send it when
+	// setting up provider states, so it is found in the pacts being verified
+	// once the request of the interaction is replayed, and is empty for a
+	// BeforeInteraction hook.
+	Description string
+
+	// States the provider is set up in for the interaction
+	States []string
+
+	// Timing is the request replayed against the provider, and the status
+	// and time of its response. Only set once the provider has responded,
+	// e.g. for an AfterInteraction hook.
+	Timing *InteractionTiming
+}
+
+// InteractionHook is invoked with the details of a single interaction
+type InteractionHook func(InteractionMetadata) error
@@ -7,6 +7,13 @@ type ProviderState struct {
 	Consumer string   `json:"consumer"`
 	State    string   `json:"state"`
 	States   []string `json:"states"`
+
+	// Params of the provider state, if any
+	Params map[string]interface{} `json:"params,omitempty"`
+
+	// Action is "setup", or "teardown" once the interaction is verified.
+	// Older verifiers only send setup requests, without an action.
+	Action string `json:"action,omitempty"`
 }
 
 // ProviderStates is a mapping of consumers to all known states. This is usually
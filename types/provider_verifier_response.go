@@ -1,5 +1,10 @@
 package types
 
+import (
+	"strings"
+	"time"
+)
+
 // ProviderVerifierResponse contains the output of the pact-provider-verifier
 // command.
 type ProviderVerifierResponse struct {
@@ -27,15 +32,67 @@ type ProviderVerifierResponse struct {
 		} `json:"exception,omitempty"`
 	} `json:"examples"`
 	Summary struct {
-		Duration                     float64 `json:"duration"`
-		ExampleCount                 int     `json:"example_count"`
-		FailureCount                 int     `json:"failure_count"`
-		PendingCount                 int     `json:"pending_count"`
-		ErrorsOutsideOfExamplesCount int     `json:"errors_outside_of_examples_count"`
-		Notices                      []struct {
-			Text string `json:"text"`
-			When string `json:"when"`
-		} `json:"notices"`
+		Duration                     float64              `json:"duration"`
+		ExampleCount                 int                  `json:"example_count"`
+		FailureCount                 int                  `json:"failure_count"`
+		PendingCount                 int                  `json:"pending_count"`
+		ErrorsOutsideOfExamplesCount int                  `json:"errors_outside_of_examples_count"`
+		Notices                      []VerificationNotice `json:"notices"`
 	} `json:"summary"`
 	SummaryLine string `json:"summary_line"`
+
+	// Timings records how long the provider took to respond to each
+	// interaction replayed for this pact
+	Timings []InteractionTiming `json:"-"`
+}
+
+// VerificationNotice is a message from the broker explaining why a pact was
+// selected for verification, its pending status, or what the consequences
+// of the verification result are
+type VerificationNotice struct {
+	// Text of the notice
+	Text string `json:"text"`
+
+	// When the notice applies, e.g. "before_verification" or
+	// "after_verification:success_false_published_true"
+	When string `json:"when"`
+}
+
+// Before returns true if the notice should be displayed prior to verification
+func (n VerificationNotice) Before() bool {
+	return n.When == "before_verification"
+}
+
+// After returns true if the notice should be displayed after verification
+func (n VerificationNotice) After() bool {
+	return strings.HasPrefix(n.When, "after_verification")
+}
+
+// InteractionTiming is the provider response time for a single
+// interaction replayed during verification
+type InteractionTiming struct {
+	// Consumer whose pact contained the interaction, if known
+	Consumer string
+
+	// Description of the interaction, if known
+	Description string
+
+	// States are the provider states set up prior to the interaction
+	States []string
+
+	// Method of the replayed request
+	Method string
+
+	// Path of the replayed request
+	Path string
+
+	// Status returned by the provider
+	Status int
+
+	// Duration is the time taken by the provider to respond
+	Duration time.Duration
+
+	// ExceededSLO is set when Duration is greater than the configured
+	// SLOThreshold for the verification
+	ExceededSLO bool
 }
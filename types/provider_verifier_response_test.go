@@ -0,0 +1,26 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerificationNotice(t *testing.T) {
+	var res ProviderVerifierResponse
+	err := json.Unmarshal([]byte(`{"summary":{"notices":[
+		{"when":"before_verification","text":"pact selected as latest for tag main"},
+		{"when":"after_verification:success_false_published_true","text":"this pact is pending"}
+	]}}`), &res)
+	if err != nil {
+		t.Fatalf("unable to parse response: %v", err)
+	}
+
+	notices := res.Summary.Notices
+	assert.Len(t, notices, 2)
+	assert.True(t, notices[0].Before())
+	assert.False(t, notices[0].After())
+	assert.True(t, notices[1].After())
+	assert.False(t, notices[1].Before())
+}
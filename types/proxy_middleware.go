@@ -0,0 +1,23 @@
+package types
+
+import "github.com/pact-foundation/pact-go/proxy"
+
+// Priorities of the built-in verification proxy middleware. Middleware with a
+// lower priority wraps, and so runs before, middleware with a higher priority.
+const (
+	PriorityBeforeEach       = 100
+	PriorityAfterEach        = 200
+	PriorityInteractionHooks = 250
+	PriorityStateHandlers    = 300
+	PriorityRequestFilter    = 400
+	PriorityExchangeLog      = 500
+)
+
+// ProxyMiddleware is a middleware to insert into the verification proxy chain
+// at the given priority, relative to the built-in middleware. e.g. a Priority of
+// PriorityStateHandlers-1 runs just before the state handlers. Middleware with the
+// same priority as a built-in runs after it.
+type ProxyMiddleware struct {
+	Priority   int
+	Middleware proxy.Middleware
+}
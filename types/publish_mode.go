@@ -0,0 +1,42 @@
+package types
+
+import "github.com/pact-foundation/pact-go/utils"
+
+// PublishMode controls when verification results are published to the broker
+type PublishMode string
+
+const (
+	// PublishModeDefault publishes according to PublishVerificationResults.
+	// CI servers aren't detected unless PublishModeCI is set: the flag has
+	// always defaulted to not publishing, and detecting CI by default would
+	// start publishing from the CI builds of providers that never opted in.
+	PublishModeDefault PublishMode = ""
+
+	// PublishModeCI publishes results only when running on a CI server,
+	// so results are never published from a developer's machine
+	PublishModeCI PublishMode = "ci"
+
+	// PublishModeAlways publishes results wherever the verification runs
+	PublishModeAlways PublishMode = "always"
+
+	// PublishModeNever never publishes results
+	PublishModeNever PublishMode = "never"
+)
+
+// isCI detects CI environments, and may be replaced in tests
+var isCI = utils.IsCI
+
+// Publish returns true if results should be published, given the value
+// of the PublishVerificationResults flag
+func (m PublishMode) Publish(publishVerificationResults bool) bool {
+	switch m {
+	case PublishModeCI:
+		return isCI()
+	case PublishModeAlways:
+		return true
+	case PublishModeNever:
+		return false
+	}
+
+	return publishVerificationResults
+}
@@ -0,0 +1,41 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishMode(t *testing.T) {
+	ci := false
+	old := isCI
+	isCI = func() bool { return ci }
+	defer func() { isCI = old }()
+
+	assert.True(t, PublishModeDefault.Publish(true))
+	assert.False(t, PublishModeDefault.Publish(false))
+	assert.True(t, PublishModeAlways.Publish(false))
+	assert.False(t, PublishModeNever.Publish(true))
+
+	assert.False(t, PublishModeCI.Publish(true))
+	ci = true
+	assert.True(t, PublishModeCI.Publish(false))
+	assert.False(t, PublishModeDefault.Publish(false), "expected CI detection to be opt-in")
+}
+
+func TestVerifyRequestValidate_PublishMode(t *testing.T) {
+	old := isCI
+	isCI = func() bool { return false }
+	defer func() { isCI = old }()
+
+	request := VerifyRequest{
+		PactURLs:                   []string{"http://localhost:1234/path/to/pact"},
+		ProviderBaseURL:            "http://localhost:8080",
+		PublishVerificationResults: true,
+		PublishMode:                PublishModeCI,
+	}
+
+	err := request.Validate()
+	assert.NoError(t, err)
+	assert.NotContains(t, request.Args, "--publish_verification_results")
+}
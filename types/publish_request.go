@@ -1,8 +1,10 @@
 package types
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net/http"
 )
 
 // PublishRequest contains the details required to Publish Pacts to a broker.
@@ -22,6 +24,34 @@ type PublishRequest struct {
 	// BrokerToken is required when authenticating using the Bearer token mechanism
 	BrokerToken string
 
+	// BrokerTokenSource supplies bearer tokens for authenticating to a Pact Broker,
+	// and takes precedence over BrokerToken. Tokens are fetched for every broker
+	// request so they may be refreshed mid-run.
+	BrokerTokenSource TokenSource
+
+	// BrokerTLSConfig is the TLS configuration used when communicating with
+	// the Pact Broker, e.g. to present a client certificate to brokers
+	// deployed behind mutual TLS.
+	// Only supported when publishing with broker.Publisher.
+	BrokerTLSConfig *tls.Config
+
+	// BrokerHTTPClient is used for all communication with the Pact Broker,
+	// allowing custom proxies, tracing or CA pools to be configured.
+	// When set, BrokerTLSConfig is ignored.
+	// Only supported when publishing with broker.Publisher.
+	BrokerHTTPClient *http.Client
+
+	// BrokerProxyURL is an HTTP(S) proxy to use for requests to the broker.
+	// The HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables are
+	// honoured when it isn't set.
+	// Only supported when publishing with broker.Publisher.
+	BrokerProxyURL string
+
+	// BrokerRetry configures retries of broker requests that fail with a
+	// connection error or a retryable status code, such as 502 or 429.
+	// Only supported when publishing with broker.Publisher.
+	BrokerRetry RetryPolicy
+
 	// ConsumerVersion is the semantical version of the consumer API.
 	ConsumerVersion string
 
@@ -29,6 +59,16 @@ type PublishRequest struct {
 	// e.g. "production", "master" and "development" are some common examples.
 	Tags []string
 
+	// Branch is the repository branch of the consumer version. Optional.
+	// Defaults to the branch detected from git or the CI environment.
+	Branch string
+
+	// DisableBranchDetection turns off detection of the Branch
+	DisableBranchDetection bool
+
+	// BuildURL is the URL of the CI build that produced the consumer version. Optional.
+	BuildURL string
+
 	// Verbose increases verbosity of output
 	// Deprecated
 	Verbose bool
@@ -67,7 +107,13 @@ func (p *PublishRequest) Validate() error {
 	}
 	p.Args = append(p.Args, "--broker-base-url", p.PactBroker)
 
-	if p.BrokerToken != "" {
+	if p.BrokerTokenSource != nil {
+		token, err := p.BrokerTokenSource.Token()
+		if err != nil {
+			return fmt.Errorf("unable to obtain broker token: %v", err)
+		}
+		p.Args = append(p.Args, "--broker-token", token)
+	} else if p.BrokerToken != "" {
 		p.Args = append(p.Args, "--broker-token", p.BrokerToken)
 	}
 
@@ -82,6 +128,14 @@ func (p *PublishRequest) Validate() error {
 		}
 	}
 
+	if p.Branch != "" {
+		p.Args = append(p.Args, "--branch", p.Branch)
+	}
+
+	if p.BuildURL != "" {
+		p.Args = append(p.Args, "--build-url", p.BuildURL)
+	}
+
 	if p.Verbose {
 		p.Args = append(p.Args, "--verbose")
 	}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -115,3 +116,22 @@ func TestPublishRequest_Validate(t *testing.T) {
 		t.Fatalf("Error: %v", err)
 	}
 }
+
+func TestPublishRequest_ValidateBranchAndBuildURL(t *testing.T) {
+	p := PublishRequest{
+		PactBroker:      "http://foo.com",
+		PactURLs:        []string{"pacts/billy-bobby.json"},
+		ConsumerVersion: "1.0.0",
+		Branch:          "main",
+		BuildURL:        "https://ci/build/1",
+	}
+
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	args := strings.Join(p.Args, " ")
+	if !strings.Contains(args, "--branch main --build-url https://ci/build/1") {
+		t.Fatalf("Expected branch and build URL arguments but got '%s'", args)
+	}
+}
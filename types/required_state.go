@@ -0,0 +1,14 @@
+package types
+
+// RequiredState is a provider state that interactions in the pacts of a
+// provider expect to be set up
+type RequiredState struct {
+	// Name of the state, e.g. "user 1 exists"
+	Name string
+
+	// Params of the state, if any
+	Params map[string]interface{}
+
+	// Consumers whose pacts require the state
+	Consumers []string
+}
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"net/http"
+	"time"
+)
+
+// DefaultRetryableStatusCodes are retried when a RetryPolicy doesn't list its own
+var DefaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy configures retries of failed requests to the Pact Broker.
+// Connection errors and responses with a retryable status code are retried
+// with an exponential backoff.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a failed request is retried.
+	// Retries are disabled when zero.
+	MaxRetries int
+
+	// Backoff is the delay before the first retry, doubling on each
+	// subsequent attempt. Defaults to 1 second.
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between attempts. Defaults to 30 seconds.
+	MaxBackoff time.Duration
+
+	// RetryableStatusCodes are the response codes to retry.
+	// Defaults to DefaultRetryableStatusCodes.
+	RetryableStatusCodes []int
+}
+
+// Enabled returns true if failed requests should be retried
+func (r RetryPolicy) Enabled() bool {
+	return r.MaxRetries > 0
+}
+
+// Retryable returns true if a response with the given status code should be retried
+func (r RetryPolicy) Retryable(statusCode int) bool {
+	codes := r.RetryableStatusCodes
+	if len(codes) == 0 {
+		codes = DefaultRetryableStatusCodes
+	}
+
+	for _, c := range codes {
+		if c == statusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delay returns the time to wait before the given retry attempt, starting at 1
+func (r RetryPolicy) Delay(attempt int) time.Duration {
+	delay := r.Backoff
+	if delay <= 0 {
+		delay = time.Second
+	}
+
+	max := r.MaxBackoff
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		return max
+	}
+
+	return delay
+}
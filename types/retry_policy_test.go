@@ -0,0 +1,29 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	assert.True(t, RetryPolicy{}.Retryable(502))
+	assert.True(t, RetryPolicy{}.Retryable(429))
+	assert.False(t, RetryPolicy{}.Retryable(500))
+	assert.True(t, RetryPolicy{RetryableStatusCodes: []int{500}}.Retryable(500))
+	assert.False(t, RetryPolicy{RetryableStatusCodes: []int{500}}.Retryable(502))
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+
+	assert.Equal(t, 100*time.Millisecond, policy.Delay(1))
+	assert.Equal(t, 200*time.Millisecond, policy.Delay(2))
+	assert.Equal(t, 300*time.Millisecond, policy.Delay(3))
+	assert.Equal(t, 300*time.Millisecond, policy.Delay(10))
+
+	assert.Equal(t, time.Second, RetryPolicy{}.Delay(1))
+	assert.False(t, RetryPolicy{}.Enabled())
+	assert.True(t, RetryPolicy{MaxRetries: 1}.Enabled())
+}
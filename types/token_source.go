@@ -0,0 +1,29 @@
+package types
+
+// TokenSource supplies bearer tokens used to authenticate to a Pact Broker.
+// Token is called before each request to the broker, allowing tokens issued
+// by an identity provider to be refreshed during a long verification run.
+//
+// An oauth2.TokenSource (golang.org/x/oauth2) can be adapted with:
+//
+//	types.TokenSourceFunc(func() (string, error) {
+//		t, err := ts.Token()
+//		if err != nil {
+//			return "", err
+//		}
+//		return t.AccessToken, nil
+//	})
+//
+// Wrap the source with oauth2.ReuseTokenSource to avoid fetching a new
+// token on every call.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// TokenSourceFunc adapts an ordinary function to a TokenSource
+type TokenSourceFunc func() (string, error)
+
+// Token calls f()
+func (f TokenSourceFunc) Token() (string, error) {
+	return f()
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"time"
 
 	"github.com/pact-foundation/pact-go/proxy"
@@ -17,7 +18,8 @@ type Hook func() error
 // VerifyRequest configures the pact verification process.
 //
 type VerifyRequest struct {
-	// URL to hit during provider verification.
+	// URL to hit during provider verification. Providers listening on a unix
+	// domain socket may be given as unix:///path/to/app.sock
 	ProviderBaseURL string
 
 	// Local/HTTP paths to Pact files.
@@ -41,6 +43,14 @@ type VerifyRequest struct {
 	// Tags to apply to the provider application version
 	ProviderTags []string
 
+	// ProviderBranch is the branch of the provider application version,
+	// recorded with the verification results. Defaults to the branch
+	// detected from git or the CI environment.
+	ProviderBranch string
+
+	// DisableBranchDetection turns off detection of the ProviderBranch
+	DisableBranchDetection bool
+
 	// ProviderStatesSetupURL is the endpoint to post current provider state
 	// to on the Provider API.
 	// Deprecated: For backward compatibility ProviderStatesSetupURL is
@@ -59,16 +69,69 @@ type VerifyRequest struct {
 	// BrokerToken is required when authenticating using the Bearer token mechanism
 	BrokerToken string
 
+	// BrokerTokenSource supplies bearer tokens for authenticating to a Pact Broker,
+	// and takes precedence over BrokerToken. Tokens are fetched for every broker
+	// request so they may be refreshed mid-run.
+	BrokerTokenSource TokenSource
+
+	// BrokerTLSConfig is the TLS configuration used when communicating with
+	// the Pact Broker, e.g. to present a client certificate to brokers
+	// deployed behind mutual TLS.
+	BrokerTLSConfig *tls.Config
+
+	// BrokerHTTPClient is used for all communication with the Pact Broker,
+	// allowing custom proxies, tracing or CA pools to be configured.
+	// When set, BrokerTLSConfig is ignored.
+	BrokerHTTPClient *http.Client
+
+	// BrokerProxyURL is an HTTP(S) proxy to use for requests to the broker
+	// and to remote PactURLs. The HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+	// environment variables are honoured when it isn't set.
+	BrokerProxyURL string
+
+	// BrokerRetry configures retries of broker requests that fail with a
+	// connection error or a retryable status code, such as 502 or 429.
+	BrokerRetry RetryPolicy
+
+	// BrokerCacheDir is a directory used to cache pacts fetched from the broker,
+	// keyed by URL and revalidated with their ETag on subsequent runs
+	BrokerCacheDir string
+
+	// BrokerOffline verifies against the pacts in BrokerCacheDir when the
+	// broker can't be reached
+	BrokerOffline bool
+
 	// FailIfNoPactsFound configures the framework to return an error
 	// if no pacts were found when looking up from a broker
 	FailIfNoPactsFound bool
 
+	// SpecificationVersion is the latest version of the Pact Specification,
+	// 1 to 4, the pacts verified may be written in. Pacts written in a later
+	// version, or using matchers or generators the version they're written
+	// in doesn't support, are rejected before they're verified. Defaults to 4.
+	SpecificationVersion int
+
 	// PublishVerificationResults to the Pact Broker.
 	PublishVerificationResults bool
 
+	// PublishMode controls when verification results are published, e.g.
+	// PublishModeCI to only publish from CI servers. Defaults to
+	// PublishVerificationResults; detecting CI servers is deliberately opt-in,
+	// so that leaving both unset never publishes.
+	PublishMode PublishMode
+
 	// ProviderVersion is the semantical version of the Provider API.
+	// When empty, the SHA of the current git commit is used, suffixed with
+	// "-dirty" if there are uncommitted changes.
 	ProviderVersion string
 
+	// DisableGitProviderVersion turns off detection of the ProviderVersion from git
+	DisableGitProviderVersion bool
+
+	// BuildURL is the URL of the CI build that produced the verification,
+	// published with the verification results
+	BuildURL string
+
 	// CustomProviderHeaders are headers to add during pact verification `requests`.
 	// eg 'Authorization: Basic cGFjdDpwYWN0'.
 	//
@@ -84,6 +147,41 @@ type VerifyRequest struct {
 	// verification step.
 	StateHandlers StateHandlers
 
+	// FailOnMissingStateHandler fails the setup of any interaction with a
+	// provider state that has no state handler, rather than logging a warning
+	// and verifying the interaction against unprepared state.
+	FailOnMissingStateHandler bool
+
+	// ProviderStateHeader is a request header used to tell the provider which
+	// states each interaction expects, for providers that set up state when
+	// handling a request rather than with StateHandlers. The header is added to
+	// every replayed request, with a value for each state of the interaction.
+	ProviderStateHeader string
+
+	// ContextStateHandlers set up provider states like StateHandlers, given a
+	// context that is cancelled once the StateHandlerTimeout passes
+	ContextStateHandlers ContextStateHandlers
+
+	// StateHandlerTimeout is the maximum time given to set up the provider
+	// states of each interaction. The interaction fails with a timeout error
+	// if the state handlers haven't returned in time. Disabled when zero.
+	StateHandlerTimeout time.Duration
+
+	// PatternStateHandlers set up provider states matching a regular expression,
+	// for states without an entry in StateHandlers. Groups captured from the
+	// state are passed to the handler. A state may only match one pattern.
+	PatternStateHandlers PatternStateHandlers
+
+	// DefaultStateHandler sets up any provider state without an entry in
+	// StateHandlers, receiving the name and params of the state. It takes
+	// precedence over FailOnMissingStateHandler.
+	DefaultStateHandler DefaultStateHandler
+
+	// ContextDefaultStateHandler sets up provider states like DefaultStateHandler,
+	// given a context that is cancelled once the StateHandlerTimeout passes. It
+	// takes precedence over DefaultStateHandler.
+	ContextDefaultStateHandler ContextDefaultStateHandler
+
 	// BeforeEach allows you to configure your provider prior to the individual test execution
 	// e.g. setup temporary tokens, prepare data
 	BeforeEach Hook
@@ -92,6 +190,25 @@ type VerifyRequest struct {
 	// e.g. reset the database state
 	AfterEach Hook
 
+	// BeforeSuite is run once, before the first interaction is verified
+	// e.g. start a database container, seed reference data.
+	// An error aborts the verification.
+	BeforeSuite Hook
+
+	// AfterSuite is run once, after the last interaction is verified, even
+	// when verification fails. e.g. stop a database container
+	AfterSuite Hook
+
+	// BeforeInteraction is run before the provider states of each interaction
+	// are set up, with the consumer and states of the interaction. Where the
+	// verifier sets up each state with a separate request, it is run once,
+	// with the first state. Later hooks are given the full list of states.
+	BeforeInteraction InteractionHook
+
+	// AfterInteraction is run once the provider has responded to each
+	// interaction, with the request and the status and time of the response
+	AfterInteraction InteractionHook
+
 	// RequestFilter is a piece of middleware that will intercept requests/responses
 	// from the provider in order to modify it. This is useful in situations where
 	// you need to override a value due to time sensitivity - such as a OAuth Bearer
@@ -100,10 +217,92 @@ type VerifyRequest struct {
 	// runs the risk of changing the contract and breaking the real system.
 	RequestFilter proxy.Middleware
 
+	// Middleware to insert into the verification proxy chain, ordered by
+	// priority relative to the built-in BeforeEach, AfterEach, state handler,
+	// RequestFilter and exchange log middleware (see PriorityBeforeEach etc.)
+	Middleware []ProxyMiddleware
+
+	// InternalHandler serves extra endpoints on the verification proxy under
+	// /__setup/, such as health checks or debug dumps of the current state,
+	// e.g. an http.ServeMux with a handler for /__setup/health
+	InternalHandler http.Handler
+
+	// ResponseFilter can modify each response from the provider before the
+	// verifier compares it to the pact, e.g. to remove volatile headers or
+	// normalise timestamps. The same caution as for RequestFilter applies.
+	ResponseFilter proxy.ResponseFilter
+
+	// Observer is notified of every request to, and response from, the
+	// provider, e.g. to log or assert on traffic during verification
+	Observer proxy.Observer
+
+	// DecompressBodies transparently decompresses gzip and deflate encoded
+	// bodies before they are given to the RequestFilter, compressing them
+	// again afterwards.
+	DecompressBodies bool
+
+	// ProxyPort is the port the verification proxy in front of the provider
+	// listens on. Defaults to a random free port.
+	ProxyPort int
+
+	// ProxyPortRange is an inclusive range of ports the verification proxy may
+	// use, for environments where only certain ports are open.
+	// e.g. [2]int{8000, 8010}
+	ProxyPortRange [2]int
+
+	// ProxyBindAddress is the address of the interface the verification proxy
+	// listens on, e.g. 127.0.0.1 on shared CI hosts, or the address of a
+	// specific interface in a sidecar container. Defaults to all interfaces.
+	ProxyBindAddress string
+
+	// ProxyHost is the host the verifier uses to connect to the verification
+	// proxy, e.g. ::1 in IPv6-only environments. Defaults to the
+	// ProxyBindAddress when bound to a specific interface, otherwise localhost.
+	ProxyHost string
+
+	// ProxyTLS serves the verification proxy over https, for pacts with
+	// scheme sensitive behaviour. The verifier is configured to trust the
+	// proxy's certificate, which is self-signed unless ProxyTLSCertificate is set.
+	ProxyTLS bool
+
+	// ProxyTLSCertificate is the certificate served when ProxyTLS is set
+	ProxyTLSCertificate *tls.Certificate
+
+	// ProxyReadTimeout, ProxyWriteTimeout and ProxyIdleTimeout limit the time
+	// the verification proxy spends reading a request, writing a response and
+	// waiting for the next request on a connection. Default to no timeout.
+	ProxyReadTimeout  time.Duration
+	ProxyWriteTimeout time.Duration
+	ProxyIdleTimeout  time.Duration
+
+	// ProviderResponseHeaderTimeout is the maximum time the verification proxy
+	// waits for the Provider API to send response headers. Failed requests are
+	// reported as a 504 with the cause. Defaults to no timeout.
+	ProviderResponseHeaderTimeout time.Duration
+
 	// Custom TLS Configuration to use when making the requests to/from
 	// the Provider API. Useful for setting custom certificates, MASSL etc.
 	CustomTLSConfig *tls.Config
 
+	// ProviderTransport is used by the verification proxy to send requests to
+	// the Provider API, e.g. to tune connection pooling and dial timeouts or to
+	// add instrumentation. CustomTLSConfig is not applied to a custom transport.
+	ProviderTransport http.RoundTripper
+
+	// ProviderHTTP2 enables HTTP/2 over cleartext (h2c) between the verification
+	// proxy and an http Provider API that only speaks HTTP/2.
+	ProviderHTTP2 bool
+
+	// PreserveHostHeader sends the Host header of each replayed request to the
+	// Provider API, rather than the host of the ProviderBaseURL. Useful for
+	// providers that route requests by virtual host.
+	PreserveHostHeader bool
+
+	// ForwardedHeaders adds X-Forwarded-Proto and X-Forwarded-Host headers to
+	// the requests sent to the Provider API, for providers with scheme-aware
+	// behaviour such as redirecting to https. e.g. &proxy.ForwardedHeaders{Proto: "https"}
+	ForwardedHeaders *proxy.ForwardedHeaders
+
 	// Allow pending pacts to be included in verification (see pact.io/pending)
 	EnablePending bool
 
@@ -115,6 +314,19 @@ type VerifyRequest struct {
 	// and API
 	PactLogDir string
 
+	// ExchangeLogDir is a directory to write the HTTP request sent to, and
+	// response received from, the provider for every replayed interaction.
+	// Each run is written to a new subdirectory, and the response is logged
+	// both before and after any ResponseFilter. Credential headers (and
+	// CustomProviderHeaders) are redacted, making the output suitable to keep
+	// as a CI build artifact.
+	ExchangeLogDir string
+
+	// SLOThreshold is the latency budget for each interaction. If the provider
+	// takes longer than this to respond the interaction is flagged in the
+	// results and reported as a test failure. Disabled when zero.
+	SLOThreshold time.Duration
+
 	// Specify the log verbosity of the CLI verifier process spawned through verification
 	// Useful for debugging issues with the framework itself
 	PactLogLevel string
@@ -126,6 +338,11 @@ type VerifyRequest struct {
 	// Arguments to the VerificationProvider
 	// Deprecated: This will be deleted after the native library replaces Ruby deps.
 	Args []string
+
+	// Environment variables for the VerificationProvider, in addition to the
+	// current environment
+	// Deprecated: This will be deleted after the native library replaces Ruby deps.
+	Env []string
 }
 
 // Validate checks that the minimum fields are provided.
@@ -148,6 +365,9 @@ func (v *VerifyRequest) Validate() error {
 			if err = selector.Validate(); err != nil {
 				return fmt.Errorf("invalid consumer version selector specified: %v", err)
 			}
+			if selector.MatchingBranch && v.ProviderBranch == "" {
+				return errors.New("'ProviderBranch' must be supplied to select pacts with a matching branch")
+			}
 			body, err := json.Marshal(selector)
 			if err != nil {
 				return fmt.Errorf("invalid consumer version selector specified: %v", err)
@@ -191,7 +411,13 @@ func (v *VerifyRequest) Validate() error {
 		v.Args = append(v.Args, "--pact-broker-base-url", v.BrokerURL)
 	}
 
-	if v.BrokerToken != "" {
+	if v.BrokerTokenSource != nil {
+		token, err := v.BrokerTokenSource.Token()
+		if err != nil {
+			return fmt.Errorf("unable to obtain broker token: %v", err)
+		}
+		v.Args = append(v.Args, "--broker-token", token)
+	} else if v.BrokerToken != "" {
 		v.Args = append(v.Args, "--broker-token", v.BrokerToken)
 	}
 
@@ -207,7 +433,7 @@ func (v *VerifyRequest) Validate() error {
 		v.Args = append(v.Args, "--provider", v.Provider)
 	}
 
-	if v.PublishVerificationResults {
+	if v.PublishMode.Publish(v.PublishVerificationResults) {
 		v.Args = append(v.Args, "--publish_verification_results", "true")
 	}
 
@@ -223,6 +449,14 @@ func (v *VerifyRequest) Validate() error {
 		v.Args = append(v.Args, "--provider-version-tag", tag)
 	}
 
+	if v.ProviderBranch != "" {
+		v.Args = append(v.Args, "--provider-version-branch", v.ProviderBranch)
+	}
+
+	if v.BuildURL != "" {
+		v.Args = append(v.Args, "--build-url", v.BuildURL)
+	}
+
 	if v.EnablePending {
 		v.Args = append(v.Args, "--enable-pending")
 	}
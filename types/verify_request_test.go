@@ -1,6 +1,7 @@
 package types
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -106,3 +107,44 @@ func TestVerifyRequestValidate(t *testing.T) {
 		}
 	})
 }
+
+func TestVerifyRequestValidate_ProviderBranch(t *testing.T) {
+	request := VerifyRequest{
+		PactURLs:        []string{"http://localhost:1234/path/to/pact"},
+		ProviderBaseURL: "http://localhost:8080",
+		ProviderTags:    []string{"dev"},
+		ProviderBranch:  "feat/foo",
+	}
+
+	err := request.Validate()
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Join(request.Args, " "), "--provider-version-tag dev --provider-version-branch feat/foo")
+}
+
+func TestVerifyRequestValidate_BrokerTokenSource(t *testing.T) {
+	request := VerifyRequest{
+		PactURLs:        []string{"http://localhost:1234/path/to/pact"},
+		ProviderBaseURL: "http://localhost:8080",
+		BrokerToken:     "static",
+		BrokerTokenSource: TokenSourceFunc(func() (string, error) {
+			return "refreshed", nil
+		}),
+	}
+
+	err := request.Validate()
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Join(request.Args, " "), "--broker-token refreshed")
+	assert.NotContains(t, strings.Join(request.Args, " "), "static")
+}
+
+func TestVerifyRequestValidate_BuildURL(t *testing.T) {
+	request := VerifyRequest{
+		PactURLs:        []string{"http://localhost:1234/path/to/pact"},
+		ProviderBaseURL: "http://localhost:8080",
+		BuildURL:        "http://ci/builds/1",
+	}
+
+	err := request.Validate()
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Join(request.Args, " "), "--build-url http://ci/builds/1")
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+// ciVariables are environment variables that indicate a build is running
+// on a CI server
+var ciVariables = []string{
+	"CI",
+	"BUILD_ID",
+	"BUILD_NUMBER",
+	"JENKINS_URL",
+	"GITHUB_ACTIONS",
+	"GITLAB_CI",
+	"CIRCLECI",
+	"TRAVIS",
+	"BUILDKITE",
+	"TEAMCITY_VERSION",
+	"TF_BUILD",
+	"BITBUCKET_BUILD_NUMBER",
+	"CODEBUILD_BUILD_ID",
+}
+
+// IsCI returns true if the process appears to be running on a CI server
+func IsCI() bool {
+	for _, name := range ciVariables {
+		v := strings.ToLower(strings.TrimSpace(os.Getenv(name)))
+		if v != "" && v != "false" && v != "0" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"os"
+	"testing"
+)
+
+func Test_IsCI(t *testing.T) {
+	saved := make(map[string]string)
+	for _, name := range ciVariables {
+		if v, ok := os.LookupEnv(name); ok {
+			saved[name] = v
+		}
+		os.Unsetenv(name)
+	}
+	defer func() {
+		for _, name := range ciVariables {
+			os.Unsetenv(name)
+		}
+		for name, v := range saved {
+			os.Setenv(name, v)
+		}
+	}()
+
+	if IsCI() {
+		t.Fatalf("Expected IsCI to be false without CI variables")
+	}
+
+	os.Setenv("CI", "false")
+	if IsCI() {
+		t.Fatalf("Expected IsCI to be false when CI=false")
+	}
+
+	os.Setenv("BUILD_ID", "42")
+	if !IsCI() {
+		t.Fatalf("Expected IsCI to be true when BUILD_ID is set")
+	}
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// git runs a git command in dir, returning its trimmed output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %v %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GitCommit returns the SHA of the commit checked out in the git
+// repository containing dir. An empty dir uses the working directory.
+func GitCommit(dir string) (string, error) {
+	return git(dir, "rev-parse", "HEAD")
+}
+
+// GitDirty returns true if the working tree of the git repository
+// containing dir has uncommitted changes
+func GitDirty(dir string) (bool, error) {
+	out, err := git(dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return false, err
+	}
+
+	return out != "", nil
+}
+
+// GitVersion returns a version identifier for the git repository containing
+// dir: the current commit SHA, suffixed with "-dirty" if there are
+// uncommitted changes
+func GitVersion(dir string) (string, error) {
+	sha, err := GitCommit(dir)
+	if err != nil {
+		return "", err
+	}
+
+	dirty, err := GitDirty(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if dirty {
+		return sha + "-dirty", nil
+	}
+
+	return sha, nil
+}
+
+// ciBranchVariables are environment variables set by common CI systems to the
+// branch being built, in order of precedence. CI checkouts are frequently a
+// detached HEAD, where the branch can't be determined from git.
+var ciBranchVariables = []string{
+	"GITHUB_HEAD_REF",
+	"GITHUB_REF_NAME",
+	"CI_COMMIT_BRANCH",
+	"CI_COMMIT_REF_NAME",
+	"BRANCH_NAME",
+	"GIT_BRANCH",
+	"BUILDKITE_BRANCH",
+	"CIRCLE_BRANCH",
+	"TRAVIS_PULL_REQUEST_BRANCH",
+	"TRAVIS_BRANCH",
+	"BITBUCKET_BRANCH",
+}
+
+// GitBranch returns the branch checked out in the git repository containing dir
+func GitBranch(dir string) (string, error) {
+	branch, err := git(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	if branch == "HEAD" {
+		return "", errors.New("git HEAD is detached")
+	}
+
+	return branch, nil
+}
+
+// DetectBranch returns the branch checked out in the git repository containing
+// dir, falling back to the branch reported by the CI environment when git is
+// unavailable or HEAD is detached
+func DetectBranch(dir string) (string, error) {
+	branch, err := GitBranch(dir)
+	if err == nil {
+		return branch, nil
+	}
+
+	for _, name := range ciBranchVariables {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			return strings.TrimPrefix(v, "origin/"), nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to detect branch: %v", err)
+}
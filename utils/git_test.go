@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// setupGitRepo creates a temporary git repository with a single commit
+func setupGitRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "pact-git")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "README"), []byte("pact"), 0644); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "README"},
+		{"-c", "user.name=pact", "-c", "user.email=pact@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		if _, err := git(dir, args...); err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+	}
+
+	return dir
+}
+
+func Test_GitVersion(t *testing.T) {
+	dir := setupGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	sha, err := GitCommit(dir)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(sha) != 40 {
+		t.Fatalf("Expected a 40 character SHA, got %q", sha)
+	}
+
+	version, err := GitVersion(dir)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if version != sha {
+		t.Fatalf("Expected version %q, got %q", sha, version)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "README"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	version, err = GitVersion(dir)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if version != sha+"-dirty" {
+		t.Fatalf("Expected version %q, got %q", sha+"-dirty", version)
+	}
+}
+
+func Test_GitVersionNotARepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pact-git")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := GitVersion(dir); err == nil {
+		t.Fatalf("Expected an error outside of a git repository")
+	}
+}
+
+func Test_GitBranch(t *testing.T) {
+	dir := setupGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	if _, err := git(dir, "checkout", "-q", "-b", "feat/foo"); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	branch, err := DetectBranch(dir)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if branch != "feat/foo" {
+		t.Fatalf("Expected branch %q, got %q", "feat/foo", branch)
+	}
+
+	if _, err := git(dir, "checkout", "-q", "--detach"); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	if _, err := GitBranch(dir); err == nil {
+		t.Fatalf("Expected an error for a detached HEAD")
+	}
+}
+
+func Test_DetectBranchFromCI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pact-git")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer clearCIBranchVariables()()
+
+	if _, err := DetectBranch(dir); err == nil {
+		t.Fatalf("Expected an error without git or CI variables")
+	}
+
+	os.Setenv("GIT_BRANCH", "origin/main")
+	branch, err := DetectBranch(dir)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if branch != "main" {
+		t.Fatalf("Expected branch %q, got %q", "main", branch)
+	}
+
+	os.Setenv("GITHUB_HEAD_REF", "feat/bar")
+	branch, _ = DetectBranch(dir)
+	if branch != "feat/bar" {
+		t.Fatalf("Expected branch %q, got %q", "feat/bar", branch)
+	}
+}
+
+// clearCIBranchVariables unsets the CI branch variables, returning
+// a function to restore them
+func clearCIBranchVariables() func() {
+	saved := make(map[string]string)
+	for _, name := range ciBranchVariables {
+		if v, ok := os.LookupEnv(name); ok {
+			saved[name] = v
+		}
+		os.Unsetenv(name)
+	}
+
+	return func() {
+		for _, name := range ciBranchVariables {
+			os.Unsetenv(name)
+		}
+		for name, v := range saved {
+			os.Setenv(name, v)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // GetFreePort Gets an available port by asking the kernal for a random port
@@ -29,6 +30,10 @@ func GetFreePort() (int, error) {
 // Valid inputs are "8081", "8081,8085", "8081-8085". Do not combine
 // list and range
 func FindPortInRange(s string) (int, error) {
+	return findPortInRange(s, func(int) bool { return false })
+}
+
+func findPortInRange(s string, skip func(int) bool) (int, error) {
 	// Take care of csv and single value
 	if !strings.Contains(s, "-") {
 		ports := strings.Split(strings.TrimSpace(s), ",")
@@ -37,6 +42,9 @@ func FindPortInRange(s string) (int, error) {
 			if err != nil {
 				return 0, err
 			}
+			if skip(i) {
+				continue
+			}
 			err = checkPort(i)
 			if err != nil {
 				continue
@@ -62,6 +70,9 @@ func FindPortInRange(s string) (int, error) {
 		return 0, errors.New("invalid range passed")
 	}
 	for i := lower; i <= upper; i++ {
+		if skip(i) {
+			continue
+		}
 		err = checkPort(i)
 		if err != nil {
 			continue
@@ -71,6 +82,53 @@ func FindPortInRange(s string) (int, error) {
 	return 0, errors.New("all passed ports are unusable")
 }
 
+// Ports handed out by ReservePort, and not yet released
+var reserved = struct {
+	sync.Mutex
+	ports map[int]bool
+}{ports: map[int]bool{}}
+
+// ReservePort finds an available port, like GetFreePort, or like
+// FindPortInRange when given a list or range of ports, which hasn't been
+// reserved already. Servers started concurrently in the same process, e.g. by
+// parallel tests, therefore never race for the same port. Call ReleasePort
+// once the server using the port has stopped.
+func ReservePort(s string) (int, error) {
+	reserved.Lock()
+	defer reserved.Unlock()
+
+	isReserved := func(p int) bool { return reserved.ports[p] }
+
+	var port int
+	var err error
+	if s != "" {
+		port, err = findPortInRange(s, isReserved)
+	} else {
+		for attempt := 0; attempt < 10; attempt++ {
+			if port, err = GetFreePort(); err != nil || !isReserved(port) {
+				break
+			}
+		}
+		if err == nil && isReserved(port) {
+			err = errors.New("unable to find a port that isn't reserved")
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	reserved.ports[port] = true
+	return port, nil
+}
+
+// ReleasePort makes a port reserved by ReservePort available again
+func ReleasePort(port int) {
+	reserved.Lock()
+	defer reserved.Unlock()
+
+	delete(reserved.ports, port)
+}
+
 func checkPort(p int) error {
 	s := fmt.Sprintf("localhost:%d", p)
 	addr, err := net.ResolveTCPAddr("tcp", s)
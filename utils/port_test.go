@@ -144,3 +144,51 @@ func Test_checkPort(t *testing.T) {
 		t.Fatalf("Expected error got none")
 	}
 }
+
+func Test_ReservePort(t *testing.T) {
+	first, err := ReservePort("6670-6671")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	defer ReleasePort(first)
+
+	second, err := ReservePort("6670-6671")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+
+	if first != 6670 || second != 6671 {
+		t.Fatalf("Expected ports 6670 and 6671 to be reserved, got %d and %d", first, second)
+	}
+
+	if _, err := ReservePort("6670,6671"); err == nil {
+		t.Fatalf("Expected an error when all ports are reserved")
+	}
+
+	ReleasePort(second)
+	port, err := ReservePort("6670-6671")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	defer ReleasePort(port)
+
+	if port != 6671 {
+		t.Fatalf("Expected released port 6671 to be reserved again, got %d", port)
+	}
+}
+
+func Test_ReservePortFree(t *testing.T) {
+	ports := map[int]bool{}
+	for i := 0; i < 5; i++ {
+		port, err := ReservePort("")
+		if err != nil {
+			t.Fatalf("Error: %v", err)
+		}
+		defer ReleasePort(port)
+
+		if ports[port] {
+			t.Fatalf("Port %d was reserved twice", port)
+		}
+		ports[port] = true
+	}
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// RedactedValue replaces secrets in redacted output
+const RedactedValue = "[REDACTED]"
+
+// SensitiveHeaders are HTTP headers whose values are credentials,
+// and should never be written to logs
+var SensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// sensitiveHeaderLine matches a sensitive header in a dumped HTTP request or response
+var sensitiveHeaderLine = regexp.MustCompile(`(?im)^(\s*(?:` + strings.Join(SensitiveHeaders, "|") + `)\s*:\s*)[^\r\n]*`)
+
+// Redactor is an io.Writer that masks secrets, and the values of sensitive
+// headers, before passing output on to Writer. It is intended to wrap the
+// output of the standard logger.
+type Redactor struct {
+	// Writer receives the redacted output
+	Writer io.Writer
+
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// AddSecret registers values that must be masked. Empty values are ignored.
+func (r *Redactor) AddSecret(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range secrets {
+		if s == "" {
+			continue
+		}
+		found := false
+		for _, existing := range r.secrets {
+			if existing == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+}
+
+// Redact returns a copy of p with any secrets masked
+func (r *Redactor) Redact(p []byte) []byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := sensitiveHeaderLine.ReplaceAll(p, []byte("${1}"+RedactedValue))
+	for _, s := range r.secrets {
+		out = bytes.Replace(out, []byte(s), []byte(RedactedValue), -1)
+	}
+
+	return out
+}
+
+// SetWriter replaces the Writer receiving the redacted output. It is safe to
+// call while other goroutines are logging.
+func (r *Redactor) SetWriter(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Writer = w
+}
+
+// Write redacts p and writes it to the underlying Writer
+func (r *Redactor) Write(p []byte) (int, error) {
+	r.mu.RLock()
+	w := r.Writer
+	r.mu.RUnlock()
+
+	if _, err := w.Write(r.Redact(p)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_Redactor(t *testing.T) {
+	var buf bytes.Buffer
+	r := &Redactor{Writer: &buf}
+	r.AddSecret("s3cr3t", "", "tok3n")
+
+	logger := log.New(r, "", 0)
+	logger.Printf("[DEBUG] args: [--broker-password s3cr3t --broker-token tok3n]")
+	logger.Printf("[TRACE] proxy outgoing request\nGET / HTTP/1.1\r\nHost: localhost\r\nAuthorization: Bearer abc\r\nContent-Type: application/json\r\n")
+
+	out := buf.String()
+	for _, secret := range []string{"s3cr3t", "tok3n", "Bearer abc"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("Expected %q to be redacted, got %s", secret, out)
+		}
+	}
+
+	for _, want := range []string{"--broker-password [REDACTED]", "Authorization: [REDACTED]", "Content-Type: application/json"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("Expected %q in output, got %s", want, out)
+		}
+	}
+}
+
+func Test_RedactorSetWriter(t *testing.T) {
+	var first, second bytes.Buffer
+	r := &Redactor{Writer: &first}
+	r.AddSecret("s3cr3t")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			r.AddSecret("tok3n")
+		}()
+		go func() {
+			defer wg.Done()
+			r.SetWriter(&second)
+		}()
+	}
+	wg.Wait()
+
+	r.Write([]byte("s3cr3t tok3n"))
+
+	if got := second.String(); got != "[REDACTED] [REDACTED]" {
+		t.Fatalf("Expected redacted output in the new writer, got %q", got)
+	}
+	if first.Len() != 0 {
+		t.Fatalf("Expected no output in the old writer, got %q", first.String())
+	}
+}